@@ -2,11 +2,12 @@ package emp
 
 import (
 	"database/sql"
-	"encoding/json"
 
 	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/VinkoRobi2/FlashWorkEC/categorias"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,7 +15,7 @@ type UpdateEmpleadorInput struct {
 	Telefono               *string   `json:"telefono"`
 	Whatsapp               *string   `json:"whatsapp"`
 
-	PreferenciasCategorias *[]string `json:"preferencias_categorias"`
+	PreferenciasCategorias *[]int    `json:"preferencias_categorias"` // ids de categorias
 	LinkedIn               *string   `json:"linkedin"`
 	FacebookIG             *string   `json:"facebook_ig"`
 	OtrosLinks             *string   `json:"otros_links"`
@@ -1108,12 +1109,6 @@ func UpdateEmpleador(ctx *gin.Context, db *sql.DB) {
 
 
 	          
-	if input.PreferenciasCategorias != nil {
-		jsonCategorias, _ := json.Marshal(input.PreferenciasCategorias)
-		setParts = append(setParts, fmt.Sprintf("preferencias_categorias=$%d", argPos))
-		args = append(args, jsonCategorias)
-		argPos++
-	}
 	if input.LinkedIn != nil {
 		setParts = append(setParts, fmt.Sprintf("linkedin=$%d", argPos))
 		args = append(args, *input.LinkedIn)
@@ -1130,15 +1125,58 @@ func UpdateEmpleador(ctx *gin.Context, db *sql.DB) {
 		argPos++
 	}
 
-	if len(setParts) == 0 {
+	if len(setParts) == 0 && input.PreferenciasCategorias == nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "No se proporcionaron campos para actualizar"})
 		return
 	}
 
-	args = append(args, userID)
-	query := fmt.Sprintf("UPDATE empleadores SET %s WHERE id=$%d", strings.Join(setParts, ", "), argPos)
+	// Las preferencias apuntan a la tabla categorias
+	if input.PreferenciasCategorias != nil {
+		err := categorias.ValidarIDs(db, *input.PreferenciasCategorias)
+		if err == categorias.ErrNoEncontrada {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "preferencias_categorias contiene categorías inválidas"})
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar categorías"})
+			return
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar la información"})
+		return
+	}
+	defer tx.Rollback()
+
+	if len(setParts) > 0 {
+		args = append(args, userID)
+		query := fmt.Sprintf("UPDATE empleadores SET %s WHERE id=$%d", strings.Join(setParts, ", "), argPos)
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar la información"})
+			return
+		}
+	}
+
+	if input.PreferenciasCategorias != nil {
+		if _, err := tx.Exec(`DELETE FROM empleador_categorias WHERE empleador_id = $1`, userID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar preferencias"})
+			return
+		}
+		for _, catID := range *input.PreferenciasCategorias {
+			if _, err := tx.Exec(`
+				INSERT INTO empleador_categorias (empleador_id, categoria_id) VALUES ($1, $2)
+				ON CONFLICT DO NOTHING
+			`, userID, catID); err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar preferencias"})
+				return
+			}
+		}
+	}
 
-	if _, err := db.Exec(query, args...); err != nil {
+	if err := tx.Commit(); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar la información"})
 		return
 	}
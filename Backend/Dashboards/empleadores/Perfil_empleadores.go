@@ -42,7 +42,7 @@ type Perfil_Empleador struct {
 	Biografia               string   `json:"biografia"`
 	Links                   []string `json:"links"`
 	FechaNacimiento         string   `json:"fecha_nacimiento"`
-	PreferenciasCats        string   `json:"preferencias_categorias"`
+	PreferenciasCats        []int    `json:"preferencias_categorias"` // ids de categorias
 	FraseCorta              string   `json:"frase_corta"`
 	FotoPerfil              string   `json:"foto_perfil"`
 	Whatsapp                string   `json:"whatsapp"`
@@ -94,9 +94,9 @@ func Get_Empleador_Info(c *gin.Context, db *sql.DB) {
 		&perfil.Email,
 		&perfil.Telefono,
 		&perfil.Ciudad,
+		&sectorStr,
 		&perfil.Biografia,
 		&linksStr,
-		&perfil.PreferenciasCats,
 		&perfil.FotoPerfil,
 		&perfil.Whatsapp,
 		&perfil.Linkedin,
@@ -128,6 +128,23 @@ func Get_Empleador_Info(c *gin.Context, db *sql.DB) {
 		perfil.Links = []string{}
 	}
 
+	// Categorías preferidas
+	perfil.PreferenciasCats = []int{}
+	catRows, err := db.Query(`
+		SELECT categoria_id FROM empleador_categorias WHERE empleador_id = $1 ORDER BY categoria_id
+	`, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"err": err.Error()})
+		return
+	}
+	for catRows.Next() {
+		var catID int
+		if err := catRows.Scan(&catID); err == nil {
+			perfil.PreferenciasCats = append(perfil.PreferenciasCats, catID)
+		}
+	}
+	catRows.Close()
+
 	// Total estudiantes contratados
 	queryEstudiantes := `
 		SELECT COUNT(DISTINCT estudiante_valorador_id)
@@ -155,6 +172,8 @@ func Get_Empleador_Info(c *gin.Context, db *sql.DB) {
 		SELECT COUNT(*)
 		FROM jobs
 		WHERE empleador_id = $1
+		  AND estado <> 'borrador'
+		  AND eliminado_en IS NULL
 	`
 	if err := db.QueryRow(queryJobs, userId).Scan(&perfil.TotalTrabajosPublicados); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"err": err.Error()})
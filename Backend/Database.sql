@@ -60,3 +60,736 @@ CREATE INDEX idx_ciudad ON estudiantes (ciudad);
 CREATE INDEX idx_carrera ON estudiantes (carrera);
 CREATE INDEX idx_universidad ON estudiantes (universidad); -- Índice añadido para 'universidad'
 
+
+-- =====================================================================
+-- CUPOS POR TRABAJO
+-- Un job puede pedir varios estudiantes. Sigue en el feed mientras
+-- queden cupos libres y solo se cierra cuando todos los matches
+-- contratados se completan o se cancelan.
+-- Estados del job: abierto | cubierto | completado
+-- =====================================================================
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cupos INTEGER NOT NULL DEFAULT 1 CHECK (cupos >= 1);
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cupos_ocupados INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cupos_completados INTEGER NOT NULL DEFAULT 0;
+
+-- Un match cancelado libera su cupo (estado = 'cancelado')
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS cancelado_por VARCHAR(20);
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS motivo_cancelacion TEXT;
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS cancelado_en TIMESTAMP;
+
+-- Recalcular contadores de los jobs existentes
+UPDATE jobs j SET
+    cupos_ocupados = s.ocupados,
+    cupos_completados = s.completados
+FROM (
+    SELECT job_id,
+           COUNT(*) FILTER (WHERE estado IS DISTINCT FROM 'cancelado') AS ocupados,
+           COUNT(*) FILTER (WHERE estado = 'completado') AS completados
+    FROM matches_job
+    WHERE is_match = true
+    GROUP BY job_id
+) s
+WHERE j.id = s.job_id;
+
+-- =====================================================================
+-- EXPIRACIÓN DE TRABAJOS
+-- Un job vence en expira_en o, si no tiene, en fecha_inicio.
+-- Una tarea del servidor lo pasa a 'expirado' (sale de los feeds) y
+-- avisa al empleador 24h antes con un link de renovación.
+-- =====================================================================
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS fecha_inicio TIMESTAMPTZ;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS expira_en TIMESTAMPTZ;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS aviso_expiracion_en TIMESTAMPTZ;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS expirado_en TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_vencimiento
+    ON jobs ((COALESCE(expira_en, fecha_inicio)))
+    WHERE estado = 'abierto';
+
+-- Los jobs abiertos que ya existían reciben una expiración con margen
+-- para que el empleador alcance a recibir el aviso
+UPDATE jobs
+SET expira_en = GREATEST(creado_en + INTERVAL '30 days', NOW() + INTERVAL '7 days')
+WHERE estado = 'abierto' AND expira_en IS NULL AND fecha_inicio IS NULL;
+
+-- =====================================================================
+-- NOTIFICACIONES IN-APP
+-- rol = 'estudiante' | 'empleador' (los IDs viven en tablas distintas)
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS notificaciones (
+    id BIGSERIAL PRIMARY KEY,
+    usuario_id INTEGER NOT NULL,
+    rol VARCHAR(20) NOT NULL,
+    tipo VARCHAR(50) NOT NULL,
+    titulo VARCHAR(255) NOT NULL,
+    mensaje TEXT NOT NULL,
+    datos JSONB NOT NULL DEFAULT '{}',
+    leida BOOLEAN NOT NULL DEFAULT FALSE,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notificaciones_usuario ON notificaciones (usuario_id, rol, creado_en DESC);
+
+-- =====================================================================
+-- BORRADORES Y PUBLICACIÓN PROGRAMADA
+-- estado = 'borrador' nunca aparece en feeds, intereses ni matching.
+-- Si publicar_en está definido, una tarea lo publica a esa hora.
+-- =====================================================================
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS publicar_en TIMESTAMPTZ;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS publicado_en TIMESTAMPTZ;
+
+UPDATE jobs SET publicado_en = creado_en WHERE publicado_en IS NULL AND estado <> 'borrador';
+
+CREATE INDEX IF NOT EXISTS idx_jobs_publicar_en ON jobs (publicar_en) WHERE estado = 'borrador';
+
+-- =====================================================================
+-- TRABAJOS RECURRENTES Y TURNOS
+-- jobs_recurrentes guarda la definición (regla tipo RRULE semanal);
+-- cada turno concreto es una fila de jobs con recurrente_id, así
+-- likes, matches, completar y valorar funcionan por turno.
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS jobs_recurrentes (
+    id SERIAL PRIMARY KEY,
+    empleador_id INTEGER NOT NULL REFERENCES empleadores(id),
+    titulo VARCHAR(255) NOT NULL,
+    descripcion TEXT NOT NULL DEFAULT '',
+    ubicacion TEXT NOT NULL DEFAULT '',
+    pago_estimado NUMERIC(10,2) NOT NULL DEFAULT 0,
+    negociable BOOLEAN NOT NULL DEFAULT FALSE,
+    requisitos TEXT NOT NULL DEFAULT '',
+    categoria VARCHAR(100) NOT NULL DEFAULT '',
+    metodo_pago VARCHAR(50) NOT NULL DEFAULT '',
+    presencial BOOLEAN NOT NULL DEFAULT TRUE,
+    cupos INTEGER NOT NULL DEFAULT 1 CHECK (cupos >= 1),
+    regla TEXT NOT NULL,                -- ej: FREQ=WEEKLY;BYDAY=SA,SU
+    inicio TIMESTAMPTZ NOT NULL,        -- primer día + hora del turno
+    duracion_minutos INTEGER NOT NULL,
+    hasta TIMESTAMPTZ,                  -- fin de la serie (opcional)
+    generado_hasta TIMESTAMPTZ,         -- hasta dónde ya se crearon turnos
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actualizado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_recurrentes_empleador ON jobs_recurrentes (empleador_id);
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS recurrente_id INTEGER REFERENCES jobs_recurrentes(id);
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS fecha_fin TIMESTAMPTZ;
+
+-- Un turno vigente por serie y hora de inicio (la generación es
+-- idempotente). Los cancelados no cuentan: al editar o reactivar la
+-- serie se cancelan los turnos libres y se vuelven a generar.
+DROP INDEX IF EXISTS uq_jobs_turno;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_jobs_turno_vigente ON jobs (recurrente_id, fecha_inicio)
+    WHERE estado <> 'cancelado';
+CREATE INDEX IF NOT EXISTS idx_jobs_empleador_fecha ON jobs (empleador_id, fecha_inicio);
+
+-- =====================================================================
+-- PLANTILLAS DE TRABAJO
+-- Campos que el empleador reutiliza entre trabajos parecidos
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS plantillas_trabajo (
+    id SERIAL PRIMARY KEY,
+    empleador_id INTEGER NOT NULL REFERENCES empleadores(id) ON DELETE CASCADE,
+    nombre VARCHAR(120) NOT NULL,
+    titulo VARCHAR(255) NOT NULL DEFAULT '',
+    descripcion TEXT NOT NULL DEFAULT '',
+    ubicacion TEXT NOT NULL DEFAULT '',
+    pago_estimado NUMERIC(10,2) NOT NULL DEFAULT 0,
+    negociable BOOLEAN NOT NULL DEFAULT FALSE,
+    requisitos TEXT NOT NULL DEFAULT '',
+    categoria VARCHAR(100) NOT NULL DEFAULT '',
+    metodo_pago VARCHAR(50) NOT NULL DEFAULT '',
+    presencial BOOLEAN NOT NULL DEFAULT TRUE,
+    cupos INTEGER NOT NULL DEFAULT 1 CHECK (cupos >= 1),
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actualizado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_plantillas_empleador ON plantillas_trabajo (empleador_id);
+
+-- =====================================================================
+-- TAXONOMÍA DE CATEGORÍAS
+-- Reemplaza el texto libre de jobs.categoria. jobs.categoria se sigue
+-- llenando con el nombre oficial para las vistas existentes y
+-- jobs.categoria_id es la referencia real.
+-- Los administradores son cuentas con tipo_cuenta = 'admin'.
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS categorias (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(80) NOT NULL UNIQUE,
+    nombre VARCHAR(120) NOT NULL,
+    icono VARCHAR(80) NOT NULL DEFAULT '',
+    padre_id INTEGER REFERENCES categorias(id),
+    activa BOOLEAN NOT NULL DEFAULT TRUE,
+    orden INTEGER NOT NULL DEFAULT 0,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actualizado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Textos libres normalizados (minúsculas, sin tildes) → categoría
+CREATE TABLE IF NOT EXISTS categorias_alias (
+    alias VARCHAR(120) PRIMARY KEY,
+    categoria_id INTEGER NOT NULL REFERENCES categorias(id)
+);
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS categoria_id INTEGER REFERENCES categorias(id);
+CREATE INDEX IF NOT EXISTS idx_jobs_categoria_id ON jobs (categoria_id);
+-- Las series recurrentes copian su categoría a cada turno
+ALTER TABLE jobs_recurrentes ADD COLUMN IF NOT EXISTS categoria_id INTEGER REFERENCES categorias(id);
+
+-- Preferencias del empleador (antes JSON de textos en empleadores.preferencias_categorias)
+CREATE TABLE IF NOT EXISTS empleador_categorias (
+    empleador_id INTEGER NOT NULL REFERENCES empleadores(id) ON DELETE CASCADE,
+    categoria_id INTEGER NOT NULL REFERENCES categorias(id),
+    PRIMARY KEY (empleador_id, categoria_id)
+);
+
+-- Categorías iniciales
+INSERT INTO categorias (slug, nombre, icono, orden) VALUES
+    ('meseros', 'Meseros', 'utensils', 10),
+    ('cocina', 'Cocina', 'chef-hat', 20),
+    ('eventos', 'Eventos', 'party-popper', 30),
+    ('ventas', 'Ventas', 'shopping-bag', 40),
+    ('atencion-al-cliente', 'Atención al cliente', 'headset', 50),
+    ('delivery', 'Delivery y mensajería', 'bike', 60),
+    ('limpieza', 'Limpieza', 'sparkles', 70),
+    ('tutorias', 'Tutorías', 'book-open', 80),
+    ('tecnologia', 'Tecnología', 'laptop', 90),
+    ('diseno', 'Diseño', 'palette', 100),
+    ('marketing-digital', 'Marketing digital', 'megaphone', 110),
+    ('logistica', 'Logística', 'package', 120),
+    ('cuidado', 'Cuidado de niños y mascotas', 'heart', 130),
+    ('otros', 'Otros', 'circle-ellipsis', 1000)
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO categorias (slug, nombre, icono, padre_id, orden)
+SELECT v.slug, v.nombre, v.icono, p.id, v.orden
+FROM (VALUES
+    ('promotores', 'Promotores e impulsadores', 'badge', 'eventos', 31),
+    ('bartender', 'Bartender', 'wine', 'meseros', 11)
+) AS v(slug, nombre, icono, padre, orden)
+JOIN categorias p ON p.slug = v.padre
+ON CONFLICT (slug) DO NOTHING;
+
+-- Cada nombre y slug es alias de sí mismo
+INSERT INTO categorias_alias (alias, categoria_id)
+SELECT translate(lower(btrim(nombre)), 'áéíóúüñ', 'aeiouun'), id FROM categorias
+ON CONFLICT (alias) DO NOTHING;
+INSERT INTO categorias_alias (alias, categoria_id)
+SELECT slug, id FROM categorias
+ON CONFLICT (alias) DO NOTHING;
+
+-- Variantes conocidas del texto libre
+INSERT INTO categorias_alias (alias, categoria_id)
+SELECT v.alias, c.id
+FROM (VALUES
+    ('mesero', 'meseros'), ('mesera', 'meseros'), ('meseras', 'meseros'),
+    ('meseria', 'meseros'), ('salonero', 'meseros'), ('saloneros', 'meseros'),
+    ('cocinero', 'cocina'), ('ayudante de cocina', 'cocina'), ('chef', 'cocina'),
+    ('evento', 'eventos'), ('logistica de eventos', 'eventos'),
+    ('promotor', 'promotores'), ('promotora', 'promotores'), ('impulsadora', 'promotores'),
+    ('impulsador', 'promotores'), ('impulsadoras', 'promotores'),
+    ('venta', 'ventas'), ('vendedor', 'ventas'), ('vendedora', 'ventas'),
+    ('atencion al cliente', 'atencion-al-cliente'), ('call center', 'atencion-al-cliente'),
+    ('recepcion', 'atencion-al-cliente'),
+    ('delivery', 'delivery'), ('repartidor', 'delivery'), ('mensajeria', 'delivery'),
+    ('limpieza', 'limpieza'), ('aseo', 'limpieza'),
+    ('tutoria', 'tutorias'), ('clases', 'tutorias'), ('profesor', 'tutorias'),
+    ('tecnologia', 'tecnologia'), ('programacion', 'tecnologia'), ('soporte tecnico', 'tecnologia'),
+    ('diseno grafico', 'diseno'), ('disenador', 'diseno'),
+    ('marketing', 'marketing-digital'), ('redes sociales', 'marketing-digital'),
+    ('community manager', 'marketing-digital'),
+    ('bodega', 'logistica'), ('inventario', 'logistica'),
+    ('ninera', 'cuidado'), ('paseador de perros', 'cuidado'),
+    ('bartender', 'bartender'), ('barman', 'bartender'),
+    ('otro', 'otros')
+) AS v(alias, slug)
+JOIN categorias c ON c.slug = v.slug
+ON CONFLICT (alias) DO NOTHING;
+
+-- Migración: texto libre de jobs → categoria_id (y nombre oficial)
+UPDATE jobs j
+SET categoria_id = a.categoria_id,
+    categoria = c.nombre
+FROM categorias_alias a
+JOIN categorias c ON c.id = a.categoria_id
+WHERE j.categoria_id IS NULL
+  AND a.alias = translate(lower(btrim(j.categoria)), 'áéíóúüñ', 'aeiouun');
+
+UPDATE jobs_recurrentes r
+SET categoria_id = a.categoria_id,
+    categoria = c.nombre
+FROM categorias_alias a
+JOIN categorias c ON c.id = a.categoria_id
+WHERE r.categoria_id IS NULL
+  AND a.alias = translate(lower(btrim(r.categoria)), 'áéíóúüñ', 'aeiouun');
+
+-- Lo que no se pudo mapear queda sin categoria_id; esta consulta lo
+-- lista para crear alias desde el panel de admin
+-- (POST /protected/admin/categorias/:id/alias)
+SELECT DISTINCT categoria AS categoria_sin_mapear
+FROM jobs
+WHERE categoria_id IS NULL AND btrim(COALESCE(categoria, '')) <> '';
+
+-- Migración: preferencias JSON de textos → empleador_categorias
+INSERT INTO empleador_categorias (empleador_id, categoria_id)
+SELECT DISTINCT e.id, a.categoria_id
+FROM empleadores e
+CROSS JOIN LATERAL jsonb_array_elements_text(e.preferencias_categorias::jsonb) AS p(valor)
+JOIN categorias_alias a ON a.alias = translate(lower(btrim(p.valor)), 'áéíóúüñ', 'aeiouun')
+WHERE btrim(COALESCE(e.preferencias_categorias::text, '')) LIKE '[%'
+ON CONFLICT DO NOTHING;
+
+-- =====================================================================
+-- GALERÍA DE FOTOS POR TRABAJO
+-- archivo y miniatura son nombres dentro de ./uploads. La portada se
+-- replica en jobs.foto_job para las vistas que muestran una sola foto.
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS jobs_media (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    archivo TEXT NOT NULL,
+    miniatura TEXT NOT NULL,
+    orden INTEGER NOT NULL DEFAULT 0,
+    es_portada BOOLEAN NOT NULL DEFAULT FALSE,
+    ancho INTEGER NOT NULL DEFAULT 0,
+    alto INTEGER NOT NULL DEFAULT 0,
+    bytes INTEGER NOT NULL DEFAULT 0,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_media_job ON jobs_media (job_id, orden);
+-- Máximo una portada por trabajo
+CREATE UNIQUE INDEX IF NOT EXISTS uq_jobs_media_portada ON jobs_media (job_id) WHERE es_portada;
+
+-- Migración: la foto única existente (uploads/job_{id}.png) pasa a ser
+-- la portada de la galería; usa el mismo archivo como miniatura
+INSERT INTO jobs_media (job_id, archivo, miniatura, orden, es_portada)
+SELECT j.id, 'job_' || j.id || '.png', 'job_' || j.id || '.png', 0, TRUE
+FROM jobs j
+WHERE COALESCE(j.foto_job, '') <> ''
+  AND NOT EXISTS (SELECT 1 FROM jobs_media m WHERE m.job_id = j.id);
+
+-- =====================================================================
+-- BORRADO LÓGICO, ARCHIVO Y PURGA DE TRABAJOS
+-- eliminado_en: en la papelera (restaurable 30 días)
+-- archivado_en: completado que el empleador sacó de su lista
+-- purgado_en: pasado el plazo se vació el contenido pero la fila se
+--             conserva porque la referencian matches o valoraciones
+-- =====================================================================
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS eliminado_en TIMESTAMPTZ;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS archivado_en TIMESTAMPTZ;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS purgado_en TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_eliminado_en ON jobs (eliminado_en) WHERE eliminado_en IS NOT NULL;
+
+-- =====================================================================
+-- HISTORIAL DE EDICIONES Y CAMBIOS DE PAGO
+-- Cada edición guarda solo los campos que cambiaron:
+--   cambios = {"pago_estimado": {"antes": 20, "despues": 15}, ...}
+-- Con contratados activos el pago no se cambia directo: queda una
+-- propuesta que cada contratado acepta o rechaza. Se aplica cuando
+-- todos aceptan; un solo rechazo la cierra.
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS jobs_versiones (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    editado_por INTEGER NOT NULL,
+    motivo VARCHAR(30) NOT NULL DEFAULT 'edicion', -- edicion | cambio_pago_aceptado
+    cambios JSONB NOT NULL,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (job_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS propuestas_pago (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    empleador_id INTEGER NOT NULL,
+    pago_anterior NUMERIC(10,2) NOT NULL,
+    pago_nuevo NUMERIC(10,2) NOT NULL,
+    estado VARCHAR(20) NOT NULL DEFAULT 'pendiente', -- pendiente | aceptada | rechazada | cancelada
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resuelto_en TIMESTAMPTZ
+);
+
+-- Solo una propuesta abierta por trabajo
+CREATE UNIQUE INDEX IF NOT EXISTS uq_propuestas_pago_pendiente ON propuestas_pago (job_id) WHERE estado = 'pendiente';
+
+CREATE TABLE IF NOT EXISTS propuestas_pago_respuestas (
+    propuesta_id INTEGER NOT NULL REFERENCES propuestas_pago(id) ON DELETE CASCADE,
+    match_id INTEGER NOT NULL REFERENCES matches_job(id) ON DELETE CASCADE,
+    estudiante_id INTEGER NOT NULL,
+    estado VARCHAR(20) NOT NULL DEFAULT 'pendiente', -- pendiente | aceptada | rechazada
+    respondido_en TIMESTAMPTZ,
+    PRIMARY KEY (propuesta_id, match_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_propuestas_respuestas_estudiante ON propuestas_pago_respuestas (estudiante_id) WHERE estado = 'pendiente';
+
+-- =====================================================================
+-- PREGUNTAS DE FILTRO
+-- tipo: si_no | opcion | texto. respuestas_descarte son las respuestas
+-- que descartan al candidato (knockout): el like se guarda, pero queda
+-- marcado como descartado y no genera match automático.
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS jobs_preguntas (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    texto TEXT NOT NULL,
+    tipo VARCHAR(10) NOT NULL CHECK (tipo IN ('si_no', 'opcion', 'texto')),
+    opciones TEXT[] NOT NULL DEFAULT '{}',
+    obligatoria BOOLEAN NOT NULL DEFAULT TRUE,
+    respuestas_descarte TEXT[] NOT NULL DEFAULT '{}',
+    orden INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_preguntas_job ON jobs_preguntas (job_id, orden);
+
+CREATE TABLE IF NOT EXISTS respuestas_preguntas (
+    estudiante_id INTEGER NOT NULL,
+    job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    pregunta_id INTEGER NOT NULL REFERENCES jobs_preguntas(id) ON DELETE CASCADE,
+    respuesta TEXT NOT NULL,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (estudiante_id, pregunta_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_respuestas_preguntas_job ON respuestas_preguntas (job_id, estudiante_id);
+
+ALTER TABLE intereses_estudiante ADD COLUMN IF NOT EXISTS descartado BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- =====================================================================
+-- NEGOCIACIÓN DEL PAGO (OFERTAS Y CONTRAOFERTAS)
+-- Una oferta pendiente por match; al aceptarse el monto queda congelado
+-- en matches_job.pago_acordado. NULL = rige el pago publicado del job.
+-- unidad: hora | turno | tarea | dia
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS ofertas_pago (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches_job(id) ON DELETE CASCADE,
+    autor_rol VARCHAR(20) NOT NULL, -- estudiante | empleador
+    monto NUMERIC(10,2) NOT NULL CHECK (monto > 0),
+    unidad VARCHAR(10) NOT NULL,
+    mensaje TEXT NOT NULL DEFAULT '',
+    estado VARCHAR(20) NOT NULL DEFAULT 'pendiente', -- pendiente | aceptada | rechazada | contraofertada | expirada
+    respuesta_a INTEGER REFERENCES ofertas_pago(id),
+    expira_en TIMESTAMPTZ NOT NULL,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    respondida_en TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_ofertas_pago_match ON ofertas_pago (match_id, id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_ofertas_pago_pendiente ON ofertas_pago (match_id) WHERE estado = 'pendiente';
+
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS pago_acordado NUMERIC(10,2);
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS unidad_pago VARCHAR(10);
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS pago_acordado_en TIMESTAMPTZ;
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS oferta_aceptada_id INTEGER REFERENCES ofertas_pago(id);
+
+-- =====================================================================
+-- COMPENSACIÓN ESTRUCTURADA DEL TRABAJO
+-- pago_estimado es el monto exacto (al centavo) por unidad_pago:
+-- hora | turno | tarea | dia. Los trabajos anteriores quedan "por tarea".
+-- horas_estimadas permite calcular el valor por hora y avisar si queda
+-- por debajo del mínimo legal (SALARIO_BASICO_UNIFICADO / 240).
+-- =====================================================================
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS moneda CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS unidad_pago VARCHAR(10) NOT NULL DEFAULT 'tarea';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS horas_estimadas NUMERIC(5,2);
+
+-- Las series recurrentes guardan la misma compensación y la copian a cada turno
+ALTER TABLE jobs_recurrentes ADD COLUMN IF NOT EXISTS moneda CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE jobs_recurrentes ADD COLUMN IF NOT EXISTS unidad_pago VARCHAR(10) NOT NULL DEFAULT 'tarea';
+ALTER TABLE jobs_recurrentes ADD COLUMN IF NOT EXISTS horas_estimadas NUMERIC(5,2);
+
+-- =====================================================================
+-- MOTOR DE MATCH
+-- Guardar un interés y crear el match pasa en una transacción que
+-- bloquea la fila del job. Las restricciones únicas garantizan un solo
+-- interés por parte y un solo match por estudiante y trabajo.
+-- =====================================================================
+CREATE UNIQUE INDEX IF NOT EXISTS uq_intereses_estudiante ON intereses_estudiante (estudiante_id, job_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_intereses_empleador ON intereses_empleador (empleador_id, estudiante_id, job_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_matches_job ON matches_job (estudiante_id, empleador_id, job_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_matches_job_estudiante_job ON matches_job (estudiante_id, job_id);
+
+-- =====================================================================
+-- DISPUTAS SOBRE LA FINALIZACIÓN DE UN MATCH
+-- Mientras hay una disputa abierta el match queda en estado 'en_disputa'
+-- (estado_previo guarda el anterior). Un admin la resuelve:
+-- completado | parcial | no_completado. matches_job.pago_final es lo que
+-- se paga al terminar el match, con o sin disputa.
+-- Las evidencias se guardan fuera de /uploads (./privado/disputas).
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS disputas (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches_job(id) ON DELETE CASCADE,
+    abierta_por VARCHAR(20) NOT NULL, -- estudiante | empleador
+    motivo VARCHAR(30) NOT NULL, -- trabajo_no_realizado | trabajo_incompleto | calidad | pago | otro
+    declaracion_estudiante TEXT,
+    declaracion_empleador TEXT,
+    estado VARCHAR(20) NOT NULL DEFAULT 'abierta', -- abierta | resuelta
+    estado_previo VARCHAR(40) NOT NULL,
+    resultado VARCHAR(20), -- completado | parcial | no_completado
+    monto_pagar NUMERIC(10,2),
+    nota_resolucion TEXT,
+    resuelta_por INTEGER,
+    resuelta_en TIMESTAMPTZ,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_disputas_match ON disputas (match_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_disputas_abierta ON disputas (match_id) WHERE estado = 'abierta';
+
+CREATE TABLE IF NOT EXISTS disputas_evidencias (
+    id SERIAL PRIMARY KEY,
+    disputa_id INTEGER NOT NULL REFERENCES disputas(id) ON DELETE CASCADE,
+    autor_rol VARCHAR(20) NOT NULL,
+    autor_id INTEGER NOT NULL,
+    nombre_original TEXT NOT NULL,
+    descripcion TEXT NOT NULL DEFAULT '',
+    tipo_mime VARCHAR(50) NOT NULL,
+    bytes INTEGER NOT NULL,
+    archivo TEXT NOT NULL, -- ruta relativa a ./privado/disputas
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_disputas_evidencias ON disputas_evidencias (disputa_id, id);
+
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS pago_final NUMERIC(10,2);
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS resultado_disputa VARCHAR(20);
+
+-- =====================================================================
+-- CONFIRMACIÓN AUTOMÁTICA Y HISTORIAL DEL MATCH
+-- confirmacion_pendiente_desde marca cuándo confirmó la primera parte;
+-- pasadas HORAS_CONFIRMACION_AUTOMATICA (72 por defecto) sin respuesta
+-- ni disputa, una tarea completa el match (completado_automatico).
+-- matches_historial guarda cada cambio de estado; actor_rol 'sistema'
+-- son las tareas programadas.
+-- =====================================================================
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS confirmacion_pendiente_desde TIMESTAMPTZ;
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS recordatorio_confirmacion_en TIMESTAMPTZ;
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS completado_automatico BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Los que ya esperaban confirmación empiezan a contar desde la migración
+UPDATE matches_job SET confirmacion_pendiente_desde = NOW()
+WHERE estado IN ('pendiente_confirmacion_estudiante', 'pendiente_confirmacion_empleador')
+  AND confirmacion_pendiente_desde IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_matches_job_pendiente_confirmacion ON matches_job (confirmacion_pendiente_desde)
+    WHERE estado IN ('pendiente_confirmacion_estudiante', 'pendiente_confirmacion_empleador');
+
+CREATE TABLE IF NOT EXISTS matches_historial (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches_job(id) ON DELETE CASCADE,
+    evento VARCHAR(30) NOT NULL,
+    estado_anterior VARCHAR(40) NOT NULL,
+    estado_nuevo VARCHAR(40) NOT NULL,
+    actor_rol VARCHAR(20) NOT NULL, -- estudiante | empleador | admin | sistema
+    detalle TEXT,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_matches_historial_match ON matches_historial (match_id, id);
+
+-- =====================================================================
+-- ASISTENCIA EN TRABAJOS PRESENCIALES
+-- El estudiante registra entrada y salida con el código rotativo que
+-- muestra el empleador; si el job tiene coordenadas, debe estar dentro
+-- de radio_metros. horas_trabajadas suma las jornadas cerradas y define
+-- el pago final de los trabajos por hora. no_se_presento se marca si
+-- pasada la tolerancia desde fecha_inicio no hay ninguna entrada.
+-- =====================================================================
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS latitud DOUBLE PRECISION;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS longitud DOUBLE PRECISION;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS radio_metros INTEGER NOT NULL DEFAULT 200;
+
+CREATE TABLE IF NOT EXISTS asistencias (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches_job(id) ON DELETE CASCADE,
+    entrada_en TIMESTAMPTZ NOT NULL,
+    entrada_latitud DOUBLE PRECISION,
+    entrada_longitud DOUBLE PRECISION,
+    entrada_distancia_m NUMERIC(8,0),
+    salida_en TIMESTAMPTZ,
+    salida_latitud DOUBLE PRECISION,
+    salida_longitud DOUBLE PRECISION,
+    salida_distancia_m NUMERIC(8,0),
+    horas NUMERIC(6,2)
+);
+
+CREATE INDEX IF NOT EXISTS idx_asistencias_match ON asistencias (match_id, entrada_en);
+-- Una sola jornada abierta por match
+CREATE UNIQUE INDEX IF NOT EXISTS uq_asistencias_abierta ON asistencias (match_id) WHERE salida_en IS NULL;
+
+-- Códigos de asistencia incorrectos, para limitar los intentos por match
+CREATE TABLE IF NOT EXISTS asistencias_intentos (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches_job(id) ON DELETE CASCADE,
+    intentado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_asistencias_intentos_match ON asistencias_intentos (match_id, intentado_en);
+
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS horas_trabajadas NUMERIC(6,2);
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS no_se_presento BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS no_se_presento_avisado_en TIMESTAMPTZ;
+
+-- =====================================================================
+-- ENTREGABLES AL COMPLETAR
+-- Archivos y nota que el estudiante adjunta al marcar el match como
+-- completado. Se guardan fuera de /uploads (./privado/entregables).
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS matches_entregables (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches_job(id) ON DELETE CASCADE,
+    nombre_original TEXT NOT NULL,
+    tipo_mime VARCHAR(50) NOT NULL,
+    bytes INTEGER NOT NULL,
+    archivo TEXT NOT NULL, -- ruta relativa a ./privado/entregables
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_matches_entregables_match ON matches_entregables (match_id, id);
+
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS nota_entrega TEXT;
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS entregado_en TIMESTAMPTZ;
+
+-- =====================================================================
+-- CERTIFICADOS DE TRABAJO COMPLETADO
+-- Un certificado por match completado, con código de verificación
+-- estable. Guarda la foto de los datos al emitirse y su firma HMAC;
+-- /verificar-certificado/:code la comprueba.
+-- =====================================================================
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS completado_en TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS certificados (
+    id SERIAL PRIMARY KEY,
+    codigo VARCHAR(14) NOT NULL UNIQUE,
+    match_id INTEGER NOT NULL UNIQUE REFERENCES matches_job(id) ON DELETE CASCADE,
+    estudiante_id INTEGER NOT NULL REFERENCES estudiantes(id) ON DELETE CASCADE,
+    estudiante TEXT NOT NULL,
+    empleador TEXT NOT NULL,
+    titulo TEXT NOT NULL,
+    categoria TEXT NOT NULL DEFAULT '',
+    fecha_inicio DATE,
+    fecha_fin DATE,
+    horas NUMERIC(6,2),
+    horas_registradas BOOLEAN NOT NULL DEFAULT FALSE,
+    rating INTEGER,
+    emitido_en TIMESTAMPTZ NOT NULL,
+    firma CHAR(64) NOT NULL
+);
+
+-- =====================================================================
+-- LIBRO DE PAGOS
+-- Lo adeudado por match es matches_job.pago_final (se fija al completar).
+-- Cada fila es un pago que el empleador marca como hecho, con método y
+-- referencia; el estudiante lo confirma o lo rechaza. Solo los
+-- confirmados descuentan del saldo.
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS pagos (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches_job(id) ON DELETE CASCADE,
+    empleador_id INTEGER NOT NULL REFERENCES empleadores(id) ON DELETE CASCADE,
+    monto NUMERIC(10,2) NOT NULL CHECK (monto > 0),
+    metodo VARCHAR(50) NOT NULL DEFAULT '',
+    referencia VARCHAR(100) NOT NULL DEFAULT '',
+    nota TEXT NOT NULL DEFAULT '',
+    estado VARCHAR(12) NOT NULL DEFAULT 'marcado', -- marcado | confirmado | rechazado
+    marcado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    respondido_en TIMESTAMPTZ,
+    motivo_rechazo TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_pagos_match ON pagos (match_id, estado);
+
+-- =====================================================================
+-- PAGOS EN CUSTODIA
+-- Con una pasarela configurada (PASARELA_PAGOS), el pago se retiene al
+-- crear el match, se captura y transfiere al estudiante al completarse y
+-- se devuelve al cancelarse. Una custodia por match.
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS custodias (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL UNIQUE REFERENCES matches_job(id) ON DELETE CASCADE,
+    proveedor VARCHAR(30) NOT NULL,
+    referencia VARCHAR(60) NOT NULL, -- idempotencia ante la pasarela
+    -- nueva | por_fondear | fondeada | fallida | por_liberar | liberada
+    -- | por_reembolsar | reembolsada | anulada
+    estado VARCHAR(20) NOT NULL DEFAULT 'nueva',
+    cargo_id VARCHAR(100),
+    transferencia_id VARCHAR(100),
+    monto_fondeado NUMERIC(10,2),
+    monto_capturado NUMERIC(10,2),
+    monto_reembolsado NUMERIC(10,2),
+    url_pago TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actualizado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    liberado_en TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_custodias_estado ON custodias (estado, actualizado_en);
+CREATE INDEX IF NOT EXISTS idx_custodias_cargo ON custodias (cargo_id);
+CREATE INDEX IF NOT EXISTS idx_custodias_transferencia ON custodias (transferencia_id);
+
+-- Eventos de webhook ya aplicados: la pasarela puede reenviarlos
+CREATE TABLE IF NOT EXISTS custodias_webhooks (
+    evento_id VARCHAR(100) NOT NULL,
+    proveedor VARCHAR(30) NOT NULL,
+    tipo VARCHAR(50) NOT NULL,
+    operacion_id VARCHAR(100) NOT NULL,
+    recibido_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (proveedor, evento_id)
+);
+
+-- =====================================================================
+-- COMPROBANTES DE PAGO
+-- Uno por match pagado por completo. Foto de los datos del empleador
+-- (cliente) y del estudiante (prestador) al emitirse; el número es
+-- 001-001-<id con 9 dígitos>.
+-- =====================================================================
+CREATE TABLE IF NOT EXISTS comprobantes (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL UNIQUE REFERENCES matches_job(id) ON DELETE CASCADE,
+    job_id INTEGER NOT NULL,
+    empleador_id INTEGER NOT NULL REFERENCES empleadores(id) ON DELETE CASCADE,
+    estudiante_id INTEGER NOT NULL,
+    tipo_identidad VARCHAR(10) NOT NULL DEFAULT 'persona', -- persona | empresa
+    cliente TEXT NOT NULL,
+    identificacion_cliente VARCHAR(13) NOT NULL DEFAULT '',
+    direccion_cliente TEXT NOT NULL DEFAULT '',
+    email_cliente TEXT NOT NULL DEFAULT '',
+    prestador TEXT NOT NULL,
+    identificacion_prestador VARCHAR(13) NOT NULL DEFAULT '',
+    titulo TEXT NOT NULL,
+    descripcion TEXT NOT NULL DEFAULT '',
+    categoria TEXT NOT NULL DEFAULT '',
+    monto NUMERIC(10,2) NOT NULL,
+    metodo TEXT NOT NULL DEFAULT '',
+    fecha_pago TIMESTAMPTZ,
+    emitido_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_comprobantes_empleador ON comprobantes (empleador_id, fecha_pago DESC);
+
+-- =====================================================================
+-- ANALÍTICAS DEL EMPLEADOR
+-- emparejado_en es el momento del match; el tiempo hasta el match se
+-- mide desde publicado_en. Los matches anteriores toman el último de los
+-- dos likes, que es lo más cercano que queda guardado.
+-- =====================================================================
+ALTER TABLE matches_job ADD COLUMN IF NOT EXISTS emparejado_en TIMESTAMPTZ;
+
+UPDATE matches_job mj SET emparejado_en = GREATEST(ie.creado_en, iem.creado_en)
+FROM intereses_estudiante ie, intereses_empleador iem
+WHERE mj.emparejado_en IS NULL
+  AND mj.is_match = true
+  AND ie.estudiante_id = mj.estudiante_id AND ie.job_id = mj.job_id
+  AND iem.empleador_id = mj.empleador_id AND iem.estudiante_id = mj.estudiante_id
+  AND iem.job_id = mj.job_id;
+
+CREATE INDEX IF NOT EXISTS idx_intereses_estudiante_job ON intereses_estudiante (job_id) WHERE interesado = true;
@@ -0,0 +1,244 @@
+package compensacion
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Compensación estructurada de un trabajo: monto en centavos, moneda,
+// unidad (por hora, turno, tarea o día) y horas estimadas. En la base
+// el monto sigue en jobs.pago_estimado (NUMERIC(10,2), exacto al
+// centavo); aquí se maneja siempre en centavos para no arrastrar
+// errores de float.
+
+const (
+	MonedaUSD = "USD"
+
+	UnidadHora  = "hora"
+	UnidadTurno = "turno"
+	UnidadTarea = "tarea"
+	UnidadDia   = "dia"
+
+	// Unidad de los trabajos creados antes de que existiera la compensación
+	UnidadDefault = UnidadTarea
+
+	MaxCentavos       = 1000000 // $10.000
+	MaxHorasEstimadas = 168
+
+	// Jornada de un día cuando no se indican horas
+	HorasPorDia = 8
+	// El Código del Trabajo toma 240 horas al mes para el valor hora
+	HorasMensuales = 240
+	// Salario básico unificado usado si no se configura SALARIO_BASICO_UNIFICADO
+	SBUDefault = 470.0
+)
+
+var Unidades = []string{UnidadHora, UnidadTurno, UnidadTarea, UnidadDia}
+
+// Compensacion es como se guarda y se muestra el pago de un trabajo
+type Compensacion struct {
+	MontoCentavos  int64    `json:"monto_centavos"`
+	Monto          float64  `json:"monto"` // monto_centavos / 100, para mostrar
+	Moneda         string   `json:"moneda"`
+	Unidad         string   `json:"unidad"`
+	HorasEstimadas *float64 `json:"horas_estimadas"`
+	// Equivalente por hora si se puede calcular (unidad hora, día u horas estimadas)
+	EquivalenteHora *float64 `json:"equivalente_hora"`
+}
+
+// Entrada es lo que llega en los requests de crear y editar
+type Entrada struct {
+	MontoCentavos  *int64   `json:"monto_centavos"`
+	Moneda         string   `json:"moneda"`
+	Unidad         string   `json:"unidad"`
+	HorasEstimadas *float64 `json:"horas_estimadas"`
+}
+
+// ConPago combina el pago_estimado en dólares que aún envían los clientes
+// con la entrada estructurada; monto_centavos tiene prioridad
+func ConPago(e *Entrada, pago *float64) *Entrada {
+	if pago == nil || (e != nil && e.MontoCentavos != nil) {
+		return e
+	}
+	c := Entrada{}
+	if e != nil {
+		c = *e
+	}
+	centavos := DesdeDecimal(*pago)
+	c.MontoCentavos = &centavos
+	return &c
+}
+
+// ValidarUnidad normaliza la unidad ("día" → "dia")
+func ValidarUnidad(u string) (string, bool) {
+	u = strings.ToLower(strings.TrimSpace(u))
+	u = strings.ReplaceAll(u, "í", "i")
+	for _, v := range Unidades {
+		if v == u {
+			return u, true
+		}
+	}
+	return "", false
+}
+
+// Nueva arma una compensación con los valores por defecto y el equivalente por hora
+func Nueva(centavos int64, unidad string, horas *float64) Compensacion {
+	if unidad == "" {
+		unidad = UnidadDefault
+	}
+	c := Compensacion{
+		MontoCentavos:  centavos,
+		Monto:          float64(centavos) / 100,
+		Moneda:         MonedaUSD,
+		Unidad:         unidad,
+		HorasEstimadas: horas,
+	}
+	c.EquivalenteHora = c.equivalenteHora()
+	return c
+}
+
+// DesdeBD arma la compensación con las columnas de jobs (o de un match)
+func DesdeBD(pago float64, unidad string, horas sql.NullFloat64) Compensacion {
+	var h *float64
+	if horas.Valid {
+		h = &horas.Float64
+	}
+	return Nueva(DesdeDecimal(pago), unidad, h)
+}
+
+// DesdeDecimal convierte un pago en dólares (pago_estimado) a centavos
+func DesdeDecimal(pago float64) int64 {
+	return int64(math.Round(pago * 100))
+}
+
+// Decimal devuelve el monto como texto para columnas NUMERIC ("12.50")
+func (c Compensacion) Decimal() string {
+	return fmt.Sprintf("%d.%02d", c.MontoCentavos/100, c.MontoCentavos%100)
+}
+
+// Aplicar mezcla la entrada sobre la compensación actual: los campos
+// ausentes se conservan. Devuelve un mensaje si algo no es válido.
+func (c Compensacion) Aplicar(e *Entrada) (Compensacion, string) {
+	if e == nil {
+		e = &Entrada{}
+	}
+	centavos, unidad, horas := c.MontoCentavos, c.Unidad, c.HorasEstimadas
+
+	if e.MontoCentavos != nil {
+		centavos = *e.MontoCentavos
+	}
+	if centavos < 0 || centavos > MaxCentavos {
+		return c, "monto_centavos debe estar entre 0 y " + strconv.Itoa(MaxCentavos)
+	}
+	if m := strings.ToUpper(strings.TrimSpace(e.Moneda)); m != "" && m != MonedaUSD {
+		return c, "la única moneda aceptada es USD"
+	}
+	if e.Unidad != "" {
+		u, ok := ValidarUnidad(e.Unidad)
+		if !ok {
+			return c, "unidad debe ser " + strings.Join(Unidades, ", ")
+		}
+		unidad = u
+	}
+	if e.HorasEstimadas != nil {
+		// 0 borra las horas estimadas
+		if *e.HorasEstimadas < 0 || *e.HorasEstimadas > MaxHorasEstimadas {
+			return c, "horas_estimadas debe estar entre 0 y " + strconv.Itoa(MaxHorasEstimadas)
+		}
+		horas = e.HorasEstimadas
+		if *horas == 0 {
+			horas = nil
+		}
+	}
+	return Nueva(centavos, unidad, horas), ""
+}
+
+// ValidarPublicacion indica qué le falta a la compensación para publicar
+// el trabajo. Por hora se necesitan las horas estimadas: con ellas se
+// retiene el pago en custodia y se paga si no hay asistencia registrada.
+func (c Compensacion) ValidarPublicacion() string {
+	if c.Unidad == UnidadHora && (c.HorasEstimadas == nil || *c.HorasEstimadas <= 0) {
+		return "los trabajos por hora necesitan horas_estimadas"
+	}
+	return ""
+}
+
+func (c Compensacion) equivalenteHora() *float64 {
+	var horas float64
+	switch {
+	case c.Unidad == UnidadHora:
+		horas = 1
+	case c.HorasEstimadas != nil && *c.HorasEstimadas > 0:
+		horas = *c.HorasEstimadas
+	case c.Unidad == UnidadDia:
+		horas = HorasPorDia
+	default:
+		return nil
+	}
+	v := math.Round(float64(c.MontoCentavos)/horas) / 100
+	return &v
+}
+
+// MinimoPorHora es el valor hora del salario básico unificado vigente
+func MinimoPorHora() float64 {
+	sbu := SBUDefault
+	if v, err := strconv.ParseFloat(os.Getenv("SALARIO_BASICO_UNIFICADO"), 64); err == nil && v > 0 {
+		sbu = v
+	}
+	return sbu / HorasMensuales
+}
+
+// Advertencias no bloquean la publicación; avisan al empleador de pagos
+// por debajo del mínimo legal cuando se puede calcular el valor hora
+func (c Compensacion) Advertencias() []string {
+	avisos := []string{}
+	if c.MontoCentavos == 0 {
+		return avisos
+	}
+	if c.EquivalenteHora == nil {
+		if c.Unidad == UnidadTurno || c.Unidad == UnidadTarea {
+			avisos = append(avisos, "Indica horas_estimadas para mostrar el valor por hora")
+		}
+		return avisos
+	}
+	minimo := MinimoPorHora()
+	if *c.EquivalenteHora < minimo {
+		avisos = append(avisos, fmt.Sprintf(
+			"El pago equivale a $%.2f por hora, por debajo del mínimo legal de $%.2f por hora",
+			*c.EquivalenteHora, minimo))
+	}
+	return avisos
+}
+
+// Texto resume la compensación para mostrarla: "$25.00 por turno (~4 h)"
+func (c Compensacion) Texto() string {
+	s := fmt.Sprintf("$%s por %s", c.Decimal(), strings.Replace(c.Unidad, "dia", "día", 1))
+	if c.HorasEstimadas != nil {
+		s += fmt.Sprintf(" (~%s h)", strconv.FormatFloat(*c.HorasEstimadas, 'f', -1, 64))
+	}
+	return s
+}
+
+// MontoFondeoSQL es lo que se retiene al crear el match (alias mj y j):
+// el pago acordado o publicado y, por hora, por las horas estimadas
+const MontoFondeoSQL = `CASE
+		WHEN COALESCE(mj.unidad_pago, j.unidad_pago) = 'hora' AND COALESCE(j.horas_estimadas, 0) > 0
+		THEN ROUND(COALESCE(mj.pago_acordado, j.pago_estimado) * j.horas_estimadas, 2)
+		ELSE COALESCE(mj.pago_acordado, j.pago_estimado)
+	END`
+
+// PagoFinalSQL calcula lo que se paga al completar (alias mj y j): el
+// monto acordado o publicado y, en trabajos por hora, ese valor por las
+// horas trabajadas o, si no hay asistencia registrada, por las horas
+// estimadas, igual que lo retenido con MontoFondeoSQL
+const PagoFinalSQL = `CASE
+		WHEN COALESCE(mj.unidad_pago, j.unidad_pago) = 'hora' AND COALESCE(mj.horas_trabajadas, 0) > 0
+		THEN ROUND(COALESCE(mj.pago_acordado, j.pago_estimado) * mj.horas_trabajadas, 2)
+		WHEN COALESCE(mj.unidad_pago, j.unidad_pago) = 'hora' AND COALESCE(j.horas_estimadas, 0) > 0
+		THEN ROUND(COALESCE(mj.pago_acordado, j.pago_estimado) * j.horas_estimadas, 2)
+		ELSE COALESCE(mj.pago_acordado, j.pago_estimado)
+	END`
@@ -0,0 +1,120 @@
+package compensacion
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func centavos(v int64) *int64 { return &v }
+
+func TestAplicarPorHoraConservaHoras(t *testing.T) {
+	comp, msg := Nueva(0, "", nil).Aplicar(&Entrada{
+		MontoCentavos:  centavos(350),
+		Unidad:         UnidadHora,
+		HorasEstimadas: ptr(4),
+	})
+	if msg != "" {
+		t.Fatalf("Aplicar: %s", msg)
+	}
+	if comp.Unidad != UnidadHora || comp.HorasEstimadas == nil || *comp.HorasEstimadas != 4 {
+		t.Fatalf("compensación = %+v, se esperaba por hora con 4 horas", comp)
+	}
+	if comp.EquivalenteHora == nil || *comp.EquivalenteHora != 3.5 {
+		t.Errorf("equivalente_hora = %v, se esperaba 3.5", comp.EquivalenteHora)
+	}
+
+	// Editar solo el monto conserva la unidad y las horas
+	comp, msg = comp.Aplicar(&Entrada{MontoCentavos: centavos(400)})
+	if msg != "" || comp.HorasEstimadas == nil || *comp.HorasEstimadas != 4 {
+		t.Errorf("tras editar el monto = %+v (%s), se esperaban 4 horas", comp, msg)
+	}
+}
+
+func TestValidarPublicacion(t *testing.T) {
+	casos := []struct {
+		nombre string
+		comp   Compensacion
+		valida bool
+	}{
+		{"por hora con horas", Nueva(350, UnidadHora, ptr(4)), true},
+		{"por hora sin horas", Nueva(350, UnidadHora, nil), false},
+		{"por hora con cero horas", Nueva(350, UnidadHora, ptr(0)), false},
+		{"por tarea sin horas", Nueva(2000, UnidadTarea, nil), true},
+		{"por turno sin horas", Nueva(2500, UnidadTurno, nil), true},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if msg := c.comp.ValidarPublicacion(); (msg == "") != c.valida {
+				t.Errorf("ValidarPublicacion() = %q, se esperaba válida = %v", msg, c.valida)
+			}
+		})
+	}
+}
+
+func TestConPago(t *testing.T) {
+	pago := 12.5
+	e := ConPago(&Entrada{Unidad: UnidadHora}, &pago)
+	if e.MontoCentavos == nil || *e.MontoCentavos != 1250 || e.Unidad != UnidadHora {
+		t.Errorf("entrada = %+v, se esperaban 1250 centavos por hora", e)
+	}
+	e = ConPago(&Entrada{MontoCentavos: centavos(900)}, &pago)
+	if *e.MontoCentavos != 900 {
+		t.Errorf("monto_centavos = %d, debía tener prioridad sobre pago_estimado", *e.MontoCentavos)
+	}
+	if ConPago(nil, nil) != nil {
+		t.Error("sin pago ni compensación no debe haber entrada")
+	}
+}
+
+// TestFondeoYPagoPorHora evalúa MontoFondeoSQL y PagoFinalSQL en Postgres
+// con un trabajo por hora armado con Aplicar. Necesita TEST_DATABASE_URL.
+func TestFondeoYPagoPorHora(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL no configurada")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	comp, msg := Nueva(0, "", nil).Aplicar(&Entrada{
+		MontoCentavos:  centavos(350),
+		Unidad:         UnidadHora,
+		HorasEstimadas: ptr(4),
+	})
+	if msg != "" {
+		t.Fatalf("Aplicar: %s", msg)
+	}
+
+	casos := []struct {
+		nombre          string
+		horasTrabajadas *float64
+		fondeo, pago    int64
+	}{
+		{"sin asistencia paga las horas estimadas", nil, 1400, 1400},
+		{"con asistencia paga las horas trabajadas", ptr(3), 1400, 1050},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			var fondeo, pago float64
+			err := db.QueryRow(`
+				SELECT `+MontoFondeoSQL+`, `+PagoFinalSQL+`
+				FROM (VALUES ($1::numeric, $2::varchar, $3::numeric)) AS j(pago_estimado, unidad_pago, horas_estimadas),
+				     (VALUES (NULL::numeric, NULL::varchar, $4::numeric)) AS mj(pago_acordado, unidad_pago, horas_trabajadas)
+			`, comp.Decimal(), comp.Unidad, comp.HorasEstimadas, c.horasTrabajadas).Scan(&fondeo, &pago)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if DesdeDecimal(fondeo) != c.fondeo || DesdeDecimal(pago) != c.pago {
+				t.Errorf("fondeo = %.2f, pago = %.2f; se esperaba %d y %d centavos", fondeo, pago, c.fondeo, c.pago)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package cupos
+
+import (
+	"database/sql"
+)
+
+// Ejecutor permite usar tanto *sql.DB como *sql.Tx
+type Ejecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Estado de los cupos de un trabajo
+type Estado struct {
+	Cupos       int    `json:"cupos"`
+	Ocupados    int    `json:"cupos_ocupados"`
+	Completados int    `json:"cupos_completados"`
+	EstadoJob   string `json:"estado"`
+}
+
+// Disponibles devuelve cuántos cupos quedan libres
+func (e Estado) Disponibles() int {
+	if e.Ocupados >= e.Cupos {
+		return 0
+	}
+	return e.Cupos - e.Ocupados
+}
+
+// ------------------------------------------------------
+// Recalcular cuenta los matches del trabajo y actualiza
+// cupos_ocupados, cupos_completados y el estado del job:
+//
+//   - abierto:    quedan cupos libres (sigue en el feed)
+//   - cubierto:   todos los cupos ocupados, hay matches en curso
+//   - completado: todos los cupos ocupados y todos los matches
+//     contratados terminaron
+//
+// Un match cancelado libera su cupo. Estados que no son de
+// contratación (borrador, expirado, etc.) no se tocan.
+// ------------------------------------------------------
+func Recalcular(q Ejecutor, jobID int) (Estado, error) {
+	var e Estado
+
+	err := q.QueryRow(`
+		UPDATE jobs j SET
+			cupos_ocupados = s.ocupados,
+			cupos_completados = s.completados,
+			estado = CASE
+				WHEN j.estado NOT IN ('abierto', 'cubierto') THEN j.estado
+				WHEN s.ocupados >= j.cupos AND s.activos = 0 THEN 'completado'
+				WHEN s.ocupados >= j.cupos THEN 'cubierto'
+				ELSE 'abierto'
+			END,
+			actualizado_en = NOW()
+		FROM (
+			SELECT
+				COUNT(*) FILTER (WHERE estado IS DISTINCT FROM 'cancelado') AS ocupados,
+				COUNT(*) FILTER (WHERE estado = 'completado') AS completados,
+				COUNT(*) FILTER (
+					WHERE estado IS DISTINCT FROM 'cancelado'
+					  AND estado IS DISTINCT FROM 'completado'
+				) AS activos
+			FROM matches_job
+			WHERE job_id = $1 AND is_match = true
+		) s
+		WHERE j.id = $1
+		RETURNING j.cupos, j.cupos_ocupados, j.cupos_completados, j.estado
+	`, jobID).Scan(&e.Cupos, &e.Ocupados, &e.Completados, &e.EstadoJob)
+
+	return e, err
+}
+
+// Obtener lee el estado actual de cupos sin modificarlo
+func Obtener(q Ejecutor, jobID int) (Estado, error) {
+	var e Estado
+
+	err := q.QueryRow(`
+		SELECT cupos, cupos_ocupados, cupos_completados, estado
+		FROM jobs
+		WHERE id = $1
+	`, jobID).Scan(&e.Cupos, &e.Ocupados, &e.Completados, &e.EstadoJob)
+
+	return e, err
+}
@@ -0,0 +1,399 @@
+package jobsemp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/categorias"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/gin-gonic/gin"
+)
+
+// Un borrador nunca aparece en feeds, intereses ni matching.
+// Se publica a mano (PublicarTrabajoHandler) o lo publica la tarea
+// PublicarTrabajosProgramados cuando llega publicar_en.
+
+type EditarBorradorRequest struct {
+	ID          int      `json:"id"`
+	Titulo      *string  `json:"titulo"`
+	Descripcion *string  `json:"descripcion"`
+	Ubicacion   *string  `json:"ubicacion"`
+	Pago        *float64 `json:"pago_estimado"` // en dólares; compensacion lo reemplaza
+	Negociable  *bool    `json:"negociable"`
+	Requisitos  *string  `json:"requisitos"`
+	Categoria   *string  `json:"categoria"`
+	CategoriaID *int     `json:"categoria_id"`
+	MetodoPago  *string  `json:"metodo_pago"`
+	Presencial  *bool    `json:"presencial"`
+	Cupos       *int     `json:"cupos"`
+	FechaInicio *string  `json:"fecha_inicio"` // "" borra la fecha
+	ExpiraEn    *string  `json:"expira_en"`    // "" borra la fecha
+	PublicarEn  *string  `json:"publicar_en"`  // "" deja el borrador sin programar
+
+	Compensacion *compensacion.Entrada `json:"compensacion"`
+}
+
+// verificarBorrador comprueba que el job exista, sea del empleador y siga en borrador
+func verificarBorrador(db *sql.DB, jobID, empleadorID int) (int, string) {
+	var ownerID int
+	var estado string
+	err := db.QueryRow(`SELECT empleador_id, estado FROM jobs WHERE id = $1 AND eliminado_en IS NULL`, jobID).Scan(&ownerID, &estado)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, "Trabajo no encontrado"
+	}
+	if err != nil {
+		return http.StatusInternalServerError, "Error al leer trabajo"
+	}
+	if ownerID != empleadorID {
+		return http.StatusForbidden, "No puedes editar este trabajo"
+	}
+	if estado != "borrador" {
+		return http.StatusConflict, "El trabajo ya no es un borrador"
+	}
+	return 0, ""
+}
+
+// -------------------------------
+// PATCH /protected/editar-borrador
+// -------------------------------
+func EditarBorradorHandler(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	var req EditarBorradorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	if req.ID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if status, msg := verificarBorrador(db, req.ID, userID); status != 0 {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	var setParts []string
+	var args []interface{}
+	argPos := 1
+
+	set := func(col string, val interface{}) {
+		setParts = append(setParts, fmt.Sprintf("%s=$%d", col, argPos))
+		args = append(args, val)
+		argPos++
+	}
+
+	if req.Titulo != nil {
+		set("titulo", *req.Titulo)
+	}
+	if req.Descripcion != nil {
+		set("descripcion", *req.Descripcion)
+	}
+	// Si deja de ser presencial → ubicación vacía
+	if req.Presencial != nil && !*req.Presencial {
+		vacia := ""
+		req.Ubicacion = &vacia
+	}
+	if req.Ubicacion != nil {
+		set("ubicacion", *req.Ubicacion)
+	}
+	// Compensación: mismas reglas que al editar un trabajo publicado; lo
+	// que exige la publicación (horas por hora) se revisa al publicar
+	var comp *compensacion.Compensacion
+	if entrada := compensacion.ConPago(req.Compensacion, req.Pago); entrada != nil {
+		var pago float64
+		var unidad string
+		var horas sql.NullFloat64
+		err := db.QueryRow(`
+			SELECT pago_estimado, unidad_pago, horas_estimadas FROM jobs WHERE id = $1
+		`, req.ID).Scan(&pago, &unidad, &horas)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer borrador"})
+			return
+		}
+		nueva, msg := compensacion.DesdeBD(pago, unidad, horas).Aplicar(entrada)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		set("pago_estimado", nueva.Decimal())
+		set("moneda", nueva.Moneda)
+		set("unidad_pago", nueva.Unidad)
+		set("horas_estimadas", nueva.HorasEstimadas)
+		comp = &nueva
+	}
+	if req.Negociable != nil {
+		set("negociable", *req.Negociable)
+	}
+	if req.Requisitos != nil {
+		set("requisitos", *req.Requisitos)
+	}
+	if req.CategoriaID != nil || (req.Categoria != nil && *req.Categoria != "") {
+		id, texto := 0, ""
+		if req.CategoriaID != nil {
+			id = *req.CategoriaID
+		}
+		if req.Categoria != nil {
+			texto = *req.Categoria
+		}
+		cat, err := categorias.Elegir(db, id, texto)
+		if err == categorias.ErrNoEncontrada || (err == nil && cat == nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "categoria inválida"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar categoria"})
+			return
+		}
+		set("categoria", cat.Nombre)
+		set("categoria_id", cat.ID)
+	} else if req.Categoria != nil {
+		// "" deja el borrador sin categoría
+		set("categoria", "")
+		set("categoria_id", nil)
+	}
+	if req.MetodoPago != nil {
+		set("metodo_pago", *req.MetodoPago)
+	}
+	if req.Presencial != nil {
+		set("presencial", *req.Presencial)
+	}
+	if req.Cupos != nil {
+		if *req.Cupos < 1 || *req.Cupos > MaxCupos {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cupos debe estar entre 1 y " + strconv.Itoa(MaxCupos)})
+			return
+		}
+		set("cupos", *req.Cupos)
+	}
+
+	// Fechas: mismas reglas que al crear
+	fechas := []struct {
+		campo string
+		valor *string
+	}{
+		{"fecha_inicio", req.FechaInicio},
+		{"expira_en", req.ExpiraEn},
+		{"publicar_en", req.PublicarEn},
+	}
+	for _, f := range fechas {
+		if f.valor == nil {
+			continue
+		}
+		t, err := parseFechaOpcional(*f.valor)
+		if err != nil || (t != nil && t.Before(time.Now())) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": f.campo + " inválida o en el pasado"})
+			return
+		}
+		set(f.campo, t)
+	}
+
+	if len(setParts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se proporcionaron campos para actualizar"})
+		return
+	}
+
+	args = append(args, req.ID)
+	query := fmt.Sprintf(
+		"UPDATE jobs SET %s, actualizado_en = NOW() WHERE id=$%d AND estado = 'borrador'",
+		strings.Join(setParts, ", "), argPos,
+	)
+
+	if _, err := db.Exec(query, args...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar borrador"})
+		return
+	}
+
+	resp := gin.H{
+		"message": "Borrador actualizado",
+		"id":      req.ID,
+	}
+	if comp != nil {
+		resp["compensacion"] = comp
+		resp["advertencias"] = comp.Advertencias()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// publicarBorradoresSQL pasa a 'abierto' los borradores indicados.
+// Si el job no tenía fechas recibe la expiración por defecto desde hoy.
+const publicarBorradoresSQL = `
+	UPDATE jobs
+	SET estado = 'abierto',
+	    publicado_en = NOW(),
+	    publicar_en = NULL,
+	    expira_en = COALESCE(
+	        expira_en,
+	        CASE WHEN fecha_inicio IS NULL THEN NOW() + make_interval(days => %d) END
+	    ),
+	    actualizado_en = NOW()
+	WHERE estado = 'borrador'
+	  AND eliminado_en IS NULL
+	  AND %s
+	RETURNING id, empleador_id, titulo
+`
+
+// validarPublicacion revisa que un borrador se pueda publicar; devuelve
+// el motivo si no. La usan la publicación manual y la programada.
+func validarPublicacion(titulo, descripcion string, fechaInicio, expiraEn sql.NullTime, comp compensacion.Compensacion) string {
+	if strings.TrimSpace(titulo) == "" || strings.TrimSpace(descripcion) == "" {
+		return "El trabajo necesita título y descripción para publicarse"
+	}
+	if msg := comp.ValidarPublicacion(); msg != "" {
+		return msg
+	}
+	// Un borrador puede haberse quedado con fechas ya pasadas
+	ahora := time.Now()
+	if (fechaInicio.Valid && fechaInicio.Time.Before(ahora)) || (expiraEn.Valid && expiraEn.Time.Before(ahora)) {
+		return "Las fechas del borrador ya pasaron, actualízalas antes de publicar"
+	}
+	return ""
+}
+
+// -------------------------------
+// POST /protected/publicar-trabajo  { "id": 1 }  (publicar ahora)
+// -------------------------------
+func PublicarTrabajoHandler(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	var req struct {
+		ID int `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if status, msg := verificarBorrador(db, req.ID, userID); status != 0 {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	var titulo, descripcion, unidad string
+	var fechaInicio, expiraEn sql.NullTime
+	var pago float64
+	var horas sql.NullFloat64
+	err = db.QueryRow(`
+		SELECT titulo, descripcion, fecha_inicio, expira_en, pago_estimado, unidad_pago, horas_estimadas
+		FROM jobs WHERE id = $1
+	`, req.ID).Scan(&titulo, &descripcion, &fechaInicio, &expiraEn, &pago, &unidad, &horas)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer borrador"})
+		return
+	}
+	comp := compensacion.DesdeBD(pago, unidad, horas)
+	if msg := validarPublicacion(titulo, descripcion, fechaInicio, expiraEn, comp); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	query := fmt.Sprintf(publicarBorradoresSQL, DiasExpiracionDefault, "id = $1")
+	var id, empleadorID int
+	if err := db.QueryRow(query, req.ID).Scan(&id, &empleadorID, &titulo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al publicar trabajo"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Trabajo publicado",
+		"id":      id,
+		"estado":  "abierto",
+	})
+}
+
+// ------------------------------------------------------
+// TAREA: publicar los borradores cuyo publicar_en ya llegó
+// ------------------------------------------------------
+func PublicarTrabajosProgramados(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, empleador_id, titulo, descripcion, fecha_inicio, expira_en, pago_estimado, unidad_pago, horas_estimadas
+		FROM jobs
+		WHERE estado = 'borrador'
+		  AND eliminado_en IS NULL
+		  AND publicar_en IS NOT NULL AND publicar_en <= NOW()
+	`)
+	if err != nil {
+		return err
+	}
+
+	type programado struct {
+		id, empleadorID       int
+		titulo, descripcion   string
+		fechaInicio, expiraEn sql.NullTime
+		comp                  compensacion.Compensacion
+	}
+	var programados []programado
+	for rows.Next() {
+		var p programado
+		var pago float64
+		var unidad string
+		var horas sql.NullFloat64
+		if err := rows.Scan(&p.id, &p.empleadorID, &p.titulo, &p.descripcion, &p.fechaInicio, &p.expiraEn, &pago, &unidad, &horas); err != nil {
+			rows.Close()
+			return err
+		}
+		p.comp = compensacion.DesdeBD(pago, unidad, horas)
+		programados = append(programados, p)
+	}
+	rows.Close()
+
+	// Se vuelve a filtrar por publicar_en por si el empleador editó el
+	// borrador entre la lectura y la publicación
+	query := fmt.Sprintf(publicarBorradoresSQL, DiasExpiracionDefault, "id = $1 AND publicar_en IS NOT NULL AND publicar_en <= NOW()")
+
+	for _, p := range programados {
+		aviso := notificaciones.Notificacion{
+			UsuarioID: p.empleadorID,
+			Rol:       notificaciones.RolEmpleador,
+			Tipo:      "trabajo_publicado",
+			Titulo:    "Tu trabajo fue publicado",
+			Mensaje:   fmt.Sprintf("El trabajo \"%s\" ya está visible para los estudiantes.", p.titulo),
+			Datos:     map[string]interface{}{"job_id": p.id},
+		}
+
+		if msg := validarPublicacion(p.titulo, p.descripcion, p.fechaInicio, p.expiraEn, p.comp); msg != "" {
+			// Queda como borrador sin programación hasta que el empleador lo corrija
+			_, err := db.ExecContext(ctx, `
+				UPDATE jobs SET publicar_en = NULL, actualizado_en = NOW()
+				WHERE id = $1 AND estado = 'borrador'
+			`, p.id)
+			if err != nil {
+				return err
+			}
+			aviso.Tipo = "trabajo_no_publicado"
+			aviso.Titulo = "No pudimos publicar tu trabajo"
+			aviso.Mensaje = fmt.Sprintf("El trabajo \"%s\" sigue como borrador. %s.", p.titulo, msg)
+		} else {
+			var id, empleadorID int
+			var titulo string
+			err := db.QueryRowContext(ctx, query, p.id).Scan(&id, &empleadorID, &titulo)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := notificaciones.Crear(db, aviso); err != nil {
+			log.Println("Error notificando trabajo publicado:", err)
+		}
+	}
+
+	return nil
+}
@@ -1,11 +1,25 @@
 package jobsemp
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"log"
 	"net/http"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/media"
 	"github.com/gin-gonic/gin"
 )
 
+// Borrar un trabajo es un borrado lógico (eliminado_en): desaparece de
+// todos los listados pero el empleador puede restaurarlo durante
+// DiasRestauracion. Pasado ese plazo la tarea PurgarTrabajosEliminados
+// lo borra de verdad, salvo que tenga matches o valoraciones: en ese
+// caso queda una fila mínima para no romper ese historial.
+
+const DiasRestauracion = 30
+
 type DeleteJobRequest struct {
 	ID int `json:"id"`
 }
@@ -36,14 +50,19 @@ func DeleteJob(db *sql.DB, c *gin.Context) {
 		return
 	}
 
-	// Verificar que el job exista, sea del usuario y esté abierto
+	// Verificar que el job exista, sea del usuario y no esté ya eliminado
 	var estado string
 	var ownerID int
+	var activos int
 
-	err = db.QueryRow(
-		"SELECT estado, empleador_id FROM jobs WHERE id = $1",
-		req.ID,
-	).Scan(&estado, &ownerID)
+	err = db.QueryRow(`
+		SELECT j.estado, j.empleador_id,
+		       (SELECT COUNT(*) FROM matches_job m
+		        WHERE m.job_id = j.id AND m.is_match = true
+		          AND COALESCE(m.estado, 'en_progreso') NOT IN ('completado', 'cancelado'))
+		FROM jobs j
+		WHERE j.id = $1 AND j.eliminado_en IS NULL
+	`, req.ID).Scan(&estado, &ownerID, &activos)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -66,16 +85,33 @@ func DeleteJob(db *sql.DB, c *gin.Context) {
 		return
 	}
 
-	// Verificar que esté abierto
-	if estado != "abierto" {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Solo se pueden borrar trabajos con estado 'abierto'",
+	// Un trabajo completado se archiva, no se borra
+	if estado == "completado" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Los trabajos completados no se borran; usa archivar",
+		})
+		return
+	}
+
+	// Con estudiantes trabajando hay que cancelar antes esos matches
+	if activos > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "El trabajo tiene matches en curso; cancélalos antes de borrarlo",
+			"matches_activos": activos,
 		})
 		return
 	}
 
-	// Borrar el trabajo
-	_, err = db.Exec("DELETE FROM jobs WHERE id = $1 AND estado = 'abierto'", req.ID)
+	// Borrado lógico; publicar_en se limpia para que no se publique solo
+	var eliminadoEn time.Time
+	err = db.QueryRow(`
+		UPDATE jobs
+		SET eliminado_en = NOW(),
+		    publicar_en = NULL,
+		    actualizado_en = NOW()
+		WHERE id = $1 AND eliminado_en IS NULL
+		RETURNING eliminado_en
+	`, req.ID).Scan(&eliminadoEn)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Error al borrar el trabajo",
@@ -84,9 +120,266 @@ func DeleteJob(db *sql.DB, c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Trabajo eliminado correctamente",
+		"message":           "Trabajo eliminado correctamente",
+		"id":                req.ID,
+		"restaurable_hasta": eliminadoEn.AddDate(0, 0, DiasRestauracion).Format(time.RFC3339),
+	})
+}
+
+// -------------------------------
+// GET /protected/trabajos-eliminados  (papelera)
+// -------------------------------
+func GetTrabajosEliminados(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT id, titulo, estado, eliminado_en,
+		       eliminado_en + make_interval(days => $2) AS restaurable_hasta
+		FROM jobs
+		WHERE empleador_id = $1
+		  AND eliminado_en IS NOT NULL
+		  AND purgado_en IS NULL
+		ORDER BY eliminado_en DESC
+	`, userID, DiasRestauracion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener trabajos eliminados", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	type eliminado struct {
+		ID               int    `json:"id"`
+		Titulo           string `json:"titulo"`
+		Estado           string `json:"estado"`
+		EliminadoEn      string `json:"eliminado_en"`
+		RestaurableHasta string `json:"restaurable_hasta"`
+	}
+	lista := []eliminado{}
+	for rows.Next() {
+		var e eliminado
+		var eliminadoEn, hasta time.Time
+		if err := rows.Scan(&e.ID, &e.Titulo, &e.Estado, &eliminadoEn, &hasta); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo trabajos eliminados"})
+			return
+		}
+		e.EliminadoEn = eliminadoEn.Format(time.RFC3339)
+		e.RestaurableHasta = hasta.Format(time.RFC3339)
+		lista = append(lista, e)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"trabajos": lista})
+}
+
+// -------------------------------
+// POST /protected/restaurar-trabajo  { "id": 1 }
+// -------------------------------
+func RestaurarTrabajoHandler(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	var req DeleteJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var estado string
+	err = db.QueryRow(`
+		UPDATE jobs
+		SET eliminado_en = NULL,
+		    actualizado_en = NOW()
+		WHERE id = $1
+		  AND empleador_id = $2
+		  AND eliminado_en IS NOT NULL
+		  AND purgado_en IS NULL
+		  AND eliminado_en > NOW() - make_interval(days => $3)
+		RETURNING estado
+	`, req.ID, userID, DiasRestauracion).Scan(&estado)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No hay un trabajo eliminado restaurable con ese ID"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al restaurar trabajo"})
+		return
+	}
+
+	// Si venció mientras estaba en la papelera, la tarea de expiración
+	// lo pasará a 'expirado' y se puede renovar
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Trabajo restaurado",
 		"id":      req.ID,
+		"estado":  estado,
+	})
+}
+
+// -------------------------------
+// POST /protected/archivar-trabajo  { "id": 1, "archivar": true }
+// Solo para trabajos completados: salen de la lista principal
+// (GET /trabajos_creados?archivados=true los muestra)
+// -------------------------------
+func ArchivarTrabajoHandler(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	var req struct {
+		ID       int   `json:"id"`
+		Archivar *bool `json:"archivar"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	archivar := req.Archivar == nil || *req.Archivar
+
+	var ownerID int
+	var estado string
+	err = db.QueryRow(`
+		SELECT empleador_id, estado FROM jobs WHERE id = $1 AND eliminado_en IS NULL
+	`, req.ID).Scan(&ownerID, &estado)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer trabajo"})
+		return
+	}
+	if ownerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No puedes archivar este trabajo"})
+		return
+	}
+	if estado != "completado" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Solo se pueden archivar trabajos completados"})
+		return
+	}
+
+	if _, err := db.Exec(`
+		UPDATE jobs
+		SET archivado_en = CASE WHEN $2 THEN NOW() END,
+		    actualizado_en = NOW()
+		WHERE id = $1
+	`, req.ID, archivar); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al archivar trabajo"})
+		return
+	}
+
+	mensaje := "Trabajo archivado"
+	if !archivar {
+		mensaje = "Trabajo desarchivado"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":   mensaje,
+		"id":        req.ID,
+		"archivado": archivar,
 	})
 }
 
+// ------------------------------------------------------
+// TAREA: purgar los trabajos que pasaron el plazo de restauración.
+// Sin matches ni valoraciones se borran por completo; con historial
+// se vacía el contenido y se marca purgado_en, conservando la fila
+// que referencian matches_job y las valoraciones.
+// ------------------------------------------------------
+func PurgarTrabajosEliminados(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT j.id,
+		       EXISTS (SELECT 1 FROM matches_job m WHERE m.job_id = j.id)
+		    OR EXISTS (SELECT 1 FROM valoracion_empleador v WHERE v.job_id = j.id)
+		    OR EXISTS (SELECT 1 FROM valoracion_estudiante v WHERE v.job_id = j.id)
+		FROM jobs j
+		WHERE j.eliminado_en IS NOT NULL
+		  AND j.purgado_en IS NULL
+		  AND j.eliminado_en <= NOW() - make_interval(days => $1)
+		LIMIT 500
+	`, DiasRestauracion)
+	if err != nil {
+		return err
+	}
+
+	type candidato struct {
+		id           int
+		conHistorial bool
+	}
+	var candidatos []candidato
+	for rows.Next() {
+		var cand candidato
+		if err := rows.Scan(&cand.id, &cand.conHistorial); err != nil {
+			rows.Close()
+			return err
+		}
+		candidatos = append(candidatos, cand)
+	}
+	rows.Close()
+
+	borrados, vaciados := 0, 0
+	for _, cand := range candidatos {
+		if err := media.BorrarGaleria(db, cand.id); err != nil {
+			log.Printf("Error borrando fotos del job %d: %v", cand.id, err)
+			continue
+		}
+		if err := purgarTrabajo(db, cand.id, cand.conHistorial); err != nil {
+			log.Printf("Error purgando job %d: %v", cand.id, err)
+			continue
+		}
+		if cand.conHistorial {
+			vaciados++
+		} else {
+			borrados++
+		}
+	}
 
+	if borrados+vaciados > 0 {
+		log.Printf("Purga de trabajos: %d borrados, %d vaciados", borrados, vaciados)
+	}
+	return nil
+}
+
+func purgarTrabajo(db *sql.DB, jobID int, conHistorial bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Likes que nunca llegaron a match
+	if _, err := tx.Exec(`
+		DELETE FROM intereses_estudiante ie
+		WHERE ie.job_id = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM matches_job m
+			WHERE m.job_id = ie.job_id AND m.estudiante_id = ie.estudiante_id
+		  )
+	`, jobID); err != nil {
+		return err
+	}
+
+	if conHistorial {
+		_, err = tx.Exec(`
+			UPDATE jobs
+			SET descripcion = '',
+			    requisitos = '',
+			    ubicacion = '',
+			    foto_job = NULL,
+			    purgado_en = NOW()
+			WHERE id = $1
+		`, jobID)
+	} else {
+		_, err = tx.Exec(`DELETE FROM jobs WHERE id = $1`, jobID)
+	}
+	if err != nil {
+		return fmt.Errorf("purgando job %d: %w", jobID, err)
+	}
+
+	return tx.Commit()
+}
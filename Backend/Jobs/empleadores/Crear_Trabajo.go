@@ -3,25 +3,48 @@ package jobsemp
 import (
 	"database/sql"
 	"encoding/base64"
+	"encoding/json"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/media"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/preguntas"
+	"github.com/VinkoRobi2/FlashWorkEC/asistencia"
+	"github.com/VinkoRobi2/FlashWorkEC/categorias"
 	"github.com/gin-gonic/gin"
 )
 
+// Máximo de estudiantes que un solo trabajo puede pedir
+const MaxCupos = 50
+
 type CrearTrabajoRequest struct {
-	Titulo       string `json:"titulo"`
-	Descripcion  string `json:"descripcion"`
-	Ubicacion    string `json:"ubicacion"`
-	PagoEstimado int    `json:"pago_estimado"`
-	Negociable   bool   `json:"negociable"`
-	Requisitos   string `json:"requisitos"`
-	Categoria    string `json:"categoria"`
-	MetodoPago   string `json:"metodo_pago"`
-	Presencial   bool   `json:"presencial"`
+	Titulo       string  `json:"titulo"`
+	Descripcion  string  `json:"descripcion"`
+	Ubicacion    string  `json:"ubicacion"`
+	PagoEstimado float64 `json:"pago_estimado"` // en dólares; compensacion lo reemplaza
+	Negociable   bool    `json:"negociable"`
+	Requisitos   string  `json:"requisitos"`
+	Categoria    string  `json:"categoria"`    // slug, nombre o alias de una categoría activa
+	CategoriaID  int     `json:"categoria_id"` // alternativa a categoria
+	MetodoPago   string  `json:"metodo_pago"`
+	Presencial   bool    `json:"presencial"`
+	// Coordenadas del lugar para validar la asistencia (solo presencial)
+	Latitud     *float64 `json:"latitud"`
+	Longitud    *float64 `json:"longitud"`
+	RadioMetros int      `json:"radio_metros"`
+	Cupos       int      `json:"cupos"`        // cuántos estudiantes necesita (por defecto 1)
+	FechaInicio string   `json:"fecha_inicio"` // cuándo empieza el trabajo (RFC3339 o "2006-01-02T15:04")
+	ExpiraEn    string   `json:"expira_en"`    // opcional: hasta cuándo se aceptan estudiantes
+	Borrador    bool     `json:"borrador"`     // guardar sin publicar
+	PublicarEn  string   `json:"publicar_en"`  // opcional: publicar automáticamente en esta fecha (implica borrador)
+	TemplateID  int      `json:"template_id"`  // opcional: prellenar con una plantilla guardada
+	// Monto en centavos, unidad (hora, turno, tarea, día) y horas estimadas
+	Compensacion *compensacion.Entrada `json:"compensacion"`
+	// Preguntas de filtro que el estudiante responde al dar like
+	Preguntas []preguntas.Pregunta `json:"preguntas"`
 	// 👇 IMPORTANTE: mismo nombre que envías desde el frontend (foto_trabajo_base64)
 	FotoBase64 string `json:"foto_trabajo_base64"`
 }
@@ -35,54 +58,55 @@ func CrearTrabajoHandler(c *gin.Context, db *sql.DB) {
 	}
 	userID := userIDInterface.(int)
 
-	var req CrearTrabajoRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	body, err := c.GetRawData()
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
 		return
 	}
 
-	// Si no es presencial → ubicación vacía
-	if !req.Presencial {
-		req.Ubicacion = ""
+	var req CrearTrabajoRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
 	}
 
-	// -------------------------------------
-	// INSERTAR TRABAJO SIN FOTO
-	// -------------------------------------
-	query := `
-		INSERT INTO jobs 
-		(titulo, descripcion, ubicacion, pago_estimado, negociable, requisitos, categoria, metodo_pago, presencial, empleador_id, estado, creado_en, actualizado_en)
-		VALUES 
-		($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,'abierto',NOW(),NOW())
-		RETURNING id;
-	`
-
-	var trabajoID int
-	err := db.QueryRow(query,
-		req.Titulo,
-		req.Descripcion,
-		req.Ubicacion,
-		req.PagoEstimado,
-		req.Negociable,
-		req.Requisitos,
-		req.Categoria,
-		req.MetodoPago,
-		req.Presencial,
-		userID,
-	).Scan(&trabajoID)
+	// Con template_id se parte de la plantilla y se vuelve a aplicar
+	// el JSON encima: los campos enviados reemplazan a los guardados
+	if req.TemplateID > 0 {
+		plantillaID := req.TemplateID
+		req, err = cargarPlantilla(db, plantillaID, userID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Plantilla no encontrada"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer plantilla", "err": err.Error()})
+			return
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+			return
+		}
+	}
 
+	t, msg, err := validarTrabajo(db, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error al crear trabajo",
-			"err":   err.Error(),
-		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar categoria", "err": err.Error()})
 		return
 	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	req = t.req
+	estado, publicarEn, expiraEn := t.estado, t.publicarEn, t.expiraEn
 
 	// -------------------------------------
-	// SUBIR FOTO BASE64 SI EXISTE
+	// VALIDAR FOTO BASE64 SI EXISTE
 	// -------------------------------------
-	fotoURL := ""
+	// Se valida antes de crear nada: una imagen inválida no deja un
+	// trabajo huérfano
+	var imagen *media.Imagen
 
 	// El frontend está enviando algo tipo: "data:image/png;base64,AAAA..."
 	raw := strings.TrimSpace(req.FotoBase64)
@@ -99,38 +123,221 @@ func CrearTrabajoHandler(c *gin.Context, db *sql.DB) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "foto_trabajo_base64 inválido"})
 			return
 		}
-
-		// Crear carpeta uploads si no existe
-		if err := os.MkdirAll("./uploads", 0755); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo preparar el almacenamiento de imágenes"})
+		imagen, err = media.Validar(data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+	}
+
+	// -------------------------------------
+	// INSERTAR TRABAJO Y FOTO EN UNA TRANSACCIÓN
+	// -------------------------------------
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
 
-		// Nombre final del archivo
-		filename := filepath.Join("./uploads", "job_"+strconv.Itoa(trabajoID)+".png")
+	trabajoID, err := insertarTrabajo(tx, userID, t)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Error al crear trabajo",
+			"err":   err.Error(),
+		})
+		return
+	}
 
-		// Guardar imagen
-		if err := os.WriteFile(filename, data, 0644); err != nil {
+	// La foto inicial entra a la galería como portada (con miniatura);
+	// media.Guardar también actualiza jobs.foto_job
+	fotoURL := ""
+	var foto media.Foto
+	if imagen != nil {
+		foto, err = media.Guardar(tx, trabajoID, imagen, true, c.Request.Host)
+		if err != nil {
+			foto.Descartar()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando imagen"})
 			return
 		}
+		fotoURL = foto.URL
+	}
 
-		// URL pública de la foto
-		fotoURL = "http://" + c.Request.Host + "/uploads/" + "job_" + strconv.Itoa(trabajoID) + ".png"
-
-		// Actualizar job con la imagen
-		if _, err := db.Exec(`UPDATE jobs SET foto_job = $1 WHERE id = $2`, fotoURL, trabajoID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando foto en la BD"})
-			return
-		}
+	if err := tx.Commit(); err != nil {
+		foto.Descartar()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear trabajo", "err": err.Error()})
+		return
 	}
 
 	// -------------------------------------
 	// RESPUESTA FINAL
 	// -------------------------------------
+	mensaje := "Trabajo creado exitosamente"
+	if estado == "borrador" {
+		mensaje = "Borrador guardado"
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
-		"mensaje":    "Trabajo creado exitosamente",
-		"trabajo_id": trabajoID,
-		"foto_job":   fotoURL,
+		"mensaje":     mensaje,
+		"estado":      estado,
+		"publicar_en": publicarEn,
+		"trabajo_id":  trabajoID,
+		"foto_job":    fotoURL,
+		"cupos":       req.Cupos,
+		"categoria":   req.Categoria,
+		"expira_en":   expiraEn,
+		// Avisos que no bloquean, p. ej. pago por debajo del mínimo legal por hora
+		"compensacion": t.comp,
+		"advertencias": t.comp.Advertencias(),
 	})
-}
\ No newline at end of file
+}
+
+// trabajoValidado tiene los valores de un CrearTrabajoRequest listos para insertar
+type trabajoValidado struct {
+	req                               CrearTrabajoRequest
+	categoriaID                       *int
+	comp                              compensacion.Compensacion
+	fechaInicio, expiraEn, publicarEn *time.Time
+	estado                            string
+}
+
+// validarTrabajo aplica las reglas de creación. Devuelve un mensaje para el
+// usuario si el request no es válido, o err si falló la base de datos.
+func validarTrabajo(db *sql.DB, req CrearTrabajoRequest) (*trabajoValidado, string, error) {
+	t := &trabajoValidado{}
+
+	// Categoría: debe existir en la taxonomía; se guarda el id y el nombre oficial
+	categoria, err := categorias.Elegir(db, req.CategoriaID, req.Categoria)
+	if err == categorias.ErrNoEncontrada {
+		return nil, "categoria inválida", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if categoria != nil {
+		req.Categoria = categoria.Nombre
+		t.categoriaID = &categoria.ID
+	}
+
+	// Cupos: por defecto 1 estudiante
+	if req.Cupos == 0 {
+		req.Cupos = 1
+	}
+	if req.Cupos < 1 || req.Cupos > MaxCupos {
+		return nil, "cupos debe estar entre 1 y " + strconv.Itoa(MaxCupos), nil
+	}
+
+	// Fechas: el job vence en expira_en o, si no hay, en fecha_inicio
+	if t.fechaInicio, err = parseFechaOpcional(req.FechaInicio); err != nil {
+		return nil, "fecha_inicio inválida", nil
+	}
+	if t.expiraEn, err = parseFechaOpcional(req.ExpiraEn); err != nil {
+		return nil, "expira_en inválida", nil
+	}
+	if t.fechaInicio != nil && t.fechaInicio.Before(time.Now()) {
+		return nil, "fecha_inicio debe ser futura", nil
+	}
+	if t.expiraEn != nil && t.expiraEn.Before(time.Now()) {
+		return nil, "expira_en debe ser futura", nil
+	}
+	if t.publicarEn, err = parseFechaOpcional(req.PublicarEn); err != nil {
+		return nil, "publicar_en inválida", nil
+	}
+	if t.publicarEn != nil && t.publicarEn.Before(time.Now()) {
+		return nil, "publicar_en debe ser futura", nil
+	}
+
+	// Con publicar_en el job queda como borrador hasta esa fecha
+	t.estado = "abierto"
+	if req.Borrador || t.publicarEn != nil {
+		t.estado = "borrador"
+	}
+
+	// La expiración por defecto de un borrador se calcula al publicarlo
+	if t.fechaInicio == nil && t.expiraEn == nil && t.estado == "abierto" {
+		exp := time.Now().AddDate(0, 0, DiasExpiracionDefault)
+		t.expiraEn = &exp
+	}
+
+	// Si no es presencial → ubicación vacía
+	if !req.Presencial {
+		req.Ubicacion = ""
+		req.Latitud, req.Longitud, req.RadioMetros = nil, nil, 0
+	}
+	if msg := asistencia.ValidarCoordenadas(req.Latitud, req.Longitud); msg != "" {
+		return nil, msg, nil
+	}
+	radio, msg := asistencia.NormalizarRadio(req.RadioMetros)
+	if msg != "" {
+		return nil, msg, nil
+	}
+	req.RadioMetros = radio
+
+	// Compensación: pago_estimado sigue funcionando como monto por tarea
+	comp, msg := compensacion.Nueva(compensacion.DesdeDecimal(req.PagoEstimado), "", nil).Aplicar(req.Compensacion)
+	if msg != "" {
+		return nil, msg, nil
+	}
+	// Los borradores lo revisan al publicarse
+	if t.estado == "abierto" {
+		if msg := comp.ValidarPublicacion(); msg != "" {
+			return nil, msg, nil
+		}
+	}
+	t.comp = comp
+	req.PagoEstimado = comp.Monto
+
+	if msg := preguntas.Validar(req.Preguntas); msg != "" {
+		return nil, msg, nil
+	}
+
+	t.req = req
+	return t, "", nil
+}
+
+// insertarTrabajo crea el job (sin foto) con sus preguntas; q puede ser *sql.DB o *sql.Tx
+func insertarTrabajo(q preguntas.Ejecutor, empleadorID int, t *trabajoValidado) (int, error) {
+	query := `
+		INSERT INTO jobs 
+		(titulo, descripcion, ubicacion, pago_estimado, negociable, requisitos, categoria, metodo_pago, presencial, empleador_id, cupos, fecha_inicio, expira_en, publicar_en, estado, publicado_en, categoria_id, moneda, unidad_pago, horas_estimadas, latitud, longitud, radio_metros, creado_en, actualizado_en)
+		VALUES 
+		($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15::varchar,CASE WHEN $15::varchar = 'abierto' THEN NOW() END,$16,$17,$18,$19,$20,$21,$22,NOW(),NOW())
+		RETURNING id;
+	`
+
+	var trabajoID int
+	err := q.QueryRow(query,
+		t.req.Titulo,
+		t.req.Descripcion,
+		t.req.Ubicacion,
+		t.comp.Decimal(),
+		t.req.Negociable,
+		t.req.Requisitos,
+		t.req.Categoria,
+		t.req.MetodoPago,
+		t.req.Presencial,
+		empleadorID,
+		t.req.Cupos,
+		t.fechaInicio,
+		t.expiraEn,
+		t.publicarEn,
+		t.estado,
+		t.categoriaID,
+		t.comp.Moneda,
+		t.comp.Unidad,
+		t.comp.HorasEstimadas,
+		t.req.Latitud,
+		t.req.Longitud,
+		t.req.RadioMetros,
+	).Scan(&trabajoID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(t.req.Preguntas) > 0 {
+		if err := preguntas.Guardar(q, trabajoID, t.req.Preguntas); err != nil {
+			return 0, err
+		}
+	}
+	return trabajoID, nil
+}
@@ -3,8 +3,14 @@ package jobsemp
 import (
 	"database/sql"
 	"net/http"
+	"strconv"
+	"time"
 
-
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/cupos"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/versiones"
+	"github.com/VinkoRobi2/FlashWorkEC/asistencia"
+	"github.com/VinkoRobi2/FlashWorkEC/categorias"
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,12 +18,22 @@ type EditJobRequest struct {
 	Titulo      *string  `json:"titulo"`
 	Descripcion *string  `json:"descripcion"`
 	Categoria   *string  `json:"categoria"`
+	CategoriaID *int     `json:"categoria_id"`
 	Ubicacion   *string  `json:"ubicacion"`
 	Pago        *float64 `json:"pago_estimado"`
 	Negociable  *bool    `json:"negociable"`
 	Requisitos  *string  `json:"requisitos"`
 	Habilidades *string  `json:"habilidades"`
-	ID          int      `json:"id"`
+	Cupos       *int     `json:"cupos"`
+	FechaInicio *string  `json:"fecha_inicio"`
+	ExpiraEn    *string  `json:"expira_en"`
+	// Coordenadas del lugar (trabajos presenciales)
+	Latitud     *float64 `json:"latitud"`
+	Longitud    *float64 `json:"longitud"`
+	RadioMetros *int     `json:"radio_metros"`
+	// Solo se cambian los campos enviados; horas_estimadas 0 las borra
+	Compensacion *compensacion.Entrada `json:"compensacion"`
+	ID           int                   `json:"id"`
 }
 
 func EditJob(db *sql.DB, c *gin.Context) {
@@ -46,13 +62,23 @@ func EditJob(db *sql.DB, c *gin.Context) {
 	var negociable bool
 	var ownerID int
 	var estado string
+	var cuposActual, cuposOcupados int
+	var fechaInicio, expiraEn sql.NullTime
+	var categoriaID sql.NullInt64
+	var unidadPago string
+	var horasEstimadas sql.NullFloat64
+	var presencial bool
+	var latitud, longitud sql.NullFloat64
+	var radio int
 
 	err = db.QueryRow(`
-        SELECT titulo, descripcion, categoria, ubicacion, pago_estimado, negociable, requisitos, habilidades, empleador_id, estado
-        FROM jobs WHERE id = $1
+        SELECT titulo, descripcion, categoria, ubicacion, pago_estimado, negociable, requisitos, habilidades, empleador_id, estado, cupos, cupos_ocupados, fecha_inicio, expira_en, categoria_id, unidad_pago, horas_estimadas, presencial, latitud, longitud, radio_metros
+        FROM jobs WHERE id = $1 AND eliminado_en IS NULL
     `, req.ID).Scan(
 		&titulo, &descripcion, &categoria, &ubicacion, &pago,
 		&negociable, &requisitos, &habilidades, &ownerID, &estado,
+		&cuposActual, &cuposOcupados, &fechaInicio, &expiraEn, &categoriaID,
+		&unidadPago, &horasEstimadas, &presencial, &latitud, &longitud, &radio,
 	)
 
 	if err == sql.ErrNoRows {
@@ -71,12 +97,37 @@ func EditJob(db *sql.DB, c *gin.Context) {
 		return
 	}
 
-	// Solo se puede editar si está abierto
-	if estado != "abierto" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Solo se pueden editar trabajos con estado 'abierto'"})
+	// Solo se puede editar si está abierto (o cubierto, para sumar cupos)
+	if estado != "abierto" && estado != "cubierto" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo se pueden editar trabajos con estado 'abierto' o 'cubierto'"})
 		return
 	}
 
+	// Foto de los campos editables, para guardar solo lo que cambió
+	campos := func() map[string]interface{} {
+		return map[string]interface{}{
+			"titulo":          titulo,
+			"descripcion":     descripcion,
+			"categoria":       categoria,
+			"categoria_id":    categoriaID,
+			"ubicacion":       ubicacion,
+			"pago_estimado":   pago,
+			"unidad_pago":     unidadPago,
+			"horas_estimadas": horasEstimadas,
+			"negociable":      negociable,
+			"requisitos":      requisitos,
+			"habilidades":     habilidades,
+			"cupos":           cuposActual,
+			"fecha_inicio":    fechaInicio,
+			"expira_en":       expiraEn,
+			"latitud":         latitud,
+			"longitud":        longitud,
+			"radio_metros":    radio,
+		}
+	}
+	antes := campos()
+	pagoAnterior := pago
+
 	// Reemplazar solo campos presentes en el JSON
 	if req.Titulo != nil {
 		titulo = *req.Titulo
@@ -84,14 +135,65 @@ func EditJob(db *sql.DB, c *gin.Context) {
 	if req.Descripcion != nil {
 		descripcion = *req.Descripcion
 	}
-	if req.Categoria != nil {
-		categoria = *req.Categoria
+	if req.Categoria != nil || req.CategoriaID != nil {
+		id, texto := 0, ""
+		if req.CategoriaID != nil {
+			id = *req.CategoriaID
+		}
+		if req.Categoria != nil {
+			texto = *req.Categoria
+		}
+		cat, err := categorias.Elegir(db, id, texto)
+		if err == categorias.ErrNoEncontrada || (err == nil && cat == nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "categoria inválida"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar categoria"})
+			return
+		}
+		categoria = cat.Nombre
+		categoriaID = sql.NullInt64{Int64: int64(cat.ID), Valid: true}
 	}
 	if req.Ubicacion != nil {
 		ubicacion = *req.Ubicacion
 	}
-	if req.Pago != nil {
-		pago = *req.Pago
+	// Las coordenadas se envían juntas y se validan como al crear
+	if req.Latitud != nil || req.Longitud != nil {
+		if !presencial {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "solo los trabajos presenciales tienen coordenadas"})
+			return
+		}
+		if msg := asistencia.ValidarCoordenadas(req.Latitud, req.Longitud); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		latitud = sql.NullFloat64{Float64: *req.Latitud, Valid: true}
+		longitud = sql.NullFloat64{Float64: *req.Longitud, Valid: true}
+	}
+	if req.RadioMetros != nil {
+		r, msg := asistencia.NormalizarRadio(*req.RadioMetros)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		radio = r
+	}
+	// Compensación: pago_estimado (en dólares) y compensacion se combinan
+	entrada := compensacion.ConPago(req.Compensacion, req.Pago)
+	comp, msg := compensacion.DesdeBD(pago, unidadPago, horasEstimadas).Aplicar(entrada)
+	if msg == "" && entrada != nil {
+		// El trabajo ya está publicado
+		msg = comp.ValidarPublicacion()
+	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	pago, unidadPago = comp.Monto, comp.Unidad
+	horasEstimadas = sql.NullFloat64{}
+	if comp.HorasEstimadas != nil {
+		horasEstimadas = sql.NullFloat64{Float64: *comp.HorasEstimadas, Valid: true}
 	}
 	if req.Negociable != nil {
 		negociable = *req.Negociable
@@ -102,9 +204,97 @@ func EditJob(db *sql.DB, c *gin.Context) {
 	if req.Habilidades != nil {
 		habilidades = *req.Habilidades
 	}
+	if req.Cupos != nil {
+		if *req.Cupos < 1 || *req.Cupos > MaxCupos {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cupos debe estar entre 1 y " + strconv.Itoa(MaxCupos)})
+			return
+		}
+		// No se puede bajar por debajo de los estudiantes ya contratados
+		if *req.Cupos < cuposOcupados {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":          "No puedes tener menos cupos que estudiantes ya contratados",
+				"cupos_ocupados": cuposOcupados,
+			})
+			return
+		}
+		cuposActual = *req.Cupos
+	}
+	// Los trabajos anteriores a la expiración pueden no tener fechas; solo
+	// se exige una si el trabajo ya la tenía
+	teniaFecha := fechaInicio.Valid || expiraEn.Valid
+
+	// Fechas: string vacío borra el valor
+	if req.FechaInicio != nil {
+		t, err := parseFechaOpcional(*req.FechaInicio)
+		if err != nil || (t != nil && t.Before(time.Now())) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fecha_inicio inválida o en el pasado"})
+			return
+		}
+		fechaInicio = sql.NullTime{}
+		if t != nil {
+			fechaInicio = sql.NullTime{Time: *t, Valid: true}
+		}
+	}
+	if req.ExpiraEn != nil {
+		t, err := parseFechaOpcional(*req.ExpiraEn)
+		if err != nil || (t != nil && t.Before(time.Now())) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expira_en inválida o en el pasado"})
+			return
+		}
+		expiraEn = sql.NullTime{}
+		if t != nil {
+			expiraEn = sql.NullTime{Time: *t, Valid: true}
+		}
+	}
+	if teniaFecha && !fechaInicio.Valid && !expiraEn.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El trabajo necesita fecha_inicio o expira_en"})
+		return
+	}
+
+	cambios := versiones.Diferencias(antes, campos())
+	if len(cambios) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "No hay cambios que guardar",
+			"id":      req.ID,
+		})
+		return
+	}
+
+	// Con contratados activos el pago no cambia sin su aceptación:
+	// se guarda como propuesta y el resto de la edición sí se aplica
+	var activos []versiones.MatchActivo
+	pagoPropuesto := pago
+	_, cambiaPago := cambios["pago_estimado"]
+	_, cambiaUnidad := cambios["unidad_pago"]
+	if cambiaPago || cambiaUnidad {
+		activos, err = versiones.MatchesActivos(db, req.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al revisar matches"})
+			return
+		}
+		// Las propuestas solo cambian el monto; la unidad no se toca con contratados
+		if cambiaUnidad && len(activos) > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":       "No puedes cambiar la unidad de pago con estudiantes contratados",
+				"contratados": len(activos),
+			})
+			return
+		}
+		if len(activos) > 0 {
+			pago = pagoAnterior
+			delete(cambios, "pago_estimado")
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
 
 	// Actualizar en BD
-	_, err = db.Exec(`
+	_, err = tx.Exec(`
         UPDATE jobs SET
             titulo = $1,
             descripcion = $2,
@@ -114,18 +304,78 @@ func EditJob(db *sql.DB, c *gin.Context) {
             negociable = $6,
             requisitos = $7,
             habilidades = $8,
+            cupos = $9,
+            fecha_inicio = $10,
+            expira_en = $11,
+            aviso_expiracion_en = CASE WHEN $12::boolean THEN NULL ELSE aviso_expiracion_en END,
+            categoria_id = $13,
+            unidad_pago = $14,
+            horas_estimadas = $15,
+            latitud = $16,
+            longitud = $17,
+            radio_metros = $18,
             actualizado_en = NOW()
-        WHERE id = $9
+        WHERE id = $19
     `, titulo, descripcion, categoria, ubicacion, pago,
-		negociable, requisitos, habilidades, req.ID)
+		negociable, requisitos, habilidades, cuposActual,
+		fechaInicio, expiraEn, req.FechaInicio != nil || req.ExpiraEn != nil, categoriaID,
+		unidadPago, horasEstimadas, latitud, longitud, radio, req.ID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar trabajo"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	version := 0
+	if len(cambios) > 0 {
+		version, err = versiones.Registrar(tx, req.ID, userID, versiones.MotivoEdicion, cambios)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar versión", "err": err.Error()})
+			return
+		}
+	}
+	propuestaID := 0
+	if len(activos) > 0 {
+		propuestaID, err = versiones.ProponerPago(tx, req.ID, userID, pagoAnterior, pagoPropuesto, activos)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al proponer cambio de pago", "err": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar trabajo"})
+		return
+	}
+
+	// Con más cupos un job cubierto vuelve a abrirse
+	if req.Cupos != nil {
+		if _, err := cupos.Recalcular(db, req.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error actualizando cupos"})
+			return
+		}
+	}
+
+	versiones.NotificarCambios(db, req.ID, titulo, version, cambios, nil)
+
+	resp := gin.H{
 		"message": "Trabajo actualizado exitosamente",
 		"id":      req.ID,
-	})
+		"version": version,
+		"cambios": cambios,
+		// Avisos sobre la compensación publicada (el pago propuesto aún no rige)
+		"compensacion": compensacion.DesdeBD(pago, unidadPago, horasEstimadas),
+		"advertencias": comp.Advertencias(),
+	}
+	if propuestaID > 0 {
+		versiones.NotificarPropuesta(db, propuestaID, req.ID, titulo, pagoAnterior, pagoPropuesto, activos)
+		resp["cambio_pago_pendiente"] = gin.H{
+			"propuesta_id": propuestaID,
+			"pago_actual":  pagoAnterior,
+			"pago_nuevo":   pagoPropuesto,
+			"contratados":  len(activos),
+			"mensaje":      "El nuevo pago se aplicará cuando todos los estudiantes contratados lo acepten",
+		}
+	}
+	c.JSON(http.StatusOK, resp)
 }
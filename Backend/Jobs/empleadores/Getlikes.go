@@ -3,11 +3,19 @@ package jobsemp
 import (
 	"database/sql"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/preguntas"
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 )
 
+// GET /protected/ver-likes-empleador
+// Filtros opcionales:
+//   ?job_id=5
+//   ?descartados=excluir|incluir|solo  (por defecto se excluyen los descartados por sus respuestas)
+//   ?respuesta=12:si&respuesta=13:Fin de semana  (pregunta_id:respuesta, todas deben cumplirse)
 func GetLikesDeEstudiantesParaEmpleador(c *gin.Context, db *sql.DB) {
 
 	// Obtener empleador_id desde token
@@ -18,6 +26,27 @@ func GetLikesDeEstudiantesParaEmpleador(c *gin.Context, db *sql.DB) {
 	}
 	empleadorID := userIDInterface.(int)
 
+	jobFiltro, _ := strconv.Atoi(c.Query("job_id"))
+	descartados := c.DefaultQuery("descartados", "excluir")
+	if descartados != "excluir" && descartados != "incluir" && descartados != "solo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "descartados debe ser excluir, incluir o solo"})
+		return
+	}
+	filtros := map[int]string{}
+	for _, f := range c.QueryArray("respuesta") {
+		idStr, valor, ok := strings.Cut(f, ":")
+		id, err := strconv.Atoi(idStr)
+		if !ok || err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "respuesta debe ser pregunta_id:valor"})
+			return
+		}
+		valor = strings.ToLower(strings.TrimSpace(valor))
+		if valor == "sí" {
+			valor = "si"
+		}
+		filtros[id] = valor
+	}
+
 	query := `
 		SELECT 
 			e.id,
@@ -30,15 +59,18 @@ func GetLikesDeEstudiantesParaEmpleador(c *gin.Context, db *sql.DB) {
 			e.links,
 			e.universidad,
 			e.ciudad,
-			ie.job_id
+			ie.job_id,
+			COALESCE(ie.descartado, false)
 		FROM intereses_estudiante ie
 		JOIN estudiantes e ON e.id = ie.estudiante_id
 		JOIN jobs t ON t.id = ie.job_id
 		WHERE t.empleador_id = $1
-		  AND ie.interesado = true;
+		  AND ie.interesado = true
+		  AND t.eliminado_en IS NULL
+		  AND ($2 = 0 OR ie.job_id = $2);
 	`
 
-	rows, err := db.Query(query, empleadorID)
+	rows, err := db.Query(query, empleadorID, jobFiltro)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo intereses", "err": err.Error()})
 		return
@@ -58,6 +90,9 @@ func GetLikesDeEstudiantesParaEmpleador(c *gin.Context, db *sql.DB) {
 		Ciudad         string   `json:"ciudad"`
 		JobID          int      `json:"job_id"`
 		Valoracion     float64  `json:"valoracion"`
+
+		Respuestas []preguntas.RespuestaVista `json:"respuestas"`
+		Descartado bool                       `json:"descartado"`
 	}
 
 	var lista []EstudianteLike
@@ -78,6 +113,7 @@ func GetLikesDeEstudiantesParaEmpleador(c *gin.Context, db *sql.DB) {
 			&e.Universidad,
 			&e.Ciudad,
 			&e.JobID,
+			&e.Descartado,
 		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo datos", "err": err.Error()})
@@ -120,6 +156,53 @@ func GetLikesDeEstudiantesParaEmpleador(c *gin.Context, db *sql.DB) {
 		lista = append(lista, e)
 	}
 
+	// =====================================
+	// RESPUESTAS A LAS PREGUNTAS Y FILTROS
+	// =====================================
+	var jobIDs []int
+	vistos := map[int]bool{}
+	for _, e := range lista {
+		if !vistos[e.JobID] {
+			vistos[e.JobID] = true
+			jobIDs = append(jobIDs, e.JobID)
+		}
+	}
+	respuestas, err := preguntas.RespuestasDeJobs(db, jobIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo respuestas", "err": err.Error()})
+		return
+	}
+
+	filtrada := []EstudianteLike{}
+	for _, e := range lista {
+		if (descartados == "excluir" && e.Descartado) || (descartados == "solo" && !e.Descartado) {
+			continue
+		}
+		e.Respuestas = respuestas[preguntas.Clave{EstudianteID: e.ID, JobID: e.JobID}]
+		if e.Respuestas == nil {
+			e.Respuestas = []preguntas.RespuestaVista{}
+		}
+
+		cumple := true
+		for preguntaID, valor := range filtros {
+			encontrada := false
+			for _, r := range e.Respuestas {
+				if r.PreguntaID == preguntaID && strings.ToLower(r.Respuesta) == valor {
+					encontrada = true
+					break
+				}
+			}
+			if !encontrada {
+				cumple = false
+				break
+			}
+		}
+		if cumple {
+			filtrada = append(filtrada, e)
+		}
+	}
+	lista = filtrada
+
 	c.JSON(http.StatusOK, gin.H{
 		"total":     len(lista),
 		"intereses": lista,
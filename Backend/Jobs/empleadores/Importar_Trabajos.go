@@ -0,0 +1,379 @@
+package jobsemp
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// Importación masiva: un CSV o XLSX con una fila por trabajo y los mismos
+// nombres de campo que CrearTrabajoRequest en la primera fila. Cada fila
+// se valida igual que en /crear-trabajo; las válidas se crean juntas en
+// una transacción y las demás vuelven con sus errores.
+const (
+	MaxFilasImportacion = 200
+	MaxBytesImportacion = 2 << 20 // 2 MB
+)
+
+// camposImportacion son los campos de CrearTrabajoRequest que se pueden importar
+var camposImportacion = []string{
+	"titulo", "descripcion", "ubicacion", "pago_estimado", "unidad_pago", "horas_estimadas",
+	"negociable", "requisitos", "categoria", "categoria_id", "metodo_pago", "presencial", "cupos", "fecha_inicio",
+	"expira_en", "borrador", "publicar_en", "template_id",
+}
+
+func esCampoImportable(col string) bool {
+	for _, campo := range camposImportacion {
+		if campo == col {
+			return true
+		}
+	}
+	return false
+}
+
+var columnasFecha = map[string]bool{"fecha_inicio": true, "expira_en": true, "publicar_en": true}
+
+type FilaImportada struct {
+	Fila      int      `json:"fila"`
+	Titulo    string   `json:"titulo"`
+	Valida    bool     `json:"valida"`
+	Errores   []string `json:"errores,omitempty"`
+	TrabajoID int      `json:"trabajo_id,omitempty"`
+	Estado    string   `json:"estado,omitempty"`
+	// Avisos de compensación, p. ej. pago por debajo del mínimo por hora
+	Advertencias []string `json:"advertencias,omitempty"`
+}
+
+// leerFilas devuelve las filas del archivo (la primera es el encabezado)
+func leerFilas(nombre string, data []byte) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(nombre)) {
+	case ".csv":
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM de Excel
+		r := csv.NewReader(bytes.NewReader(data))
+		// Excel en español exporta con punto y coma
+		primera, _, _ := bytes.Cut(data, []byte("\n"))
+		if bytes.Count(primera, []byte(";")) > bytes.Count(primera, []byte(",")) {
+			r.Comma = ';'
+		}
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		return r.ReadAll()
+	case ".xlsx":
+		f, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		hoja := f.GetSheetName(0)
+		// Valores crudos: las fechas llegan como número de serie de Excel
+		filas, err := f.GetRows(hoja, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, err
+		}
+		if len(filas) > 0 {
+			fechas := map[int]bool{}
+			for i, col := range filas[0] {
+				if columnasFecha[strings.ToLower(strings.TrimSpace(col))] {
+					fechas[i] = true
+				}
+			}
+			for _, fila := range filas[1:] {
+				for i := range fila {
+					if !fechas[i] {
+						continue
+					}
+					if serial, err := strconv.ParseFloat(fila[i], 64); err == nil {
+						if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+							fila[i] = t.Format("2006-01-02T15:04")
+						}
+					}
+				}
+			}
+		}
+		return filas, nil
+	}
+	return nil, fmt.Errorf("formato no soportado, usa .csv o .xlsx")
+}
+
+func parseBoolCelda(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "si", "sí", "s", "x", "yes":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+// filaARequest arma el request de una fila. Con template_id parte de la
+// plantilla y las celdas con valor la reemplazan, igual que en el JSON.
+func filaARequest(db *sql.DB, empleadorID int, valores map[string]string) (CrearTrabajoRequest, []string, error) {
+	var req CrearTrabajoRequest
+	var errores []string
+
+	entero := func(campo string) int {
+		n, err := strconv.Atoi(strings.TrimSpace(valores[campo]))
+		if err != nil {
+			errores = append(errores, campo+" debe ser un número entero")
+		}
+		return n
+	}
+	booleano := func(campo string) bool {
+		b, err := parseBoolCelda(strings.TrimSpace(valores[campo]))
+		if err != nil {
+			errores = append(errores, campo+" debe ser sí/no o true/false")
+		}
+		return b
+	}
+	// La unidad y las horas van en la compensación; el monto sigue en pago_estimado
+	entrada := func() *compensacion.Entrada {
+		if req.Compensacion == nil {
+			req.Compensacion = &compensacion.Entrada{}
+		}
+		return req.Compensacion
+	}
+
+	if v := valores["template_id"]; v != "" {
+		plantillaID := entero("template_id")
+		if plantillaID > 0 {
+			var err error
+			req, err = cargarPlantilla(db, plantillaID, empleadorID)
+			if err == sql.ErrNoRows {
+				errores = append(errores, "plantilla no encontrada")
+			} else if err != nil {
+				return req, nil, err
+			}
+			req.TemplateID = plantillaID
+		}
+	}
+
+	for _, campo := range camposImportacion {
+		v := valores[campo]
+		if v == "" {
+			continue
+		}
+		switch campo {
+		case "titulo":
+			req.Titulo = v
+		case "descripcion":
+			req.Descripcion = v
+		case "ubicacion":
+			req.Ubicacion = v
+		case "pago_estimado":
+			// Se acepta "15", "15.00" o "15,00"
+			pago, err := strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+			if err != nil || pago < 0 {
+				errores = append(errores, "pago_estimado inválido")
+			}
+			req.PagoEstimado = pago
+		case "unidad_pago":
+			entrada().Unidad = v
+		case "horas_estimadas":
+			horas, err := strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+			if err != nil {
+				errores = append(errores, "horas_estimadas inválido")
+			}
+			entrada().HorasEstimadas = &horas
+		case "negociable":
+			req.Negociable = booleano(campo)
+		case "requisitos":
+			req.Requisitos = v
+		case "categoria":
+			req.Categoria = v
+		case "categoria_id":
+			req.CategoriaID = entero(campo)
+		case "metodo_pago":
+			req.MetodoPago = v
+		case "presencial":
+			req.Presencial = booleano(campo)
+		case "cupos":
+			req.Cupos = entero(campo)
+		case "fecha_inicio":
+			req.FechaInicio = v
+		case "expira_en":
+			req.ExpiraEn = v
+		case "borrador":
+			req.Borrador = booleano(campo)
+		case "publicar_en":
+			req.PublicarEn = v
+		}
+	}
+
+	if strings.TrimSpace(req.Titulo) == "" {
+		errores = append(errores, "titulo es requerido")
+	}
+	return req, errores, nil
+}
+
+// -------------------------------
+// POST /protected/importar-trabajos   (multipart)
+//
+//	archivo: .csv o .xlsx
+//	publicar: true para publicar las filas válidas (por defecto quedan como borrador)
+//	dry_run: true para solo validar, sin crear nada
+//
+// -------------------------------
+func ImportarTrabajosHandler(c *gin.Context, db *sql.DB) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	archivo, err := c.FormFile("archivo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archivo es requerido"})
+		return
+	}
+	if archivo.Size > MaxBytesImportacion {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El archivo supera los 2 MB"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultPostForm("dry_run", c.Query("dry_run")))
+	publicar, _ := strconv.ParseBool(c.DefaultPostForm("publicar", c.Query("publicar")))
+
+	f, err := archivo.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, MaxBytesImportacion+1))
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+
+	filas, err := leerFilas(archivo.Filename, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Archivo inválido", "err": err.Error()})
+		return
+	}
+	if len(filas) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El archivo no tiene filas de trabajos"})
+		return
+	}
+	if len(filas)-1 > MaxFilasImportacion {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Máximo " + strconv.Itoa(MaxFilasImportacion) + " trabajos por archivo"})
+		return
+	}
+
+	// Encabezado: nombres de campo de CrearTrabajoRequest
+	encabezado := make([]string, len(filas[0]))
+	var desconocidas []string
+	for i, col := range filas[0] {
+		col = strings.ToLower(strings.TrimSpace(col))
+		encabezado[i] = col
+		if col != "" && !esCampoImportable(col) {
+			desconocidas = append(desconocidas, col)
+		}
+	}
+	if len(desconocidas) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Columnas desconocidas: " + strings.Join(desconocidas, ", ")})
+		return
+	}
+
+	// Validar todas las filas antes de crear nada
+	resultado := []FilaImportada{}
+	var validos []*trabajoValidado
+	var indices []int
+	for n, fila := range filas[1:] {
+		valores := map[string]string{}
+		vacia := true
+		for i, v := range fila {
+			if i < len(encabezado) && encabezado[i] != "" {
+				valores[encabezado[i]] = strings.TrimSpace(v)
+				if valores[encabezado[i]] != "" {
+					vacia = false
+				}
+			}
+		}
+		if vacia {
+			continue
+		}
+
+		r := FilaImportada{Fila: n + 2} // la fila 1 es el encabezado
+		req, errores, err := filaARequest(db, userID, valores)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer plantilla", "err": err.Error()})
+			return
+		}
+		r.Titulo = req.Titulo
+		if !publicar {
+			req.Borrador = true
+		}
+
+		if len(errores) == 0 {
+			t, msg, err := validarTrabajo(db, req)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar categoria", "err": err.Error()})
+				return
+			}
+			if msg != "" {
+				errores = append(errores, msg)
+			} else {
+				r.Estado = t.estado
+				r.Advertencias = t.comp.Advertencias()
+				validos = append(validos, t)
+				indices = append(indices, len(resultado))
+			}
+		}
+		r.Errores = errores
+		r.Valida = len(errores) == 0
+		resultado = append(resultado, r)
+	}
+
+	reporte := gin.H{
+		"dry_run":     dryRun,
+		"total":       len(resultado),
+		"validas":     len(validos),
+		"con_errores": len(resultado) - len(validos),
+		"filas":       resultado,
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, reporte)
+		return
+	}
+	if len(validos) == 0 {
+		reporte["error"] = "Ninguna fila es válida"
+		c.JSON(http.StatusBadRequest, reporte)
+		return
+	}
+
+	// Todas las filas válidas se crean o ninguna
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	for i, t := range validos {
+		id, err := insertarTrabajo(tx, userID, t)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Error al crear trabajo de la fila " + strconv.Itoa(resultado[indices[i]].Fila),
+				"err":   err.Error(),
+			})
+			return
+		}
+		resultado[indices[i]].TrabajoID = id
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear trabajos"})
+		return
+	}
+
+	reporte["creados"] = len(validos)
+	c.JSON(http.StatusCreated, reporte)
+}
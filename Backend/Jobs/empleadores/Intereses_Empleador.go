@@ -7,6 +7,8 @@ import (
 	"database/sql"
 	"net/http"
 
+	"github.com/VinkoRobi2/FlashWorkEC/emparejamiento"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,7 +18,7 @@ type InteresEmpleadorRequest struct {
 	JobID        int  `json:"job_id"`
 }
 
-func GuardarInteresEmpleadorHandler(c *gin.Context, db *sql.DB) {
+func GuardarInteresEmpleadorHandler(c *gin.Context, db *sql.DB, motor *emparejamiento.Servicio) {
 
 	userIDInterface, ok := c.Get("userID")
 	if !ok {
@@ -37,53 +39,39 @@ func GuardarInteresEmpleadorHandler(c *gin.Context, db *sql.DB) {
 	}
 
 	// -------------------------------------------
-	// GUARDAR INTERÉS DEL EMPLEADOR
+	// GUARDAR INTERÉS Y EVALUAR MATCH (AMBOS DIERON LIKE)
+	// El motor verifica dueño y cupos con el job bloqueado
 	// -------------------------------------------
-	query := `
-		INSERT INTO intereses_empleador (empleador_id, estudiante_id, job_id, interesado)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (empleador_id, estudiante_id, job_id)
-		DO UPDATE SET interesado = EXCLUDED.interesado, creado_en = NOW();
-	`
-
-	_, err := db.Exec(query, empleadorID, req.EstudianteID, req.JobID, req.Interesado)
-	if err != nil {
+	res, err := motor.Registrar(emparejamiento.Interes{
+		Rol:          notificaciones.RolEmpleador,
+		UsuarioID:    empleadorID,
+		EstudianteID: req.EstudianteID,
+		JobID:        req.JobID,
+		Interesado:   req.Interesado,
+	})
+	switch err {
+	case nil:
+	case emparejamiento.ErrNoEncontrado:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo no encontrado"})
+		return
+	case emparejamiento.ErrPermiso:
+		c.JSON(http.StatusForbidden, gin.H{"error": "No puedes responder likes de este trabajo"})
+		return
+	case emparejamiento.ErrBorrador:
+		c.JSON(http.StatusConflict, gin.H{"error": "El trabajo todavía es un borrador"})
+		return
+	case emparejamiento.ErrSinCupos:
+		c.JSON(http.StatusConflict, gin.H{"error": "Todos los cupos de este trabajo ya están ocupados"})
+		return
+	default:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar interés", "err": err.Error()})
 		return
 	}
 
-	// -------------------------------------------
-	// LÓGICA DE MATCH (AMBOS DIERON LIKE)
-	// -------------------------------------------
-	if req.Interesado {
-
-		var interesEstudiante bool
-		err = db.QueryRow(`
-			SELECT interesado 
-			FROM intereses_estudiante
-			WHERE estudiante_id = $1 AND job_id = $2
-		`, req.EstudianteID, req.JobID).Scan(&interesEstudiante)
-
-		if err == nil && interesEstudiante {
-
-			// *** CAMBIO AQUÍ ***
-			// Antes ponías "match", ahora usamos is_match
-			_, err = db.Exec(`
-				INSERT INTO matches_job (estudiante_id, empleador_id, job_id, is_match)
-				VALUES ($1, $2, $3, true)
-				ON CONFLICT (estudiante_id, empleador_id, job_id)
-				DO UPDATE SET is_match = true;
-			`, req.EstudianteID, empleadorID, req.JobID)
-
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear el match", "err": err.Error()})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{"mensaje": "MATCH generado 🔥"})
-			return
-		}
+	if res.Match {
+		c.JSON(http.StatusOK, gin.H{"mensaje": "MATCH generado 🔥", "match_id": res.MatchID})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"mensaje": "Interés guardado"})
-}
+}
\ No newline at end of file
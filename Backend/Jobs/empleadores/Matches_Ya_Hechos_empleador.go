@@ -31,7 +31,10 @@ func GetMatchesEmpleadorHandler(ctx *gin.Context, db *sql.DB) {
 			e.universidad,
 			j.id,
 			j.titulo,
-			j.descripcion
+			j.descripcion,
+			CAST(COALESCE(m.pago_acordado, j.pago_estimado) AS FLOAT),
+			CAST(m.pago_acordado AS FLOAT),
+			m.unidad_pago
 		FROM matches_job m
 		JOIN estudiantes e ON m.estudiante_id = e.id
 		JOIN jobs j ON m.job_id = j.id
@@ -56,6 +59,9 @@ func GetMatchesEmpleadorHandler(ctx *gin.Context, db *sql.DB) {
 		JobID          int    `json:"job_id"`
 		JobTitulo      string `json:"job_titulo"`
 		JobDescripcion string `json:"job_descripcion"`
+		Pago           float64  `json:"pago"`          // acordado o, si no hubo negociación, el publicado
+		PagoAcordado   *float64 `json:"pago_acordado"` // nil mientras no se negocie
+		UnidadPago     *string  `json:"unidad_pago"`
 	}
 
 	var matches []MatchResponse
@@ -73,6 +79,9 @@ func GetMatchesEmpleadorHandler(ctx *gin.Context, db *sql.DB) {
 			&m.JobID,
 			&m.JobTitulo,
 			&m.JobDescripcion,
+			&m.Pago,
+			&m.PagoAcordado,
+			&m.UnidadPago,
 		); err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al procesar resultados", "err": err.Error()})
 			return
@@ -0,0 +1,307 @@
+package jobsemp
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/media"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/preguntas"
+	"github.com/gin-gonic/gin"
+)
+
+// Una plantilla guarda los campos que el empleador repite entre
+// trabajos parecidos. No tiene fechas ni foto: solo sirve para
+// prellenar CrearTrabajoHandler (template_id).
+
+type PlantillaRequest struct {
+	Nombre       string `json:"nombre"`
+	Titulo       string `json:"titulo"`
+	Descripcion  string `json:"descripcion"`
+	Ubicacion    string `json:"ubicacion"`
+	PagoEstimado int    `json:"pago_estimado"`
+	Negociable   bool   `json:"negociable"`
+	Requisitos   string `json:"requisitos"`
+	Categoria    string `json:"categoria"`
+	MetodoPago   string `json:"metodo_pago"`
+	Presencial   bool   `json:"presencial"`
+	Cupos        int    `json:"cupos"`
+}
+
+type Plantilla struct {
+	ID            int     `json:"id"`
+	Nombre        string  `json:"nombre"`
+	Titulo        string  `json:"titulo"`
+	Descripcion   string  `json:"descripcion"`
+	Ubicacion     string  `json:"ubicacion"`
+	PagoEstimado  float64 `json:"pago_estimado"`
+	Negociable    bool    `json:"negociable"`
+	Requisitos    string  `json:"requisitos"`
+	Categoria     string  `json:"categoria"`
+	MetodoPago    string  `json:"metodo_pago"`
+	Presencial    bool    `json:"presencial"`
+	Cupos         int     `json:"cupos"`
+	ActualizadoEn string  `json:"actualizado_en"`
+}
+
+func (req *PlantillaRequest) validar() string {
+	req.Nombre = strings.TrimSpace(req.Nombre)
+	if req.Nombre == "" {
+		req.Nombre = strings.TrimSpace(req.Titulo)
+	}
+	if req.Nombre == "" {
+		return "nombre o titulo es requerido"
+	}
+	if req.Cupos == 0 {
+		req.Cupos = 1
+	}
+	if req.Cupos < 1 || req.Cupos > MaxCupos {
+		return "cupos debe estar entre 1 y " + strconv.Itoa(MaxCupos)
+	}
+	if !req.Presencial {
+		req.Ubicacion = ""
+	}
+	return ""
+}
+
+// cargarPlantilla devuelve los campos de la plantilla listos para
+// usarse como valores iniciales de un CrearTrabajoRequest
+func cargarPlantilla(db *sql.DB, plantillaID, empleadorID int) (CrearTrabajoRequest, error) {
+	var req CrearTrabajoRequest
+	var pago float64
+	err := db.QueryRow(`
+		SELECT titulo, descripcion, ubicacion, pago_estimado, negociable,
+		       requisitos, categoria, metodo_pago, presencial, cupos
+		FROM plantillas_trabajo
+		WHERE id = $1 AND empleador_id = $2
+	`, plantillaID, empleadorID).Scan(
+		&req.Titulo, &req.Descripcion, &req.Ubicacion, &pago, &req.Negociable,
+		&req.Requisitos, &req.Categoria, &req.MetodoPago, &req.Presencial, &req.Cupos,
+	)
+	req.PagoEstimado = pago
+	return req, err
+}
+
+// -------------------------------
+// POST /protected/plantillas
+// -------------------------------
+func CrearPlantillaHandler(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	var req PlantillaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	if msg := req.validar(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var id int
+	err = db.QueryRow(`
+		INSERT INTO plantillas_trabajo
+		(empleador_id, nombre, titulo, descripcion, ubicacion, pago_estimado, negociable,
+		 requisitos, categoria, metodo_pago, presencial, cupos)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+		RETURNING id
+	`, userID, req.Nombre, req.Titulo, req.Descripcion, req.Ubicacion, req.PagoEstimado,
+		req.Negociable, req.Requisitos, req.Categoria, req.MetodoPago, req.Presencial, req.Cupos).Scan(&id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear plantilla", "err": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"mensaje": "Plantilla creada",
+		"id":      id,
+	})
+}
+
+// -------------------------------
+// GET /protected/plantillas
+// -------------------------------
+func ListarPlantillasHandler(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT id, nombre, titulo, descripcion, ubicacion, pago_estimado, negociable,
+		       requisitos, categoria, metodo_pago, presencial, cupos, actualizado_en
+		FROM plantillas_trabajo
+		WHERE empleador_id = $1
+		ORDER BY actualizado_en DESC
+	`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener plantillas", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	plantillas := []Plantilla{}
+	for rows.Next() {
+		var p Plantilla
+		if err := rows.Scan(
+			&p.ID, &p.Nombre, &p.Titulo, &p.Descripcion, &p.Ubicacion, &p.PagoEstimado, &p.Negociable,
+			&p.Requisitos, &p.Categoria, &p.MetodoPago, &p.Presencial, &p.Cupos, &p.ActualizadoEn,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo plantillas", "err": err.Error()})
+			return
+		}
+		plantillas = append(plantillas, p)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plantillas": plantillas})
+}
+
+// -------------------------------
+// PUT /protected/plantillas/:id
+// -------------------------------
+func EditarPlantillaHandler(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req PlantillaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	if msg := req.validar(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	res, err := db.Exec(`
+		UPDATE plantillas_trabajo
+		SET nombre = $1, titulo = $2, descripcion = $3, ubicacion = $4, pago_estimado = $5,
+		    negociable = $6, requisitos = $7, categoria = $8, metodo_pago = $9,
+		    presencial = $10, cupos = $11, actualizado_en = NOW()
+		WHERE id = $12 AND empleador_id = $13
+	`, req.Nombre, req.Titulo, req.Descripcion, req.Ubicacion, req.PagoEstimado, req.Negociable,
+		req.Requisitos, req.Categoria, req.MetodoPago, req.Presencial, req.Cupos, id, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar plantilla", "err": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plantilla no encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Plantilla actualizada",
+		"id":      id,
+	})
+}
+
+// -------------------------------
+// DELETE /protected/plantillas/:id
+// -------------------------------
+func BorrarPlantillaHandler(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	res, err := db.Exec(`DELETE FROM plantillas_trabajo WHERE id = $1 AND empleador_id = $2`, id, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al borrar plantilla"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plantilla no encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Plantilla eliminada",
+		"id":      id,
+	})
+}
+
+// -------------------------------
+// POST /protected/duplicar-trabajo  { "id": 1 }
+// Clona un trabajo propio (con sus fotos) como un borrador nuevo.
+// Fechas, matches y cupos ocupados no se copian.
+// -------------------------------
+func DuplicarTrabajoHandler(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	var req struct {
+		ID int `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var nuevoID int
+	err = db.QueryRow(`
+		INSERT INTO jobs
+		(titulo, descripcion, ubicacion, pago_estimado, negociable, requisitos, categoria,
+		 metodo_pago, presencial, empleador_id, cupos, categoria_id, moneda, unidad_pago, horas_estimadas,
+		 latitud, longitud, radio_metros, estado, creado_en, actualizado_en)
+		SELECT titulo, descripcion, ubicacion, pago_estimado, negociable, requisitos, categoria,
+		       metodo_pago, presencial, empleador_id, cupos, categoria_id, moneda, unidad_pago, horas_estimadas,
+		       latitud, longitud, radio_metros, 'borrador', NOW(), NOW()
+		FROM jobs
+		WHERE id = $1 AND empleador_id = $2 AND eliminado_en IS NULL
+		RETURNING id
+	`, req.ID, userID).Scan(&nuevoID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al duplicar trabajo", "err": err.Error()})
+		return
+	}
+
+	if err := preguntas.Copiar(db, req.ID, nuevoID); err != nil {
+		log.Printf("No se pudieron copiar las preguntas del job %d: %v", req.ID, err)
+	}
+
+	// Las fotos se copian a archivos propios para que borrar o cambiar
+	// las del original no afecte a la copia
+	if err := media.Copiar(db, req.ID, nuevoID, c.Request.Host); err != nil {
+		log.Printf("No se pudieron copiar las fotos del job %d: %v", req.ID, err)
+	}
+	var fotoURL sql.NullString
+	db.QueryRow(`SELECT foto_job FROM jobs WHERE id = $1`, nuevoID).Scan(&fotoURL)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"mensaje":    "Trabajo duplicado como borrador",
+		"trabajo_id": nuevoID,
+		"estado":     "borrador",
+		"foto_job":   fotoURL.String,
+	})
+}
@@ -24,6 +24,11 @@ type Job struct {
 	FechaCreacion           string  `json:"fecha_creacion"`
 		Estado       string  `json:"estado"`
 	PostulacionContratadaID *int    `json:"postulacion_contratada_id"`
+	Cupos                   int     `json:"cupos"`
+	CuposOcupados           int     `json:"cupos_ocupados"`
+	CuposCompletados        int     `json:"cupos_completados"`
+	FechaInicio             *string `json:"fecha_inicio"`
+	ExpiraEn                *string `json:"expira_en"`
 }
 
 func getUserIdFromJWT(c *gin.Context) (int, error) {
@@ -70,13 +75,23 @@ func Get_Mis_Jobs(c *gin.Context, db *sql.DB) {
 			requisitos,
 			estado,
 			creado_en,
-			postulacion_contratada_id
+			postulacion_contratada_id,
+			cupos,
+			cupos_ocupados,
+			cupos_completados,
+			fecha_inicio,
+			expira_en
 		FROM jobs
 		WHERE empleador_id = $1
+		  AND eliminado_en IS NULL
+		  AND (archivado_en IS NOT NULL) = $2
 		ORDER BY creado_en DESC
 	`
 
-	rows, err := db.Query(query, userId)
+	// ?archivados=true lista solo los completados archivados
+	archivados := c.Query("archivados") == "true"
+
+	rows, err := db.Query(query, userId, archivados)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al consultar jobs", "err":err.Error()})
 		return
@@ -91,6 +106,8 @@ func Get_Mis_Jobs(c *gin.Context, db *sql.DB) {
 			catStr  string
 			reqStr  string
 			contrID sql.NullInt64
+
+			fechaInicio, expiraEn sql.NullString
 		)
 
 		err := rows.Scan(
@@ -106,6 +123,11 @@ func Get_Mis_Jobs(c *gin.Context, db *sql.DB) {
 			&j.Estado,
 			&j.FechaCreacion,
 			&contrID,
+			&j.Cupos,
+			&j.CuposOcupados,
+			&j.CuposCompletados,
+			&fechaInicio,
+			&expiraEn,
 		)
 
 		if err != nil {
@@ -125,6 +147,13 @@ func Get_Mis_Jobs(c *gin.Context, db *sql.DB) {
 			j.PostulacionContratadaID = nil
 		}
 
+		if fechaInicio.Valid {
+			j.FechaInicio = &fechaInicio.String
+		}
+		if expiraEn.Valid {
+			j.ExpiraEn = &expiraEn.String
+		}
+
 		jobs = append(jobs, j)
 	}
 
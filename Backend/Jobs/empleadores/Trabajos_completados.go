@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
 	auth "github.com/VinkoRobi2/FlashWorkEC/service"
 	"github.com/gin-gonic/gin"
 )
@@ -20,6 +21,9 @@ type TrabajoCompletadoEmpleador struct {
 	Categoria          string  `json:"categoria"`
 	Ubicacion          string  `json:"ubicacion"`
 	PagoEstimado       float64 `json:"pago_estimado"`
+	PagoAcordado       *float64 `json:"pago_acordado"` // negociado en el match, si hubo
+	UnidadPago         string   `json:"unidad_pago"` // la acordada o la publicada
+	Compensacion       compensacion.Compensacion `json:"compensacion"` // sobre el pago que rige
 
 	PostulacionID      int     `json:"postulacion_id"`
 	EstudianteID       int     `json:"estudiante_id"`
@@ -79,10 +83,15 @@ func GetTrabajosCompletadosEmpleador(db *sql.DB, c *gin.Context) {
 
         ja.creado_en AS fecha_postulacion,
 		j.actualizado_en AS fecha_trabajo,
-		ja.actualizado_en AS fecha_completado
+		ja.actualizado_en AS fecha_completado,
+
+		CAST(m.pago_acordado AS FLOAT),
+		COALESCE(m.unidad_pago, j.unidad_pago),
+		j.horas_estimadas
 
 	FROM job_applications ja
 	JOIN jobs j ON j.id = ja.trabajo_id
+	LEFT JOIN matches_job m ON m.job_id = j.id AND m.estudiante_id = ja.estudiante_id AND m.is_match = true
 	JOIN estudiantes e ON e.id = ja.estudiante_id
 
 	WHERE j.empleador_id = $1
@@ -108,6 +117,7 @@ func GetTrabajosCompletadosEmpleador(db *sql.DB, c *gin.Context) {
 		var fechaJob time.Time
 		var fechaDone time.Time
 		var pago sql.NullFloat64
+		var horas sql.NullFloat64
 
 		err := rows.Scan(
 			&t.TrabajoID,
@@ -125,6 +135,10 @@ func GetTrabajosCompletadosEmpleador(db *sql.DB, c *gin.Context) {
 			&fechaPost,
 			&fechaJob,
 			&fechaDone,
+
+			&t.PagoAcordado,
+			&t.UnidadPago,
+			&horas,
 		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error escaneando datos", "detail": err.Error()})
@@ -134,6 +148,11 @@ func GetTrabajosCompletadosEmpleador(db *sql.DB, c *gin.Context) {
 		if pago.Valid {
 			t.PagoEstimado = pago.Float64
 		}
+		vigente := t.PagoEstimado
+		if t.PagoAcordado != nil {
+			vigente = *t.PagoAcordado
+		}
+		t.Compensacion = compensacion.DesdeBD(vigente, t.UnidadPago, horas)
 
 		t.FechaPostulacion = fechaPost.Format(time.RFC3339)
 		t.FechaTrabajo = fechaJob.Format(time.RFC3339)
@@ -0,0 +1,347 @@
+package jobsemp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/cupos"
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/VinkoRobi2/FlashWorkEC/secretos"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// Si el job no trae fecha_inicio ni expira_en, expira a los 30 días
+	DiasExpiracionDefault = 30
+	// Días que se extiende un job al renovarlo
+	DiasRenovacionDefault = 7
+	DiasRenovacionMax     = 30
+	// Con cuánta anticipación se avisa al empleador
+	AvisoExpiracionAntes = 24 * time.Hour
+	// Validez del link de renovación en un clic
+	renovacionTTL = 7 * 24 * time.Hour
+)
+
+// parseFecha acepta RFC3339 o "2006-01-02T15:04" / "2006-01-02 15:04" en hora de Ecuador
+func parseFecha(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, fechas.ZonaEcuador); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("fecha inválida: %q", s)
+}
+
+// parseFechaOpcional devuelve nil si el string viene vacío
+func parseFechaOpcional(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := parseFecha(s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ------------------------------------------------------
+// TAREA: expirar trabajos vencidos
+// Un job vence en expira_en o, si no tiene, en fecha_inicio.
+// Si no tenía ningún contratado pasa a 'expirado' y sale de los
+// feeds; si ya tenía matches se cierran los cupos libres y sigue
+// su curso hasta completarse.
+// ------------------------------------------------------
+func ExpirarTrabajosVencidos(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `
+		UPDATE jobs
+		SET estado = 'expirado',
+		    expirado_en = NOW(),
+		    actualizado_en = NOW()
+		WHERE estado = 'abierto'
+		  AND eliminado_en IS NULL
+		  AND cupos_ocupados = 0
+		  AND COALESCE(expira_en, fecha_inicio) <= NOW()
+		RETURNING id, empleador_id, titulo
+	`)
+	if err != nil {
+		return err
+	}
+
+	type expirado struct {
+		id, empleadorID int
+		titulo          string
+	}
+	var expirados []expirado
+	for rows.Next() {
+		var e expirado
+		if err := rows.Scan(&e.id, &e.empleadorID, &e.titulo); err != nil {
+			rows.Close()
+			return err
+		}
+		expirados = append(expirados, e)
+	}
+	rows.Close()
+
+	for _, e := range expirados {
+		err := notificaciones.Notificar(db, notificaciones.Notificacion{
+			UsuarioID: e.empleadorID,
+			Rol:       notificaciones.RolEmpleador,
+			Tipo:      "trabajo_expirado",
+			Titulo:    "Tu trabajo expiró",
+			Mensaje:   fmt.Sprintf("El trabajo \"%s\" expiró y ya no aparece para los estudiantes. Puedes renovarlo desde tus trabajos creados.", e.titulo),
+			Datos:     map[string]interface{}{"job_id": e.id},
+		})
+		if err != nil {
+			log.Println("Error notificando trabajo expirado:", err)
+		}
+	}
+
+	// Jobs con contratados: se cierran los cupos que quedaron libres
+	parciales, err := db.QueryContext(ctx, `
+		UPDATE jobs
+		SET cupos = cupos_ocupados,
+		    actualizado_en = NOW()
+		WHERE estado = 'abierto'
+		  AND eliminado_en IS NULL
+		  AND cupos_ocupados > 0
+		  AND COALESCE(expira_en, fecha_inicio) <= NOW()
+		RETURNING id
+	`)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for parciales.Next() {
+		var id int
+		if err := parciales.Scan(&id); err != nil {
+			parciales.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	parciales.Close()
+
+	for _, id := range ids {
+		if _, err := cupos.Recalcular(db, id); err != nil {
+			log.Printf("Error recalculando cupos del job %d: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// ------------------------------------------------------
+// TAREA: avisar al empleador antes de que su job expire,
+// con un link de renovación en un clic
+// ------------------------------------------------------
+func AvisarTrabajosPorVencer(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `
+		UPDATE jobs
+		SET aviso_expiracion_en = NOW()
+		WHERE estado = 'abierto'
+		  AND eliminado_en IS NULL
+		  AND aviso_expiracion_en IS NULL
+		  AND COALESCE(expira_en, fecha_inicio) > NOW()
+		  AND COALESCE(expira_en, fecha_inicio) <= NOW() + $1::interval
+		RETURNING id, empleador_id, titulo, COALESCE(expira_en, fecha_inicio)
+	`, fmt.Sprintf("%d seconds", int(AvisoExpiracionAntes.Seconds())))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var jobID, empleadorID int
+		var titulo string
+		var vence time.Time
+		if err := rows.Scan(&jobID, &empleadorID, &titulo, &vence); err != nil {
+			return err
+		}
+
+		token, err := generarTokenRenovacion(jobID, empleadorID)
+		if err != nil {
+			log.Println("Error generando token de renovación:", err)
+			continue
+		}
+		link := fmt.Sprintf("%s/renovar-trabajo?token=%s", frontendURL(), token)
+
+		err = notificaciones.Notificar(db, notificaciones.Notificacion{
+			UsuarioID: empleadorID,
+			Rol:       notificaciones.RolEmpleador,
+			Tipo:      "trabajo_por_expirar",
+			Titulo:    "Tu trabajo está por expirar",
+			Mensaje: fmt.Sprintf(
+				"El trabajo \"%s\" expira el %s. Si aún necesitas estudiantes, renuévalo %d días con un clic:\n%s",
+				titulo, vence.In(fechas.ZonaEcuador).Format("02/01/2006 15:04"), DiasRenovacionDefault, link,
+			),
+			Datos: map[string]interface{}{"job_id": jobID, "token_renovacion": token},
+		})
+		if err != nil {
+			log.Println("Error notificando trabajo por expirar:", err)
+		}
+	}
+
+	return rows.Err()
+}
+
+// ------------------------------------------------------
+// renovarTrabajo extiende la expiración y reabre el job si ya
+// había expirado. Devuelve la nueva fecha de expiración.
+// ------------------------------------------------------
+func renovarTrabajo(db *sql.DB, jobID, empleadorID, dias int) (time.Time, error) {
+	var nuevaExpiracion time.Time
+
+	err := db.QueryRow(`
+		UPDATE jobs
+		SET expira_en = GREATEST(COALESCE(expira_en, fecha_inicio, NOW()), NOW()) + make_interval(days => $3),
+		    estado = CASE WHEN estado = 'expirado' THEN 'abierto' ELSE estado END,
+		    expirado_en = NULL,
+		    aviso_expiracion_en = NULL,
+		    actualizado_en = NOW()
+		WHERE id = $1
+		  AND empleador_id = $2
+		  AND estado IN ('abierto', 'expirado')
+		  AND eliminado_en IS NULL
+		RETURNING expira_en
+	`, jobID, empleadorID, dias).Scan(&nuevaExpiracion)
+
+	if err == sql.ErrNoRows {
+		return time.Time{}, errors.New("el trabajo no existe, no es tuyo o no se puede renovar")
+	}
+	return nuevaExpiracion, err
+}
+
+// -------------------------------
+// POST /protected/renovar-trabajo  { "id": 1, "dias": 7 }
+// -------------------------------
+func RenovarTrabajoHandler(db *sql.DB, c *gin.Context) {
+	userID, err := getEmployerIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+
+	var req struct {
+		ID   int `json:"id"`
+		Dias int `json:"dias"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	if req.ID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	if req.Dias == 0 {
+		req.Dias = DiasRenovacionDefault
+	}
+	if req.Dias < 1 || req.Dias > DiasRenovacionMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("dias debe estar entre 1 y %d", DiasRenovacionMax)})
+		return
+	}
+
+	expira, err := renovarTrabajo(db, req.ID, userID, req.Dias)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Trabajo renovado",
+		"id":        req.ID,
+		"expira_en": expira.Format(time.RFC3339),
+	})
+}
+
+// -------------------------------
+// POST /renovar-trabajo/:token  (link del correo, sin login)
+// -------------------------------
+func RenovarTrabajoConTokenHandler(c *gin.Context, db *sql.DB) {
+	tokenStr := c.Param("token")
+	if tokenStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token no proporcionado"})
+		return
+	}
+
+	claims, err := validarTokenRenovacion(tokenStr)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
+		return
+	}
+
+	expira, err := renovarTrabajo(db, claims.JobID, claims.EmpleadorID, DiasRenovacionDefault)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Trabajo renovado",
+		"id":        claims.JobID,
+		"expira_en": expira.Format(time.RFC3339),
+	})
+}
+
+// ======================== TOKEN DE RENOVACIÓN ========================
+
+type RenovacionClaims struct {
+	JobID       int    `json:"job_id"`
+	EmpleadorID int    `json:"empleador_id"`
+	Proposito   string `json:"proposito"`
+	jwt.RegisteredClaims
+}
+
+func frontendURL() string {
+	if u := os.Getenv("FRONTEND_URL"); u != "" {
+		return u
+	}
+	return "https://cameya.pages.dev"
+}
+
+func generarTokenRenovacion(jobID, empleadorID int) (string, error) {
+	now := time.Now()
+	claims := RenovacionClaims{
+		JobID:       jobID,
+		EmpleadorID: empleadorID,
+		Proposito:   "renovar_trabajo",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(renovacionTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	clave, err := secretos.Clave("")
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(clave)
+}
+
+func validarTokenRenovacion(tokenStr string) (*RenovacionClaims, error) {
+	claims := &RenovacionClaims{}
+	tok, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, errors.New("algoritmo de firma inválido")
+		}
+		return secretos.Clave("")
+	})
+	if err != nil || !tok.Valid {
+		return nil, errors.New("token inválido")
+	}
+	if claims.Proposito != "renovar_trabajo" || claims.JobID == 0 || claims.EmpleadorID == 0 {
+		return nil, errors.New("token inválido")
+	}
+	return claims, nil
+}
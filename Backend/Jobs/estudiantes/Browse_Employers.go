@@ -93,7 +93,8 @@ ORDER BY promedio_rating DESC;
 		jobQuery := `
 			SELECT id, titulo, descripcion, pago_estimado, foto_job
 			FROM jobs 
-			WHERE empleador_id = $1 AND estado = 'abierto';
+			WHERE empleador_id = $1 AND estado = 'abierto' AND eliminado_en IS NULL
+			  AND (COALESCE(expira_en, fecha_inicio) IS NULL OR COALESCE(expira_en, fecha_inicio) > NOW());
 		`
 
 		jobRows, err := db.Query(jobQuery, emp.EmpleadorID)
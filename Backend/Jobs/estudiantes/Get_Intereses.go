@@ -41,7 +41,8 @@ func GetAllInteresesHandler(c *gin.Context, db *sql.DB) {
 		FROM intereses_estudiante ie
 		INNER JOIN jobs j ON j.id = ie.job_id
 		WHERE ie.estudiante_id = $1
-		AND ie.interesado = true;
+		AND ie.interesado = true
+		AND j.eliminado_en IS NULL;
 	`
 
 	type JobResponse struct {
@@ -32,7 +32,9 @@ func GetMatchesEstudianteHandler(ctx *gin.Context, db *sql.DB) {
 			j.id AS job_id,
 			j.titulo,
 			j.descripcion,
-			j.pago_estimado
+			j.pago_estimado,
+			CAST(m.pago_acordado AS FLOAT),
+			m.unidad_pago
 		FROM matches_job m
 		JOIN empleadores e ON m.empleador_id = e.id
 		JOIN jobs j ON m.job_id = j.id
@@ -58,6 +60,8 @@ func GetMatchesEstudianteHandler(ctx *gin.Context, db *sql.DB) {
 		JobTitulo           string  `json:"job_titulo"`
 		JobDescripcion      string  `json:"job_descripcion"`
 		JobPago             float64 `json:"job_pago_estimado"`
+		PagoAcordado        *float64 `json:"pago_acordado"` // nil mientras no se negocie
+		UnidadPago          *string  `json:"unidad_pago"`
 	}
 
 	var matches []MatchResponse
@@ -80,6 +84,8 @@ func GetMatchesEstudianteHandler(ctx *gin.Context, db *sql.DB) {
 			&m.JobTitulo,
 			&m.JobDescripcion,
 			&m.JobPago,
+			&m.PagoAcordado,
+			&m.UnidadPago,
 		); err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al procesar resultados", "err": err.Error()})
 			return
@@ -7,15 +7,19 @@ import (
 	"database/sql"
 	"net/http"
 
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/preguntas"
+	"github.com/VinkoRobi2/FlashWorkEC/emparejamiento"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
 	"github.com/gin-gonic/gin"
 )
 
 type InteresRequest struct {
-	JobID      int  `json:"job_id"`
-	Interesado bool `json:"interesado"` // true = like, false = dislike
+	JobID      int                   `json:"job_id"`
+	Interesado bool                  `json:"interesado"` // true = like, false = dislike
+	Respuestas []preguntas.Respuesta `json:"respuestas"` // obligatorias si el job tiene preguntas
 }
 
-func GuardarInteresEstudianteHandler(c *gin.Context, db *sql.DB) {
+func GuardarInteresEstudianteHandler(c *gin.Context, db *sql.DB, motor *emparejamiento.Servicio) {
 
 	// Obtener ID del estudiante desde middleware
 	userIDInterface, ok := c.Get("userID")
@@ -36,56 +40,53 @@ func GuardarInteresEstudianteHandler(c *gin.Context, db *sql.DB) {
 		return
 	}
 
-	// Guardar interés del estudiante
-	query := `
-		INSERT INTO intereses_estudiante (estudiante_id, job_id, interesado)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (estudiante_id, job_id)
-		DO UPDATE SET interesado = EXCLUDED.interesado, creado_en = NOW();
-	`
-
-	_, err := db.Exec(query, estudianteID, req.JobID, req.Interesado)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar interés", "err": err.Error()})
-		return
-	}
-
-	// ---------------------------
-	// LÓGICA DE MATCH
-	// ---------------------------
+	// Preguntas de filtro: el like necesita todas las respuestas obligatorias
+	var respuestas []preguntas.Respuesta
+	descartado := false
 	if req.Interesado {
-
-		var empleadorID int
-		err = db.QueryRow(`SELECT empleador_id FROM jobs WHERE id = $1`, req.JobID).Scan(&empleadorID)
+		ps, err := preguntas.DeJob(db, req.JobID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo obtener empleador del job"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener preguntas", "err": err.Error()})
 			return
 		}
-
-		var interesEmpleador bool
-		err = db.QueryRow(`
-			SELECT interesado FROM intereses_empleador
-			WHERE empleador_id = $1 AND estudiante_id = $2 AND job_id = $3
-		`, empleadorID, estudianteID, req.JobID).Scan(&interesEmpleador)
-
-		if err == nil && interesEmpleador {
-
-			_, err = db.Exec(`
-				INSERT INTO matches_job (estudiante_id, empleador_id, job_id, is_match)
-				VALUES ($1, $2, $3, true)
-				ON CONFLICT (estudiante_id, empleador_id, job_id)
-				DO UPDATE SET is_match = true;
-			`, estudianteID, empleadorID, req.JobID)
-
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear match", "err": err.Error()})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{"mensaje": "MATCH generado 🔥"})
+		var msg string
+		respuestas, descartado, msg = preguntas.Revisar(ps, req.Respuestas)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":     msg,
+				"preguntas": preguntas.Publicas(ps),
+			})
 			return
 		}
 	}
 
+	// Guardar interés y evaluar el match en una sola transacción.
+	// Un candidato descartado por sus respuestas no hace match automático.
+	res, err := motor.Registrar(emparejamiento.Interes{
+		Rol:        notificaciones.RolEstudiante,
+		UsuarioID:  estudianteID,
+		JobID:      req.JobID,
+		Interesado: req.Interesado,
+		Respuestas: respuestas,
+		Descartado: descartado,
+	})
+	switch err {
+	case nil:
+	case emparejamiento.ErrNoEncontrado:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo no encontrado"})
+		return
+	case emparejamiento.ErrSinCupos:
+		c.JSON(http.StatusConflict, gin.H{"error": "Este trabajo ya no tiene cupos disponibles"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar interés", "err": err.Error()})
+		return
+	}
+
+	if res.Match {
+		c.JSON(http.StatusOK, gin.H{"mensaje": "MATCH generado 🔥", "match_id": res.MatchID})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"mensaje": "Interés guardado"})
 }
\ No newline at end of file
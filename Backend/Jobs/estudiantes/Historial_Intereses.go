@@ -0,0 +1,358 @@
+package jobsest
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/preguntas"
+	"github.com/VinkoRobi2/FlashWorkEC/emparejamiento"
+	"github.com/gin-gonic/gin"
+)
+
+// Manejo del historial de swipes del estudiante. Un dislike saca el job
+// del feed para siempre (el feed excluye todo lo que está en
+// intereses_estudiante), así que aquí se puede deshacer el último swipe,
+// ver los descartados y reconsiderarlos, o retirar un like antes del match.
+
+// Solo se puede deshacer un swipe reciente
+const VentanaDeshacerSwipe = time.Hour
+
+// tieneMatch indica si ya hay match del estudiante en el job; con match
+// el interés ya no se toca
+func tieneMatch(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, estudianteID, jobID int) (bool, error) {
+	var existe bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM matches_job
+			WHERE estudiante_id = $1 AND job_id = $2 AND is_match = true
+		)
+	`, estudianteID, jobID).Scan(&existe)
+	return existe, err
+}
+
+// borrarInteres elimina el swipe y las respuestas a las preguntas del job
+func borrarInteres(tx *sql.Tx, estudianteID, jobID int) error {
+	if err := preguntas.GuardarRespuestas(tx, estudianteID, jobID, nil, false); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		DELETE FROM intereses_estudiante WHERE estudiante_id = $1 AND job_id = $2
+	`, estudianteID, jobID)
+	return err
+}
+
+// -------------------------------
+// POST /protected/deshacer-swipe
+// Borra el último like o dislike (de la última hora) y el job vuelve al feed.
+// -------------------------------
+func DeshacerSwipeHandler(c *gin.Context, db *sql.DB) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	estudianteID := userIDInterface.(int)
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	var jobID int
+	var interesado bool
+	var creadoEn time.Time
+	err = tx.QueryRow(`
+		SELECT job_id, interesado, creado_en
+		FROM intereses_estudiante
+		WHERE estudiante_id = $1
+		ORDER BY creado_en DESC
+		LIMIT 1
+	`, estudianteID).Scan(&jobID, &interesado, &creadoEn)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No hay swipes para deshacer"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer el último swipe", "err": err.Error()})
+		return
+	}
+	if time.Since(creadoEn) > VentanaDeshacerSwipe {
+		c.JSON(http.StatusConflict, gin.H{"error": "El último swipe ya no se puede deshacer"})
+		return
+	}
+
+	// Con el job bloqueado ningún like del empleador puede crear el match a la vez
+	if _, _, _, err := emparejamiento.BloquearJob(tx, jobID); err != nil && err != emparejamiento.ErrNoEncontrado {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer trabajo"})
+		return
+	}
+
+	match, err := tieneMatch(tx, estudianteID, jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al revisar match"})
+		return
+	}
+	if match {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ese like ya generó un match; no se puede deshacer"})
+		return
+	}
+
+	if err := borrarInteres(tx, estudianteID, jobID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al deshacer swipe", "err": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al deshacer swipe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mensaje":    "Swipe deshecho",
+		"job_id":     jobID,
+		"interesado": interesado,
+	})
+}
+
+// -------------------------------
+// GET /protected/descartados?page=1&limit=20
+// Trabajos a los que el estudiante dio dislike, del más reciente al más antiguo.
+// -------------------------------
+func GetDescartadosHandler(c *gin.Context, db *sql.DB) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	estudianteID := userIDInterface.(int)
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	baseURL := scheme + "://" + c.Request.Host + "/uploads/"
+
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit := 20
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 50 {
+		limit = l
+	}
+
+	var total int
+	err = db.QueryRow(`
+		SELECT COUNT(*)
+		FROM intereses_estudiante ie
+		JOIN jobs j ON j.id = ie.job_id
+		WHERE ie.estudiante_id = $1 AND ie.interesado = false AND j.eliminado_en IS NULL
+	`, estudianteID).Scan(&total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al contar descartados", "err": err.Error()})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT j.id, j.titulo, j.descripcion, j.categoria, j.ubicacion, j.pago_estimado,
+		       j.unidad_pago, j.estado, j.foto_job, ie.creado_en,
+		       j.estado = 'abierto'
+		       AND (COALESCE(j.expira_en, j.fecha_inicio) IS NULL OR COALESCE(j.expira_en, j.fecha_inicio) > NOW())
+		FROM intereses_estudiante ie
+		JOIN jobs j ON j.id = ie.job_id
+		WHERE ie.estudiante_id = $1 AND ie.interesado = false AND j.eliminado_en IS NULL
+		ORDER BY ie.creado_en DESC
+		LIMIT $2 OFFSET $3
+	`, estudianteID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener descartados", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	type Descartado struct {
+		JobID        int     `json:"job_id"`
+		Titulo       string  `json:"titulo"`
+		Descripcion  string  `json:"descripcion"`
+		Categoria    string  `json:"categoria"`
+		Ubicacion    string  `json:"ubicacion"`
+		PagoEstimado float64 `json:"pago_estimado"`
+		UnidadPago   string  `json:"unidad_pago"`
+		Estado       string  `json:"estado"`
+		FotoJob      *string `json:"foto_job"`
+		DescartadoEn string  `json:"descartado_en"`
+		// Si se reconsidera, vuelve al feed solo si sigue abierto
+		Disponible bool `json:"disponible"`
+	}
+
+	descartados := []Descartado{}
+	for rows.Next() {
+		var d Descartado
+		var foto sql.NullString
+		var ubicacion sql.NullString
+		var descartadoEn time.Time
+		if err := rows.Scan(&d.JobID, &d.Titulo, &d.Descripcion, &d.Categoria, &ubicacion, &d.PagoEstimado,
+			&d.UnidadPago, &d.Estado, &foto, &descartadoEn, &d.Disponible); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo descartados", "err": err.Error()})
+			return
+		}
+		d.Ubicacion = ubicacion.String
+		if foto.Valid && foto.String != "" {
+			url := baseURL + foto.String
+			d.FotoJob = &url
+		}
+		d.DescartadoEn = descartadoEn.Format(time.RFC3339)
+		descartados = append(descartados, d)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"descartados": descartados,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+		"total_pages": (total + limit - 1) / limit,
+	})
+}
+
+// -------------------------------
+// POST /protected/descartados/reconsiderar  { "job_id": 1 }
+// Borra el dislike para que el trabajo vuelva a aparecer en el feed.
+// -------------------------------
+func ReconsiderarDescartadoHandler(c *gin.Context, db *sql.DB) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	estudianteID := userIDInterface.(int)
+
+	var req struct {
+		JobID int `json:"job_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.JobID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "job_id es requerido"})
+		return
+	}
+
+	res, err := db.Exec(`
+		DELETE FROM intereses_estudiante
+		WHERE estudiante_id = $1 AND job_id = $2 AND interesado = false
+	`, estudianteID, req.JobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al reconsiderar trabajo", "err": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ese trabajo no está en tus descartados"})
+		return
+	}
+
+	var disponible bool
+	err = db.QueryRow(`
+		SELECT estado = 'abierto' AND eliminado_en IS NULL
+		       AND (COALESCE(expira_en, fecha_inicio) IS NULL OR COALESCE(expira_en, fecha_inicio) > NOW())
+		FROM jobs WHERE id = $1
+	`, req.JobID).Scan(&disponible)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer trabajo"})
+		return
+	}
+
+	mensaje := "El trabajo volverá a aparecer en tu feed"
+	if !disponible {
+		mensaje = "Se quitó de descartados, pero el trabajo ya no está abierto"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"mensaje":    mensaje,
+		"job_id":     req.JobID,
+		"disponible": disponible,
+	})
+}
+
+// -------------------------------
+// POST /protected/retirar-interes  { "job_id": 1 }
+// Retira un like antes del match. El like queda como dislike (el job no
+// vuelve al feed, pero aparece en descartados) y se borran las respuestas,
+// así un like del empleador ya no puede generar match con este estudiante.
+// -------------------------------
+func RetirarInteresHandler(c *gin.Context, db *sql.DB) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	estudianteID := userIDInterface.(int)
+
+	var req struct {
+		JobID int `json:"job_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.JobID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "job_id es requerido"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Mismo orden de bloqueo que el motor de match: primero el job
+	_, _, _, err = emparejamiento.BloquearJob(tx, req.JobID)
+	if err == emparejamiento.ErrNoEncontrado {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer trabajo"})
+		return
+	}
+
+	var interesado bool
+	err = tx.QueryRow(`
+		SELECT interesado FROM intereses_estudiante
+		WHERE estudiante_id = $1 AND job_id = $2
+	`, estudianteID, req.JobID).Scan(&interesado)
+	if err == sql.ErrNoRows || (err == nil && !interesado) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No tienes un like en este trabajo"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer interés"})
+		return
+	}
+
+	match, err := tieneMatch(tx, estudianteID, req.JobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al revisar match"})
+		return
+	}
+	if match {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ya hay match en este trabajo; cancélalo desde tus matches"})
+		return
+	}
+
+	if err := preguntas.GuardarRespuestas(tx, estudianteID, req.JobID, nil, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al borrar respuestas", "err": err.Error()})
+		return
+	}
+	if _, err := tx.Exec(`
+		UPDATE intereses_estudiante SET interesado = false, creado_en = NOW()
+		WHERE estudiante_id = $1 AND job_id = $2
+	`, estudianteID, req.JobID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al retirar interés", "err": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al retirar interés"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Interés retirado", "job_id": req.JobID})
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
 	auth "github.com/VinkoRobi2/FlashWorkEC/service"
 	"github.com/gin-gonic/gin"
 )
@@ -16,7 +17,10 @@ type TrabajoCompletado struct {
 	TrabajoID        int     `json:"trabajo_id"`
 	Titulo           string  `json:"titulo"`
 	Descripcion      string  `json:"descripcion"`
-	Precio           float64 `json:"precio"`
+	Precio           float64 `json:"precio"`         // pago acordado si hubo negociación
+	PagoAcordado     *float64 `json:"pago_acordado"` // nil = se pagó lo publicado
+	UnidadPago       string   `json:"unidad_pago"`   // la acordada o la publicada
+	Compensacion     compensacion.Compensacion `json:"compensacion"`
 	FechaPostulacion string  `json:"fecha_postulacion"`
 	FechaTrabajo     string  `json:"fecha_trabajo"`
 }
@@ -63,11 +67,15 @@ func GetTrabajosCompletadosEstudiante(db *sql.DB, c *gin.Context) {
 			j.id,
 			j.titulo,
 			j.descripcion,
-			CAST(j.pago_estimado AS FLOAT) AS precio,
+			CAST(COALESCE(m.pago_acordado, j.pago_estimado) AS FLOAT) AS precio,
 			ja.creado_en AS fecha_postulacion,
-			j.creado_en AS fecha_trabajo
+			j.creado_en AS fecha_trabajo,
+			CAST(m.pago_acordado AS FLOAT),
+			COALESCE(m.unidad_pago, j.unidad_pago),
+			j.horas_estimadas
 		FROM job_applications ja
 		JOIN jobs j ON j.id = ja.trabajo_id
+		LEFT JOIN matches_job m ON m.job_id = j.id AND m.estudiante_id = ja.estudiante_id AND m.is_match = true
 		WHERE ja.estudiante_id = $1
 		AND ja.student_completed = TRUE
 		AND ja.employer_completed = TRUE
@@ -88,6 +96,7 @@ func GetTrabajosCompletadosEstudiante(db *sql.DB, c *gin.Context) {
 
 	for rows.Next() {
 		var t TrabajoCompletado
+		var horas sql.NullFloat64
 
 		err := rows.Scan(
 			&t.PostulacionID,
@@ -97,6 +106,9 @@ func GetTrabajosCompletadosEstudiante(db *sql.DB, c *gin.Context) {
 			&t.Precio,
 			&t.FechaPostulacion,
 			&t.FechaTrabajo,
+			&t.PagoAcordado,
+			&t.UnidadPago,
+			&horas,
 		)
 
 		if err != nil {
@@ -107,6 +119,7 @@ func GetTrabajosCompletadosEstudiante(db *sql.DB, c *gin.Context) {
 			return
 		}
 
+		t.Compensacion = compensacion.DesdeBD(t.Precio, t.UnidadPago, horas)
 		trabajos = append(trabajos, t)
 	}
 
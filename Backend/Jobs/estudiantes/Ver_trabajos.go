@@ -5,6 +5,10 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/media"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/preguntas"
+	"github.com/VinkoRobi2/FlashWorkEC/categorias"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,9 +18,12 @@ type PublicJob struct {
 	Titulo                  string  `json:"titulo"`
 	Descripcion             string  `json:"descripcion"`
 	Categoria               string  `json:"categoria"`
+	CategoriaID             *int    `json:"categoria_id"`
 	Requisitos              string  `json:"requisitos"`
 	Habilidades             string  `json:"habilidades"`
 	Salario                 string  `json:"salario"`
+	// Monto, moneda, unidad (hora/turno/tarea/día) y valor por hora
+	Compensacion compensacion.Compensacion `json:"compensacion"`
 	Negociable              bool    `json:"negociable"`
 	Ciudad                  string  `json:"ciudad"`
 	Modalidad               string  `json:"modalidad"`
@@ -26,6 +33,18 @@ type PublicJob struct {
 	Estado                  string  `json:"estado"`
 	FotoJob                 string  `json:"foto_job"`
 	FotoEmpleador           string  `json:"foto_empleador"`
+	Cupos                   int     `json:"cupos"`
+	CuposDisponibles        int     `json:"cupos_disponibles"`
+	FechaInicio             *string `json:"fecha_inicio"`
+	ExpiraEn                *string `json:"expira_en"`
+	FechaFin                *string `json:"fecha_fin"`     // fin del turno, si aplica
+	RecurrenteID            *int    `json:"recurrente_id"` // turno de un trabajo recurrente
+
+	Galeria []media.Foto `json:"galeria"`
+	Portada *media.Foto  `json:"portada"`
+
+	// Preguntas que hay que responder al dar like (sin respuestas de descarte)
+	Preguntas []preguntas.Pregunta `json:"preguntas"`
 
 	NombreEmpleador   string  `json:"nombre_empleador"`
 	ApellidoEmpleador string  `json:"apellido_empleador"`
@@ -62,17 +81,31 @@ func Get_Jobs_Abiertos(c *gin.Context, db *sql.DB) {
 
 	offset := (page - 1) * limit
 
+	// Filtro opcional ?categoria=slug|id (incluye sus subcategorías)
+	var categoriaID *int
+	if filtro := c.Query("categoria"); filtro != "" {
+		cat, err := categorias.Resolver(db, filtro)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "categoria inválida"})
+			return
+		}
+		categoriaID = &cat.ID
+	}
+
 	var total int
 	err = db.QueryRow(`
 		SELECT COUNT(*) 
 		FROM jobs 
 		WHERE estado = 'abierto'
+		AND eliminado_en IS NULL
+		AND (COALESCE(expira_en, fecha_inicio) IS NULL OR COALESCE(expira_en, fecha_inicio) > NOW())
 		AND id NOT IN (
 			SELECT job_id
 			FROM intereses_estudiante
 			WHERE estudiante_id = $1
 		)
-	`, estudianteID).Scan(&total)
+		AND ($2::int IS NULL OR categoria_id = $2 OR categoria_id IN (SELECT id FROM categorias WHERE padre_id = $2))
+	`, estudianteID, categoriaID).Scan(&total)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "error obteniendo total de trabajos"})
@@ -88,6 +121,7 @@ func Get_Jobs_Abiertos(c *gin.Context, db *sql.DB) {
 			j.titulo,
 			j.descripcion,
 			j.categoria,
+			j.categoria_id,
 			j.requisitos,
 			j.negociable,
 			j.ubicacion,
@@ -97,6 +131,14 @@ func Get_Jobs_Abiertos(c *gin.Context, db *sql.DB) {
 			j.estado,
 			j.pago_estimado,
 			j.foto_job,
+			j.cupos,
+			j.cupos - j.cupos_ocupados AS cupos_disponibles,
+			j.fecha_inicio,
+			j.expira_en,
+			j.fecha_fin,
+			j.recurrente_id,
+			j.unidad_pago,
+			j.horas_estimadas,
 			
 			e.foto_perfil,
 			e.nombre,
@@ -111,16 +153,19 @@ func Get_Jobs_Abiertos(c *gin.Context, db *sql.DB) {
 		FROM jobs j
 		JOIN empleadores e ON e.id = j.empleador_id
 		WHERE j.estado = 'abierto'
+		AND j.eliminado_en IS NULL
+		AND (COALESCE(j.expira_en, j.fecha_inicio) IS NULL OR COALESCE(j.expira_en, j.fecha_inicio) > NOW())
 		AND j.id NOT IN (
 			SELECT job_id 
 			FROM intereses_estudiante 
 			WHERE estudiante_id = $1
 		)
+		AND ($4::int IS NULL OR j.categoria_id = $4 OR j.categoria_id IN (SELECT id FROM categorias WHERE padre_id = $4))
 		ORDER BY j.creado_en DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := db.Query(query, estudianteID, limit, offset)
+	rows, err := db.Query(query, estudianteID, limit, offset, categoriaID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al consultar trabajos", "err": err.Error()})
 		return
@@ -135,6 +180,11 @@ func Get_Jobs_Abiertos(c *gin.Context, db *sql.DB) {
 		var fotoJob sql.NullString
 		var fotoEmp sql.NullString
 		var rating sql.NullFloat64
+		var fechaInicio, expiraEn, fechaFin sql.NullString
+		var recurrenteID sql.NullInt64
+		var categoriaJob sql.NullInt64
+		var unidadPago string
+		var horas sql.NullFloat64
 
 		err := rows.Scan(
 			&j.ID,
@@ -142,6 +192,7 @@ func Get_Jobs_Abiertos(c *gin.Context, db *sql.DB) {
 			&j.Titulo,
 			&j.Descripcion,
 			&j.Categoria,
+			&categoriaJob,
 			&j.Requisitos,
 			&j.Negociable,
 			&j.Ciudad,
@@ -151,6 +202,14 @@ func Get_Jobs_Abiertos(c *gin.Context, db *sql.DB) {
 			&j.Estado,
 			&j.Salario,
 			&fotoJob,
+			&j.Cupos,
+			&j.CuposDisponibles,
+			&fechaInicio,
+			&expiraEn,
+			&fechaFin,
+			&recurrenteID,
+			&unidadPago,
+			&horas,
 
 			&fotoEmp,
 			&j.NombreEmpleador,
@@ -182,9 +241,57 @@ func Get_Jobs_Abiertos(c *gin.Context, db *sql.DB) {
 			j.PostulacionContratadaID = &val
 		}
 
+		if fechaInicio.Valid {
+			j.FechaInicio = &fechaInicio.String
+		}
+		if expiraEn.Valid {
+			j.ExpiraEn = &expiraEn.String
+		}
+		if fechaFin.Valid {
+			j.FechaFin = &fechaFin.String
+		}
+		if categoriaJob.Valid {
+			val := int(categoriaJob.Int64)
+			j.CategoriaID = &val
+		}
+		if recurrenteID.Valid {
+			val := int(recurrenteID.Int64)
+			j.RecurrenteID = &val
+		}
+
+		pago, _ := strconv.ParseFloat(j.Salario, 64)
+		j.Compensacion = compensacion.DesdeBD(pago, unidadPago, horas)
+
 		jobs = append(jobs, j)
 	}
 
+	// Galería de fotos de cada trabajo, en una sola consulta
+	ids := make([]int, len(jobs))
+	for i := range jobs {
+		ids[i] = jobs[i].ID
+	}
+	galerias, err := media.Galerias(db, ids, baseURL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error obteniendo fotos", "err": err.Error()})
+		return
+	}
+	for i := range jobs {
+		jobs[i].Galeria = galerias[jobs[i].ID]
+		if jobs[i].Galeria == nil {
+			jobs[i].Galeria = []media.Foto{}
+		}
+		jobs[i].Portada = media.Portada(jobs[i].Galeria)
+	}
+
+	porJob, err := preguntas.DeJobs(db, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error obteniendo preguntas", "err": err.Error()})
+		return
+	}
+	for i := range jobs {
+		jobs[i].Preguntas = preguntas.Publicas(porJob[jobs[i].ID])
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"page":        page,
 		"total_pages": totalPages,
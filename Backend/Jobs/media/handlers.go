@@ -0,0 +1,323 @@
+package media
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verificarJob comprueba que el job exista, sea del empleador y
+// todavía se pueda editar su galería
+func verificarJob(c *gin.Context, db *sql.DB) (int, bool) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return 0, false
+	}
+	empleadorID := userIDInterface.(int)
+
+	jobID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || jobID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, false
+	}
+
+	var ownerID int
+	var estado string
+	err = db.QueryRow(`SELECT empleador_id, estado FROM jobs WHERE id = $1 AND eliminado_en IS NULL`, jobID).Scan(&ownerID, &estado)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo no encontrado"})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer trabajo"})
+		return 0, false
+	}
+	if ownerID != empleadorID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No puedes editar este trabajo"})
+		return 0, false
+	}
+	if estado == "completado" || estado == "cancelado" {
+		c.JSON(http.StatusConflict, gin.H{"error": "El trabajo ya no admite cambios en sus fotos"})
+		return 0, false
+	}
+	return jobID, true
+}
+
+func listarFotos(c *gin.Context, db *sql.DB, jobID int) ([]Foto, error) {
+	galerias, err := Galerias(db, []int{jobID}, "http://"+c.Request.Host+"/uploads/")
+	if err != nil {
+		return nil, err
+	}
+	fotos := galerias[jobID]
+	if fotos == nil {
+		fotos = []Foto{}
+	}
+	return fotos, nil
+}
+
+// -------------------------------
+// GET /protected/trabajos/:id/fotos
+// -------------------------------
+func ListarFotosHandler(c *gin.Context, db *sql.DB) {
+	jobID, ok := verificarJob(c, db)
+	if !ok {
+		return
+	}
+
+	fotos, err := listarFotos(c, db, jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener fotos", "err": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"fotos":     fotos,
+		"portada":   Portada(fotos),
+		"max_fotos": MaxFotosPorTrabajo,
+	})
+}
+
+// -------------------------------
+// POST /protected/trabajos/:id/fotos  { "foto_base64": "...", "portada": false }
+// -------------------------------
+func AgregarFotoHandler(c *gin.Context, db *sql.DB) {
+	jobID, ok := verificarJob(c, db)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		FotoBase64 string `json:"foto_base64"`
+		Portada    bool   `json:"portada"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+
+	// Acepta "data:image/png;base64,AAAA..." o solo la parte base64
+	raw := strings.TrimSpace(req.FotoBase64)
+	if idx := strings.Index(raw, ","); idx != -1 {
+		raw = raw[idx+1:]
+	}
+	if raw == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "foto_base64 es requerido"})
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "foto_base64 inválido"})
+		return
+	}
+
+	imagen, err := Validar(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	foto, err := Guardar(tx, jobID, imagen, req.Portada, c.Request.Host)
+	if err == ErrLimiteFotos {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		foto.Descartar()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando imagen", "err": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		foto.Descartar()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando imagen", "err": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"mensaje": "Foto agregada",
+		"foto":    foto,
+	})
+}
+
+// -------------------------------
+// DELETE /protected/trabajos/:id/fotos/:foto_id
+// -------------------------------
+func BorrarFotoHandler(c *gin.Context, db *sql.DB) {
+	jobID, ok := verificarJob(c, db)
+	if !ok {
+		return
+	}
+	fotoID, err := strconv.Atoi(c.Param("foto_id"))
+	if err != nil || fotoID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "foto_id inválido"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	var archivo, miniatura string
+	var eraPortada bool
+	err = tx.QueryRow(`
+		DELETE FROM jobs_media WHERE id = $1 AND job_id = $2
+		RETURNING archivo, miniatura, es_portada
+	`, fotoID, jobID).Scan(&archivo, &miniatura, &eraPortada)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Foto no encontrada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al borrar foto"})
+		return
+	}
+
+	// Si era la portada pasa a serlo la siguiente; sin fotos, foto_job queda vacío
+	if eraPortada {
+		var siguiente int
+		err := tx.QueryRow(`
+			SELECT id FROM jobs_media WHERE job_id = $1 ORDER BY orden, id LIMIT 1
+		`, jobID).Scan(&siguiente)
+		switch err {
+		case nil:
+			err = MarcarPortada(tx, jobID, siguiente, c.Request.Host)
+		case sql.ErrNoRows:
+			_, err = tx.Exec(`UPDATE jobs SET foto_job = NULL WHERE id = $1`, jobID)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error actualizando portada"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al borrar foto"})
+		return
+	}
+	BorrarArchivos(archivo, miniatura)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Foto eliminada",
+		"id":      fotoID,
+	})
+}
+
+// -------------------------------
+// PUT /protected/trabajos/:id/fotos/orden  { "ids": [3, 1, 2] }
+// -------------------------------
+func OrdenarFotosHandler(c *gin.Context, db *sql.DB) {
+	jobID, ok := verificarJob(c, db)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		IDs []int `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.IDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids es requerido"})
+		return
+	}
+
+	actuales, err := listarFotos(c, db, jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener fotos"})
+		return
+	}
+
+	// La lista debe traer todas las fotos del trabajo, sin repetir
+	existe := map[int]bool{}
+	for _, f := range actuales {
+		existe[f.ID] = true
+	}
+	vistos := map[int]bool{}
+	for _, id := range req.IDs {
+		if !existe[id] || vistos[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ids debe contener cada foto del trabajo una sola vez"})
+			return
+		}
+		vistos[id] = true
+	}
+	if len(vistos) != len(existe) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids debe contener cada foto del trabajo una sola vez"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	for orden, id := range req.IDs {
+		if _, err := tx.Exec(`UPDATE jobs_media SET orden = $1 WHERE id = $2 AND job_id = $3`, orden, id, jobID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al ordenar fotos"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al ordenar fotos"})
+		return
+	}
+
+	fotos, _ := listarFotos(c, db, jobID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Orden actualizado",
+		"fotos":   fotos,
+	})
+}
+
+// -------------------------------
+// PATCH /protected/trabajos/:id/fotos/:foto_id/portada
+// -------------------------------
+func PortadaHandler(c *gin.Context, db *sql.DB) {
+	jobID, ok := verificarJob(c, db)
+	if !ok {
+		return
+	}
+	fotoID, err := strconv.Atoi(c.Param("foto_id"))
+	if err != nil || fotoID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "foto_id inválido"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	err = MarcarPortada(tx, jobID, fotoID, c.Request.Host)
+	if err == ErrNoEncontrada {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Foto no encontrada"})
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al cambiar portada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Portada actualizada",
+		"portada_id": fotoID,
+	})
+}
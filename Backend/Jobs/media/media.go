@@ -0,0 +1,414 @@
+package media
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Galería de fotos de un trabajo. Cada foto guarda el archivo original
+// y una miniatura JPEG en ./uploads; la portada se copia además a
+// jobs.foto_job para las pantallas que solo muestran una imagen.
+
+const (
+	MaxFotosPorTrabajo = 8
+	MaxBytesFoto       = 5 << 20 // 5 MB por imagen
+	MaxLadoFoto        = 6000    // px, evita imágenes gigantes al decodificar
+	LadoMiniatura      = 320     // px del lado mayor de la miniatura
+	DirUploads         = "./uploads"
+)
+
+var (
+	ErrFormato      = errors.New("formato no soportado, usa PNG o JPEG")
+	ErrMuyGrande    = fmt.Errorf("la imagen supera %d MB", MaxBytesFoto>>20)
+	ErrDimensiones  = fmt.Errorf("la imagen supera %dpx de lado", MaxLadoFoto)
+	ErrLimiteFotos  = fmt.Errorf("el trabajo ya tiene %d fotos", MaxFotosPorTrabajo)
+	ErrNoEncontrada = errors.New("foto no encontrada")
+)
+
+// Ejecutor lo cumplen *sql.DB y *sql.Tx
+type Ejecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type Foto struct {
+	ID           int    `json:"id"`
+	URL          string `json:"url"`
+	MiniaturaURL string `json:"miniatura_url"`
+	Orden        int    `json:"orden"`
+	EsPortada    bool   `json:"es_portada"`
+	Ancho        int    `json:"ancho"`
+	Alto         int    `json:"alto"`
+
+	archivo, miniatura string
+}
+
+// Imagen es una foto ya validada y decodificada, lista para guardarse
+type Imagen struct {
+	data        []byte
+	img         image.Image
+	ext         string
+	ancho, alto int
+}
+
+// Validar revisa tamaño, formato y dimensiones sin tocar la base ni el
+// disco, para rechazar la imagen antes de crear nada
+func Validar(data []byte) (*Imagen, error) {
+	if len(data) > MaxBytesFoto {
+		return nil, ErrMuyGrande
+	}
+	cfg, formato, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (formato != "png" && formato != "jpeg") {
+		return nil, ErrFormato
+	}
+	if cfg.Width > MaxLadoFoto || cfg.Height > MaxLadoFoto {
+		return nil, ErrDimensiones
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrFormato
+	}
+	ext := "png"
+	if formato == "jpeg" {
+		ext = "jpg"
+	}
+	return &Imagen{data: data, img: img, ext: ext, ancho: cfg.Width, alto: cfg.Height}, nil
+}
+
+// Guardar inserta la foto al final de la galería dentro de tx y después
+// escribe original y miniatura. La primera foto queda como portada. Si
+// tx no llega a confirmarse, quien llama borra los archivos con Descartar.
+func Guardar(tx *sql.Tx, jobID int, im *Imagen, portada bool, host string) (Foto, error) {
+	var f Foto
+
+	// Con el job bloqueado dos subidas simultáneas no pasan el límite
+	if _, err := tx.Exec(`SELECT 1 FROM jobs WHERE id = $1 FOR UPDATE`, jobID); err != nil {
+		return f, err
+	}
+	var total int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM jobs_media WHERE job_id = $1`, jobID).Scan(&total); err != nil {
+		return f, err
+	}
+	if total >= MaxFotosPorTrabajo {
+		return f, ErrLimiteFotos
+	}
+
+	base := fmt.Sprintf("job_%d_%d", jobID, time.Now().UnixNano())
+	f.archivo = base + "." + im.ext
+	f.miniatura = base + "_mini.jpg"
+
+	err := tx.QueryRow(`
+		INSERT INTO jobs_media (job_id, archivo, miniatura, orden, es_portada, ancho, alto, bytes)
+		VALUES ($1, $2, $3,
+		        COALESCE((SELECT MAX(orden) + 1 FROM jobs_media WHERE job_id = $1), 0),
+		        false, $4, $5, $6)
+		RETURNING id, orden
+	`, jobID, f.archivo, f.miniatura, im.ancho, im.alto, len(im.data)).Scan(&f.ID, &f.Orden)
+	if err != nil {
+		return f, err
+	}
+	if portada || total == 0 {
+		if err := MarcarPortada(tx, jobID, f.ID, host); err != nil {
+			return f, err
+		}
+		f.EsPortada = true
+	}
+
+	if err := os.MkdirAll(DirUploads, 0755); err != nil {
+		return f, err
+	}
+	if err := os.WriteFile(filepath.Join(DirUploads, f.archivo), im.data, 0644); err != nil {
+		return f, err
+	}
+	if err := escribirMiniatura(filepath.Join(DirUploads, f.miniatura), im.img); err != nil {
+		os.Remove(filepath.Join(DirUploads, f.archivo))
+		return f, err
+	}
+
+	f.URL = URL(host, f.archivo)
+	f.MiniaturaURL = URL(host, f.miniatura)
+	f.Ancho, f.Alto = im.ancho, im.alto
+	return f, nil
+}
+
+// Descartar borra los archivos de una foto cuya fila no se guardó
+func (f Foto) Descartar() {
+	if f.archivo != "" {
+		BorrarArchivos(f.archivo, f.miniatura)
+	}
+}
+
+// URL pública de un archivo de ./uploads (mismo formato que foto_job)
+func URL(host, archivo string) string {
+	return "http://" + host + "/uploads/" + archivo
+}
+
+// MarcarPortada deja una sola portada por trabajo y la copia a jobs.foto_job
+func MarcarPortada(q Ejecutor, jobID, fotoID int, host string) error {
+	var archivo string
+	err := q.QueryRow(`
+		SELECT archivo FROM jobs_media WHERE id = $1 AND job_id = $2
+	`, fotoID, jobID).Scan(&archivo)
+	if err == sql.ErrNoRows {
+		return ErrNoEncontrada
+	}
+	if err != nil {
+		return err
+	}
+	// En dos pasos para no chocar con el índice único de portada
+	if _, err := q.Exec(`
+		UPDATE jobs_media SET es_portada = false WHERE job_id = $1 AND es_portada AND id <> $2
+	`, jobID, fotoID); err != nil {
+		return err
+	}
+	if _, err := q.Exec(`UPDATE jobs_media SET es_portada = true WHERE id = $1`, fotoID); err != nil {
+		return err
+	}
+	_, err = q.Exec(`UPDATE jobs SET foto_job = $1 WHERE id = $2`, URL(host, archivo), jobID)
+	return err
+}
+
+// Galerias carga las fotos de varios trabajos de una vez (para feeds)
+func Galerias(db *sql.DB, jobIDs []int, baseURL string) (map[int][]Foto, error) {
+	res := map[int][]Foto{}
+	if len(jobIDs) == 0 {
+		return res, nil
+	}
+	ids := make([]int64, len(jobIDs))
+	for i, id := range jobIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := db.Query(`
+		SELECT job_id, id, archivo, miniatura, orden, es_portada, ancho, alto
+		FROM jobs_media
+		WHERE job_id = ANY($1)
+		ORDER BY job_id, orden, id
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var jobID int
+		var f Foto
+		var archivo, miniatura string
+		if err := rows.Scan(&jobID, &f.ID, &archivo, &miniatura, &f.Orden, &f.EsPortada, &f.Ancho, &f.Alto); err != nil {
+			return nil, err
+		}
+		f.URL = baseURL + archivo
+		f.MiniaturaURL = baseURL + miniatura
+		res[jobID] = append(res[jobID], f)
+	}
+	return res, rows.Err()
+}
+
+// Portada devuelve la foto de portada de una galería (o nil)
+func Portada(fotos []Foto) *Foto {
+	for i := range fotos {
+		if fotos[i].EsPortada {
+			return &fotos[i]
+		}
+	}
+	if len(fotos) > 0 {
+		return &fotos[0]
+	}
+	return nil
+}
+
+// Copiar duplica la galería de un trabajo en otro. Los archivos se
+// enlazan (hard link) con nombres propios del destino, así borrar las
+// fotos de uno no afecta al otro.
+func Copiar(db *sql.DB, origenID, destinoID int, host string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT archivo, miniatura, orden, es_portada, ancho, alto, bytes
+		FROM jobs_media WHERE job_id = $1 ORDER BY orden, id
+	`, origenID)
+	if err != nil {
+		return err
+	}
+	type fila struct {
+		archivo, miniatura         string
+		orden, ancho, alto, tamano int
+		portada                    bool
+	}
+	var filas []fila
+	for rows.Next() {
+		var f fila
+		if err := rows.Scan(&f.archivo, &f.miniatura, &f.orden, &f.portada, &f.ancho, &f.alto, &f.tamano); err != nil {
+			rows.Close()
+			return err
+		}
+		filas = append(filas, f)
+	}
+	rows.Close()
+
+	var creados []Foto
+	descartar := func() {
+		for _, f := range creados {
+			f.Descartar()
+		}
+	}
+	for i, f := range filas {
+		base := fmt.Sprintf("job_%d_%d_%d", destinoID, time.Now().UnixNano(), i)
+		archivo := base + filepath.Ext(f.archivo)
+		miniatura := base + "_mini.jpg"
+		if err := os.Link(filepath.Join(DirUploads, f.archivo), filepath.Join(DirUploads, archivo)); err != nil {
+			descartar()
+			return err
+		}
+		if err := os.Link(filepath.Join(DirUploads, f.miniatura), filepath.Join(DirUploads, miniatura)); err != nil {
+			miniatura = archivo
+		}
+		creados = append(creados, Foto{archivo: archivo, miniatura: miniatura})
+
+		var id int
+		if err := tx.QueryRow(`
+			INSERT INTO jobs_media (job_id, archivo, miniatura, orden, es_portada, ancho, alto, bytes)
+			VALUES ($1, $2, $3, $4, false, $5, $6, $7)
+			RETURNING id
+		`, destinoID, archivo, miniatura, f.orden, f.ancho, f.alto, f.tamano).Scan(&id); err != nil {
+			descartar()
+			return err
+		}
+		if f.portada {
+			if err := MarcarPortada(tx, destinoID, id, host); err != nil {
+				descartar()
+				return err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		descartar()
+		return err
+	}
+	return nil
+}
+
+// BorrarArchivos quita original y miniatura del disco
+func BorrarArchivos(archivo, miniatura string) {
+	os.Remove(filepath.Join(DirUploads, archivo))
+	if miniatura != archivo {
+		os.Remove(filepath.Join(DirUploads, miniatura))
+	}
+}
+
+// ======================== MINIATURAS ========================
+
+func escribirMiniatura(ruta string, img image.Image) error {
+	out, err := os.Create(ruta)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(out, reducir(img, LadoMiniatura), &jpeg.Options{Quality: 80}); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// reducir escala la imagen para que su lado mayor mida max px,
+// promediando los píxeles de origen que caen en cada píxel destino
+func reducir(img image.Image, max int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return sinTransparencia(img)
+	}
+
+	nw, nh := max, h*max/w
+	if h > w {
+		nw, nh = w*max/h, max
+	}
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	for y := 0; y < nh; y++ {
+		y0 := b.Min.Y + y*h/nh
+		y1 := b.Min.Y + (y+1)*h/nh
+		for x := 0; x < nw; x++ {
+			x0 := b.Min.X + x*w/nw
+			x1 := b.Min.X + (x+1)*w/nw
+
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					// Fondo blanco donde hay transparencia (JPEG no tiene alfa)
+					blanco := 0xffff - pa
+					r += uint64(pr + blanco)
+					g += uint64(pg + blanco)
+					bl += uint64(pb + blanco)
+					n++
+				}
+			}
+			if n == 0 {
+				n = 1
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
+
+// sinTransparencia pinta sobre blanco las imágenes pequeñas con alfa
+func sinTransparencia(img image.Image) image.Image {
+	if _, ok := img.(*image.YCbCr); ok {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			pr, pg, pb, pa := img.At(x, y).RGBA()
+			blanco := 0xffff - pa
+			dst.Set(x, y, color.RGBA64{R: uint16(pr + blanco), G: uint16(pg + blanco), B: uint16(pb + blanco), A: 0xffff})
+		}
+	}
+	return dst
+}
+
+// BorrarGaleria elimina todas las fotos de un trabajo (filas y archivos)
+func BorrarGaleria(db *sql.DB, jobID int) error {
+	rows, err := db.Query(`
+		DELETE FROM jobs_media WHERE job_id = $1 RETURNING archivo, miniatura
+	`, jobID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var archivo, miniatura string
+		if err := rows.Scan(&archivo, &miniatura); err != nil {
+			return err
+		}
+		BorrarArchivos(archivo, miniatura)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,118 @@
+package preguntas
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verificarJob comprueba que el job exista y sea del empleador
+func verificarJob(c *gin.Context, db *sql.DB) (int, bool) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return 0, false
+	}
+	empleadorID := userIDInterface.(int)
+
+	jobID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || jobID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, false
+	}
+
+	var ownerID int
+	err = db.QueryRow(`SELECT empleador_id FROM jobs WHERE id = $1 AND eliminado_en IS NULL`, jobID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo no encontrado"})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer trabajo"})
+		return 0, false
+	}
+	if ownerID != empleadorID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No puedes editar este trabajo"})
+		return 0, false
+	}
+	return jobID, true
+}
+
+// -------------------------------
+// GET /protected/trabajos/:id/preguntas
+// -------------------------------
+func ListarPreguntasHandler(c *gin.Context, db *sql.DB) {
+	jobID, ok := verificarJob(c, db)
+	if !ok {
+		return
+	}
+
+	ps, err := DeJob(db, jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener preguntas", "err": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"preguntas": ps})
+}
+
+// -------------------------------
+// PUT /protected/trabajos/:id/preguntas  { "preguntas": [...] }
+// Reemplaza todas las preguntas. Una vez que algún estudiante respondió
+// ya no se pueden cambiar, para no mezclar respuestas de versiones distintas.
+// -------------------------------
+func GuardarPreguntasHandler(c *gin.Context, db *sql.DB) {
+	jobID, ok := verificarJob(c, db)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Preguntas []Pregunta `json:"preguntas"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	if msg := Validar(req.Preguntas); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	var respondidas bool
+	if err := tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM respuestas_preguntas WHERE job_id = $1)
+	`, jobID).Scan(&respondidas); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al revisar respuestas"})
+		return
+	}
+	if respondidas {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ya hay estudiantes que respondieron; las preguntas no se pueden cambiar"})
+		return
+	}
+
+	if err := Guardar(tx, jobID, req.Preguntas); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar preguntas", "err": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar preguntas"})
+		return
+	}
+
+	if req.Preguntas == nil {
+		req.Preguntas = []Pregunta{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Preguntas actualizadas",
+		"preguntas": req.Preguntas,
+	})
+}
@@ -0,0 +1,348 @@
+package preguntas
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Preguntas de filtro que el empleador pone a un trabajo. El estudiante
+// las responde al dar like. Una respuesta de descarte (knockout) no
+// bloquea el like, pero marca al candidato como descartado: no genera
+// match automático y por defecto no aparece en la lista del empleador.
+
+const (
+	TipoSiNo   = "si_no"
+	TipoOpcion = "opcion"
+	TipoTexto  = "texto"
+
+	MaxPreguntas      = 10
+	MaxOpciones       = 10
+	MaxLargoPregunta  = 300
+	MaxLargoOpcion    = 100
+	MaxLargoRespuesta = 500
+)
+
+// Ejecutor permite usar tanto *sql.DB como *sql.Tx
+type Ejecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type Pregunta struct {
+	ID          int      `json:"id"`
+	Texto       string   `json:"texto"`
+	Tipo        string   `json:"tipo"` // si_no | opcion | texto
+	Opciones    []string `json:"opciones,omitempty"`
+	Obligatoria bool     `json:"obligatoria"`
+	// Respuestas que descartan al candidato; no se envían a los estudiantes
+	Descarte []string `json:"respuestas_descarte,omitempty"`
+	Orden    int      `json:"orden"`
+}
+
+type Respuesta struct {
+	PreguntaID int    `json:"pregunta_id"`
+	Respuesta  string `json:"respuesta"`
+}
+
+// RespuestaVista es una respuesta como la ve el empleador
+type RespuestaVista struct {
+	PreguntaID int    `json:"pregunta_id"`
+	Pregunta   string `json:"pregunta"`
+	Respuesta  string `json:"respuesta"`
+	Descarta   bool   `json:"descarta"`
+}
+
+// normalizarSiNo acepta si/sí/no y true/false
+func normalizarSiNo(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "si", "sí", "true":
+		return "si", true
+	case "no", "false":
+		return "no", true
+	}
+	return "", false
+}
+
+// Validar normaliza las preguntas de un trabajo y devuelve un mensaje si
+// alguna es inválida
+func Validar(ps []Pregunta) string {
+	if len(ps) > MaxPreguntas {
+		return "máximo " + strconv.Itoa(MaxPreguntas) + " preguntas por trabajo"
+	}
+	for i := range ps {
+		p := &ps[i]
+		n := "pregunta " + strconv.Itoa(i+1) + ": "
+		p.Texto = strings.TrimSpace(p.Texto)
+		if p.Texto == "" || len(p.Texto) > MaxLargoPregunta {
+			return n + "texto es requerido (máx. " + strconv.Itoa(MaxLargoPregunta) + " caracteres)"
+		}
+		p.Orden = i
+
+		switch p.Tipo {
+		case TipoSiNo:
+			p.Opciones = nil
+			for j, d := range p.Descarte {
+				v, ok := normalizarSiNo(d)
+				if !ok {
+					return n + "las respuestas de descarte deben ser si o no"
+				}
+				p.Descarte[j] = v
+			}
+		case TipoOpcion:
+			if len(p.Opciones) < 2 || len(p.Opciones) > MaxOpciones {
+				return n + "debe tener entre 2 y " + strconv.Itoa(MaxOpciones) + " opciones"
+			}
+			vistas := map[string]bool{}
+			for j, o := range p.Opciones {
+				o = strings.TrimSpace(o)
+				if o == "" || len(o) > MaxLargoOpcion || vistas[o] {
+					return n + "opciones vacías, repetidas o muy largas"
+				}
+				vistas[o] = true
+				p.Opciones[j] = o
+			}
+			for j, d := range p.Descarte {
+				d = strings.TrimSpace(d)
+				if !vistas[d] {
+					return n + "las respuestas de descarte deben ser opciones de la pregunta"
+				}
+				p.Descarte[j] = d
+			}
+		case TipoTexto:
+			p.Opciones = nil
+			// Una respuesta libre no se puede descartar automáticamente
+			if len(p.Descarte) > 0 {
+				return n + "las preguntas de texto no admiten respuestas de descarte"
+			}
+		default:
+			return n + "tipo debe ser si_no, opcion o texto"
+		}
+	}
+	return ""
+}
+
+// Guardar reemplaza las preguntas del trabajo (ya validadas)
+func Guardar(q Ejecutor, jobID int, ps []Pregunta) error {
+	if _, err := q.Exec(`DELETE FROM jobs_preguntas WHERE job_id = $1`, jobID); err != nil {
+		return err
+	}
+	for i := range ps {
+		p := &ps[i]
+		err := q.QueryRow(`
+			INSERT INTO jobs_preguntas (job_id, texto, tipo, opciones, obligatoria, respuestas_descarte, orden)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`, jobID, p.Texto, p.Tipo, arreglo(p.Opciones), p.Obligatoria, arreglo(p.Descarte), p.Orden).Scan(&p.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// arreglo evita guardar NULL cuando la lista viene vacía
+func arreglo(s []string) interface{} {
+	if s == nil {
+		s = []string{}
+	}
+	return pq.Array(s)
+}
+
+func ids64(jobIDs []int) []int64 {
+	ids := make([]int64, len(jobIDs))
+	for i, id := range jobIDs {
+		ids[i] = int64(id)
+	}
+	return ids
+}
+
+// Copiar duplica las preguntas de un trabajo en otro
+func Copiar(q Ejecutor, origen, destino int) error {
+	_, err := q.Exec(`
+		INSERT INTO jobs_preguntas (job_id, texto, tipo, opciones, obligatoria, respuestas_descarte, orden)
+		SELECT $2, texto, tipo, opciones, obligatoria, respuestas_descarte, orden
+		FROM jobs_preguntas WHERE job_id = $1
+	`, origen, destino)
+	return err
+}
+
+// DeJobs devuelve las preguntas de varios trabajos en una sola consulta
+func DeJobs(db *sql.DB, jobIDs []int) (map[int][]Pregunta, error) {
+	res := map[int][]Pregunta{}
+	if len(jobIDs) == 0 {
+		return res, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT job_id, id, texto, tipo, opciones, obligatoria, respuestas_descarte, orden
+		FROM jobs_preguntas
+		WHERE job_id = ANY($1)
+		ORDER BY job_id, orden, id
+	`, pq.Array(ids64(jobIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var jobID int
+		var p Pregunta
+		var opciones, descarte pq.StringArray
+		if err := rows.Scan(&jobID, &p.ID, &p.Texto, &p.Tipo, &opciones, &p.Obligatoria, &descarte, &p.Orden); err != nil {
+			return nil, err
+		}
+		p.Opciones = opciones
+		p.Descarte = descarte
+		res[jobID] = append(res[jobID], p)
+	}
+	return res, rows.Err()
+}
+
+// DeJob devuelve las preguntas de un trabajo (lista vacía si no tiene)
+func DeJob(db *sql.DB, jobID int) ([]Pregunta, error) {
+	m, err := DeJobs(db, []int{jobID})
+	if err != nil {
+		return nil, err
+	}
+	if m[jobID] == nil {
+		return []Pregunta{}, nil
+	}
+	return m[jobID], nil
+}
+
+// Publicas quita las respuestas de descarte antes de mostrarlas al estudiante
+func Publicas(ps []Pregunta) []Pregunta {
+	out := make([]Pregunta, len(ps))
+	for i, p := range ps {
+		p.Descarte = nil
+		out[i] = p
+	}
+	return out
+}
+
+// Revisar valida las respuestas de un estudiante contra las preguntas.
+// Devuelve las respuestas normalizadas, si alguna lo descarta y un
+// mensaje de error para el estudiante.
+func Revisar(ps []Pregunta, resps []Respuesta) ([]Respuesta, bool, string) {
+	porID := map[int]string{}
+	for _, r := range resps {
+		porID[r.PreguntaID] = strings.TrimSpace(r.Respuesta)
+	}
+
+	var validas []Respuesta
+	var faltan []string
+	descartado := false
+	conocidas := map[int]bool{}
+	for _, p := range ps {
+		conocidas[p.ID] = true
+		v, ok := porID[p.ID]
+		if !ok || v == "" {
+			if p.Obligatoria {
+				faltan = append(faltan, strconv.Itoa(p.ID))
+			}
+			continue
+		}
+
+		switch p.Tipo {
+		case TipoSiNo:
+			n, ok := normalizarSiNo(v)
+			if !ok {
+				return nil, false, "la pregunta " + strconv.Itoa(p.ID) + " se responde con si o no"
+			}
+			v = n
+		case TipoOpcion:
+			valida := false
+			for _, o := range p.Opciones {
+				if o == v {
+					valida = true
+					break
+				}
+			}
+			if !valida {
+				return nil, false, "respuesta no válida para la pregunta " + strconv.Itoa(p.ID)
+			}
+		case TipoTexto:
+			if len(v) > MaxLargoRespuesta {
+				return nil, false, "la respuesta a la pregunta " + strconv.Itoa(p.ID) + " es muy larga"
+			}
+		}
+
+		for _, d := range p.Descarte {
+			if d == v {
+				descartado = true
+			}
+		}
+		validas = append(validas, Respuesta{PreguntaID: p.ID, Respuesta: v})
+	}
+	for id := range porID {
+		if !conocidas[id] {
+			return nil, false, "la pregunta " + strconv.Itoa(id) + " no es de este trabajo"
+		}
+	}
+	if len(faltan) > 0 {
+		return nil, false, "faltan respuestas obligatorias: preguntas " + strings.Join(faltan, ", ")
+	}
+	return validas, descartado, ""
+}
+
+// GuardarRespuestas reemplaza las respuestas del estudiante para el trabajo
+func GuardarRespuestas(q Ejecutor, estudianteID, jobID int, resps []Respuesta, descartado bool) error {
+	if _, err := q.Exec(`
+		DELETE FROM respuestas_preguntas WHERE estudiante_id = $1 AND job_id = $2
+	`, estudianteID, jobID); err != nil {
+		return err
+	}
+	for _, r := range resps {
+		if _, err := q.Exec(`
+			INSERT INTO respuestas_preguntas (estudiante_id, job_id, pregunta_id, respuesta)
+			VALUES ($1, $2, $3, $4)
+		`, estudianteID, jobID, r.PreguntaID, r.Respuesta); err != nil {
+			return err
+		}
+	}
+	_, err := q.Exec(`
+		UPDATE intereses_estudiante SET descartado = $3
+		WHERE estudiante_id = $1 AND job_id = $2
+	`, estudianteID, jobID, descartado)
+	return err
+}
+
+// Clave identifica las respuestas de un estudiante a un trabajo
+type Clave struct {
+	EstudianteID int
+	JobID        int
+}
+
+// RespuestasDeJobs devuelve las respuestas de todos los candidatos de esos trabajos
+func RespuestasDeJobs(db *sql.DB, jobIDs []int) (map[Clave][]RespuestaVista, error) {
+	res := map[Clave][]RespuestaVista{}
+	if len(jobIDs) == 0 {
+		return res, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT r.estudiante_id, r.job_id, p.id, p.texto, r.respuesta,
+		       r.respuesta = ANY(p.respuestas_descarte)
+		FROM respuestas_preguntas r
+		JOIN jobs_preguntas p ON p.id = r.pregunta_id
+		WHERE r.job_id = ANY($1)
+		ORDER BY r.job_id, r.estudiante_id, p.orden
+	`, pq.Array(ids64(jobIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k Clave
+		var r RespuestaVista
+		if err := rows.Scan(&k.EstudianteID, &k.JobID, &r.PreguntaID, &r.Pregunta, &r.Respuesta, &r.Descarta); err != nil {
+			return nil, err
+		}
+		res[k] = append(res[k], r)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,229 @@
+package recurrentes
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/gin-gonic/gin"
+)
+
+type EstudianteTurno struct {
+	MatchID      int    `json:"match_id"`
+	EstudianteID int    `json:"estudiante_id"`
+	Nombre       string `json:"nombre"`
+	Apellido     string `json:"apellido"`
+	Estado       string `json:"estado"`
+}
+
+type Turno struct {
+	JobID         int               `json:"job_id"`
+	RecurrenteID  *int              `json:"recurrente_id"`
+	Titulo        string            `json:"titulo"`
+	FechaInicio   string            `json:"fecha_inicio"`
+	FechaFin      *string           `json:"fecha_fin"`
+	Estado        string            `json:"estado"`
+	Cupos         int               `json:"cupos"`
+	CuposOcupados int               `json:"cupos_ocupados"`
+	Likes         int               `json:"likes_pendientes"`
+	Estudiantes   []EstudianteTurno `json:"estudiantes"`
+}
+
+// -------------------------------
+// GET /protected/turnos/calendario?desde=2026-10-01&hasta=2026-10-31
+// Todos los trabajos del empleador con fecha en el rango (turnos de
+// recurrentes y trabajos sueltos), agrupados por día.
+// -------------------------------
+func CalendarioTurnosHandler(c *gin.Context, db *sql.DB) {
+	empleadorID, ok := getEmpleadorID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	hoy := time.Now().In(fechas.ZonaEcuador)
+	desde := time.Date(hoy.Year(), hoy.Month(), 1, 0, 0, 0, 0, fechas.ZonaEcuador)
+	if s := c.Query("desde"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, fechas.ZonaEcuador)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "desde inválida, usa AAAA-MM-DD"})
+			return
+		}
+		desde = t
+	}
+	hasta := desde.AddDate(0, 1, 0)
+	if s := c.Query("hasta"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, fechas.ZonaEcuador)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hasta inválida, usa AAAA-MM-DD"})
+			return
+		}
+		hasta = t.AddDate(0, 0, 1)
+	}
+	if !hasta.After(desde) || hasta.Sub(desde) > 93*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El rango debe ser positivo y de máximo 3 meses"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT
+			j.id, j.recurrente_id, j.titulo, j.fecha_inicio, j.fecha_fin, j.estado,
+			j.cupos, j.cupos_ocupados,
+			(SELECT COUNT(*) FROM intereses_estudiante ie
+			 WHERE ie.job_id = j.id AND ie.interesado = true
+			   AND NOT EXISTS (
+				SELECT 1 FROM matches_job m
+				WHERE m.job_id = j.id AND m.estudiante_id = ie.estudiante_id
+			   )) AS likes
+		FROM jobs j
+		WHERE j.empleador_id = $1
+		  AND j.fecha_inicio >= $2
+		  AND j.fecha_inicio < $3
+		  AND j.estado <> 'borrador'
+		  AND j.eliminado_en IS NULL
+		ORDER BY j.fecha_inicio
+	`, empleadorID, desde, hasta)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar turnos", "err": err.Error()})
+		return
+	}
+
+	turnos := []Turno{}
+	indice := map[int]int{}
+	var ids []int
+
+	for rows.Next() {
+		var t Turno
+		var recID sql.NullInt64
+		var inicio time.Time
+		var fin sql.NullTime
+		if err := rows.Scan(&t.JobID, &recID, &t.Titulo, &inicio, &fin, &t.Estado,
+			&t.Cupos, &t.CuposOcupados, &t.Likes); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo turnos", "err": err.Error()})
+			return
+		}
+		if recID.Valid {
+			v := int(recID.Int64)
+			t.RecurrenteID = &v
+		}
+		t.FechaInicio = inicio.In(fechas.ZonaEcuador).Format(time.RFC3339)
+		if fin.Valid {
+			f := fin.Time.In(fechas.ZonaEcuador).Format(time.RFC3339)
+			t.FechaFin = &f
+		}
+		t.Estudiantes = []EstudianteTurno{}
+
+		indice[t.JobID] = len(turnos)
+		ids = append(ids, t.JobID)
+		turnos = append(turnos, t)
+	}
+	rows.Close()
+
+	// Estudiantes contratados en cada turno
+	if len(ids) > 0 {
+		mrows, err := db.Query(`
+			SELECT m.job_id, m.id, e.id, e.nombre, e.apellido, COALESCE(m.estado, 'en_progreso')
+			FROM matches_job m
+			JOIN estudiantes e ON e.id = m.estudiante_id
+			JOIN jobs j ON j.id = m.job_id
+			WHERE j.empleador_id = $1
+			  AND j.fecha_inicio >= $2
+			  AND j.fecha_inicio < $3
+			  AND m.is_match = true
+			  AND j.eliminado_en IS NULL
+		`, empleadorID, desde, hasta)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar estudiantes", "err": err.Error()})
+			return
+		}
+		defer mrows.Close()
+
+		for mrows.Next() {
+			var jobID int
+			var e EstudianteTurno
+			if err := mrows.Scan(&jobID, &e.MatchID, &e.EstudianteID, &e.Nombre, &e.Apellido, &e.Estado); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo estudiantes", "err": err.Error()})
+				return
+			}
+			if i, ok := indice[jobID]; ok {
+				turnos[i].Estudiantes = append(turnos[i].Estudiantes, e)
+			}
+		}
+	}
+
+	// Agrupar por día (hora de Ecuador)
+	porDia := map[string][]Turno{}
+	for _, t := range turnos {
+		dia := t.FechaInicio[:10]
+		porDia[dia] = append(porDia[dia], t)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"desde":   desde.Format("2006-01-02"),
+		"hasta":   hasta.AddDate(0, 0, -1).Format("2006-01-02"),
+		"total":   len(turnos),
+		"turnos":  turnos,
+		"por_dia": porDia,
+	})
+}
+
+// -------------------------------
+// POST /protected/turnos/cancelar  { "job_id": 1 }
+// Cancela un turno puntual sin tocar el resto de la serie
+// -------------------------------
+func CancelarTurnoHandler(c *gin.Context, db *sql.DB) {
+	empleadorID, ok := getEmpleadorID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var body struct {
+		JobID int `json:"job_id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.JobID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "job_id es requerido"})
+		return
+	}
+
+	var ownerID, ocupados int
+	var recID sql.NullInt64
+	var estado string
+	err := db.QueryRow(`
+		SELECT empleador_id, recurrente_id, estado, cupos_ocupados FROM jobs WHERE id = $1 AND eliminado_en IS NULL
+	`, body.JobID).Scan(&ownerID, &recID, &estado, &ocupados)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Turno no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer turno"})
+		return
+	}
+	if ownerID != empleadorID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No puedes cancelar este turno"})
+		return
+	}
+	if !recID.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El trabajo no pertenece a una serie recurrente"})
+		return
+	}
+	if estado != "abierto" || ocupados > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Solo se pueden cancelar turnos abiertos sin estudiantes contratados; cancela primero los matches"})
+		return
+	}
+
+	if _, err := db.Exec(`
+		UPDATE jobs SET estado = 'cancelado', actualizado_en = NOW() WHERE id = $1
+	`, body.JobID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al cancelar turno"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mensaje": "Turno cancelado",
+		"job_id":  body.JobID,
+	})
+}
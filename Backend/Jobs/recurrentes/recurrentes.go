@@ -0,0 +1,625 @@
+package recurrentes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/categorias"
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/gin-gonic/gin"
+)
+
+// Un trabajo recurrente es la definición (qué, cuándo se repite);
+// cada turno concreto es una fila normal de jobs con recurrente_id.
+// Así likes, matches, completar y valorar funcionan por turno con
+// el flujo de matches_job que ya existe.
+
+const (
+	// Cuántas semanas hacia adelante se generan turnos
+	HorizonteSemanas = 4
+	MaxCupos         = 50
+)
+
+type RecurrenteRequest struct {
+	Titulo          string  `json:"titulo"`
+	Descripcion     string  `json:"descripcion"`
+	Ubicacion       string  `json:"ubicacion"`
+	PagoEstimado    float64 `json:"pago_estimado"` // en dólares; compensacion lo reemplaza
+	Negociable      bool    `json:"negociable"`
+	Requisitos      string  `json:"requisitos"`
+	Categoria       string  `json:"categoria"`
+	CategoriaID     int     `json:"categoria_id"` // alternativa a categoria
+	MetodoPago      string  `json:"metodo_pago"`
+	Presencial      bool    `json:"presencial"`
+	Cupos           int     `json:"cupos"`
+	Regla           string  `json:"regla"`            // ej: "FREQ=WEEKLY;BYDAY=SA,SU"
+	HoraInicio      string  `json:"hora_inicio"`      // "18:00" hora de Ecuador
+	DuracionMinutos int     `json:"duracion_minutos"` // duración de cada turno
+	Desde           string  `json:"desde"`            // "2026-10-24"
+	Hasta           string  `json:"hasta"`            // opcional
+
+	Compensacion *compensacion.Entrada `json:"compensacion"`
+
+	// comp y categoriaID ya validados se guardan y se copian a los turnos
+	comp        compensacion.Compensacion
+	categoriaID sql.NullInt64
+}
+
+type Recurrente struct {
+	ID              int     `json:"id"`
+	Titulo          string  `json:"titulo"`
+	Descripcion     string  `json:"descripcion"`
+	Ubicacion       string  `json:"ubicacion"`
+	PagoEstimado    float64 `json:"pago_estimado"`
+	Negociable      bool    `json:"negociable"`
+	Requisitos      string  `json:"requisitos"`
+	Categoria       string  `json:"categoria"`
+	CategoriaID     *int    `json:"categoria_id"`
+	MetodoPago      string  `json:"metodo_pago"`
+	Presencial      bool    `json:"presencial"`
+	Cupos           int     `json:"cupos"`
+	Regla           string  `json:"regla"`
+	HoraInicio      string  `json:"hora_inicio"`
+	DuracionMinutos int     `json:"duracion_minutos"`
+	Desde           string  `json:"desde"`
+	Hasta           *string `json:"hasta"`
+	Activo          bool    `json:"activo"`
+	ProximosTurnos  int     `json:"proximos_turnos"`
+
+	Compensacion compensacion.Compensacion `json:"compensacion"`
+}
+
+func getEmpleadorID(c *gin.Context) (int, bool) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		return 0, false
+	}
+	return userIDInterface.(int), true
+}
+
+// validar normaliza el request y devuelve la regla, la hora y las fechas parseadas
+func (req *RecurrenteRequest) validar() (Regla, time.Time, *time.Time, error) {
+	var hasta *time.Time
+
+	if strings.TrimSpace(req.Titulo) == "" {
+		return Regla{}, time.Time{}, nil, fmt.Errorf("titulo es requerido")
+	}
+
+	regla, err := ParseRegla(req.Regla)
+	if err != nil {
+		return Regla{}, time.Time{}, nil, fmt.Errorf("regla inválida: %v", err)
+	}
+	req.Regla = regla.String()
+
+	hora, err := time.Parse("15:04", req.HoraInicio)
+	if err != nil {
+		return Regla{}, time.Time{}, nil, fmt.Errorf("hora_inicio inválida, usa HH:MM")
+	}
+
+	if req.DuracionMinutos < 15 || req.DuracionMinutos > 24*60 {
+		return Regla{}, time.Time{}, nil, fmt.Errorf("duracion_minutos debe estar entre 15 y 1440")
+	}
+
+	if req.Cupos == 0 {
+		req.Cupos = 1
+	}
+	if req.Cupos < 1 || req.Cupos > MaxCupos {
+		return Regla{}, time.Time{}, nil, fmt.Errorf("cupos debe estar entre 1 y %d", MaxCupos)
+	}
+
+	// Misma compensación que un trabajo normal; los turnos se publican
+	// al generarse, así que ya debe poder publicarse
+	comp, msg := compensacion.Nueva(compensacion.DesdeDecimal(req.PagoEstimado), "", nil).Aplicar(req.Compensacion)
+	if msg == "" {
+		msg = comp.ValidarPublicacion()
+	}
+	if msg != "" {
+		return Regla{}, time.Time{}, nil, fmt.Errorf("%s", msg)
+	}
+	req.comp = comp
+	req.PagoEstimado = comp.Monto
+
+	desde := time.Now().In(fechas.ZonaEcuador)
+	if req.Desde != "" {
+		desde, err = time.ParseInLocation("2006-01-02", req.Desde, fechas.ZonaEcuador)
+		if err != nil {
+			return Regla{}, time.Time{}, nil, fmt.Errorf("desde inválida, usa AAAA-MM-DD")
+		}
+	}
+	inicio := time.Date(desde.Year(), desde.Month(), desde.Day(), hora.Hour(), hora.Minute(), 0, 0, fechas.ZonaEcuador)
+
+	if req.Hasta != "" {
+		h, err := time.ParseInLocation("2006-01-02", req.Hasta, fechas.ZonaEcuador)
+		if err != nil {
+			return Regla{}, time.Time{}, nil, fmt.Errorf("hasta inválida, usa AAAA-MM-DD")
+		}
+		h = h.Add(24*time.Hour - time.Second)
+		if h.Before(inicio) {
+			return Regla{}, time.Time{}, nil, fmt.Errorf("hasta debe ser posterior a desde")
+		}
+		hasta = &h
+	}
+
+	if !req.Presencial {
+		req.Ubicacion = ""
+	}
+
+	return regla, inicio, hasta, nil
+}
+
+// elegirCategoria valida categoria o categoria_id igual que al crear un
+// trabajo y deja el nombre oficial. Devuelve un mensaje si no existe.
+func (req *RecurrenteRequest) elegirCategoria(db *sql.DB) (string, error) {
+	cat, err := categorias.Elegir(db, req.CategoriaID, req.Categoria)
+	if err == categorias.ErrNoEncontrada {
+		return "categoria inválida", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	req.categoriaID = sql.NullInt64{}
+	if cat != nil {
+		req.Categoria = cat.Nombre
+		req.categoriaID = sql.NullInt64{Int64: int64(cat.ID), Valid: true}
+	}
+	return "", nil
+}
+
+// -------------------------------
+// POST /protected/trabajos-recurrentes
+// -------------------------------
+func CrearRecurrenteHandler(c *gin.Context, db *sql.DB) {
+	empleadorID, ok := getEmpleadorID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var req RecurrenteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+
+	_, inicio, hasta, err := req.validar()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	msg, err := req.elegirCategoria(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar categoria", "err": err.Error()})
+		return
+	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var id int
+	err = db.QueryRow(`
+		INSERT INTO jobs_recurrentes
+		(empleador_id, titulo, descripcion, ubicacion, pago_estimado, negociable, requisitos,
+		 categoria, metodo_pago, presencial, cupos, regla, inicio, duracion_minutos, hasta, activo,
+		 moneda, unidad_pago, horas_estimadas, categoria_id)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,true,$16,$17,$18,$19)
+		RETURNING id
+	`, empleadorID, req.Titulo, req.Descripcion, req.Ubicacion, req.comp.Decimal(), req.Negociable,
+		req.Requisitos, req.Categoria, req.MetodoPago, req.Presencial, req.Cupos,
+		req.Regla, inicio, req.DuracionMinutos, hasta,
+		req.comp.Moneda, req.comp.Unidad, req.comp.HorasEstimadas, req.categoriaID).Scan(&id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear trabajo recurrente", "err": err.Error()})
+		return
+	}
+
+	generados, err := generarTurnos(db, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generando turnos", "err": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"mensaje":          "Trabajo recurrente creado",
+		"id":               id,
+		"regla":            req.Regla,
+		"turnos_generados": generados,
+		"compensacion":     req.comp,
+		"advertencias":     req.comp.Advertencias(),
+	})
+}
+
+// -------------------------------
+// GET /protected/trabajos-recurrentes
+// -------------------------------
+func ListarRecurrentesHandler(c *gin.Context, db *sql.DB) {
+	empleadorID, ok := getEmpleadorID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT r.id, r.titulo, r.descripcion, r.ubicacion, r.pago_estimado, r.negociable,
+		       r.requisitos, r.categoria, r.metodo_pago, r.presencial, r.cupos,
+		       r.regla, r.inicio, r.duracion_minutos, r.hasta, r.activo,
+		       r.unidad_pago, r.horas_estimadas, r.categoria_id,
+		       (SELECT COUNT(*) FROM jobs j
+		        WHERE j.recurrente_id = r.id AND j.fecha_inicio > NOW()
+		          AND j.estado IN ('abierto', 'cubierto') AND j.eliminado_en IS NULL)
+		FROM jobs_recurrentes r
+		WHERE r.empleador_id = $1
+		ORDER BY r.creado_en DESC
+	`, empleadorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener trabajos recurrentes", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	lista := []Recurrente{}
+	for rows.Next() {
+		var r Recurrente
+		var inicio time.Time
+		var hasta sql.NullTime
+		var unidad string
+		var horas sql.NullFloat64
+		var categoriaID sql.NullInt64
+		if err := rows.Scan(
+			&r.ID, &r.Titulo, &r.Descripcion, &r.Ubicacion, &r.PagoEstimado, &r.Negociable,
+			&r.Requisitos, &r.Categoria, &r.MetodoPago, &r.Presencial, &r.Cupos,
+			&r.Regla, &inicio, &r.DuracionMinutos, &hasta, &r.Activo,
+			&unidad, &horas, &categoriaID, &r.ProximosTurnos,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo trabajos recurrentes", "err": err.Error()})
+			return
+		}
+		r.Compensacion = compensacion.DesdeBD(r.PagoEstimado, unidad, horas)
+		if categoriaID.Valid {
+			id := int(categoriaID.Int64)
+			r.CategoriaID = &id
+		}
+		inicio = inicio.In(fechas.ZonaEcuador)
+		r.Desde = inicio.Format("2006-01-02")
+		r.HoraInicio = inicio.Format("15:04")
+		if hasta.Valid {
+			h := hasta.Time.In(fechas.ZonaEcuador).Format("2006-01-02")
+			r.Hasta = &h
+		}
+		lista = append(lista, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":                len(lista),
+		"trabajos_recurrentes": lista,
+	})
+}
+
+// -------------------------------
+// PUT /protected/trabajos-recurrentes/:id
+// Reemplaza la definición. Los turnos futuros que aún no tienen
+// likes ni matches se cancelan y se regeneran con la nueva regla;
+// los que ya tienen estudiantes se respetan.
+// -------------------------------
+func EditarRecurrenteHandler(c *gin.Context, db *sql.DB) {
+	empleadorID, ok := getEmpleadorID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req RecurrenteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+
+	_, inicio, hasta, err := req.validar()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	msg, err := req.elegirCategoria(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar categoria", "err": err.Error()})
+		return
+	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error iniciando transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE jobs_recurrentes SET
+			titulo = $1, descripcion = $2, ubicacion = $3, pago_estimado = $4, negociable = $5,
+			requisitos = $6, categoria = $7, metodo_pago = $8, presencial = $9, cupos = $10,
+			regla = $11, inicio = $12, duracion_minutos = $13, hasta = $14,
+			moneda = $17, unidad_pago = $18, horas_estimadas = $19, categoria_id = $20,
+			generado_hasta = NOW(),
+			actualizado_en = NOW()
+		WHERE id = $15 AND empleador_id = $16
+	`, req.Titulo, req.Descripcion, req.Ubicacion, req.comp.Decimal(), req.Negociable,
+		req.Requisitos, req.Categoria, req.MetodoPago, req.Presencial, req.Cupos,
+		req.Regla, inicio, req.DuracionMinutos, hasta, id, empleadorID,
+		req.comp.Moneda, req.comp.Unidad, req.comp.HorasEstimadas, req.categoriaID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar trabajo recurrente", "err": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo recurrente no encontrado"})
+		return
+	}
+
+	cancelados, err := cancelarTurnosLibres(tx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error actualizando turnos", "err": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando cambios"})
+		return
+	}
+
+	generados, err := generarTurnos(db, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generando turnos", "err": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mensaje":           "Trabajo recurrente actualizado",
+		"id":                id,
+		"turnos_cancelados": cancelados,
+		"turnos_generados":  generados,
+		"compensacion":      req.comp,
+		"advertencias":      req.comp.Advertencias(),
+	})
+}
+
+// -------------------------------
+// PATCH /protected/trabajos-recurrentes/:id/estado  { "activo": false }
+// Pausar deja de generar turnos y cancela los futuros sin estudiantes
+// -------------------------------
+func CambiarEstadoRecurrenteHandler(c *gin.Context, db *sql.DB) {
+	empleadorID, ok := getEmpleadorID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var body struct {
+		Activo *bool `json:"activo"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Activo == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "activo es requerido"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error iniciando transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Al reactivar se genera desde ahora, no desde donde se quedó
+	res, err := tx.Exec(`
+		UPDATE jobs_recurrentes
+		SET activo = $1,
+		    generado_hasta = CASE WHEN $1 THEN NOW() ELSE generado_hasta END,
+		    actualizado_en = NOW()
+		WHERE id = $2 AND empleador_id = $3
+	`, *body.Activo, id, empleadorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar trabajo recurrente"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo recurrente no encontrado"})
+		return
+	}
+
+	cancelados := 0
+	if !*body.Activo {
+		cancelados, err = cancelarTurnosLibres(tx, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error cancelando turnos"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando cambios"})
+		return
+	}
+
+	generados := 0
+	if *body.Activo {
+		if generados, err = generarTurnos(db, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generando turnos"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mensaje":           "Estado actualizado",
+		"activo":            *body.Activo,
+		"turnos_cancelados": cancelados,
+		"turnos_generados":  generados,
+	})
+}
+
+// cancelarTurnosLibres cancela los turnos futuros sin likes ni matches
+func cancelarTurnosLibres(tx *sql.Tx, recurrenteID int) (int, error) {
+	res, err := tx.Exec(`
+		UPDATE jobs j
+		SET estado = 'cancelado', actualizado_en = NOW()
+		WHERE j.recurrente_id = $1
+		  AND j.estado = 'abierto'
+		  AND j.fecha_inicio > NOW()
+		  AND j.cupos_ocupados = 0
+		  AND NOT EXISTS (
+			SELECT 1 FROM intereses_estudiante ie
+			WHERE ie.job_id = j.id AND ie.interesado = true
+		  )
+	`, recurrenteID)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// ------------------------------------------------------
+// generarTurnos crea los turnos que faltan hasta el horizonte.
+// Solo genera después de generado_hasta, así un turno que el
+// empleador canceló o borró no vuelve a aparecer. Editar o reactivar
+// la serie reinicia generado_hasta tras cancelar los turnos libres,
+// que se vuelven a crear con los datos nuevos.
+// ------------------------------------------------------
+func generarTurnos(db *sql.DB, recurrenteID int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var (
+		empleadorID, cupos, duracion                                        int
+		titulo, descripcion, ubicacion, requisitos, categoria, metodo, rule string
+		moneda, unidadPago                                                  string
+		pago                                                                float64
+		horasEstimadas                                                      sql.NullFloat64
+		categoriaID                                                         sql.NullInt64
+		negociable, presencial, activo                                      bool
+		inicio                                                              time.Time
+		hasta, generadoHasta                                                sql.NullTime
+	)
+
+	// FOR UPDATE: dos generaciones simultáneas no duplican turnos
+	err = tx.QueryRow(`
+		SELECT empleador_id, titulo, descripcion, ubicacion, pago_estimado, negociable,
+		       requisitos, categoria, metodo_pago, presencial, cupos,
+		       regla, inicio, duracion_minutos, hasta, generado_hasta, activo,
+		       moneda, unidad_pago, horas_estimadas, categoria_id
+		FROM jobs_recurrentes
+		WHERE id = $1
+		FOR UPDATE
+	`, recurrenteID).Scan(
+		&empleadorID, &titulo, &descripcion, &ubicacion, &pago, &negociable,
+		&requisitos, &categoria, &metodo, &presencial, &cupos,
+		&rule, &inicio, &duracion, &hasta, &generadoHasta, &activo,
+		&moneda, &unidadPago, &horasEstimadas, &categoriaID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if !activo {
+		return 0, nil
+	}
+
+	regla, err := ParseRegla(rule)
+	if err != nil {
+		return 0, err
+	}
+
+	desde := time.Now()
+	if generadoHasta.Valid && generadoHasta.Time.After(desde) {
+		desde = generadoHasta.Time
+	}
+	limite := time.Now().AddDate(0, 0, 7*HorizonteSemanas)
+	if hasta.Valid && hasta.Time.Before(limite) {
+		limite = hasta.Time
+	}
+
+	generados := 0
+	for _, t := range regla.Ocurrencias(inicio.In(fechas.ZonaEcuador), desde, limite) {
+		fin := t.Add(time.Duration(duracion) * time.Minute)
+
+		res, err := tx.Exec(`
+			INSERT INTO jobs
+			(titulo, descripcion, ubicacion, pago_estimado, negociable, requisitos, categoria, metodo_pago,
+			 presencial, empleador_id, cupos, fecha_inicio, fecha_fin, expira_en, recurrente_id,
+			 moneda, unidad_pago, horas_estimadas, categoria_id,
+			 estado, publicado_en, creado_en, actualizado_en)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$12,$14,$15,$16,$17,$18,'abierto',NOW(),NOW(),NOW())
+			ON CONFLICT (recurrente_id, fecha_inicio) WHERE estado <> 'cancelado' DO NOTHING
+		`, titulo, descripcion, ubicacion, pago, negociable, requisitos, categoria, metodo,
+			presencial, empleadorID, cupos, t, fin, recurrenteID,
+			moneda, unidadPago, horasEstimadas, categoriaID)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			generados++
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE jobs_recurrentes SET generado_hasta = $1 WHERE id = $2`, limite, recurrenteID); err != nil {
+		return 0, err
+	}
+
+	return generados, tx.Commit()
+}
+
+// ------------------------------------------------------
+// TAREA: mantener generados los turnos de todos los recurrentes activos
+// ------------------------------------------------------
+func GenerarTurnosPendientes(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id FROM jobs_recurrentes
+		WHERE activo = true
+		  AND (hasta IS NULL OR hasta > NOW())
+		  AND (generado_hasta IS NULL OR generado_hasta < NOW() + make_interval(weeks => $1))
+	`, HorizonteSemanas)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if _, err := generarTurnos(db, id); err != nil {
+			log.Printf("Error generando turnos del recurrente %d: %v", id, err)
+		}
+	}
+
+	return nil
+}
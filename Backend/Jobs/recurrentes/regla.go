@@ -0,0 +1,197 @@
+package recurrentes
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+)
+
+// Regla es un subconjunto de RRULE (RFC 5545) suficiente para turnos:
+//
+//	FREQ=WEEKLY;INTERVAL=1;BYDAY=SA,SU;UNTIL=20261231;COUNT=10
+//
+// FREQ admite WEEKLY y DAILY. BYDAY solo aplica a WEEKLY; si no se
+// indica se usa el día de la semana de la fecha de inicio.
+type Regla struct {
+	Frecuencia string
+	Intervalo  int
+	Dias       []time.Weekday
+	Hasta      *time.Time
+	Conteo     int
+}
+
+var diasRRule = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// ParseRegla interpreta un string tipo RRULE (con o sin prefijo "RRULE:")
+func ParseRegla(s string) (Regla, error) {
+	r := Regla{Intervalo: 1}
+
+	s = strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(s), "RRULE:"))
+	if s == "" {
+		return r, errors.New("regla vacía")
+	}
+
+	for _, parte := range strings.Split(s, ";") {
+		if parte == "" {
+			continue
+		}
+		kv := strings.SplitN(parte, "=", 2)
+		if len(kv) != 2 {
+			return r, errors.New("parte inválida: " + parte)
+		}
+		clave, valor := kv[0], kv[1]
+
+		switch clave {
+		case "FREQ":
+			if valor != "WEEKLY" && valor != "DAILY" {
+				return r, errors.New("FREQ solo admite WEEKLY o DAILY")
+			}
+			r.Frecuencia = valor
+		case "INTERVAL":
+			n, err := strconv.Atoi(valor)
+			if err != nil || n < 1 || n > 52 {
+				return r, errors.New("INTERVAL inválido")
+			}
+			r.Intervalo = n
+		case "BYDAY":
+			for _, d := range strings.Split(valor, ",") {
+				wd, ok := diasRRule[d]
+				if !ok {
+					return r, errors.New("BYDAY inválido: " + d)
+				}
+				r.Dias = append(r.Dias, wd)
+			}
+		case "UNTIL":
+			t, err := parseUntil(valor)
+			if err != nil {
+				return r, errors.New("UNTIL inválido")
+			}
+			r.Hasta = &t
+		case "COUNT":
+			n, err := strconv.Atoi(valor)
+			if err != nil || n < 1 {
+				return r, errors.New("COUNT inválido")
+			}
+			r.Conteo = n
+		default:
+			return r, errors.New("parte no soportada: " + clave)
+		}
+	}
+
+	if r.Frecuencia == "" {
+		return r, errors.New("FREQ es requerido")
+	}
+	if r.Frecuencia == "DAILY" && len(r.Dias) > 0 {
+		return r, errors.New("BYDAY solo se admite con FREQ=WEEKLY")
+	}
+
+	return r, nil
+}
+
+func parseUntil(v string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if t, err := time.ParseInLocation(layout, v, fechas.ZonaEcuador); err == nil {
+			if layout == "20060102" {
+				// UNTIL de solo fecha incluye todo ese día
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("fecha inválida")
+}
+
+// String vuelve a armar la regla en formato RRULE
+func (r Regla) String() string {
+	partes := []string{"FREQ=" + r.Frecuencia}
+	if r.Intervalo > 1 {
+		partes = append(partes, "INTERVAL="+strconv.Itoa(r.Intervalo))
+	}
+	if len(r.Dias) > 0 {
+		var dias []string
+		for _, d := range r.Dias {
+			for k, v := range diasRRule {
+				if v == d {
+					dias = append(dias, k)
+				}
+			}
+		}
+		partes = append(partes, "BYDAY="+strings.Join(dias, ","))
+	}
+	if r.Hasta != nil {
+		partes = append(partes, "UNTIL="+r.Hasta.In(fechas.ZonaEcuador).Format("20060102"))
+	}
+	if r.Conteo > 0 {
+		partes = append(partes, "COUNT="+strconv.Itoa(r.Conteo))
+	}
+	return strings.Join(partes, ";")
+}
+
+func (r Regla) incluyeDia(d time.Weekday, inicio time.Time) bool {
+	if len(r.Dias) == 0 {
+		return d == inicio.Weekday()
+	}
+	for _, x := range r.Dias {
+		if x == d {
+			return true
+		}
+	}
+	return false
+}
+
+// Ocurrencias devuelve los inicios de turno dentro de (desde, hasta].
+// inicio es el primer día posible con la hora del turno ya aplicada;
+// COUNT se cuenta siempre desde inicio, no desde "desde".
+func (r Regla) Ocurrencias(inicio, desde, hasta time.Time) []time.Time {
+	var res []time.Time
+
+	if r.Hasta != nil && r.Hasta.Before(hasta) {
+		hasta = *r.Hasta
+	}
+
+	// Semana de referencia: lunes de la semana de inicio
+	offset := (int(inicio.Weekday()) + 6) % 7
+	lunes := time.Date(inicio.Year(), inicio.Month(), inicio.Day()-offset, 0, 0, 0, 0, inicio.Location())
+
+	conteo := 0
+	for dia := 0; ; dia++ {
+		t := time.Date(inicio.Year(), inicio.Month(), inicio.Day()+dia,
+			inicio.Hour(), inicio.Minute(), 0, 0, inicio.Location())
+		if t.After(hasta) {
+			break
+		}
+
+		incluir := false
+		switch r.Frecuencia {
+		case "DAILY":
+			incluir = dia%r.Intervalo == 0
+		case "WEEKLY":
+			semana := int(t.Sub(lunes).Hours()/24) / 7
+			incluir = semana%r.Intervalo == 0 && r.incluyeDia(t.Weekday(), inicio)
+		}
+		if !incluir {
+			continue
+		}
+
+		conteo++
+		if r.Conteo > 0 && conteo > r.Conteo {
+			break
+		}
+		if t.After(desde) {
+			res = append(res, t)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,345 @@
+package versiones
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/gin-gonic/gin"
+)
+
+type Version struct {
+	Version    int     `json:"version"`
+	EditadoPor int     `json:"editado_por"`
+	Motivo     string  `json:"motivo"`
+	Cambios    Cambios `json:"cambios"`
+	CreadoEn   string  `json:"creado_en"`
+}
+
+type Respuesta struct {
+	MatchID      int     `json:"match_id"`
+	EstudianteID int     `json:"estudiante_id"`
+	Estado       string  `json:"estado"`
+	RespondidoEn *string `json:"respondido_en"`
+}
+
+type Propuesta struct {
+	ID           int         `json:"id"`
+	JobID        int         `json:"job_id"`
+	Titulo       string      `json:"titulo,omitempty"`
+	PagoAnterior float64     `json:"pago_anterior"`
+	PagoNuevo    float64     `json:"pago_nuevo"`
+	Estado       string      `json:"estado"`
+	CreadoEn     string      `json:"creado_en"`
+	Respuestas   []Respuesta `json:"respuestas,omitempty"`
+}
+
+func getUserID(c *gin.Context) (int, bool) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		return 0, false
+	}
+	return userIDInterface.(int), true
+}
+
+// -------------------------------
+// GET /protected/trabajos/:id/historial
+// Versiones del trabajo (más reciente primero) y sus propuestas de pago
+// -------------------------------
+func HistorialHandler(c *gin.Context, db *sql.DB) {
+	empleadorID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	jobID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || jobID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var ownerID int
+	err = db.QueryRow(`SELECT empleador_id FROM jobs WHERE id = $1 AND eliminado_en IS NULL`, jobID).Scan(&ownerID)
+	if err == sql.ErrNoRows || (err == nil && ownerID != empleadorID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer trabajo"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT version, editado_por, motivo, cambios, creado_en
+		FROM jobs_versiones
+		WHERE job_id = $1
+		ORDER BY version DESC
+	`, jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener historial", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	historial := []Version{}
+	for rows.Next() {
+		var v Version
+		var data []byte
+		if err := rows.Scan(&v.Version, &v.EditadoPor, &v.Motivo, &data, &v.CreadoEn); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo historial", "err": err.Error()})
+			return
+		}
+		if err := json.Unmarshal(data, &v.Cambios); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo historial", "err": err.Error()})
+			return
+		}
+		historial = append(historial, v)
+	}
+
+	propuestas, err := propuestasDelJob(db, jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener propuestas de pago", "err": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job_id":          jobID,
+		"versiones":       historial,
+		"propuestas_pago": propuestas,
+	})
+}
+
+func propuestasDelJob(db *sql.DB, jobID int) ([]Propuesta, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.pago_anterior, p.pago_nuevo, p.estado, p.creado_en,
+		       r.match_id, r.estudiante_id, r.estado, r.respondido_en
+		FROM propuestas_pago p
+		JOIN propuestas_pago_respuestas r ON r.propuesta_id = p.id
+		WHERE p.job_id = $1
+		ORDER BY p.id DESC, r.match_id
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	propuestas := []Propuesta{}
+	for rows.Next() {
+		var p Propuesta
+		var r Respuesta
+		var respondido sql.NullString
+		if err := rows.Scan(&p.ID, &p.PagoAnterior, &p.PagoNuevo, &p.Estado, &p.CreadoEn,
+			&r.MatchID, &r.EstudianteID, &r.Estado, &respondido); err != nil {
+			return nil, err
+		}
+		if respondido.Valid {
+			r.RespondidoEn = &respondido.String
+		}
+		if n := len(propuestas); n == 0 || propuestas[n-1].ID != p.ID {
+			p.JobID = jobID
+			propuestas = append(propuestas, p)
+		}
+		ultima := &propuestas[len(propuestas)-1]
+		ultima.Respuestas = append(ultima.Respuestas, r)
+	}
+	return propuestas, rows.Err()
+}
+
+// -------------------------------
+// GET /protected/cambios-pago
+// Propuestas de pago que el estudiante todavía no responde
+// -------------------------------
+func PropuestasPendientesHandler(c *gin.Context, db *sql.DB) {
+	estudianteID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT p.id, p.job_id, j.titulo, p.pago_anterior, p.pago_nuevo, p.estado, p.creado_en
+		FROM propuestas_pago_respuestas r
+		JOIN propuestas_pago p ON p.id = r.propuesta_id
+		JOIN jobs j ON j.id = p.job_id
+		WHERE r.estudiante_id = $1 AND r.estado = 'pendiente' AND p.estado = 'pendiente'
+		ORDER BY p.creado_en DESC
+	`, estudianteID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener cambios de pago", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	propuestas := []Propuesta{}
+	for rows.Next() {
+		var p Propuesta
+		if err := rows.Scan(&p.ID, &p.JobID, &p.Titulo, &p.PagoAnterior, &p.PagoNuevo, &p.Estado, &p.CreadoEn); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo cambios de pago", "err": err.Error()})
+			return
+		}
+		propuestas = append(propuestas, p)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"propuestas": propuestas})
+}
+
+// -------------------------------
+// POST /protected/cambios-pago/responder  { "id": 1, "aceptar": true }
+// Un rechazo cierra la propuesta. Cuando el último contratado acepta,
+// el nuevo pago se aplica al trabajo y queda como nueva versión.
+// -------------------------------
+func ResponderPropuestaHandler(c *gin.Context, db *sql.DB) {
+	estudianteID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var req struct {
+		ID      int   `json:"id"`
+		Aceptar *bool `json:"aceptar"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID <= 0 || req.Aceptar == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id y aceptar son requeridos"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Bloquea la propuesta para que dos respuestas simultáneas no la apliquen dos veces
+	var jobID, empleadorID int
+	var anterior, nuevo float64
+	var titulo string
+	err = tx.QueryRow(`
+		SELECT p.job_id, p.empleador_id, p.pago_anterior, p.pago_nuevo, j.titulo
+		FROM propuestas_pago p
+		JOIN jobs j ON j.id = p.job_id
+		JOIN propuestas_pago_respuestas r ON r.propuesta_id = p.id
+		WHERE p.id = $1 AND p.estado = 'pendiente'
+		  AND r.estudiante_id = $2 AND r.estado = 'pendiente'
+		FOR UPDATE OF p
+	`, req.ID, estudianteID).Scan(&jobID, &empleadorID, &anterior, &nuevo, &titulo)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": ErrNoEncontrada.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer propuesta", "err": err.Error()})
+		return
+	}
+
+	estado := "rechazada"
+	if *req.Aceptar {
+		estado = "aceptada"
+	}
+	if _, err := tx.Exec(`
+		UPDATE propuestas_pago_respuestas SET estado = $1, respondido_en = NOW()
+		WHERE propuesta_id = $2 AND estudiante_id = $3
+	`, estado, req.ID, estudianteID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar respuesta"})
+		return
+	}
+
+	resultado := "pendiente"
+	version := 0
+	if !*req.Aceptar {
+		resultado = "rechazada"
+	} else {
+		// Solo cuentan los contratados que siguen activos
+		var faltan int
+		err := tx.QueryRow(`
+			SELECT COUNT(*)
+			FROM propuestas_pago_respuestas r
+			JOIN matches_job m ON m.id = r.match_id
+			WHERE r.propuesta_id = $1 AND r.estado = 'pendiente'
+			  AND m.estado IS DISTINCT FROM 'cancelado'
+		`, req.ID).Scan(&faltan)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al revisar respuestas"})
+			return
+		}
+		if faltan == 0 {
+			resultado = "aceptada"
+			if _, err := tx.Exec(`
+				UPDATE jobs SET pago_estimado = $1, actualizado_en = NOW() WHERE id = $2
+			`, nuevo, jobID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al aplicar el nuevo pago"})
+				return
+			}
+			version, err = Registrar(tx, jobID, empleadorID, MotivoPagoAceptado, Cambios{
+				"pago_estimado": {Antes: anterior, Despues: nuevo},
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar versión", "err": err.Error()})
+				return
+			}
+		}
+	}
+	if resultado != "pendiente" {
+		if _, err := tx.Exec(`
+			UPDATE propuestas_pago SET estado = $1, resuelto_en = NOW() WHERE id = $2
+		`, resultado, req.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al cerrar propuesta"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar respuesta"})
+		return
+	}
+
+	switch resultado {
+	case "rechazada":
+		notificarEmpleador(db, empleadorID, jobID, req.ID, "cambio_pago_rechazado", "Cambio de pago rechazado",
+			fmt.Sprintf("Un estudiante contratado rechazó el nuevo pago de \"%s\". El pago sigue en %s.", titulo, texto(anterior)))
+	case "aceptada":
+		notificarEmpleador(db, empleadorID, jobID, req.ID, "cambio_pago_aceptado", "Cambio de pago aceptado",
+			fmt.Sprintf("Todos los contratados aceptaron el nuevo pago de \"%s\": %s.", titulo, texto(nuevo)))
+
+		// Los contratados ya lo aceptaron; el aviso es para quienes solo dieron like
+		activos, err := MatchesActivos(db, jobID)
+		if err != nil {
+			log.Printf("No se pudieron obtener los matches del job %d: %v", jobID, err)
+		}
+		omitir := map[int]bool{}
+		for _, m := range activos {
+			omitir[m.EstudianteID] = true
+		}
+		NotificarCambios(db, jobID, titulo, version, Cambios{
+			"pago_estimado": {Antes: anterior, Despues: nuevo},
+		}, omitir)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Respuesta registrada",
+		"propuesta_id":     req.ID,
+		"estado_propuesta": resultado,
+	})
+}
+
+func notificarEmpleador(db *sql.DB, empleadorID, jobID, propuestaID int, tipo, titulo, mensaje string) {
+	err := notificaciones.Notificar(db, notificaciones.Notificacion{
+		UsuarioID: empleadorID,
+		Rol:       notificaciones.RolEmpleador,
+		Tipo:      tipo,
+		Titulo:    titulo,
+		Mensaje:   mensaje,
+		Datos: map[string]interface{}{
+			"job_id":       jobID,
+			"propuesta_id": propuestaID,
+		},
+	})
+	if err != nil {
+		log.Println("Error notificando respuesta de propuesta:", err)
+	}
+}
@@ -0,0 +1,340 @@
+package versiones
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+)
+
+// Cada edición de un trabajo guarda una fila en jobs_versiones con solo
+// los campos que cambiaron (antes/después). Los cambios "materiales"
+// (pago y su unidad, ubicación, fechas, requisitos) se avisan a los estudiantes
+// que dieron like o ya hicieron match. Un cambio de pago con matches
+// activos no se aplica directo: queda como propuesta hasta que todos
+// los contratados la acepten.
+
+const (
+	MotivoEdicion      = "edicion"
+	MotivoPagoAceptado = "cambio_pago_aceptado"
+)
+
+var ErrNoEncontrada = errors.New("propuesta no encontrada")
+
+// Ejecutor permite usar tanto *sql.DB como *sql.Tx
+type Ejecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type Cambio struct {
+	Antes   interface{} `json:"antes"`
+	Despues interface{} `json:"despues"`
+}
+
+// Cambios va de nombre de campo a su diferencia
+type Cambios map[string]Cambio
+
+// camposMateriales son los que afectan la decisión del estudiante
+var camposMateriales = []string{"pago_estimado", "unidad_pago", "horas_estimadas", "ubicacion", "fecha_inicio", "expira_en", "requisitos"}
+
+var etiquetas = map[string]string{
+	"pago_estimado":   "pago",
+	"unidad_pago":     "unidad de pago",
+	"horas_estimadas": "horas estimadas",
+	"ubicacion":       "ubicación",
+	"fecha_inicio":    "fecha de inicio",
+	"expira_en":       "fecha de cierre",
+	"requisitos":      "requisitos",
+}
+
+// normalizar deja los valores en tipos que se comparan y serializan igual
+func normalizar(v interface{}) interface{} {
+	switch x := v.(type) {
+	case sql.NullTime:
+		if !x.Valid {
+			return nil
+		}
+		return x.Time.UTC().Format(time.RFC3339)
+	case sql.NullInt64:
+		if !x.Valid {
+			return nil
+		}
+		return x.Int64
+	case sql.NullFloat64:
+		if !x.Valid {
+			return nil
+		}
+		return x.Float64
+	case sql.NullString:
+		if !x.Valid {
+			return nil
+		}
+		return x.String
+	case int:
+		return int64(x)
+	case float32:
+		return float64(x)
+	}
+	return v
+}
+
+// Diferencias compara dos fotos de los mismos campos y devuelve los que cambiaron
+func Diferencias(antes, despues map[string]interface{}) Cambios {
+	cambios := Cambios{}
+	for campo, a := range antes {
+		a, d := normalizar(a), normalizar(despues[campo])
+		if a != d {
+			cambios[campo] = Cambio{Antes: a, Despues: d}
+		}
+	}
+	return cambios
+}
+
+// Materiales devuelve, en orden fijo, los campos materiales que cambiaron
+func (cs Cambios) Materiales() []string {
+	var campos []string
+	for _, campo := range camposMateriales {
+		if _, ok := cs[campo]; ok {
+			campos = append(campos, campo)
+		}
+	}
+	return campos
+}
+
+// Registrar guarda la siguiente versión del trabajo y devuelve su número
+func Registrar(q Ejecutor, jobID, empleadorID int, motivo string, cambios Cambios) (int, error) {
+	data, err := json.Marshal(cambios)
+	if err != nil {
+		return 0, err
+	}
+
+	var version int
+	err = q.QueryRow(`
+		INSERT INTO jobs_versiones (job_id, version, editado_por, motivo, cambios)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4
+		FROM jobs_versiones WHERE job_id = $1
+		RETURNING version
+	`, jobID, empleadorID, motivo, data).Scan(&version)
+	return version, err
+}
+
+// describir arma un texto corto tipo: pago: 20 → 15; ubicación: A → B
+func describir(cambios Cambios, campos []string) string {
+	partes := make([]string, 0, len(campos))
+	for _, campo := range campos {
+		ch := cambios[campo]
+		antes, despues := texto(ch.Antes), texto(ch.Despues)
+		if campo == "horas_estimadas" {
+			antes, despues = textoHoras(ch.Antes), textoHoras(ch.Despues)
+		}
+		partes = append(partes, fmt.Sprintf("%s: %s → %s", etiquetas[campo], antes, despues))
+	}
+	return strings.Join(partes, "; ")
+}
+
+func texto(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "(sin definir)"
+	case string:
+		if x == "" {
+			return "(vacío)"
+		}
+		if t, err := time.Parse(time.RFC3339, x); err == nil {
+			return t.Format("02/01/2006 15:04")
+		}
+		if len(x) > 60 {
+			return x[:57] + "..."
+		}
+		return x
+	case float64:
+		return fmt.Sprintf("$%.2f", x)
+	}
+	return fmt.Sprint(v)
+}
+
+func textoHoras(v interface{}) string {
+	if h, ok := v.(float64); ok {
+		return strconv.FormatFloat(h, 'f', -1, 64) + " h"
+	}
+	return texto(v)
+}
+
+// Afectados devuelve los estudiantes con like pendiente o match activo en el job.
+// El bool indica si tiene match activo.
+func Afectados(db *sql.DB, jobID int) (map[int]bool, error) {
+	rows, err := db.Query(`
+		SELECT ie.estudiante_id, false
+		FROM intereses_estudiante ie
+		WHERE ie.job_id = $1 AND ie.interesado = true
+		UNION ALL
+		SELECT m.estudiante_id, true
+		FROM matches_job m
+		WHERE m.job_id = $1 AND m.is_match = true
+		  AND m.estado IS DISTINCT FROM 'cancelado'
+		  AND m.estado IS DISTINCT FROM 'completado'
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	afectados := map[int]bool{}
+	for rows.Next() {
+		var id int
+		var conMatch bool
+		if err := rows.Scan(&id, &conMatch); err != nil {
+			return nil, err
+		}
+		afectados[id] = afectados[id] || conMatch
+	}
+	return afectados, rows.Err()
+}
+
+// NotificarCambios avisa a los estudiantes afectados si hubo cambios materiales.
+// Los IDs en omitir no se notifican (p. ej. quienes ya aceptaron el cambio).
+func NotificarCambios(db *sql.DB, jobID int, titulo string, version int, cambios Cambios, omitir map[int]bool) {
+	campos := cambios.Materiales()
+	if len(campos) == 0 {
+		return
+	}
+	afectados, err := Afectados(db, jobID)
+	if err != nil {
+		log.Printf("No se pudieron obtener los estudiantes del job %d: %v", jobID, err)
+		return
+	}
+
+	mensaje := fmt.Sprintf("El trabajo \"%s\" cambió. %s.", titulo, describir(cambios, campos))
+	for estudianteID := range afectados {
+		if omitir[estudianteID] {
+			continue
+		}
+		err := notificaciones.Notificar(db, notificaciones.Notificacion{
+			UsuarioID: estudianteID,
+			Rol:       notificaciones.RolEstudiante,
+			Tipo:      "trabajo_modificado",
+			Titulo:    "Un trabajo que te interesa cambió",
+			Mensaje:   mensaje,
+			Datos: map[string]interface{}{
+				"job_id":  jobID,
+				"version": version,
+				"campos":  campos,
+			},
+		})
+		if err != nil {
+			log.Println("Error notificando cambio de trabajo:", err)
+		}
+	}
+}
+
+// MatchActivo es un contratado que todavía no termina el trabajo
+type MatchActivo struct {
+	MatchID      int
+	EstudianteID int
+}
+
+// MatchesActivos lista los matches del job que no están cancelados ni
+// completados. Los que negociaron su propio pago (pago_acordado) no
+// dependen del pago publicado y quedan fuera.
+func MatchesActivos(db *sql.DB, jobID int) ([]MatchActivo, error) {
+	rows, err := db.Query(`
+		SELECT id, estudiante_id
+		FROM matches_job
+		WHERE job_id = $1 AND is_match = true
+		  AND estado IS DISTINCT FROM 'cancelado'
+		  AND estado IS DISTINCT FROM 'completado'
+		  AND pago_acordado IS NULL
+		ORDER BY id
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activos []MatchActivo
+	for rows.Next() {
+		var m MatchActivo
+		if err := rows.Scan(&m.MatchID, &m.EstudianteID); err != nil {
+			return nil, err
+		}
+		activos = append(activos, m)
+	}
+	return activos, rows.Err()
+}
+
+// ProponerPago deja el nuevo pago pendiente de aceptación de cada contratado.
+// Una propuesta anterior sin resolver queda cancelada y la reemplaza esta.
+func ProponerPago(tx *sql.Tx, jobID, empleadorID int, anterior, nuevo float64, activos []MatchActivo) (int, error) {
+	if _, err := tx.Exec(`
+		UPDATE propuestas_pago SET estado = 'cancelada', resuelto_en = NOW()
+		WHERE job_id = $1 AND estado = 'pendiente'
+	`, jobID); err != nil {
+		return 0, err
+	}
+
+	var propuestaID int
+	err := tx.QueryRow(`
+		INSERT INTO propuestas_pago (job_id, empleador_id, pago_anterior, pago_nuevo)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, jobID, empleadorID, anterior, nuevo).Scan(&propuestaID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range activos {
+		if _, err := tx.Exec(`
+			INSERT INTO propuestas_pago_respuestas (propuesta_id, match_id, estudiante_id)
+			VALUES ($1, $2, $3)
+		`, propuestaID, m.MatchID, m.EstudianteID); err != nil {
+			return 0, err
+		}
+	}
+	return propuestaID, nil
+}
+
+// CerrarPropuestaSinContratados cancela la propuesta pendiente del job si
+// todos sus contratados cancelaron: ya no queda nadie que la acepte. Se
+// llama desde cada camino que cancela un match, dentro de su transacción.
+func CerrarPropuestaSinContratados(tx *sql.Tx, jobID int) error {
+	_, err := tx.Exec(`
+		UPDATE propuestas_pago p SET estado = 'cancelada', resuelto_en = NOW()
+		WHERE p.job_id = $1 AND p.estado = 'pendiente'
+		  AND NOT EXISTS (
+			SELECT 1
+			FROM propuestas_pago_respuestas r
+			JOIN matches_job m ON m.id = r.match_id
+			WHERE r.propuesta_id = p.id AND m.estado IS DISTINCT FROM 'cancelado'
+		  )
+	`, jobID)
+	return err
+}
+
+// NotificarPropuesta pide a cada contratado que acepte o rechace el nuevo pago
+func NotificarPropuesta(db *sql.DB, propuestaID, jobID int, titulo string, anterior, nuevo float64, activos []MatchActivo) {
+	for _, m := range activos {
+		err := notificaciones.Notificar(db, notificaciones.Notificacion{
+			UsuarioID: m.EstudianteID,
+			Rol:       notificaciones.RolEstudiante,
+			Tipo:      "cambio_pago_propuesto",
+			Titulo:    "El empleador quiere cambiar el pago",
+			Mensaje: fmt.Sprintf("El pago de \"%s\" pasaría de %s a %s. El cambio solo se aplica si lo aceptas.",
+				titulo, texto(anterior), texto(nuevo)),
+			Datos: map[string]interface{}{
+				"job_id":       jobID,
+				"propuesta_id": propuestaID,
+				"match_id":     m.MatchID,
+			},
+		})
+		if err != nil {
+			log.Println("Error notificando propuesta de pago:", err)
+		}
+	}
+}
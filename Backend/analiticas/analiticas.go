@@ -0,0 +1,386 @@
+package analiticas
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/VinkoRobi2/FlashWorkEC/ganancias"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/VinkoRobi2/FlashWorkEC/pagos"
+)
+
+// Analíticas del empleador. El gasto sale del libro de pagos (lo adeudado
+// por cada match completado) y se agrupa por la fecha de completado; la
+// recontratación cuenta estudiantes con más de un match completado en el
+// mismo rango. Publicación, tiempo hasta el match, embudo y valoraciones
+// se miden sobre los trabajos publicados en el rango (sin borradores ni
+// eliminados). Las fechas están en hora de Ecuador.
+
+// Trabajo es el embudo de un trabajo publicado
+type Trabajo struct {
+	JobID            int       `json:"job_id"`
+	Titulo           string    `json:"titulo"`
+	Categoria        string    `json:"categoria"`
+	Estado           string    `json:"estado"`
+	PublicadoEn      time.Time `json:"publicado_en"`
+	Cupos            int       `json:"cupos"`
+	CuposOcupados    int       `json:"cupos_ocupados"`
+	Likes            int       `json:"likes"`
+	Matches          int       `json:"matches"`
+	Completados      int       `json:"completados"`
+	Cancelados       int       `json:"cancelados"`
+	HorasPrimerMatch *float64  `json:"horas_primer_match"`
+	Gastado          float64   `json:"gastado"`
+}
+
+// Grupo acumula el gasto de un periodo o categoría
+type Grupo struct {
+	Clave        string  `json:"clave"`
+	Matches      int     `json:"matches"`
+	Gastado      float64 `json:"gastado"`
+	Pagado       float64 `json:"pagado"`
+	PorConfirmar float64 `json:"por_confirmar"`
+	Pendiente    float64 `json:"pendiente"`
+}
+
+type Gasto struct {
+	Totales      Grupo   `json:"totales"`
+	PorSemana    []Grupo `json:"por_semana"`
+	PorMes       []Grupo `json:"por_mes"`
+	PorSemestre  []Grupo `json:"por_semestre"`
+	PorCategoria []Grupo `json:"por_categoria"`
+}
+
+type Publicacion struct {
+	Publicados          int     `json:"publicados"`
+	Cubiertos           int     `json:"cubiertos"` // todos los cupos con match
+	PorcentajeCubiertos float64 `json:"porcentaje_cubiertos"`
+	Cupos               int     `json:"cupos"`
+	CuposOcupados       int     `json:"cupos_ocupados"`
+}
+
+// TiempoMatch son las horas desde la publicación hasta cada match
+type TiempoMatch struct {
+	Matches       int     `json:"matches"`
+	PromedioHoras float64 `json:"promedio_horas"`
+	MedianaHoras  float64 `json:"mediana_horas"`
+}
+
+type Embudo struct {
+	Likes                 int     `json:"likes"`
+	Matches               int     `json:"matches"`
+	Completados           int     `json:"completados"`
+	PorcentajeMatch       float64 `json:"porcentaje_match"`       // matches / likes
+	PorcentajeCompletados float64 `json:"porcentaje_completados"` // completados / matches
+}
+
+type Recontratacion struct {
+	Estudiantes   int     `json:"estudiantes"`
+	Recontratados int     `json:"recontratados"` // con 2 o más trabajos completados
+	Porcentaje    float64 `json:"porcentaje"`
+}
+
+type Promedio struct {
+	Cantidad int     `json:"cantidad"`
+	Promedio float64 `json:"promedio"`
+}
+
+type Valoraciones struct {
+	Dadas     Promedio `json:"dadas"`     // a estudiantes
+	Recibidas Promedio `json:"recibidas"` // de estudiantes
+}
+
+type Reporte struct {
+	Gasto          Gasto          `json:"gasto"`
+	Publicacion    Publicacion    `json:"publicacion"`
+	TiempoMatch    TiempoMatch    `json:"tiempo_match"`
+	Embudo         Embudo         `json:"embudo"`
+	Recontratacion Recontratacion `json:"recontratacion"`
+	Valoraciones   Valoraciones   `json:"valoraciones"`
+	Trabajos       []Trabajo      `json:"trabajos"`
+}
+
+func redondear(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func porcentaje(parte, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return redondear(float64(parte) * 100 / float64(total))
+}
+
+func enRango(t time.Time, desde, hasta *time.Time) bool {
+	if desde != nil && t.Before(*desde) {
+		return false
+	}
+	return hasta == nil || t.Before(*hasta)
+}
+
+func (g *Grupo) sumar(s pagos.Saldo) {
+	g.Matches++
+	g.Gastado = redondear(g.Gastado + s.Adeudado)
+	g.Pagado = redondear(g.Pagado + s.Pagado)
+	g.PorConfirmar = redondear(g.PorConfirmar + s.PorConfirmar)
+	g.Pendiente = redondear(g.Pendiente + s.Pendiente)
+}
+
+// Generar arma el reporte del empleador; desde y hasta son opcionales
+func Generar(db *sql.DB, empleadorID int, desde, hasta *time.Time) (*Reporte, error) {
+	r := &Reporte{}
+
+	trabajos, err := cargarTrabajos(db, empleadorID, desde, hasta)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.gasto(db, empleadorID, desde, hasta, trabajos); err != nil {
+		return nil, err
+	}
+	if err := r.tiempoMatch(db, empleadorID, trabajos); err != nil {
+		return nil, err
+	}
+	if err := r.valoraciones(db, empleadorID, trabajos); err != nil {
+		return nil, err
+	}
+
+	r.Trabajos = []Trabajo{}
+	for _, t := range trabajos {
+		r.Publicacion.Publicados++
+		r.Publicacion.Cupos += t.Cupos
+		r.Publicacion.CuposOcupados += t.CuposOcupados
+		if t.CuposOcupados >= t.Cupos {
+			r.Publicacion.Cubiertos++
+		}
+		r.Embudo.Likes += t.Likes
+		r.Embudo.Matches += t.Matches
+		r.Embudo.Completados += t.Completados
+		r.Trabajos = append(r.Trabajos, *t)
+	}
+	r.Publicacion.PorcentajeCubiertos = porcentaje(r.Publicacion.Cubiertos, r.Publicacion.Publicados)
+	r.Embudo.PorcentajeMatch = porcentaje(r.Embudo.Matches, r.Embudo.Likes)
+	r.Embudo.PorcentajeCompletados = porcentaje(r.Embudo.Completados, r.Embudo.Matches)
+	sort.Slice(r.Trabajos, func(i, j int) bool { return r.Trabajos[i].PublicadoEn.After(r.Trabajos[j].PublicadoEn) })
+	return r, nil
+}
+
+// cargarTrabajos lee los trabajos publicados en el rango con sus likes y
+// matches, indexados por id
+func cargarTrabajos(db *sql.DB, empleadorID int, desde, hasta *time.Time) (map[int]*Trabajo, error) {
+	rows, err := db.Query(`
+		SELECT j.id, j.titulo, COALESCE(j.categoria, ''), j.estado,
+		       COALESCE(j.publicado_en, j.creado_en), j.cupos, j.cupos_ocupados,
+		       (SELECT COUNT(*) FROM intereses_estudiante ie
+		        WHERE ie.job_id = j.id AND ie.interesado = true),
+		       COUNT(mj.id),
+		       COUNT(mj.id) FILTER (WHERE mj.estado = 'completado'),
+		       COUNT(mj.id) FILTER (WHERE mj.estado = 'cancelado')
+		FROM jobs j
+		LEFT JOIN matches_job mj ON mj.job_id = j.id AND mj.is_match = true
+		WHERE j.empleador_id = $1
+		  AND j.estado <> 'borrador'
+		  AND j.eliminado_en IS NULL
+		GROUP BY j.id
+	`, empleadorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trabajos := map[int]*Trabajo{}
+	for rows.Next() {
+		var t Trabajo
+		if err := rows.Scan(&t.JobID, &t.Titulo, &t.Categoria, &t.Estado,
+			&t.PublicadoEn, &t.Cupos, &t.CuposOcupados,
+			&t.Likes, &t.Matches, &t.Completados, &t.Cancelados); err != nil {
+			return nil, err
+		}
+		t.PublicadoEn = t.PublicadoEn.In(fechas.ZonaEcuador)
+		if !enRango(t.PublicadoEn, desde, hasta) {
+			continue
+		}
+		trabajos[t.JobID] = &t
+	}
+	return trabajos, rows.Err()
+}
+
+// gasto agrupa lo adeudado por los matches completados en el rango y
+// calcula la recontratación sobre esos mismos matches
+func (r *Reporte) gasto(db *sql.DB, empleadorID int, desde, hasta *time.Time, trabajos map[int]*Trabajo) error {
+	saldos, err := pagos.SaldosDe(db, empleadorID, notificaciones.RolEmpleador)
+	if err != nil {
+		return err
+	}
+
+	type fila struct {
+		saldo      pagos.Saldo
+		completado time.Time
+	}
+	filas := []fila{}
+	r.Gasto.Totales.Clave = "total"
+	porEstudiante := map[int]int{}
+	for _, s := range saldos {
+		if s.CompletadoEn == nil {
+			continue
+		}
+		c, err := time.Parse(time.RFC3339, *s.CompletadoEn)
+		if err != nil || !enRango(c, desde, hasta) {
+			continue
+		}
+		filas = append(filas, fila{s, c})
+		r.Gasto.Totales.sumar(s)
+		porEstudiante[s.EstudianteID]++
+		if t, ok := trabajos[s.JobID]; ok {
+			t.Gastado = redondear(t.Gastado + s.Adeudado)
+		}
+	}
+
+	agrupar := func(clave func(fila) string) []Grupo {
+		indice := map[string]int{}
+		grupos := []Grupo{}
+		for _, f := range filas {
+			k := clave(f)
+			i, ok := indice[k]
+			if !ok {
+				i = len(grupos)
+				indice[k] = i
+				grupos = append(grupos, Grupo{Clave: k})
+			}
+			grupos[i].sumar(f.saldo)
+		}
+		return grupos
+	}
+	porPeriodo := func(periodo func(time.Time) string) []Grupo {
+		grupos := agrupar(func(f fila) string { return periodo(f.completado) })
+		// Las claves de periodo ordenan cronológicamente como texto
+		sort.Slice(grupos, func(i, j int) bool { return grupos[i].Clave < grupos[j].Clave })
+		return grupos
+	}
+	r.Gasto.PorSemana = porPeriodo(ganancias.Semana)
+	r.Gasto.PorMes = porPeriodo(ganancias.Mes)
+	r.Gasto.PorSemestre = porPeriodo(ganancias.Semestre)
+	r.Gasto.PorCategoria = agrupar(func(f fila) string {
+		if f.saldo.Categoria == "" {
+			return "sin_categoria"
+		}
+		return f.saldo.Categoria
+	})
+	sort.SliceStable(r.Gasto.PorCategoria, func(i, j int) bool {
+		return r.Gasto.PorCategoria[i].Gastado > r.Gasto.PorCategoria[j].Gastado
+	})
+
+	r.Recontratacion.Estudiantes = len(porEstudiante)
+	for _, n := range porEstudiante {
+		if n > 1 {
+			r.Recontratacion.Recontratados++
+		}
+	}
+	r.Recontratacion.Porcentaje = porcentaje(r.Recontratacion.Recontratados, r.Recontratacion.Estudiantes)
+	return nil
+}
+
+// tiempoMatch mide las horas desde la publicación hasta cada match de los
+// trabajos del reporte; los matches sin emparejado_en no cuentan
+func (r *Reporte) tiempoMatch(db *sql.DB, empleadorID int, trabajos map[int]*Trabajo) error {
+	rows, err := db.Query(`
+		SELECT mj.job_id,
+		       CAST(EXTRACT(EPOCH FROM (mj.emparejado_en - COALESCE(j.publicado_en, j.creado_en))) / 3600 AS FLOAT)
+		FROM matches_job mj
+		JOIN jobs j ON j.id = mj.job_id
+		WHERE j.empleador_id = $1 AND mj.is_match = true AND mj.emparejado_en IS NOT NULL
+	`, empleadorID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	horas := []float64{}
+	for rows.Next() {
+		var jobID int
+		var h float64
+		if err := rows.Scan(&jobID, &h); err != nil {
+			return err
+		}
+		t, ok := trabajos[jobID]
+		if !ok {
+			continue
+		}
+		// Un match no puede ser anterior a la publicación
+		h = math.Max(h, 0)
+		horas = append(horas, h)
+		if t.HorasPrimerMatch == nil || h < *t.HorasPrimerMatch {
+			primero := h
+			t.HorasPrimerMatch = &primero
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, t := range trabajos {
+		if t.HorasPrimerMatch != nil {
+			*t.HorasPrimerMatch = redondear(*t.HorasPrimerMatch)
+		}
+	}
+
+	r.TiempoMatch.Matches = len(horas)
+	if len(horas) == 0 {
+		return nil
+	}
+	sort.Float64s(horas)
+	var suma float64
+	for _, h := range horas {
+		suma += h
+	}
+	r.TiempoMatch.PromedioHoras = redondear(suma / float64(len(horas)))
+	mitad := len(horas) / 2
+	if len(horas)%2 == 0 {
+		r.TiempoMatch.MedianaHoras = redondear((horas[mitad-1] + horas[mitad]) / 2)
+	} else {
+		r.TiempoMatch.MedianaHoras = redondear(horas[mitad])
+	}
+	return nil
+}
+
+// valoraciones promedia las valoraciones dadas y recibidas en los
+// trabajos del reporte
+func (r *Reporte) valoraciones(db *sql.DB, empleadorID int, trabajos map[int]*Trabajo) error {
+	promedio := func(query string) (Promedio, error) {
+		var p Promedio
+		rows, err := db.Query(query, empleadorID)
+		if err != nil {
+			return p, err
+		}
+		defer rows.Close()
+		suma := 0
+		for rows.Next() {
+			var jobID, rating int
+			if err := rows.Scan(&jobID, &rating); err != nil {
+				return p, err
+			}
+			if _, ok := trabajos[jobID]; !ok {
+				continue
+			}
+			p.Cantidad++
+			suma += rating
+		}
+		if p.Cantidad > 0 {
+			p.Promedio = redondear(float64(suma) / float64(p.Cantidad))
+		}
+		return p, rows.Err()
+	}
+
+	var err error
+	r.Valoraciones.Dadas, err = promedio(`
+		SELECT job_id, rating FROM valoracion_estudiante WHERE empleador_valorador_id = $1
+	`)
+	if err != nil {
+		return err
+	}
+	r.Valoraciones.Recibidas, err = promedio(`
+		SELECT job_id, rating FROM valoracion_empleador WHERE empleador_valorado_id = $1
+	`)
+	return err
+}
@@ -0,0 +1,159 @@
+package analiticas
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+
+	"github.com/VinkoRobi2/FlashWorkEC/exportar"
+	"github.com/xuri/excelize/v2"
+)
+
+// Tabla es una sección del reporte lista para exportar: una hoja en XLSX
+// y un bloque con título en CSV. Las celdas son string, int o float64.
+type Tabla struct {
+	Nombre   string
+	Columnas []string
+	Filas    [][]interface{}
+}
+
+func filasGrupos(grupos []Grupo) [][]interface{} {
+	filas := [][]interface{}{}
+	for _, g := range grupos {
+		filas = append(filas, []interface{}{g.Clave, g.Matches, g.Gastado, g.Pagado, g.PorConfirmar, g.Pendiente})
+	}
+	return filas
+}
+
+// Tablas divide el reporte en secciones
+func (r *Reporte) Tablas() []Tabla {
+	columnasGasto := []string{"clave", "matches", "gastado", "pagado", "por_confirmar", "pendiente"}
+
+	resumen := Tabla{Nombre: "resumen", Columnas: []string{"indicador", "valor"}, Filas: [][]interface{}{
+		{"gastado", r.Gasto.Totales.Gastado},
+		{"pagado", r.Gasto.Totales.Pagado},
+		{"por_confirmar", r.Gasto.Totales.PorConfirmar},
+		{"pendiente", r.Gasto.Totales.Pendiente},
+		{"matches_completados", r.Gasto.Totales.Matches},
+		{"trabajos_publicados", r.Publicacion.Publicados},
+		{"trabajos_cubiertos", r.Publicacion.Cubiertos},
+		{"porcentaje_cubiertos", r.Publicacion.PorcentajeCubiertos},
+		{"cupos", r.Publicacion.Cupos},
+		{"cupos_ocupados", r.Publicacion.CuposOcupados},
+		{"horas_hasta_match_promedio", r.TiempoMatch.PromedioHoras},
+		{"horas_hasta_match_mediana", r.TiempoMatch.MedianaHoras},
+		{"likes", r.Embudo.Likes},
+		{"matches", r.Embudo.Matches},
+		{"completados", r.Embudo.Completados},
+		{"porcentaje_match", r.Embudo.PorcentajeMatch},
+		{"porcentaje_completados", r.Embudo.PorcentajeCompletados},
+		{"estudiantes_contratados", r.Recontratacion.Estudiantes},
+		{"estudiantes_recontratados", r.Recontratacion.Recontratados},
+		{"porcentaje_recontratacion", r.Recontratacion.Porcentaje},
+		{"valoraciones_dadas", r.Valoraciones.Dadas.Cantidad},
+		{"valoracion_dada_promedio", r.Valoraciones.Dadas.Promedio},
+		{"valoraciones_recibidas", r.Valoraciones.Recibidas.Cantidad},
+		{"valoracion_recibida_promedio", r.Valoraciones.Recibidas.Promedio},
+	}}
+
+	trabajos := Tabla{Nombre: "trabajos", Columnas: []string{
+		"job_id", "publicado", "titulo", "categoria", "estado", "cupos", "cupos_ocupados",
+		"likes", "matches", "completados", "cancelados", "horas_primer_match", "gastado",
+	}}
+	for _, t := range r.Trabajos {
+		var primerMatch interface{} = ""
+		if t.HorasPrimerMatch != nil {
+			primerMatch = *t.HorasPrimerMatch
+		}
+		trabajos.Filas = append(trabajos.Filas, []interface{}{
+			t.JobID, t.PublicadoEn.Format("2006-01-02"), t.Titulo, t.Categoria, t.Estado, t.Cupos, t.CuposOcupados,
+			t.Likes, t.Matches, t.Completados, t.Cancelados, primerMatch, t.Gastado,
+		})
+	}
+
+	return []Tabla{
+		resumen,
+		{Nombre: "gasto_semana", Columnas: columnasGasto, Filas: filasGrupos(r.Gasto.PorSemana)},
+		{Nombre: "gasto_mes", Columnas: columnasGasto, Filas: filasGrupos(r.Gasto.PorMes)},
+		{Nombre: "gasto_semestre", Columnas: columnasGasto, Filas: filasGrupos(r.Gasto.PorSemestre)},
+		{Nombre: "gasto_categoria", Columnas: columnasGasto, Filas: filasGrupos(r.Gasto.PorCategoria)},
+		trabajos,
+	}
+}
+
+// texto convierte la celda para el CSV; los textos vienen de los
+// usuarios y se neutralizan por si empiezan como una fórmula
+func texto(v interface{}) string {
+	switch x := v.(type) {
+	case float64:
+		return fmt.Sprintf("%.2f", x)
+	case string:
+		return exportar.CeldaCSV(x)
+	}
+	return fmt.Sprint(v)
+}
+
+// CSV escribe las secciones una tras otra, cada una con su nombre en la
+// primera fila. Lleva BOM para que Excel respete las tildes.
+func CSV(tablas []Tabla) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	for i, t := range tablas {
+		if i > 0 {
+			w.Write([]string{})
+		}
+		w.Write([]string{"# " + t.Nombre})
+		w.Write(t.Columnas)
+		for _, fila := range t.Filas {
+			celdas := make([]string, len(fila))
+			for j, v := range fila {
+				celdas[j] = texto(v)
+			}
+			w.Write(celdas)
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// XLSX escribe una hoja por sección con la cabecera en negrita
+func XLSX(tablas []Tabla) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	negrita, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range tablas {
+		if i == 0 {
+			if err := f.SetSheetName(f.GetSheetName(0), t.Nombre); err != nil {
+				return nil, err
+			}
+		} else if _, err := f.NewSheet(t.Nombre); err != nil {
+			return nil, err
+		}
+		if err := f.SetSheetRow(t.Nombre, "A1", &t.Columnas); err != nil {
+			return nil, err
+		}
+		if err := f.SetRowStyle(t.Nombre, 1, 1, negrita); err != nil {
+			return nil, err
+		}
+		for j, fila := range t.Filas {
+			celda, err := excelize.CoordinatesToCellName(1, j+2)
+			if err != nil {
+				return nil, err
+			}
+			if err := f.SetSheetRow(t.Nombre, celda, &fila); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
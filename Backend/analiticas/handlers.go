@@ -0,0 +1,59 @@
+package analiticas
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/VinkoRobi2/FlashWorkEC/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+// -------------------------------
+// GET /protected/analiticas?desde=2025-01-01&hasta=2025-06-30&formato=json|csv|xlsx
+// Gasto, publicación, tiempo hasta el match, embudo por trabajo,
+// recontratación y valoraciones
+// -------------------------------
+func AnaliticasHandler(c *gin.Context, db *sql.DB) {
+	userID, ok := middlewares.UsuarioConRol(c, "empleador")
+	if !ok {
+		return
+	}
+	desde, hasta, ok := fechas.Rango(c)
+	if !ok {
+		return
+	}
+
+	formato := c.DefaultQuery("formato", "json")
+	if formato != "json" && formato != "csv" && formato != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "formato debe ser json, csv o xlsx"})
+		return
+	}
+
+	reporte, err := Generar(db, userID, desde, hasta)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener analíticas", "err": err.Error()})
+		return
+	}
+
+	switch formato {
+	case "json":
+		c.JSON(http.StatusOK, reporte)
+	case "csv":
+		out, err := CSV(reporte.Tablas())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar CSV", "err": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="analiticas.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", out)
+	case "xlsx":
+		out, err := XLSX(reporte.Tablas())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar XLSX", "err": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="analiticas.xlsx"`)
+		c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", out)
+	}
+}
@@ -0,0 +1,123 @@
+package asistencia
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/secretos"
+)
+
+// Asistencia en trabajos presenciales. El empleador muestra en su
+// dispositivo un código (o QR) que rota cada VentanaCodigo; el estudiante
+// lo ingresa al llegar (entrada) y al irse (salida), opcionalmente con sus
+// coordenadas, que deben caer dentro del radio del trabajo si este tiene
+// ubicación. Los códigos no se guardan: se derivan con HMAC del match,
+// la acción y la ventana de tiempo, así que cualquier instancia del
+// servidor los valida igual. Los intentos fallidos sí se guardan, para
+// cortar a quien pruebe códigos al azar.
+//
+// Las horas trabajadas se acumulan en matches_job.horas_trabajadas y
+// alimentan el pago final de los trabajos por hora; si el estudiante no
+// registra entrada tras la tolerancia, el match queda marcado con
+// no_se_presento.
+
+const (
+	AccionEntrada = "entrada"
+	AccionSalida  = "salida"
+
+	VentanaCodigo  = 60 * time.Second
+	DigitosCodigo  = 6
+	RadioDefault   = 200 // metros
+	RadioMinimo    = 50
+	RadioMaximo    = 5000
+	radioTierraMts = 6371000
+
+	// Tiempo desde fecha_inicio sin entrada para marcar la inasistencia
+	ToleranciaInasistencia = 2 * time.Hour
+
+	// Intentos fallidos permitidos por match dentro de VentanaIntentos
+	MaxIntentosCodigo = 5
+	VentanaIntentos   = 15 * time.Minute
+)
+
+// Ventana devuelve el número de ventana de tiempo de t
+func Ventana(t time.Time) int64 {
+	return t.Unix() / int64(VentanaCodigo/time.Second)
+}
+
+// Codigo genera el código de la acción para el match en la ventana dada
+// con ASISTENCIA_SECRET o, si no existe, JWT_SECRET
+func Codigo(matchID int, accion string, ventana int64) (string, error) {
+	clave, err := secretos.Clave("ASISTENCIA_SECRET")
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, clave)
+	fmt.Fprintf(mac, "asistencia:%d:%s:%d", matchID, accion, ventana)
+	sum := mac.Sum(nil)
+	n := binary.BigEndian.Uint32(sum[:4]) % uint32(math.Pow10(DigitosCodigo))
+	return fmt.Sprintf("%0*d", DigitosCodigo, n), nil
+}
+
+// CodigoActual devuelve el código vigente y cuándo rota
+func CodigoActual(matchID int, accion string, ahora time.Time) (string, time.Time, error) {
+	v := Ventana(ahora)
+	codigo, err := Codigo(matchID, accion, v)
+	return codigo, time.Unix((v+1)*int64(VentanaCodigo/time.Second), 0), err
+}
+
+// VerificarCodigo acepta el código de la ventana actual y el de la
+// anterior, para no rechazar a quien lo tipeó justo cuando rotaba
+func VerificarCodigo(matchID int, accion, codigo string, ahora time.Time) (bool, error) {
+	v := Ventana(ahora)
+	ok := false
+	for _, w := range []int64{v, v - 1} {
+		esperado, err := Codigo(matchID, accion, w)
+		if err != nil {
+			return false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(esperado), []byte(codigo)) == 1 {
+			ok = true
+		}
+	}
+	return ok, nil
+}
+
+// ValidarCoordenadas comprueba que ambas coordenadas vengan juntas y en rango
+func ValidarCoordenadas(lat, lon *float64) string {
+	if (lat == nil) != (lon == nil) {
+		return "latitud y longitud van juntas"
+	}
+	if lat == nil {
+		return ""
+	}
+	if *lat < -90 || *lat > 90 || *lon < -180 || *lon > 180 {
+		return "coordenadas fuera de rango"
+	}
+	return ""
+}
+
+// NormalizarRadio aplica el radio por defecto y los límites
+func NormalizarRadio(radio int) (int, string) {
+	if radio == 0 {
+		return RadioDefault, ""
+	}
+	if radio < RadioMinimo || radio > RadioMaximo {
+		return 0, fmt.Sprintf("radio_metros debe estar entre %d y %d", RadioMinimo, RadioMaximo)
+	}
+	return radio, ""
+}
+
+// DistanciaMetros calcula la distancia haversine entre dos puntos
+func DistanciaMetros(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := func(g float64) float64 { return g * math.Pi / 180 }
+	dLat, dLon := rad(lat2-lat1), rad(lon2-lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return radioTierraMts * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,413 @@
+package asistencia
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/gin-gonic/gin"
+)
+
+// match son los datos del match y su job que importan para la asistencia
+type match struct {
+	id, jobID, estudianteID, empleadorID int
+	titulo, estado                       string
+	presencial                           bool
+	latitud, longitud                    sql.NullFloat64
+	radio                                int
+}
+
+func cargarMatch(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, matchID int, bloquear bool) (*match, error) {
+	query := `
+		SELECT m.id, m.job_id, m.estudiante_id, j.empleador_id, j.titulo, COALESCE(m.estado, ''),
+		       j.presencial, j.latitud, j.longitud, j.radio_metros
+		FROM matches_job m
+		JOIN jobs j ON j.id = m.job_id
+		WHERE m.id = $1 AND m.is_match = true
+	`
+	if bloquear {
+		query += " FOR UPDATE OF m"
+	}
+	var m match
+	err := q.QueryRow(query, matchID).Scan(&m.id, &m.jobID, &m.estudianteID, &m.empleadorID, &m.titulo,
+		&m.estado, &m.presencial, &m.latitud, &m.longitud, &m.radio)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// JornadaAbierta indica si el estudiante registró entrada y todavía no la salida
+func JornadaAbierta(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, matchID int) (bool, error) {
+	var abierta bool
+	err := q.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM asistencias WHERE match_id = $1 AND salida_en IS NULL)
+	`, matchID).Scan(&abierta)
+	return abierta, err
+}
+
+// verificarUbicacion compara las coordenadas del estudiante con las del
+// trabajo. Si el trabajo no tiene coordenadas, no se exige ubicación.
+func verificarUbicacion(m *match, lat, lon *float64) (*float64, int, string) {
+	if msg := ValidarCoordenadas(lat, lon); msg != "" {
+		return nil, http.StatusBadRequest, msg
+	}
+	if !m.latitud.Valid || !m.longitud.Valid {
+		return nil, 0, ""
+	}
+	if lat == nil {
+		return nil, http.StatusBadRequest, "este trabajo exige enviar latitud y longitud"
+	}
+	d := math.Round(DistanciaMetros(m.latitud.Float64, m.longitud.Float64, *lat, *lon))
+	if d > float64(m.radio) {
+		return &d, http.StatusForbidden, fmt.Sprintf("estás a %.0f m del lugar del trabajo (máximo %d m)", d, m.radio)
+	}
+	return &d, 0, ""
+}
+
+type registroRequest struct {
+	MatchID  int      `json:"match_id"`
+	Codigo   string   `json:"codigo"`
+	Latitud  *float64 `json:"latitud"`
+	Longitud *float64 `json:"longitud"`
+}
+
+// -------------------------------
+// GET /protected/asistencia/codigo?match_id=1&accion=entrada
+// El empleador lo muestra en pantalla (como número o QR); rota cada minuto
+// -------------------------------
+func CodigoHandler(c *gin.Context, db *sql.DB) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok || c.GetString("roles") != notificaciones.RolEmpleador {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo el empleador del trabajo"})
+		return
+	}
+	userID := userIDInterface.(int)
+
+	matchID, err := strconv.Atoi(c.Query("match_id"))
+	if err != nil || matchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id inválido"})
+		return
+	}
+	accion := c.DefaultQuery("accion", AccionEntrada)
+	if accion != AccionEntrada && accion != AccionSalida {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "accion debe ser entrada o salida"})
+		return
+	}
+
+	m, err := cargarMatch(db, matchID, false)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "match no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando match"})
+		return
+	}
+	if m.empleadorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no tienes permiso sobre este match"})
+		return
+	}
+	if !m.presencial {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "la asistencia solo aplica a trabajos presenciales"})
+		return
+	}
+
+	codigo, rota, err := CodigoActual(matchID, accion, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generando código", "err": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"match_id":  matchID,
+		"accion":    accion,
+		"codigo":    codigo,
+		"expira_en": rota.Format(time.RFC3339),
+		// Contenido para el QR que escanea el estudiante
+		"qr": fmt.Sprintf("cameya:asistencia:%d:%s:%s", matchID, accion, codigo),
+	})
+}
+
+// -------------------------------
+// POST /protected/asistencia/entrada  { "match_id": 1, "codigo": "123456", "latitud": -2.17, "longitud": -79.92 }
+// -------------------------------
+func EntradaHandler(c *gin.Context, db *sql.DB) {
+	registrar(c, db, AccionEntrada)
+}
+
+// -------------------------------
+// POST /protected/asistencia/salida  { "match_id": 1, "codigo": "654321", "latitud": -2.17, "longitud": -79.92 }
+// -------------------------------
+func SalidaHandler(c *gin.Context, db *sql.DB) {
+	registrar(c, db, AccionSalida)
+}
+
+func registrar(c *gin.Context, db *sql.DB, accion string) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok || c.GetString("roles") != notificaciones.RolEstudiante {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo el estudiante del match"})
+		return
+	}
+	userID := userIDInterface.(int)
+
+	var req registroRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.MatchID <= 0 || req.Codigo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id y codigo son requeridos"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	m, err := cargarMatch(tx, req.MatchID, true)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "match no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando match"})
+		return
+	}
+	if m.estudianteID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no tienes permiso sobre este match"})
+		return
+	}
+	if !m.presencial {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "la asistencia solo aplica a trabajos presenciales"})
+		return
+	}
+	if m.estado != "" && m.estado != "en_progreso" {
+		c.JSON(http.StatusConflict, gin.H{"error": "el match está " + m.estado})
+		return
+	}
+
+	abierta, err := JornadaAbierta(tx, m.id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando asistencia"})
+		return
+	}
+	if accion == AccionEntrada && abierta {
+		c.JSON(http.StatusConflict, gin.H{"error": "ya registraste tu entrada; falta la salida"})
+		return
+	}
+	if accion == AccionSalida && !abierta {
+		c.JSON(http.StatusConflict, gin.H{"error": "no hay una entrada registrada"})
+		return
+	}
+
+	// El match está bloqueado, así que los intentos simultáneos se cuentan en orden
+	var fallidos int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM asistencias_intentos
+		WHERE match_id = $1 AND intentado_en > NOW() - $2 * INTERVAL '1 second'
+	`, m.id, int(VentanaIntentos/time.Second)).Scan(&fallidos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando asistencia"})
+		return
+	}
+	if fallidos >= MaxIntentosCodigo {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("demasiados códigos incorrectos; intenta de nuevo en %d minutos", int(VentanaIntentos/time.Minute))})
+		return
+	}
+
+	ahora := time.Now()
+	valido, err := VerificarCodigo(m.id, accion, req.Codigo, ahora)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verificando código", "err": err.Error()})
+		return
+	}
+	if !valido {
+		// El intento fallido se guarda aunque la asistencia no se registre
+		if _, err := tx.Exec(`INSERT INTO asistencias_intentos (match_id) VALUES ($1)`, m.id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error registrando intento"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error registrando intento"})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":              "código inválido o vencido; pide al empleador el código actual",
+			"intentos_restantes": MaxIntentosCodigo - fallidos - 1,
+		})
+		return
+	}
+	distancia, status, msg := verificarUbicacion(m, req.Latitud, req.Longitud)
+	if msg != "" {
+		c.JSON(status, gin.H{"error": msg, "distancia_metros": distancia, "radio_metros": m.radio})
+		return
+	}
+
+	resp := gin.H{"match_id": m.id, "accion": accion, "registrado_en": ahora.Format(time.RFC3339), "distancia_metros": distancia}
+
+	if accion == AccionEntrada {
+		_, err = tx.Exec(`
+			INSERT INTO asistencias (match_id, entrada_en, entrada_latitud, entrada_longitud, entrada_distancia_m)
+			VALUES ($1, $2, $3, $4, $5)
+		`, m.id, ahora, req.Latitud, req.Longitud, distancia)
+		if err == nil {
+			// Llegó, aunque sea tarde: se levanta la marca de inasistencia
+			_, err = tx.Exec(`UPDATE matches_job SET no_se_presento = FALSE WHERE id = $1`, m.id)
+		}
+	} else {
+		var horas float64
+		err = tx.QueryRow(`
+			UPDATE asistencias
+			SET salida_en = $2, salida_latitud = $3, salida_longitud = $4, salida_distancia_m = $5,
+			    horas = ROUND(CAST(EXTRACT(EPOCH FROM ($2 - entrada_en)) / 3600 AS NUMERIC), 2)
+			WHERE match_id = $1 AND salida_en IS NULL
+			RETURNING CAST(horas AS FLOAT)
+		`, m.id, ahora, req.Latitud, req.Longitud, distancia).Scan(&horas)
+		if err == nil {
+			resp["horas"] = horas
+			err = tx.QueryRow(`
+				UPDATE matches_job
+				SET horas_trabajadas = (SELECT COALESCE(SUM(horas), 0) FROM asistencias WHERE match_id = $1)
+				WHERE id = $1
+				RETURNING CAST(horas_trabajadas AS FLOAT)
+			`, m.id).Scan(&horas)
+			resp["horas_trabajadas"] = horas
+		}
+	}
+	if err == nil {
+		// Con un código correcto se reinicia la cuenta de intentos
+		_, err = tx.Exec(`DELETE FROM asistencias_intentos WHERE match_id = $1`, m.id)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error registrando asistencia", "err": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error registrando asistencia"})
+		return
+	}
+
+	titulo, mensaje := "El estudiante llegó", fmt.Sprintf("El estudiante registró su entrada en \"%s\".", m.titulo)
+	if accion == AccionSalida {
+		titulo, mensaje = "El estudiante terminó su jornada", fmt.Sprintf("El estudiante registró su salida en \"%s\".", m.titulo)
+	}
+	err = notificaciones.Notificar(db, notificaciones.Notificacion{
+		UsuarioID: m.empleadorID,
+		Rol:       notificaciones.RolEmpleador,
+		Tipo:      "asistencia_" + accion,
+		Titulo:    titulo,
+		Mensaje:   mensaje,
+		Datos:     map[string]interface{}{"match_id": m.id, "job_id": m.jobID},
+	})
+	if err != nil {
+		log.Println("Error notificando asistencia:", err)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+type Registro struct {
+	ID        int      `json:"id"`
+	EntradaEn string   `json:"entrada_en"`
+	SalidaEn  *string  `json:"salida_en"`
+	Horas     *float64 `json:"horas"`
+	// Distancia al lugar del trabajo, si se enviaron coordenadas
+	EntradaDistancia *float64 `json:"entrada_distancia_metros"`
+	SalidaDistancia  *float64 `json:"salida_distancia_metros"`
+}
+
+// -------------------------------
+// GET /protected/asistencia?match_id=1
+// -------------------------------
+func VerAsistenciaHandler(c *gin.Context, db *sql.DB) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	userID := userIDInterface.(int)
+	rol := c.GetString("roles")
+
+	matchID, err := strconv.Atoi(c.Query("match_id"))
+	if err != nil || matchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id inválido"})
+		return
+	}
+
+	m, err := cargarMatch(db, matchID, false)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "match no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando match"})
+		return
+	}
+	if (rol == notificaciones.RolEstudiante && userID != m.estudianteID) ||
+		(rol == notificaciones.RolEmpleador && userID != m.empleadorID) ||
+		(rol != notificaciones.RolEstudiante && rol != notificaciones.RolEmpleador && rol != "admin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no tienes permiso sobre este match"})
+		return
+	}
+
+	var horasTrabajadas float64
+	var noSePresento bool
+	err = db.QueryRow(`
+		SELECT CAST(COALESCE(horas_trabajadas, 0) AS FLOAT), no_se_presento FROM matches_job WHERE id = $1
+	`, matchID).Scan(&horasTrabajadas, &noSePresento)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando match"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT id, entrada_en, salida_en, CAST(horas AS FLOAT),
+		       CAST(entrada_distancia_m AS FLOAT), CAST(salida_distancia_m AS FLOAT)
+		FROM asistencias
+		WHERE match_id = $1
+		ORDER BY entrada_en
+	`, matchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando asistencia", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	registros := []Registro{}
+	abierta := false
+	for rows.Next() {
+		var r Registro
+		var entrada time.Time
+		var salida sql.NullTime
+		if err := rows.Scan(&r.ID, &entrada, &salida, &r.Horas, &r.EntradaDistancia, &r.SalidaDistancia); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo asistencia"})
+			return
+		}
+		r.EntradaEn = entrada.Format(time.RFC3339)
+		if salida.Valid {
+			s := salida.Time.Format(time.RFC3339)
+			r.SalidaEn = &s
+		} else {
+			abierta = true
+		}
+		registros = append(registros, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"match_id":         matchID,
+		"presencial":       m.presencial,
+		"registros":        registros,
+		"jornada_abierta":  abierta,
+		"horas_trabajadas": horasTrabajadas,
+		"asistio":          len(registros) > 0,
+		"no_se_presento":   noSePresento,
+	})
+}
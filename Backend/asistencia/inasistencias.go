@@ -0,0 +1,76 @@
+package asistencia
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+)
+
+// ------------------------------------------------------
+// TAREA: marcar a los estudiantes que no se presentaron
+// Trabajos presenciales cuya fecha_inicio pasó hace más de la tolerancia
+// sin ninguna entrada registrada. El empleador decide después si cancela
+// o abre una disputa; la marca se usa al valorar al estudiante.
+// ------------------------------------------------------
+func MarcarInasistencias(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `
+		UPDATE matches_job mj
+		SET no_se_presento = TRUE
+		FROM jobs j
+		WHERE j.id = mj.job_id
+		  AND j.presencial = TRUE
+		  AND j.fecha_inicio IS NOT NULL
+		  AND j.fecha_inicio + $1::interval <= NOW()
+		  AND mj.is_match = true
+		  AND COALESCE(mj.estado, 'en_progreso') = 'en_progreso'
+		  AND mj.no_se_presento = FALSE
+		  AND mj.no_se_presento_avisado_en IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM asistencias a WHERE a.match_id = mj.id)
+		RETURNING mj.id, mj.job_id, j.empleador_id, j.titulo
+	`, fmt.Sprintf("%d seconds", int(ToleranciaInasistencia.Seconds())))
+	if err != nil {
+		return err
+	}
+	type marcado struct {
+		matchID, jobID, empleadorID int
+		titulo                      string
+	}
+	var marcados []marcado
+	for rows.Next() {
+		var m marcado
+		if err := rows.Scan(&m.matchID, &m.jobID, &m.empleadorID, &m.titulo); err != nil {
+			rows.Close()
+			return err
+		}
+		marcados = append(marcados, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range marcados {
+		// Se avisa una sola vez, aunque la marca se levante y vuelva
+		if _, err := db.ExecContext(ctx, `UPDATE matches_job SET no_se_presento_avisado_en = NOW() WHERE id = $1`, m.matchID); err != nil {
+			return err
+		}
+		err := notificaciones.Notificar(db, notificaciones.Notificacion{
+			UsuarioID: m.empleadorID,
+			Rol:       notificaciones.RolEmpleador,
+			Tipo:      "estudiante_no_se_presento",
+			Titulo:    "El estudiante no registró su llegada",
+			Mensaje: fmt.Sprintf(
+				"El estudiante no registró su entrada en \"%s\". Si no llegó, puedes cancelar el match o abrir una disputa.",
+				m.titulo,
+			),
+			Datos: map[string]interface{}{"match_id": m.matchID, "job_id": m.jobID},
+		})
+		if err != nil {
+			log.Println("Error notificando inasistencia:", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package categorias
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Las categorías de trabajo son una taxonomía administrada: cada una
+// tiene un slug único, un nombre visible, un ícono y opcionalmente una
+// categoría padre (un solo nivel). Los textos libres antiguos
+// ("Meseros", "mesero", "Mesería") se resuelven por categorias_alias.
+
+var ErrNoEncontrada = errors.New("categoría no encontrada")
+
+// Ejecutor lo cumplen *sql.DB y *sql.Tx
+type Ejecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type Categoria struct {
+	ID            int         `json:"id"`
+	Slug          string      `json:"slug"`
+	Nombre        string      `json:"nombre"`
+	Icono         string      `json:"icono"`
+	PadreID       *int        `json:"padre_id"`
+	Activa        bool        `json:"activa"`
+	Orden         int         `json:"orden"`
+	Subcategorias []Categoria `json:"subcategorias,omitempty"`
+}
+
+// Debe coincidir con translate(lower(btrim(x)), 'áéíóúüñ', 'aeiouun')
+// que usa la migración en Database.sql
+var sinTildes = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+)
+
+// Normalizar pasa a minúsculas, recorta y quita tildes
+func Normalizar(s string) string {
+	return sinTildes.Replace(strings.ToLower(strings.TrimSpace(s)))
+}
+
+var noAlfanumerico = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slug genera "atencion-al-cliente" a partir de "Atención al cliente"
+func Slug(s string) string {
+	return strings.Trim(noAlfanumerico.ReplaceAllString(Normalizar(s), "-"), "-")
+}
+
+const columnas = `c.id, c.slug, c.nombre, c.icono, c.padre_id, c.activa, c.orden`
+
+func escanear(row *sql.Row) (Categoria, error) {
+	var cat Categoria
+	var padre sql.NullInt64
+	err := row.Scan(&cat.ID, &cat.Slug, &cat.Nombre, &cat.Icono, &padre, &cat.Activa, &cat.Orden)
+	if err == sql.ErrNoRows {
+		return cat, ErrNoEncontrada
+	}
+	if padre.Valid {
+		p := int(padre.Int64)
+		cat.PadreID = &p
+	}
+	return cat, err
+}
+
+// PorID devuelve una categoría activa
+func PorID(q Ejecutor, id int) (Categoria, error) {
+	return escanear(q.QueryRow(`
+		SELECT `+columnas+` FROM categorias c WHERE c.id = $1 AND c.activa
+	`, id))
+}
+
+// Resolver busca una categoría activa por id, slug, nombre o alias
+func Resolver(q Ejecutor, valor string) (Categoria, error) {
+	valor = strings.TrimSpace(valor)
+	if valor == "" {
+		return Categoria{}, ErrNoEncontrada
+	}
+	if id, err := strconv.Atoi(valor); err == nil {
+		return PorID(q, id)
+	}
+	return escanear(q.QueryRow(`
+		SELECT `+columnas+`
+		FROM categorias c
+		WHERE c.activa
+		  AND (c.slug = $1
+		       OR c.id IN (SELECT categoria_id FROM categorias_alias WHERE alias = $2))
+		LIMIT 1
+	`, Slug(valor), Normalizar(valor)))
+}
+
+// Elegir resuelve la categoría de un request que puede traer
+// categoria_id o el texto de categoria. Devuelve nil si no trae ninguno.
+func Elegir(q Ejecutor, id int, texto string) (*Categoria, error) {
+	var cat Categoria
+	var err error
+	switch {
+	case id > 0:
+		cat, err = PorID(q, id)
+	case strings.TrimSpace(texto) != "":
+		cat, err = Resolver(q, texto)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cat, nil
+}
+
+// ValidarIDs comprueba que todos los ids existan y estén activos
+func ValidarIDs(q Ejecutor, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	unicos := map[int]bool{}
+	for _, id := range ids {
+		unicos[id] = true
+	}
+	lista := make([]int64, 0, len(unicos))
+	for id := range unicos {
+		lista = append(lista, int64(id))
+	}
+
+	var n int
+	if err := q.QueryRow(`
+		SELECT COUNT(*) FROM categorias WHERE id = ANY($1) AND activa
+	`, pq.Array(lista)).Scan(&n); err != nil {
+		return err
+	}
+	if n != len(lista) {
+		return ErrNoEncontrada
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+package categorias
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoriaRequest struct {
+	Nombre  *string `json:"nombre"`
+	Slug    *string `json:"slug"`
+	Icono   *string `json:"icono"`
+	PadreID *int    `json:"padre_id"` // 0 la vuelve categoría principal
+	Activa  *bool   `json:"activa"`
+	Orden   *int    `json:"orden"`
+}
+
+// listar devuelve las categorías ordenadas, con o sin las inactivas
+func listar(db *sql.DB, soloActivas bool) ([]Categoria, error) {
+	rows, err := db.Query(`
+		SELECT `+columnas+`
+		FROM categorias c
+		WHERE c.activa OR NOT $1
+		ORDER BY c.orden, c.nombre
+	`, soloActivas)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todas []Categoria
+	for rows.Next() {
+		var cat Categoria
+		var padre sql.NullInt64
+		if err := rows.Scan(&cat.ID, &cat.Slug, &cat.Nombre, &cat.Icono, &padre, &cat.Activa, &cat.Orden); err != nil {
+			return nil, err
+		}
+		if padre.Valid {
+			p := int(padre.Int64)
+			cat.PadreID = &p
+		}
+		todas = append(todas, cat)
+	}
+	return todas, rows.Err()
+}
+
+// arbol agrupa las subcategorías debajo de su padre
+func arbol(todas []Categoria) []Categoria {
+	hijos := map[int][]Categoria{}
+	for _, cat := range todas {
+		if cat.PadreID != nil {
+			hijos[*cat.PadreID] = append(hijos[*cat.PadreID], cat)
+		}
+	}
+	raiz := []Categoria{}
+	for _, cat := range todas {
+		if cat.PadreID == nil {
+			cat.Subcategorias = hijos[cat.ID]
+			raiz = append(raiz, cat)
+		}
+	}
+	return raiz
+}
+
+// -------------------------------
+// GET /categorias  (público, solo activas)
+// -------------------------------
+func ListarCategoriasHandler(c *gin.Context, db *sql.DB) {
+	todas, err := listar(db, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener categorías", "err": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"categorias": arbol(todas)})
+}
+
+// -------------------------------
+// GET /protected/admin/categorias  (incluye inactivas)
+// -------------------------------
+func AdminListarCategoriasHandler(c *gin.Context, db *sql.DB) {
+	todas, err := listar(db, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener categorías", "err": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"categorias": arbol(todas)})
+}
+
+// validarPadre: el padre debe existir, no ser la misma categoría
+// y ser de primer nivel
+func validarPadre(db *sql.DB, padreID, id int) string {
+	if padreID == id {
+		return "Una categoría no puede ser su propio padre"
+	}
+	var abuelo sql.NullInt64
+	err := db.QueryRow(`SELECT padre_id FROM categorias WHERE id = $1`, padreID).Scan(&abuelo)
+	if err == sql.ErrNoRows {
+		return "padre_id no existe"
+	}
+	if err != nil {
+		return "Error al validar padre_id"
+	}
+	if abuelo.Valid {
+		return "Solo se admite un nivel de subcategorías"
+	}
+	if id > 0 {
+		var tieneHijos bool
+		db.QueryRow(`SELECT EXISTS (SELECT 1 FROM categorias WHERE padre_id = $1)`, id).Scan(&tieneHijos)
+		if tieneHijos {
+			return "Una categoría con subcategorías no puede tener padre"
+		}
+	}
+	return ""
+}
+
+// -------------------------------
+// POST /protected/admin/categorias
+// -------------------------------
+func CrearCategoriaHandler(c *gin.Context, db *sql.DB) {
+	var req CategoriaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	if req.Nombre == nil || strings.TrimSpace(*req.Nombre) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nombre es requerido"})
+		return
+	}
+
+	nombre := strings.TrimSpace(*req.Nombre)
+	slug := Slug(nombre)
+	if req.Slug != nil && *req.Slug != "" {
+		slug = Slug(*req.Slug)
+	}
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug inválido"})
+		return
+	}
+
+	var padre *int
+	if req.PadreID != nil && *req.PadreID > 0 {
+		if msg := validarPadre(db, *req.PadreID, 0); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		padre = req.PadreID
+	}
+	icono, orden, activa := "", 0, true
+	if req.Icono != nil {
+		icono = *req.Icono
+	}
+	if req.Orden != nil {
+		orden = *req.Orden
+	}
+	if req.Activa != nil {
+		activa = *req.Activa
+	}
+
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO categorias (slug, nombre, icono, padre_id, activa, orden)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (slug) DO NOTHING
+		RETURNING id
+	`, slug, nombre, icono, padre, activa, orden).Scan(&id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ya existe una categoría con ese slug", "slug": slug})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear categoría", "err": err.Error()})
+		return
+	}
+
+	// El nombre propio también sirve como alias
+	db.Exec(`
+		INSERT INTO categorias_alias (alias, categoria_id) VALUES ($1, $2)
+		ON CONFLICT (alias) DO NOTHING
+	`, Normalizar(nombre), id)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"mensaje": "Categoría creada",
+		"id":      id,
+		"slug":    slug,
+	})
+}
+
+// -------------------------------
+// PUT /protected/admin/categorias/:id
+// Para "borrar" una categoría en uso se desactiva (activa=false):
+// los trabajos existentes la conservan pero no se puede elegir.
+// -------------------------------
+func EditarCategoriaHandler(c *gin.Context, db *sql.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req CategoriaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+
+	var actual Categoria
+	var padre sql.NullInt64
+	err = db.QueryRow(`
+		SELECT id, slug, nombre, icono, padre_id, activa, orden FROM categorias WHERE id = $1
+	`, id).Scan(&actual.ID, &actual.Slug, &actual.Nombre, &actual.Icono, &padre, &actual.Activa, &actual.Orden)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Categoría no encontrada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer categoría"})
+		return
+	}
+
+	if req.Nombre != nil {
+		if strings.TrimSpace(*req.Nombre) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "nombre no puede estar vacío"})
+			return
+		}
+		actual.Nombre = strings.TrimSpace(*req.Nombre)
+	}
+	if req.Slug != nil {
+		actual.Slug = Slug(*req.Slug)
+		if actual.Slug == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "slug inválido"})
+			return
+		}
+	}
+	if req.Icono != nil {
+		actual.Icono = *req.Icono
+	}
+	if req.Activa != nil {
+		actual.Activa = *req.Activa
+	}
+	if req.Orden != nil {
+		actual.Orden = *req.Orden
+	}
+	if req.PadreID != nil {
+		padre = sql.NullInt64{}
+		if *req.PadreID > 0 {
+			if msg := validarPadre(db, *req.PadreID, id); msg != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+				return
+			}
+			padre = sql.NullInt64{Int64: int64(*req.PadreID), Valid: true}
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE categorias
+		SET slug = $1, nombre = $2, icono = $3, padre_id = $4, activa = $5, orden = $6,
+		    actualizado_en = NOW()
+		WHERE id = $7
+	`, actual.Slug, actual.Nombre, actual.Icono, padre, actual.Activa, actual.Orden, id)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "No se pudo actualizar la categoría (¿slug repetido?)", "err": err.Error()})
+		return
+	}
+
+	// jobs.categoria guarda el nombre para las vistas existentes
+	if req.Nombre != nil {
+		if _, err := tx.Exec(`UPDATE jobs SET categoria = $1 WHERE categoria_id = $2`, actual.Nombre, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error actualizando trabajos"})
+			return
+		}
+		tx.Exec(`
+			INSERT INTO categorias_alias (alias, categoria_id) VALUES ($1, $2)
+			ON CONFLICT (alias) DO NOTHING
+		`, Normalizar(actual.Nombre), id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar categoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Categoría actualizada",
+		"categoria": actual,
+	})
+}
+
+// -------------------------------
+// DELETE /protected/admin/categorias/:id
+// Solo borra categorías sin uso; si ya tiene trabajos hay que desactivarla
+// -------------------------------
+func BorrarCategoriaHandler(c *gin.Context, db *sql.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var enUso bool
+	err = db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM jobs WHERE categoria_id = $1)
+		    OR EXISTS (SELECT 1 FROM categorias WHERE padre_id = $1)
+		    OR EXISTS (SELECT 1 FROM empleador_categorias WHERE categoria_id = $1)
+	`, id).Scan(&enUso)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar categoría"})
+		return
+	}
+	if enUso {
+		c.JSON(http.StatusConflict, gin.H{"error": "La categoría está en uso; desactívala con activa=false"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	tx.Exec(`DELETE FROM categorias_alias WHERE categoria_id = $1`, id)
+	res, err := tx.Exec(`DELETE FROM categorias WHERE id = $1`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al borrar categoría"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Categoría no encontrada"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al borrar categoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Categoría eliminada",
+		"id":      id,
+	})
+}
+
+// -------------------------------
+// POST /protected/admin/categorias/:id/alias  { "alias": "Mesería" }
+// Registra un texto libre como alias y reasigna los trabajos que
+// todavía lo tenían sin categoría.
+// -------------------------------
+func AgregarAliasHandler(c *gin.Context, db *sql.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		Alias string `json:"alias"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Alias) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "alias es requerido"})
+		return
+	}
+	alias := Normalizar(req.Alias)
+
+	var nombre string
+	if err := db.QueryRow(`SELECT nombre FROM categorias WHERE id = $1`, id).Scan(&nombre); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Categoría no encontrada"})
+		return
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO categorias_alias (alias, categoria_id) VALUES ($1, $2)
+		ON CONFLICT (alias) DO UPDATE SET categoria_id = EXCLUDED.categoria_id
+	`, alias, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar alias", "err": err.Error()})
+		return
+	}
+
+	res, err := db.Exec(`
+		UPDATE jobs SET categoria_id = $1, categoria = $2
+		WHERE categoria_id IS NULL
+		  AND translate(lower(btrim(categoria)), 'áéíóúüñ', 'aeiouun') = $3
+	`, id, nombre, alias)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reasignando trabajos", "err": err.Error()})
+		return
+	}
+	reasignados, _ := res.RowsAffected()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":              "Alias guardado",
+		"alias":                alias,
+		"categoria_id":         id,
+		"trabajos_reasignados": reasignados,
+	})
+}
@@ -0,0 +1,304 @@
+package certificados
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/VinkoRobi2/FlashWorkEC/secretos"
+)
+
+// Certificados de trabajo completado. El certificado se emite una sola
+// vez, la primera vez que el estudiante lo descarga: se guarda una foto
+// de los datos (título, empleador, fechas, horas, valoración) firmada con
+// HMAC y desde entonces el PDF sale de esa foto. /verificar-certificado/:codigo
+// recalcula la firma, así que un registro alterado en la base deja de verificar.
+
+var (
+	ErrNoEncontrado = errors.New("certificado no encontrado")
+	ErrNoCompletado = errors.New("solo los trabajos completados tienen certificado")
+)
+
+type Certificado struct {
+	Codigo           string   `json:"codigo"`
+	MatchID          int      `json:"match_id"`
+	Estudiante       string   `json:"estudiante"`
+	Empleador        string   `json:"empleador"`
+	Titulo           string   `json:"titulo"`
+	Categoria        string   `json:"categoria"`
+	FechaInicio      *string  `json:"fecha_inicio"`
+	FechaFin         *string  `json:"fecha_fin"`
+	Horas            *float64 `json:"horas"`
+	HorasRegistradas bool     `json:"horas_registradas"` // false = horas estimadas del trabajo
+	Rating           *int     `json:"rating"`
+	EmitidoEn        string   `json:"emitido_en"`
+	Firma            string   `json:"firma"`
+	estudianteID     int
+}
+
+// Ejecutor permite usar tanto *sql.DB como *sql.Tx
+type Ejecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// nuevoCodigo genera un código legible tipo ABCD-EFGH-JKLM
+func nuevoCodigo() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)[:12]
+	return s[:4] + "-" + s[4:8] + "-" + s[8:], nil
+}
+
+// NormalizarCodigo acepta el código en minúsculas o sin guiones
+func NormalizarCodigo(s string) string {
+	s = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), "-", ""))
+	if len(s) != 12 {
+		return s
+	}
+	return s[:4] + "-" + s[4:8] + "-" + s[8:]
+}
+
+func texto(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+// firmar calcula la firma de los datos del certificado con
+// CERTIFICADOS_SECRET o, si no existe, JWT_SECRET
+func (c *Certificado) firmar() (string, error) {
+	clave, err := secretos.Clave("CERTIFICADOS_SECRET")
+	if err != nil {
+		return "", err
+	}
+	horas, rating := "", ""
+	if c.Horas != nil {
+		horas = fmt.Sprintf("%.2f", *c.Horas)
+	}
+	if c.Rating != nil {
+		rating = fmt.Sprint(*c.Rating)
+	}
+	mac := hmac.New(sha256.New, clave)
+	fmt.Fprintf(mac, "%s|%d|%s|%s|%s|%s|%s|%s|%s|%t|%s|%s",
+		c.Codigo, c.MatchID, c.Estudiante, c.Empleador, c.Titulo, c.Categoria,
+		texto(c.FechaInicio), texto(c.FechaFin), horas, c.HorasRegistradas, rating, c.EmitidoEn)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Valido indica si la firma guardada corresponde a los datos
+func (c *Certificado) Valido() (bool, error) {
+	firma, err := c.firmar()
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal([]byte(firma), []byte(c.Firma)), nil
+}
+
+func fecha(t sql.NullTime) *string {
+	if !t.Valid {
+		return nil
+	}
+	s := t.Time.In(fechas.ZonaEcuador).Format("2006-01-02")
+	return &s
+}
+
+// DatosMatch lee los datos actuales de un match completado del
+// estudiante, sin código ni firma
+func DatosMatch(q Ejecutor, matchID, estudianteID int) (*Certificado, error) {
+	var c Certificado
+	var inicio, fin sql.NullTime
+	var horasTrab, horasEst sql.NullFloat64
+	var rating sql.NullInt64
+	var estado string
+	err := q.QueryRow(`
+		SELECT m.id, m.estudiante_id, COALESCE(m.estado, ''),
+		       e.nombre || ' ' || e.apellido,
+		       em.nombre || ' ' || em.apellido,
+		       j.titulo, COALESCE(j.categoria, ''),
+		       COALESCE((SELECT MIN(a.entrada_en) FROM asistencias a WHERE a.match_id = m.id), j.fecha_inicio),
+		       COALESCE(m.completado_en,
+		                (SELECT MAX(h.creado_en) FROM matches_historial h
+		                 WHERE h.match_id = m.id AND h.estado_nuevo = 'completado')),
+		       CAST(m.horas_trabajadas AS FLOAT), CAST(j.horas_estimadas AS FLOAT),
+		       (SELECT v.rating FROM valoracion_estudiante v
+		        WHERE v.job_id = m.job_id AND v.estudiante_valorado_id = m.estudiante_id
+		        ORDER BY v.id DESC LIMIT 1)
+		FROM matches_job m
+		JOIN jobs j ON j.id = m.job_id
+		JOIN estudiantes e ON e.id = m.estudiante_id
+		JOIN empleadores em ON em.id = j.empleador_id
+		WHERE m.id = $1 AND m.estudiante_id = $2 AND m.is_match = true
+	`, matchID, estudianteID).Scan(&c.MatchID, &c.estudianteID, &estado, &c.Estudiante, &c.Empleador,
+		&c.Titulo, &c.Categoria, &inicio, &fin, &horasTrab, &horasEst, &rating)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	if estado != "completado" {
+		return nil, ErrNoCompletado
+	}
+
+	c.FechaInicio, c.FechaFin = fecha(inicio), fecha(fin)
+	if horasTrab.Valid && horasTrab.Float64 > 0 {
+		c.Horas, c.HorasRegistradas = &horasTrab.Float64, true
+	} else if horasEst.Valid {
+		c.Horas = &horasEst.Float64
+	}
+	if rating.Valid {
+		r := int(rating.Int64)
+		c.Rating = &r
+	}
+	return &c, nil
+}
+
+// Emitir devuelve el certificado del match. La primera vez lo firma y lo
+// guarda; después devuelve siempre la foto guardada, con su código,
+// fecha de emisión y firma originales.
+func Emitir(q Ejecutor, matchID, estudianteID int) (*Certificado, error) {
+	c, err := deMatch(q, matchID, estudianteID)
+	if err != ErrNoEncontrado {
+		return c, err
+	}
+
+	c, err = DatosMatch(q, matchID, estudianteID)
+	if err != nil {
+		return nil, err
+	}
+	if c.Codigo, err = nuevoCodigo(); err != nil {
+		return nil, err
+	}
+	c.EmitidoEn = time.Now().UTC().Format(time.RFC3339)
+	if c.Firma, err = c.firmar(); err != nil {
+		return nil, err
+	}
+
+	// Si otra petición lo emitió entretanto, vale el que quedó guardado
+	_, err = q.Exec(`
+		INSERT INTO certificados (codigo, match_id, estudiante_id, estudiante, empleador, titulo, categoria,
+		                          fecha_inicio, fecha_fin, horas, horas_registradas, rating, emitido_en, firma)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (match_id) DO NOTHING
+	`, c.Codigo, c.MatchID, c.estudianteID, c.Estudiante, c.Empleador, c.Titulo, c.Categoria,
+		c.FechaInicio, c.FechaFin, c.Horas, c.HorasRegistradas, c.Rating, c.EmitidoEn, c.Firma)
+	if err != nil {
+		return nil, err
+	}
+	return deMatch(q, matchID, estudianteID)
+}
+
+const columnasCertificado = `codigo, match_id, estudiante_id, estudiante, empleador, titulo, categoria,
+		       fecha_inicio, fecha_fin, CAST(horas AS FLOAT), horas_registradas, rating, emitido_en, firma`
+
+// escanear lee una fila con columnasCertificado
+func escanear(row interface {
+	Scan(dest ...interface{}) error
+}) (*Certificado, error) {
+	var c Certificado
+	var inicio, fin sql.NullTime
+	var emitido time.Time
+	var horas sql.NullFloat64
+	var rating sql.NullInt64
+	err := row.Scan(&c.Codigo, &c.MatchID, &c.estudianteID, &c.Estudiante, &c.Empleador,
+		&c.Titulo, &c.Categoria, &inicio, &fin, &horas, &c.HorasRegistradas, &rating, &emitido, &c.Firma)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Las fechas se guardan como DATE: se leen sin convertir de zona
+	if inicio.Valid {
+		s := inicio.Time.Format("2006-01-02")
+		c.FechaInicio = &s
+	}
+	if fin.Valid {
+		s := fin.Time.Format("2006-01-02")
+		c.FechaFin = &s
+	}
+	if horas.Valid {
+		c.Horas = &horas.Float64
+	}
+	if rating.Valid {
+		r := int(rating.Int64)
+		c.Rating = &r
+	}
+	c.EmitidoEn = emitido.UTC().Format(time.RFC3339)
+	return &c, nil
+}
+
+// deMatch lee el certificado ya emitido de un match del estudiante
+func deMatch(q Ejecutor, matchID, estudianteID int) (*Certificado, error) {
+	return escanear(q.QueryRow(`
+		SELECT `+columnasCertificado+`
+		FROM certificados
+		WHERE match_id = $1 AND estudiante_id = $2
+	`, matchID, estudianteID))
+}
+
+// Buscar lee un certificado emitido por su código
+func Buscar(db *sql.DB, codigo string) (*Certificado, error) {
+	return escanear(db.QueryRow(`
+		SELECT `+columnasCertificado+`
+		FROM certificados
+		WHERE codigo = $1
+	`, NormalizarCodigo(codigo)))
+}
+
+// Historial arma el historial del estudiante sin escribir nada: los
+// trabajos con certificado salen de su foto y los demás de los datos
+// actuales, sin código
+func Historial(db *sql.DB, estudianteID int) ([]*Certificado, error) {
+	ids, err := CompletadosDelEstudiante(db, estudianteID)
+	if err != nil {
+		return nil, err
+	}
+	certs := make([]*Certificado, 0, len(ids))
+	for _, id := range ids {
+		c, err := deMatch(db, id, estudianteID)
+		if err == ErrNoEncontrado {
+			c, err = DatosMatch(db, id, estudianteID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, nil
+}
+
+// CompletadosDelEstudiante devuelve los IDs de sus matches completados,
+// del más reciente al más antiguo
+func CompletadosDelEstudiante(db *sql.DB, estudianteID int) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT id FROM matches_job
+		WHERE estudiante_id = $1 AND is_match = true AND estado = 'completado'
+		ORDER BY completado_en DESC NULLS LAST, id DESC
+	`, estudianteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
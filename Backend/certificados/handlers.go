@@ -0,0 +1,120 @@
+package certificados
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/VinkoRobi2/FlashWorkEC/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+// urlVerificacion arma la dirección pública de verificación
+func urlVerificacion(c *gin.Context, codigo string) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	url := scheme + "://" + c.Request.Host + "/verificar-certificado/"
+	if codigo != "" {
+		url += codigo
+	}
+	return url
+}
+
+// -------------------------------
+// GET /protected/certificados/:match_id
+// PDF del certificado de un trabajo completado
+// -------------------------------
+func CertificadoHandler(c *gin.Context, db *sql.DB) {
+	userID, ok := middlewares.UsuarioConRol(c, "estudiante")
+	if !ok {
+		return
+	}
+	matchID, err := strconv.Atoi(c.Param("match_id"))
+	if err != nil || matchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id inválido"})
+		return
+	}
+
+	cert, err := Emitir(db, matchID, userID)
+	switch err {
+	case nil:
+	case ErrNoEncontrado:
+		c.JSON(http.StatusNotFound, gin.H{"error": "match no encontrado"})
+		return
+	case ErrNoCompletado:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al emitir certificado", "err": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := cert.PDF(&buf, urlVerificacion(c, cert.Codigo)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar PDF", "err": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="certificado-%s.pdf"`, cert.Codigo))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// -------------------------------
+// GET /protected/certificados/historial
+// Historial laboral consolidado para adjuntar al CV
+// -------------------------------
+func HistorialHandler(c *gin.Context, db *sql.DB) {
+	userID, ok := middlewares.UsuarioConRol(c, "estudiante")
+	if !ok {
+		return
+	}
+
+	var nombre string
+	err := db.QueryRow(`SELECT nombre || ' ' || apellido FROM estudiantes WHERE id = $1`, userID).Scan(&nombre)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener estudiante"})
+		return
+	}
+
+	certs, err := Historial(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener trabajos completados", "err": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := HistorialPDF(&buf, nombre, certs, urlVerificacion(c, "")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar PDF", "err": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="historial-laboral.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// -------------------------------
+// GET /verificar-certificado/:code (pública)
+// -------------------------------
+func VerificarHandler(c *gin.Context, db *sql.DB) {
+	cert, err := Buscar(db, c.Param("code"))
+	if err == ErrNoEncontrado {
+		c.JSON(http.StatusNotFound, gin.H{"valido": false, "error": "No existe un certificado con ese código"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar certificado"})
+		return
+	}
+	valido, err := cert.Valido()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar certificado", "err": err.Error()})
+		return
+	}
+	if !valido {
+		c.JSON(http.StatusOK, gin.H{"valido": false, "error": "La firma del certificado no coincide"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"valido": true, "certificado": cert})
+}
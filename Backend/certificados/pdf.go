@@ -0,0 +1,151 @@
+package certificados
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// nuevoPDF prepara un A4 vertical; tr convierte UTF-8 a la codificación
+// de las fuentes estándar para que salgan las tildes y la ñ
+func nuevoPDF() (*gofpdf.Fpdf, func(string) string) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	return pdf, pdf.UnicodeTranslatorFromDescriptor("")
+}
+
+func textoFecha(s *string) string {
+	if s == nil {
+		return "—"
+	}
+	t, err := time.Parse("2006-01-02", *s)
+	if err != nil {
+		return *s
+	}
+	return t.Format("02/01/2006")
+}
+
+func (c *Certificado) textoPeriodo() string {
+	inicio, fin := textoFecha(c.FechaInicio), textoFecha(c.FechaFin)
+	if inicio == fin || c.FechaInicio == nil {
+		return fin
+	}
+	return inicio + " al " + fin
+}
+
+func (c *Certificado) textoHoras() string {
+	if c.Horas == nil {
+		return "—"
+	}
+	if c.HorasRegistradas {
+		return fmt.Sprintf("%.2f h (registradas)", *c.Horas)
+	}
+	return fmt.Sprintf("%.2f h (estimadas)", *c.Horas)
+}
+
+func (c *Certificado) textoRating() string {
+	if c.Rating == nil {
+		return "Sin valoración"
+	}
+	return fmt.Sprintf("%d de 5 %s", *c.Rating, strings.Repeat("*", *c.Rating))
+}
+
+// PDF escribe el certificado de un trabajo completado.
+// urlVerificacion es la dirección pública donde se comprueba el código.
+func (c *Certificado) PDF(w io.Writer, urlVerificacion string) error {
+	pdf, tr := nuevoPDF()
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 22)
+	pdf.CellFormat(0, 14, tr("Certificado de trabajo completado"), "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(0, 8, "CameYa", "", 1, "C", false, 0, "")
+	pdf.Ln(10)
+
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.MultiCell(0, 7, tr(fmt.Sprintf(
+		"Se certifica que %s completó el trabajo \"%s\" para %s a través de CameYa.",
+		c.Estudiante, c.Titulo, c.Empleador,
+	)), "", "L", false)
+	pdf.Ln(6)
+
+	filas := [][2]string{
+		{"Trabajo", c.Titulo},
+		{"Categoría", c.Categoria},
+		{"Empleador", c.Empleador},
+		{"Fechas", c.textoPeriodo()},
+		{"Horas", c.textoHoras()},
+		{"Valoración recibida", c.textoRating()},
+	}
+	for _, f := range filas {
+		if f[1] == "" {
+			continue
+		}
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(50, 8, tr(f[0]), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.MultiCell(0, 8, tr(f[1]), "", "L", false)
+	}
+
+	pdf.Ln(12)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 8, tr("Código de verificación: "+c.Codigo), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.MultiCell(0, 5, tr("Verifica la autenticidad en "+urlVerificacion), "", "L", false)
+	pdf.MultiCell(0, 5, tr("Emitido el "+c.emitidoLocal()+" · Firma "+c.Firma[:16]), "", "L", false)
+
+	return pdf.Output(w)
+}
+
+func (c *Certificado) emitidoLocal() string {
+	t, err := time.Parse(time.RFC3339, c.EmitidoEn)
+	if err != nil {
+		return c.EmitidoEn
+	}
+	return t.In(fechas.ZonaEcuador).Format("02/01/2006 15:04")
+}
+
+// HistorialPDF escribe el historial laboral consolidado del estudiante,
+// un trabajo por bloque con su código de verificación
+func HistorialPDF(w io.Writer, estudiante string, certs []*Certificado, urlVerificacion string) error {
+	pdf, tr := nuevoPDF()
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.CellFormat(0, 12, tr("Historial laboral"), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.CellFormat(0, 8, tr(estudiante), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(0, 6, tr(fmt.Sprintf("%d trabajo(s) completado(s) en CameYa · generado el %s",
+		len(certs), time.Now().In(fechas.ZonaEcuador).Format("02/01/2006"))), "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	if len(certs) == 0 {
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.CellFormat(0, 8, tr("Todavía no hay trabajos completados."), "", 1, "L", false, 0, "")
+	}
+
+	for _, c := range certs {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.MultiCell(0, 7, tr(c.Titulo), "", "L", false)
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 5, tr(fmt.Sprintf("%s · %s", c.Empleador, c.textoPeriodo())), "", "L", false)
+		pdf.MultiCell(0, 5, tr(fmt.Sprintf("Horas: %s · Valoración: %s", c.textoHoras(), c.textoRating())), "", "L", false)
+		pdf.SetFont("Helvetica", "", 8)
+		if c.Codigo != "" {
+			pdf.MultiCell(0, 5, tr("Código de verificación: "+c.Codigo), "", "L", false)
+		} else {
+			pdf.MultiCell(0, 5, tr("Sin certificado emitido: descárgalo desde el trabajo para obtener su código"), "", "L", false)
+		}
+		pdf.Ln(4)
+	}
+
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.MultiCell(0, 5, tr("Cada trabajo se puede verificar en "+urlVerificacion), "", "L", false)
+	return pdf.Output(w)
+}
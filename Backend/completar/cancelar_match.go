@@ -0,0 +1,151 @@
+package completar
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/cupos"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/versiones"
+	"github.com/gin-gonic/gin"
+)
+
+// -------------------------------
+// HANDLER: Cancelar un match (estudiante o empleador)
+// Libera el cupo y, si el job estaba cubierto, vuelve a abrirse
+// -------------------------------
+func CancelarMatchHandler(db *sql.DB, c *gin.Context) {
+
+	userID, err := getUserIdFromJWT(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token inválido"})
+		return
+	}
+	rol := c.GetString("roles")
+
+	var body struct {
+		MatchID int    `json:"match_id"`
+		Motivo  string `json:"motivo"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body inválido"})
+		return
+	}
+
+	if body.MatchID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id es requerido"})
+		return
+	}
+
+	var (
+		jobID        int
+		estudianteID int
+		empleadorID  int
+		estado       sql.NullString
+	)
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error iniciando transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Se bloquean el job y el match (en ese orden, como el matching) para
+	// que el recálculo de cupos vea el estado definitivo
+	err = tx.QueryRow(`
+		SELECT mj.job_id, mj.estudiante_id, j.empleador_id, mj.estado
+		FROM matches_job mj
+		JOIN jobs j ON mj.job_id = j.id
+		WHERE mj.id = $1
+	`, body.MatchID).Scan(&jobID, &estudianteID, &empleadorID, &estado)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "match no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando match"})
+		return
+	}
+
+	// Los IDs de estudiantes y empleadores viven en tablas distintas,
+	// así que el rol del token decide contra cuál se compara
+	switch rol {
+	case "estudiante":
+		if userID != estudianteID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no tienes permiso sobre este match"})
+			return
+		}
+	case "empleador":
+		if userID != empleadorID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no tienes permiso sobre este match"})
+			return
+		}
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "rol no permitido"})
+		return
+	}
+
+	_, err = tx.Exec(`SELECT 1 FROM jobs WHERE id = $1 FOR UPDATE`, jobID)
+	if err == nil {
+		err = tx.QueryRow(`SELECT estado FROM matches_job WHERE id = $1 FOR UPDATE`, body.MatchID).Scan(&estado)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando match"})
+		return
+	}
+
+	if estado.String == "completado" || estado.String == "cancelado" {
+		c.JSON(http.StatusConflict, gin.H{"error": "el match ya está " + estado.String})
+		return
+	}
+	if estado.String == "en_disputa" {
+		c.JSON(http.StatusConflict, gin.H{"error": "el match tiene una disputa abierta; lo resuelve un administrador"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE matches_job
+		SET estado = 'cancelado',
+		    cancelado_por = $2,
+		    motivo_cancelacion = $3,
+		    cancelado_en = NOW()
+		WHERE id = $1
+	`, body.MatchID, rol, body.Motivo)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error cancelando match"})
+		return
+	}
+
+	if err := RegistrarHistorial(tx, body.MatchID, EventoCancelado, estado.String, "cancelado", rol, body.Motivo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error registrando historial"})
+		return
+	}
+
+	if err := versiones.CerrarPropuestaSinContratados(tx, jobID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error cerrando propuesta de pago"})
+		return
+	}
+
+	estadoCupos, err := cupos.Recalcular(tx, jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error actualizando cupos del trabajo"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error cancelando match"})
+		return
+	}
+	AvisarCancelado(body.MatchID, jobID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Match cancelado",
+		"estado":            "cancelado",
+		"job_estado":        estadoCupos.EstadoJob,
+		"cupos":             estadoCupos.Cupos,
+		"cupos_ocupados":    estadoCupos.Ocupados,
+		"cupos_completados": estadoCupos.Completados,
+	})
+}
@@ -6,7 +6,12 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/cupos"
+	"github.com/VinkoRobi2/FlashWorkEC/asistencia"
+	"github.com/VinkoRobi2/FlashWorkEC/ofertas"
 	auth "github.com/VinkoRobi2/FlashWorkEC/service"
 	"github.com/gin-gonic/gin"
 )
@@ -34,6 +39,39 @@ func getUserIdFromJWT(c *gin.Context) (int, error) {
 	return claims.UserID, nil
 }
 
+// verificarSinDisputa responde 409 si el match está en disputa: mientras
+// un admin no la resuelva nadie puede marcarlo como completado
+func verificarSinDisputa(db *sql.DB, c *gin.Context, matchID int) error {
+	var enDisputa bool
+	err := db.QueryRow(`
+		SELECT COALESCE(estado, '') = 'en_disputa' FROM matches_job WHERE id = $1
+	`, matchID).Scan(&enDisputa)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error verificando match"})
+		return err
+	}
+	if enDisputa {
+		c.JSON(http.StatusConflict, gin.H{"error": "el match tiene una disputa abierta; lo resuelve un administrador"})
+		return errors.New("match en disputa")
+	}
+	return nil
+}
+
+// verificarSinJornadaAbierta responde 409 si el estudiante registró su
+// entrada y no su salida: las horas trabajadas todavía no están completas
+func verificarSinJornadaAbierta(db *sql.DB, c *gin.Context, matchID int) error {
+	abierta, err := asistencia.JornadaAbierta(db, matchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error verificando asistencia"})
+		return err
+	}
+	if abierta {
+		c.JSON(http.StatusConflict, gin.H{"error": "el estudiante debe registrar su salida antes de completar"})
+		return errors.New("jornada abierta")
+	}
+	return nil
+}
+
 // -------------------------------
 // HANDLER: Estudiante completa (por match_id)
 // -------------------------------
@@ -45,12 +83,14 @@ func EstudianteCompletarHandler(db *sql.DB, c *gin.Context) {
 		return
 	}
 
+	// JSON o multipart: con multipart se adjuntan entregables en "archivos"
 	var body struct {
-		MatchID int `json:"match_id"`
-		JobID   int `json:"job_id"`
+		MatchID int    `json:"match_id" form:"match_id"`
+		JobID   int    `json:"job_id" form:"job_id"`
+		Nota    string `json:"nota" form:"nota"`
 	}
 
-	if err := c.ShouldBindJSON(&body); err != nil {
+	if err := c.ShouldBind(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "body inválido"})
 		return
 	}
@@ -59,6 +99,16 @@ func EstudianteCompletarHandler(db *sql.DB, c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id y job_id son requeridos"})
 		return
 	}
+	body.Nota = strings.TrimSpace(body.Nota)
+	if len(body.Nota) > MaxLargoNotaEntrega {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "la nota supera " + strconv.Itoa(MaxLargoNotaEntrega) + " caracteres"})
+		return
+	}
+	archivos, err := leerEntregables(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Validar que el match exista y pertenezca a ese estudiante + job
 	var exists bool
@@ -69,6 +119,7 @@ func EstudianteCompletarHandler(db *sql.DB, c *gin.Context) {
 			WHERE id = $1
 			  AND estudiante_id = $2
 			  AND job_id = $3
+			  AND estado IS DISTINCT FROM 'cancelado'
 		)
 	`, body.MatchID, studentID, body.JobID).Scan(&exists)
 
@@ -80,6 +131,22 @@ func EstudianteCompletarHandler(db *sql.DB, c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "no tienes permiso sobre este match / job"})
 		return
 	}
+	if err := verificarSinDisputa(db, c, body.MatchID); err != nil {
+		return
+	}
+	if err := verificarSinJornadaAbierta(db, c, body.MatchID); err != nil {
+		return
+	}
+
+	// Entregables primero: el empleador los ve antes de confirmar
+	if err := guardarEntregables(db, body.MatchID, body.Nota, archivos); err != nil {
+		if err == errLimiteEntregables {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error guardando entregables", "err": err.Error()})
+		return
+	}
 
 	// Marcar completado por parte del estudiante
 	_, err = db.Exec(`
@@ -94,17 +161,22 @@ func EstudianteCompletarHandler(db *sql.DB, c *gin.Context) {
 	}
 
 	// Lógica híbrida
-	studentDone, employerDone, estado, err := completarSiAmbos(db, body.MatchID, body.JobID)
+	studentDone, employerDone, estado, err := completarSiAmbos(db, body.MatchID, body.JobID, "estudiante")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando estado"})
 		return
 	}
 
+	if !employerDone {
+		avisarEntrega(db, body.MatchID, len(archivos))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":            "Estudiante marcó como completado",
 		"student_completed":  studentDone,
 		"employer_completed": employerDone,
 		"estado":             estado,
+		"entregables":        len(archivos),
 	})
 }
 
@@ -144,6 +216,7 @@ func EmpleadorCompletarHandler(db *sql.DB, c *gin.Context) {
 			WHERE mj.id = $1
 			  AND mj.job_id = $2
 			  AND j.empleador_id = $3
+			  AND mj.estado IS DISTINCT FROM 'cancelado'
 		)
 	`, body.MatchID, body.JobID, employerID).Scan(&exists)
 
@@ -155,6 +228,12 @@ func EmpleadorCompletarHandler(db *sql.DB, c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "no tienes permiso sobre este match / job"})
 		return
 	}
+	if err := verificarSinDisputa(db, c, body.MatchID); err != nil {
+		return
+	}
+	if err := verificarSinJornadaAbierta(db, c, body.MatchID); err != nil {
+		return
+	}
 
 	// Marcar completado por parte del empleador
 	_, err = db.Exec(`
@@ -169,7 +248,7 @@ func EmpleadorCompletarHandler(db *sql.DB, c *gin.Context) {
 	}
 
 	// Lógica híbrida
-	studentDone, employerDone, estado, err := completarSiAmbos(db, body.MatchID, body.JobID)
+	studentDone, employerDone, estado, err := completarSiAmbos(db, body.MatchID, body.JobID, "empleador")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando estado"})
 		return
@@ -186,16 +265,30 @@ func EmpleadorCompletarHandler(db *sql.DB, c *gin.Context) {
 // ------------------------------------------------------
 // FUNCIÓN: Completar match y job según ambos flags
 // (modelo híbrido sobre matches_job)
+// rol es quién acaba de confirmar, para el historial
 // ------------------------------------------------------
-func completarSiAmbos(db *sql.DB, matchID int, jobID int) (bool, bool, string, error) {
+func completarSiAmbos(db *sql.DB, matchID int, jobID int, rol string) (bool, bool, string, error) {
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, false, "", err
+	}
+	defer tx.Rollback()
+
+	// Mismo orden de locks que el matching (job y luego match), así el
+	// recálculo de cupos no se cruza con un match nuevo
+	if _, err := tx.Exec(`SELECT 1 FROM jobs WHERE id = $1 FOR UPDATE`, jobID); err != nil {
+		return false, false, "", err
+	}
 
 	var studentDone, employerDone bool
 	var estado sql.NullString
 
-	err := db.QueryRow(`
+	err = tx.QueryRow(`
         SELECT student_completed, employer_completed, estado 
         FROM matches_job
         WHERE id = $1
+        FOR UPDATE
     `, matchID).Scan(&studentDone, &employerDone, &estado)
 
 	if err != nil {
@@ -207,40 +300,64 @@ func completarSiAmbos(db *sql.DB, matchID int, jobID int) (bool, bool, string, e
 		currentEstado = estado.String
 	}
 
+	completado := false
 	if studentDone && employerDone {
 		// Ambos marcaron → completado total
 		if currentEstado != "completado" {
-			_, _ = db.Exec(`
-                UPDATE matches_job
-                SET estado = 'completado'
-                WHERE id = $1
+			// El pago final es el acordado o, sin acuerdo, el publicado
+			_, err = tx.Exec(`
+                UPDATE matches_job mj
+                SET estado = 'completado',
+                    completado_en = NOW(),
+                    pago_final = `+compensacion.PagoFinalSQL+`
+                FROM jobs j
+                WHERE mj.id = $1 AND j.id = mj.job_id
             `, matchID)
+			if err != nil {
+				return false, false, "", err
+			}
+			if err := RegistrarHistorial(tx, matchID, EventoCompletado, currentEstado, "completado", rol, ""); err != nil {
+				return false, false, "", err
+			}
+			completado = true
 		}
 
-		// Si quieres que el job completo quede cerrado con el primer match cerrado:
-		_, _ = db.Exec(`
-            UPDATE jobs
-            SET estado = 'completado'
-            WHERE id = $1
-        `, jobID)
+		// El job solo se cierra cuando todos sus cupos contratados terminaron
+		if _, err := cupos.Recalcular(tx, jobID); err != nil {
+			return false, false, "", err
+		}
 
 		currentEstado = "completado"
 	} else if studentDone && !employerDone {
 		if currentEstado == "" || currentEstado == "en_progreso" {
-			_, _ = db.Exec(`
+			_, err = tx.Exec(`
                 UPDATE matches_job
-                SET estado = 'pendiente_confirmacion_empleador'
+                SET estado = 'pendiente_confirmacion_empleador',
+                    confirmacion_pendiente_desde = NOW()
                 WHERE id = $1
             `, matchID)
+			if err != nil {
+				return false, false, "", err
+			}
+			if err := RegistrarHistorial(tx, matchID, EventoConfirmaEstudiante, currentEstado, "pendiente_confirmacion_empleador", rol, ""); err != nil {
+				return false, false, "", err
+			}
 			currentEstado = "pendiente_confirmacion_empleador"
 		}
 	} else if employerDone && !studentDone {
 		if currentEstado == "" || currentEstado == "en_progreso" {
-			_, _ = db.Exec(`
+			_, err = tx.Exec(`
                 UPDATE matches_job
-                SET estado = 'pendiente_confirmacion_estudiante'
+                SET estado = 'pendiente_confirmacion_estudiante',
+                    confirmacion_pendiente_desde = NOW()
                 WHERE id = $1
             `, matchID)
+			if err != nil {
+				return false, false, "", err
+			}
+			if err := RegistrarHistorial(tx, matchID, EventoConfirmaEmpleador, currentEstado, "pendiente_confirmacion_estudiante", rol, ""); err != nil {
+				return false, false, "", err
+			}
 			currentEstado = "pendiente_confirmacion_estudiante"
 		}
 	} else {
@@ -249,6 +366,13 @@ func completarSiAmbos(db *sql.DB, matchID int, jobID int) (bool, bool, string, e
 		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return false, false, "", err
+	}
+	if completado {
+		AvisarCompletado(matchID, jobID)
+	}
+
 	return studentDone, employerDone, currentEstado, nil
 }
 
@@ -283,27 +407,57 @@ func ObtenerEstadoMatchHandler(db *sql.DB, c *gin.Context) {
 		return
 	}
 
+	// Los IDs de estudiantes y empleadores pueden coincidir: el rol del
+	// token decide contra qué lado del match se compara
+	m, err := ofertas.CargarMatch(db, matchID, userID, c.GetString("roles"), false)
+	if err == ofertas.ErrNoEncontrada || (err == nil && m.JobID != jobID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "match no encontrado"})
+		return
+	}
+	if err == ofertas.ErrPermiso {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no tienes permiso sobre este match"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando estado del match"})
+		return
+	}
+
 	var (
-		estudianteID int
-		empleadorID  int
 		studentDone  bool
 		employerDone bool
 		estado       sql.NullString
+		pago         float64
+		pagoAcordado sql.NullFloat64
+		unidadPago   string
+		horas        sql.NullFloat64
+		pagoFinal    sql.NullFloat64
+		disputaID    sql.NullInt64
+		pendiente    sql.NullTime
+		automatico   bool
+		horasTrab    sql.NullFloat64
 	)
 
 	// Resolvemos todo a partir del match
 	err = db.QueryRow(`
 		SELECT 
-			mj.estudiante_id,
-			j.empleador_id,
 			mj.student_completed,
 			mj.employer_completed,
-			mj.estado
+			mj.estado,
+			COALESCE(mj.pago_acordado, j.pago_estimado),
+			mj.pago_acordado,
+			COALESCE(mj.unidad_pago, j.unidad_pago),
+			j.horas_estimadas,
+			mj.pago_final,
+			(SELECT d.id FROM disputas d WHERE d.match_id = mj.id ORDER BY d.id DESC LIMIT 1),
+			mj.confirmacion_pendiente_desde,
+			mj.completado_automatico,
+			CAST(mj.horas_trabajadas AS FLOAT)
 		FROM matches_job mj
 		JOIN jobs j ON mj.job_id = j.id
 		WHERE mj.id = $1
 		  AND mj.job_id = $2
-	`, matchID, jobID).Scan(&estudianteID, &empleadorID, &studentDone, &employerDone, &estado)
+	`, matchID, jobID).Scan(&studentDone, &employerDone, &estado, &pago, &pagoAcordado, &unidadPago, &horas, &pagoFinal, &disputaID, &pendiente, &automatico, &horasTrab)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "match no encontrado"})
@@ -314,19 +468,42 @@ func ObtenerEstadoMatchHandler(db *sql.DB, c *gin.Context) {
 		return
 	}
 
-	if userID != estudianteID && userID != empleadorID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "no tienes permiso sobre este match"})
-		return
-	}
-
 	currentEstado := "en_progreso"
 	if estado.Valid && estado.String != "" {
 		currentEstado = estado.String
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"student_completed":  studentDone,
-		"employer_completed": employerDone,
-		"estado":             currentEstado,
-	})
+	// El pago acordado en la negociación es el que vale al completar
+	resp := gin.H{
+		"student_completed":          studentDone,
+		"employer_completed":         employerDone,
+		"estado":                     currentEstado,
+		"pago":                       pago,
+		"pago_acordado":              nil,
+		"unidad_pago":                unidadPago,
+		"compensacion":               compensacion.DesdeBD(pago, unidadPago, horas),
+		"pago_final":                 nil,
+		"disputa_id":                 nil,
+		"completado_automatico":      automatico,
+		"confirmacion_automatica_en": nil,
+	}
+	if pagoAcordado.Valid {
+		resp["pago_acordado"] = pagoAcordado.Float64
+	}
+	// Lo que efectivamente se paga, incluido el resultado de una disputa
+	if pagoFinal.Valid {
+		resp["pago_final"] = pagoFinal.Float64
+	}
+	if disputaID.Valid {
+		resp["disputa_id"] = disputaID.Int64
+	}
+	// Horas registradas con la asistencia (trabajos presenciales)
+	if horasTrab.Valid {
+		resp["horas_trabajadas"] = horasTrab.Float64
+	}
+	// Si la otra parte no responde, el match se confirma solo a esta hora
+	if pendiente.Valid && strings.HasPrefix(currentEstado, "pendiente_confirmacion_") {
+		resp["confirmacion_automatica_en"] = pendiente.Time.Add(VentanaConfirmacion()).Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, resp)
 }
\ No newline at end of file
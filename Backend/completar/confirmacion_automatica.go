@@ -0,0 +1,245 @@
+package completar
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/cupos"
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+)
+
+// Cuando solo una parte confirmó, la otra tiene VentanaConfirmacion para
+// confirmar o abrir una disputa. Pasado ese plazo la tarea programada da
+// el match por completado (completado_automatico = TRUE) y lo deja en el
+// historial con actor "sistema". Antes del vencimiento se le recuerda a
+// la parte que falta.
+
+const (
+	HorasConfirmacionDefault = 72
+	// Con cuánta anticipación se envía el recordatorio
+	RecordatorioConfirmacionAntes = 24 * time.Hour
+)
+
+// VentanaConfirmacion lee HORAS_CONFIRMACION_AUTOMATICA (72 por defecto)
+func VentanaConfirmacion() time.Duration {
+	horas := HorasConfirmacionDefault
+	if v, err := strconv.Atoi(os.Getenv("HORAS_CONFIRMACION_AUTOMATICA")); err == nil && v > 0 {
+		horas = v
+	}
+	return time.Duration(horas) * time.Hour
+}
+
+// recordatorioAntes no puede ser mayor que la ventana: con ventanas
+// cortas el recordatorio sale a la mitad del plazo
+func recordatorioAntes(ventana time.Duration) time.Duration {
+	if RecordatorioConfirmacionAntes >= ventana {
+		return ventana / 2
+	}
+	return RecordatorioConfirmacionAntes
+}
+
+func intervalo(d time.Duration) string {
+	return fmt.Sprintf("%d seconds", int(d.Seconds()))
+}
+
+// pendiente es un match esperando la confirmación de una de las partes
+type pendiente struct {
+	matchID, jobID, estudianteID, empleadorID int
+	estado, titulo                            string
+	vence                                     time.Time
+}
+
+// faltante devuelve a quién le toca confirmar
+func (p pendiente) faltante() (int, string) {
+	if p.estado == "pendiente_confirmacion_estudiante" {
+		return p.estudianteID, notificaciones.RolEstudiante
+	}
+	return p.empleadorID, notificaciones.RolEmpleador
+}
+
+// ------------------------------------------------------
+// TAREA: recordar a la parte que no ha confirmado
+// ------------------------------------------------------
+func RecordarConfirmacionesPendientes(ctx context.Context, db *sql.DB) error {
+	ventana := VentanaConfirmacion()
+	rows, err := db.QueryContext(ctx, `
+		UPDATE matches_job mj
+		SET recordatorio_confirmacion_en = NOW()
+		FROM jobs j
+		WHERE j.id = mj.job_id
+		  AND mj.estado IN ('pendiente_confirmacion_estudiante', 'pendiente_confirmacion_empleador')
+		  AND mj.confirmacion_pendiente_desde IS NOT NULL
+		  AND (mj.recordatorio_confirmacion_en IS NULL
+		       OR mj.recordatorio_confirmacion_en < mj.confirmacion_pendiente_desde)
+		  AND mj.confirmacion_pendiente_desde + $1::interval <= NOW() + $2::interval
+		RETURNING mj.id, mj.job_id, mj.estudiante_id, j.empleador_id, mj.estado, j.titulo,
+		          mj.confirmacion_pendiente_desde + $1::interval
+	`, intervalo(ventana), intervalo(recordatorioAntes(ventana)))
+	if err != nil {
+		return err
+	}
+	var pendientes []pendiente
+	for rows.Next() {
+		var p pendiente
+		if err := rows.Scan(&p.matchID, &p.jobID, &p.estudianteID, &p.empleadorID, &p.estado, &p.titulo, &p.vence); err != nil {
+			rows.Close()
+			return err
+		}
+		pendientes = append(pendientes, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range pendientes {
+		destino, rol := p.faltante()
+		err := notificaciones.Notificar(db, notificaciones.Notificacion{
+			UsuarioID: destino,
+			Rol:       rol,
+			Tipo:      "confirmacion_pendiente",
+			Titulo:    "Confirma la finalización del trabajo",
+			Mensaje: fmt.Sprintf(
+				"La otra parte marcó \"%s\" como completado. Si no confirmas ni abres una disputa antes del %s, se confirmará automáticamente.",
+				p.titulo, p.vence.In(fechas.ZonaEcuador).Format("02/01/2006 15:04"),
+			),
+			Datos: map[string]interface{}{"match_id": p.matchID, "job_id": p.jobID, "vence": p.vence.Format(time.RFC3339)},
+		})
+		if err != nil {
+			log.Println("Error notificando confirmación pendiente:", err)
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------------------
+// TAREA: confirmar automáticamente los matches vencidos
+// Un match en disputa ya no está pendiente, así que no entra aquí
+// ------------------------------------------------------
+func ConfirmarCompletadosAutomaticos(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT mj.id
+		FROM matches_job mj
+		WHERE mj.estado IN ('pendiente_confirmacion_estudiante', 'pendiente_confirmacion_empleador')
+		  AND mj.confirmacion_pendiente_desde + $1::interval <= NOW()
+		  AND NOT EXISTS (SELECT 1 FROM disputas d WHERE d.match_id = mj.id AND d.estado = 'abierta')
+		ORDER BY mj.id
+		LIMIT 200
+	`, intervalo(VentanaConfirmacion()))
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		p, err := confirmarAutomatico(db, id)
+		if err != nil {
+			log.Printf("Error confirmando automáticamente el match %d: %v", id, err)
+			continue
+		}
+		if p == nil {
+			continue
+		}
+		AvisarCompletado(p.matchID, p.jobID)
+
+		mensaje := fmt.Sprintf("\"%s\" se dio por completado automáticamente: pasó el plazo de confirmación sin respuesta ni disputa.", p.titulo)
+		datos := map[string]interface{}{"match_id": p.matchID, "job_id": p.jobID, "automatico": true}
+		for _, n := range []notificaciones.Notificacion{
+			{UsuarioID: p.estudianteID, Rol: notificaciones.RolEstudiante},
+			{UsuarioID: p.empleadorID, Rol: notificaciones.RolEmpleador},
+		} {
+			n.Tipo, n.Titulo, n.Mensaje, n.Datos = "match_completado_automatico", "Trabajo completado", mensaje, datos
+			if err := notificaciones.Notificar(db, n); err != nil {
+				log.Println("Error notificando completado automático:", err)
+			}
+		}
+	}
+	return nil
+}
+
+// confirmarAutomatico completa un match en su propia transacción. Si
+// entretanto alguien confirmó, canceló o abrió una disputa, no hace nada.
+func confirmarAutomatico(db *sql.DB, matchID int) (*pendiente, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Mismo orden de locks que completarSiAmbos: primero el job, que
+	// cupos.Recalcular vuelve a usar, y luego el match
+	var jobID int
+	err = tx.QueryRow(`SELECT job_id FROM matches_job WHERE id = $1`, matchID).Scan(&jobID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`SELECT 1 FROM jobs WHERE id = $1 FOR UPDATE`, jobID); err != nil {
+		return nil, err
+	}
+
+	var p pendiente
+	err = tx.QueryRow(`
+		SELECT mj.id, mj.job_id, mj.estudiante_id, j.empleador_id, mj.estado, j.titulo
+		FROM matches_job mj
+		JOIN jobs j ON j.id = mj.job_id
+		WHERE mj.id = $1
+		  AND mj.estado IN ('pendiente_confirmacion_estudiante', 'pendiente_confirmacion_empleador')
+		FOR UPDATE OF mj
+	`, matchID).Scan(&p.matchID, &p.jobID, &p.estudianteID, &p.empleadorID, &p.estado, &p.titulo)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE matches_job mj
+		SET estado = 'completado',
+		    student_completed = TRUE,
+		    employer_completed = TRUE,
+		    completado_automatico = TRUE,
+		    completado_en = NOW(),
+		    pago_final = `+compensacion.PagoFinalSQL+`
+		FROM jobs j
+		WHERE mj.id = $1 AND j.id = mj.job_id
+	`, matchID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, rol := p.faltante()
+	detalle := fmt.Sprintf("Sin confirmación del %s en %d horas", rol, int(VentanaConfirmacion().Hours()))
+	if err := RegistrarHistorial(tx, matchID, EventoCompletadoAutomatico, p.estado, "completado", "sistema", detalle); err != nil {
+		return nil, err
+	}
+	if _, err := cupos.Recalcular(tx, p.jobID); err != nil {
+		return nil, err
+	}
+	return &p, tx.Commit()
+}
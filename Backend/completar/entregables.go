@@ -0,0 +1,327 @@
+package completar
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/gin-gonic/gin"
+)
+
+// Entregables: al marcar el match como completado el estudiante puede
+// adjuntar archivos o fotos del trabajo y una nota. Se guardan fuera de
+// ./uploads, en DirEntregables, y solo los descargan las dos partes o un
+// admin; el empleador los revisa antes de confirmar.
+
+const (
+	MaxBytesEntregable     = 10 << 20 // 10 MB por archivo
+	MaxEntregablesPorMatch = 10
+	MaxLargoNotaEntrega    = 2000
+	DirEntregables         = "./privado/entregables"
+)
+
+// Tipos aceptados según el contenido del archivo
+var tiposEntregable = map[string]string{
+	"image/jpeg":                "jpg",
+	"image/png":                 "png",
+	"application/pdf":           "pdf",
+	"application/zip":           "zip",
+	"text/plain; charset=utf-8": "txt",
+}
+
+var (
+	errFormatoEntregable = errors.New("los entregables deben ser JPG, PNG, PDF, ZIP o texto")
+	errEntregableGrande  = fmt.Errorf("cada entregable puede pesar hasta %d MB", MaxBytesEntregable>>20)
+	errLimiteEntregables = fmt.Errorf("máximo %d entregables por match", MaxEntregablesPorMatch)
+)
+
+type Entregable struct {
+	ID       int    `json:"id"`
+	Nombre   string `json:"nombre"`
+	TipoMime string `json:"tipo_mime"`
+	Bytes    int    `json:"bytes"`
+	CreadoEn string `json:"creado_en"`
+	archivo  string
+}
+
+// archivoNuevo es un entregable leído del request, todavía sin guardar
+type archivoNuevo struct {
+	nombre string
+	data   []byte
+	mime   string
+	ext    string
+}
+
+// leerEntregables toma los archivos del campo "archivos" de un multipart
+// y los valida por su contenido. Un request JSON no trae archivos.
+func leerEntregables(c *gin.Context) ([]archivoNuevo, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return nil, nil
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	archivos := form.File["archivos"]
+	if len(archivos) > MaxEntregablesPorMatch {
+		return nil, errLimiteEntregables
+	}
+
+	var nuevos []archivoNuevo
+	for _, fh := range archivos {
+		if fh.Size > MaxBytesEntregable {
+			return nil, errEntregableGrande
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(f, MaxBytesEntregable+1))
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > MaxBytesEntregable {
+			return nil, errEntregableGrande
+		}
+		mime := http.DetectContentType(data)
+		ext, ok := tiposEntregable[mime]
+		if !ok {
+			return nil, errFormatoEntregable
+		}
+		nuevos = append(nuevos, archivoNuevo{nombre: filepath.Base(fh.Filename), data: data, mime: mime, ext: ext})
+	}
+	return nuevos, nil
+}
+
+// guardarEntregables escribe los archivos en disco y los registra junto
+// con la nota de entrega. Los que ya tenía el match se conservan.
+func guardarEntregables(db *sql.DB, matchID int, nota string, nuevos []archivoNuevo) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var actuales int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM matches_entregables WHERE match_id = $1
+	`, matchID).Scan(&actuales)
+	if err != nil {
+		return err
+	}
+	if actuales+len(nuevos) > MaxEntregablesPorMatch {
+		return errLimiteEntregables
+	}
+
+	if len(nuevos) > 0 {
+		dir := filepath.Join(DirEntregables, strconv.Itoa(matchID))
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	for i, a := range nuevos {
+		archivo := filepath.Join(strconv.Itoa(matchID), fmt.Sprintf("%d-%d.%s", time.Now().UnixNano(), i, a.ext))
+		if err := os.WriteFile(filepath.Join(DirEntregables, archivo), a.data, 0600); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO matches_entregables (match_id, nombre_original, tipo_mime, bytes, archivo)
+			VALUES ($1, $2, $3, $4, $5)
+		`, matchID, a.nombre, a.mime, len(a.data), archivo)
+		if err != nil {
+			return err
+		}
+	}
+
+	if nota != "" || len(nuevos) > 0 {
+		_, err = tx.Exec(`
+			UPDATE matches_job
+			SET nota_entrega = COALESCE(NULLIF($2, ''), nota_entrega), entregado_en = NOW()
+			WHERE id = $1
+		`, matchID, nota)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// avisarEntrega le pide al empleador que revise la entrega y confirme
+func avisarEntrega(db *sql.DB, matchID, archivos int) {
+	var empleadorID, jobID int
+	var titulo string
+	err := db.QueryRow(`
+		SELECT j.empleador_id, j.id, j.titulo
+		FROM matches_job mj
+		JOIN jobs j ON mj.job_id = j.id
+		WHERE mj.id = $1
+	`, matchID).Scan(&empleadorID, &jobID, &titulo)
+	if err != nil {
+		log.Println("Error avisando entrega:", err)
+		return
+	}
+	mensaje := fmt.Sprintf("El estudiante marcó \"%s\" como completado. Revisa y confirma.", titulo)
+	if archivos > 0 {
+		mensaje = fmt.Sprintf("El estudiante marcó \"%s\" como completado y adjuntó %d entregable(s). Revísalos y confirma.", titulo, archivos)
+	}
+	err = notificaciones.Notificar(db, notificaciones.Notificacion{
+		UsuarioID: empleadorID,
+		Rol:       notificaciones.RolEmpleador,
+		Tipo:      "trabajo_entregado",
+		Titulo:    "Trabajo entregado",
+		Mensaje:   mensaje,
+		Datos:     map[string]interface{}{"match_id": matchID, "job_id": jobID, "entregables": archivos},
+	})
+	if err != nil {
+		log.Println("Error notificando entrega:", err)
+	}
+}
+
+// listarEntregables devuelve los archivos del match en orden de subida
+func listarEntregables(db *sql.DB, matchID int) ([]Entregable, error) {
+	rows, err := db.Query(`
+		SELECT id, nombre_original, tipo_mime, bytes, archivo, creado_en
+		FROM matches_entregables
+		WHERE match_id = $1
+		ORDER BY id
+	`, matchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lista := []Entregable{}
+	for rows.Next() {
+		var e Entregable
+		var creadoEn time.Time
+		if err := rows.Scan(&e.ID, &e.Nombre, &e.TipoMime, &e.Bytes, &e.archivo, &creadoEn); err != nil {
+			return nil, err
+		}
+		e.CreadoEn = creadoEn.Format(time.RFC3339)
+		lista = append(lista, e)
+	}
+	return lista, rows.Err()
+}
+
+// puedeVerMatch comprueba que el usuario sea parte del match o admin
+func puedeVerMatch(db *sql.DB, c *gin.Context, matchID int) bool {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return false
+	}
+	userID := userIDInterface.(int)
+	rol := c.GetString("roles")
+
+	var estudianteID, empleadorID int
+	err := db.QueryRow(`
+		SELECT mj.estudiante_id, j.empleador_id
+		FROM matches_job mj
+		JOIN jobs j ON mj.job_id = j.id
+		WHERE mj.id = $1
+	`, matchID).Scan(&estudianteID, &empleadorID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "match no encontrado"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando match"})
+		return false
+	}
+	switch {
+	case rol == "estudiante" && userID == estudianteID,
+		rol == "empleador" && userID == empleadorID,
+		rol == "admin":
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "no tienes permiso sobre este match"})
+	return false
+}
+
+// ------------------------------------------------------
+// HANDLER: Entregables de un match
+// GET /protected/completar/entregables?match_id=1
+// ------------------------------------------------------
+func ListarEntregablesHandler(db *sql.DB, c *gin.Context) {
+	matchID, err := strconv.Atoi(c.Query("match_id"))
+	if err != nil || matchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id inválido"})
+		return
+	}
+	if !puedeVerMatch(db, c, matchID) {
+		return
+	}
+
+	var nota sql.NullString
+	var entregadoEn sql.NullTime
+	err = db.QueryRow(`
+		SELECT nota_entrega, entregado_en FROM matches_job WHERE id = $1
+	`, matchID).Scan(&nota, &entregadoEn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando entrega"})
+		return
+	}
+	lista, err := listarEntregables(db, matchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando entregables", "err": err.Error()})
+		return
+	}
+
+	resp := gin.H{
+		"match_id":     matchID,
+		"nota":         nil,
+		"entregado_en": nil,
+		"entregables":  lista,
+	}
+	if nota.Valid {
+		resp["nota"] = nota.String
+	}
+	if entregadoEn.Valid {
+		resp["entregado_en"] = entregadoEn.Time.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ------------------------------------------------------
+// HANDLER: Descargar un entregable
+// GET /protected/completar/entregables/:id
+// ------------------------------------------------------
+func DescargarEntregableHandler(db *sql.DB, c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var matchID int
+	var e Entregable
+	err = db.QueryRow(`
+		SELECT match_id, nombre_original, tipo_mime, archivo
+		FROM matches_entregables
+		WHERE id = $1
+	`, id).Scan(&matchID, &e.Nombre, &e.TipoMime, &e.archivo)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "entregable no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando entregable"})
+		return
+	}
+	if !puedeVerMatch(db, c, matchID) {
+		return
+	}
+
+	c.Header("Content-Type", e.TipoMime)
+	c.FileAttachment(filepath.Join(DirEntregables, e.archivo), e.Nombre)
+}
@@ -0,0 +1,27 @@
+package completar
+
+import "github.com/VinkoRobi2/FlashWorkEC/eventos"
+
+// publicador recibe los cambios de estado del match; sin configurar, no
+// se publica nada
+var publicador eventos.Publicador
+
+// UsarEventos conecta el paquete al bus de eventos de dominio
+func UsarEventos(p eventos.Publicador) {
+	publicador = p
+}
+
+// AvisarCompletado publica que el match quedó completado. Se llama
+// después de confirmar la transacción.
+func AvisarCompletado(matchID, jobID int) {
+	if publicador != nil {
+		publicador.Publicar(eventos.TipoMatchCompletado, eventos.MatchCompletado{MatchID: matchID, JobID: jobID})
+	}
+}
+
+// AvisarCancelado publica que el match se canceló
+func AvisarCancelado(matchID, jobID int) {
+	if publicador != nil {
+		publicador.Publicar(eventos.TipoMatchCancelado, eventos.MatchCancelado{MatchID: matchID, JobID: jobID})
+	}
+}
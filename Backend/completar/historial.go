@@ -0,0 +1,91 @@
+package completar
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Eventos del historial de un match
+const (
+	EventoConfirmaEstudiante   = "confirma_estudiante"
+	EventoConfirmaEmpleador    = "confirma_empleador"
+	EventoCompletado           = "completado"
+	EventoCompletadoAutomatico = "completado_automatico"
+	EventoCancelado            = "cancelado"
+	EventoDisputaAbierta       = "disputa_abierta"
+	EventoDisputaResuelta      = "disputa_resuelta"
+	EventoPagoMarcado          = "pago_marcado"
+	EventoPagoConfirmado       = "pago_confirmado"
+	EventoPagoRechazado        = "pago_rechazado"
+)
+
+// RegistrarHistorial deja constancia de un cambio de estado del match.
+// actorRol es estudiante, empleador, admin o sistema (las tareas programadas).
+func RegistrarHistorial(q interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, matchID int, evento, estadoAnterior, estadoNuevo, actorRol, detalle string) error {
+	if estadoAnterior == "" {
+		estadoAnterior = "en_progreso"
+	}
+	_, err := q.Exec(`
+		INSERT INTO matches_historial (match_id, evento, estado_anterior, estado_nuevo, actor_rol, detalle)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, matchID, evento, estadoAnterior, estadoNuevo, actorRol, detalle)
+	return err
+}
+
+type EventoHistorial struct {
+	Evento         string `json:"evento"`
+	EstadoAnterior string `json:"estado_anterior"`
+	EstadoNuevo    string `json:"estado_nuevo"`
+	ActorRol       string `json:"actor_rol"`
+	Automatico     bool   `json:"automatico"`
+	Detalle        string `json:"detalle"`
+	CreadoEn       string `json:"creado_en"`
+}
+
+// ------------------------------------------------------
+// HANDLER: Historial de estados de un match
+// GET /protected/matches/historial?match_id=1
+// ------------------------------------------------------
+func HistorialMatchHandler(db *sql.DB, c *gin.Context) {
+	matchID, err := strconv.Atoi(c.Query("match_id"))
+	if err != nil || matchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id inválido"})
+		return
+	}
+	if !puedeVerMatch(db, c, matchID) {
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT evento, estado_anterior, estado_nuevo, actor_rol, COALESCE(detalle, ''), creado_en
+		FROM matches_historial
+		WHERE match_id = $1
+		ORDER BY id
+	`, matchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando historial", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	historial := []EventoHistorial{}
+	for rows.Next() {
+		var e EventoHistorial
+		var creadoEn time.Time
+		if err := rows.Scan(&e.Evento, &e.EstadoAnterior, &e.EstadoNuevo, &e.ActorRol, &e.Detalle, &creadoEn); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error leyendo historial"})
+			return
+		}
+		e.Automatico = e.ActorRol == "sistema"
+		e.CreadoEn = creadoEn.Format(time.RFC3339)
+		historial = append(historial, e)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"match_id": matchID, "historial": historial})
+}
@@ -0,0 +1,248 @@
+package comprobantes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/eventos"
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/VinkoRobi2/FlashWorkEC/pagos"
+)
+
+// Comprobantes de pago para el empleador. Cada match pagado por completo
+// (libro de pagos) tiene uno, emitido cuando el último pago se confirma,
+// con el empleador como cliente y el estudiante como prestador del
+// servicio. Se guarda la foto de los datos al emitirse, así que editar el
+// perfil después no altera los ya emitidos. El XML solo se parece a la
+// factura del SRI; no tiene validez tributaria.
+
+// Serie fija del número: establecimiento y punto de emisión
+const Serie = "001-001"
+
+var ErrNoEncontrado = errors.New("comprobante no encontrado")
+
+type Comprobante struct {
+	ID                        int     `json:"id"`
+	Numero                    string  `json:"numero"`
+	MatchID                   int     `json:"match_id"`
+	JobID                     int     `json:"job_id"`
+	TipoIdentidad             string  `json:"tipo_identidad"` // persona | empresa
+	Cliente                   string  `json:"cliente"`        // razón social o nombre del empleador
+	IdentificacionCliente     string  `json:"identificacion_cliente"`
+	DireccionCliente          string  `json:"direccion_cliente"`
+	EmailCliente              string  `json:"email_cliente"`
+	Prestador                 string  `json:"prestador"` // el estudiante
+	IdentificacionPrestador   string  `json:"identificacion_prestador"`
+	Titulo                    string  `json:"titulo"`
+	Descripcion               string  `json:"descripcion"`
+	Categoria                 string  `json:"categoria"`
+	Monto                     float64 `json:"monto"`
+	Metodo                    string  `json:"metodo"`
+	FechaPago                 string  `json:"fecha_pago"` // AAAA-MM-DD, hora de Ecuador
+	EmitidoEn                 string  `json:"emitido_en"`
+	empleadorID, estudianteID int
+}
+
+// Numero arma el número del comprobante a partir de su ID
+func Numero(id int) string {
+	return fmt.Sprintf("%s-%09d", Serie, id)
+}
+
+// Identificacion recupera los ceros a la izquierda que pierde el BIGINT:
+// 10 dígitos para cédula, 13 para RUC
+func Identificacion(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return ""
+	}
+	if len(s) < 10 {
+		return strings.Repeat("0", 10-len(s)) + s
+	}
+	if len(s) > 10 && len(s) < 13 {
+		return strings.Repeat("0", 13-len(s)) + s
+	}
+	return s
+}
+
+// emitir guarda el comprobante de un match ya pagado con los datos de hoy
+func emitir(db *sql.DB, s pagos.Saldo) error {
+	_, err := db.Exec(`
+		INSERT INTO comprobantes (match_id, job_id, empleador_id, estudiante_id, tipo_identidad,
+		                          cliente, identificacion_cliente, direccion_cliente, email_cliente,
+		                          prestador, identificacion_prestador, titulo, descripcion, categoria,
+		                          monto, metodo, fecha_pago)
+		SELECT mj.id, j.id, em.id, e.id, COALESCE(em.tipo_identidad, 'persona'),
+		       CASE WHEN em.tipo_identidad = 'empresa' AND COALESCE(em.razon_social, '') <> ''
+		            THEN em.razon_social ELSE em.nombre || ' ' || em.apellido END,
+		       COALESCE(CAST(em.cedula_ruc AS TEXT), ''), COALESCE(em.direccion, em.ciudad, ''), em.email,
+		       e.nombre || ' ' || e.apellido, COALESCE(CAST(e.cedula AS TEXT), ''),
+		       j.titulo, COALESCE(j.descripcion, ''), COALESCE(j.categoria, ''),
+		       $2, $3,
+		       (SELECT MAX(p.respondido_en) FROM pagos p WHERE p.match_id = mj.id AND p.estado = 'confirmado')
+		FROM matches_job mj
+		JOIN jobs j ON j.id = mj.job_id
+		JOIN empleadores em ON em.id = j.empleador_id
+		JOIN estudiantes e ON e.id = mj.estudiante_id
+		WHERE mj.id = $1
+		ON CONFLICT (match_id) DO NOTHING
+	`, s.MatchID, s.Pagado, metodos(db, s.MatchID))
+	return err
+}
+
+// metodos junta los métodos de los pagos confirmados del match
+func metodos(db *sql.DB, matchID int) string {
+	var m string
+	err := db.QueryRow(`
+		SELECT COALESCE(STRING_AGG(DISTINCT NULLIF(metodo, ''), ', '), '')
+		FROM pagos WHERE match_id = $1 AND estado = 'confirmado'
+	`, matchID).Scan(&m)
+	if err != nil {
+		return ""
+	}
+	return m
+}
+
+// emitirSiPagado emite el comprobante del match si ya está pagado por completo
+func emitirSiPagado(db *sql.DB, matchID int) error {
+	s, err := pagos.CargarSaldo(db, matchID)
+	if err != nil {
+		return err
+	}
+	if s.EstadoPago != "pagado" {
+		return nil
+	}
+	return emitir(db, *s)
+}
+
+// AlPagarMatch emite el comprobante cuando el match queda pagado
+func AlPagarMatch(db *sql.DB) eventos.Manejador {
+	return func(datos interface{}) {
+		m, ok := datos.(eventos.MatchPagado)
+		if !ok {
+			return
+		}
+		if err := emitirSiPagado(db, m.MatchID); err != nil {
+			log.Printf("Error emitiendo comprobante del match %d: %v", m.MatchID, err)
+		}
+	}
+}
+
+// ------------------------------------------------------
+// TAREA: emitir los comprobantes que falten (pagos anteriores al evento
+// o un evento que falló)
+// ------------------------------------------------------
+func EmitirPendientes(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT mj.id
+		FROM matches_job mj
+		WHERE mj.is_match = true AND mj.estado = 'completado'
+		  AND NOT EXISTS (SELECT 1 FROM comprobantes c WHERE c.match_id = mj.id)
+		  AND EXISTS (SELECT 1 FROM pagos p WHERE p.match_id = mj.id AND p.estado = 'confirmado')
+		ORDER BY mj.id
+		LIMIT 500
+	`)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := emitirSiPagado(db, id); err != nil {
+			log.Printf("Error emitiendo comprobante del match %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+const columnas = `
+	SELECT id, match_id, job_id, empleador_id, estudiante_id, tipo_identidad,
+	       cliente, identificacion_cliente, direccion_cliente, email_cliente,
+	       prestador, identificacion_prestador, titulo, descripcion, categoria,
+	       CAST(monto AS FLOAT), metodo, fecha_pago, emitido_en
+	FROM comprobantes
+`
+
+func escanear(scan func(dest ...interface{}) error) (Comprobante, error) {
+	var c Comprobante
+	var fechaPago sql.NullTime
+	var emitido time.Time
+	err := scan(&c.ID, &c.MatchID, &c.JobID, &c.empleadorID, &c.estudianteID, &c.TipoIdentidad,
+		&c.Cliente, &c.IdentificacionCliente, &c.DireccionCliente, &c.EmailCliente,
+		&c.Prestador, &c.IdentificacionPrestador, &c.Titulo, &c.Descripcion, &c.Categoria,
+		&c.Monto, &c.Metodo, &fechaPago, &emitido)
+	if err != nil {
+		return c, err
+	}
+	c.Numero = Numero(c.ID)
+	c.IdentificacionCliente = Identificacion(c.IdentificacionCliente)
+	c.IdentificacionPrestador = Identificacion(c.IdentificacionPrestador)
+	if fechaPago.Valid {
+		c.FechaPago = fechaPago.Time.In(fechas.ZonaEcuador).Format("2006-01-02")
+	} else {
+		c.FechaPago = emitido.In(fechas.ZonaEcuador).Format("2006-01-02")
+	}
+	c.EmitidoEn = emitido.UTC().Format(time.RFC3339)
+	return c, nil
+}
+
+// Listar devuelve los comprobantes del empleador, del más reciente al
+// más antiguo; desde y hasta (opcionales) acotan la fecha de pago
+func Listar(db *sql.DB, empleadorID int, desde, hasta *time.Time) ([]Comprobante, error) {
+	query := columnas + ` WHERE empleador_id = $1`
+	args := []interface{}{empleadorID}
+	if desde != nil {
+		args = append(args, *desde)
+		query += fmt.Sprintf(" AND fecha_pago >= $%d", len(args))
+	}
+	if hasta != nil {
+		args = append(args, *hasta)
+		query += fmt.Sprintf(" AND fecha_pago < $%d", len(args))
+	}
+	rows, err := db.Query(query+` ORDER BY fecha_pago DESC NULLS LAST, id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lista := []Comprobante{}
+	for rows.Next() {
+		c, err := escanear(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		lista = append(lista, c)
+	}
+	return lista, rows.Err()
+}
+
+// Buscar lee un comprobante del empleador
+func Buscar(db *sql.DB, id, empleadorID int) (*Comprobante, error) {
+	c, err := escanear(db.QueryRow(columnas+` WHERE id = $1 AND empleador_id = $2`, id, empleadorID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,94 @@
+package comprobantes
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/VinkoRobi2/FlashWorkEC/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+// -------------------------------
+// GET /protected/comprobantes?desde=2025-01-01&hasta=2025-01-31
+// Comprobantes de los trabajos pagados
+// -------------------------------
+func ListarHandler(c *gin.Context, db *sql.DB) {
+	userID, ok := middlewares.UsuarioConRol(c, "empleador")
+	if !ok {
+		return
+	}
+	desde, hasta, ok := fechas.Rango(c)
+	if !ok {
+		return
+	}
+
+	lista, err := Listar(db, userID, desde, hasta)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener comprobantes", "err": err.Error()})
+		return
+	}
+
+	var total float64
+	for _, comp := range lista {
+		total += comp.Monto
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"comprobantes": lista,
+		"cantidad":     len(lista),
+		"total":        float64(int(total*100+0.5)) / 100,
+	})
+}
+
+// -------------------------------
+// GET /protected/comprobantes/:id?formato=pdf|xml|json
+// -------------------------------
+func DescargarHandler(c *gin.Context, db *sql.DB) {
+	userID, ok := middlewares.UsuarioConRol(c, "empleador")
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	comp, err := Buscar(db, id, userID)
+	if err == ErrNoEncontrado {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener comprobante", "err": err.Error()})
+		return
+	}
+
+	archivo := "comprobante-" + comp.Numero
+	switch c.DefaultQuery("formato", "pdf") {
+	case "pdf":
+		var buf bytes.Buffer
+		if err := comp.PDF(&buf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar PDF", "err": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, archivo))
+		c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+	case "xml":
+		out, err := comp.XML()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar XML", "err": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xml"`, archivo))
+		c.Data(http.StatusOK, "application/xml; charset=utf-8", out)
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, archivo))
+		c.JSON(http.StatusOK, comp)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "formato debe ser pdf, xml o json"})
+	}
+}
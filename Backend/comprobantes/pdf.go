@@ -0,0 +1,95 @@
+package comprobantes
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/jung-kurt/gofpdf"
+)
+
+func fechaTexto(s string) string {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return s
+	}
+	return t.Format("02/01/2006")
+}
+
+// PDF escribe el comprobante en A4
+func (c *Comprobante) PDF(w io.Writer) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(110, 10, tr("Comprobante de pago"), "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 10, tr("N.º "+c.Numero), "", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(110, 6, "CameYa", "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 6, tr("Fecha de pago: "+fechaTexto(c.FechaPago)), "", 1, "R", false, 0, "")
+	pdf.Ln(8)
+
+	bloque := func(titulo string, filas [][2]string) {
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(0, 7, tr(titulo), "B", 1, "L", false, 0, "")
+		pdf.Ln(1)
+		for _, f := range filas {
+			if f[1] == "" {
+				continue
+			}
+			pdf.SetFont("Helvetica", "B", 10)
+			pdf.CellFormat(45, 6, tr(f[0]), "", 0, "L", false, 0, "")
+			pdf.SetFont("Helvetica", "", 10)
+			pdf.MultiCell(0, 6, tr(f[1]), "", "L", false)
+		}
+		pdf.Ln(4)
+	}
+
+	etiquetaID := "Cédula"
+	if len(c.IdentificacionCliente) == 13 {
+		etiquetaID = "RUC"
+	}
+	cliente := "Nombre"
+	if c.TipoIdentidad == "empresa" {
+		cliente = "Razón social"
+	}
+	bloque("Cliente", [][2]string{
+		{cliente, c.Cliente},
+		{etiquetaID, c.IdentificacionCliente},
+		{"Dirección", c.DireccionCliente},
+		{"Email", c.EmailCliente},
+	})
+	bloque("Prestador del servicio", [][2]string{
+		{"Nombre", c.Prestador},
+		{"Cédula", c.IdentificacionPrestador},
+	})
+	bloque("Servicio", [][2]string{
+		{"Trabajo", c.Titulo},
+		{"Categoría", c.Categoria},
+		{"Descripción", c.Descripcion},
+		{"Método de pago", c.Metodo},
+	})
+
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 10, tr(fmt.Sprintf("Total pagado: $%.2f", c.Monto)), "T", 1, "R", false, 0, "")
+	pdf.Ln(8)
+
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.MultiCell(0, 4, tr("Comprobante emitido por CameYa a partir de los pagos registrados y confirmados en la plataforma. "+
+		"No reemplaza a una factura electrónica autorizada por el SRI."), "", "L", false)
+	pdf.MultiCell(0, 4, tr(fmt.Sprintf("Match %d · emitido el %s", c.MatchID, c.emitidoLocal())), "", "L", false)
+	return pdf.Output(w)
+}
+
+func (c *Comprobante) emitidoLocal() string {
+	t, err := time.Parse(time.RFC3339, c.EmitidoEn)
+	if err != nil {
+		return c.EmitidoEn
+	}
+	return t.In(fechas.ZonaEcuador).Format("02/01/2006 15:04")
+}
@@ -0,0 +1,180 @@
+package comprobantes
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Estructura aproximada de la factura electrónica del SRI: usa sus
+// bloques (infoTributaria, infoFactura, detalles, infoAdicional) y los
+// nombres de sus campos, pero no es un comprobante válido. Faltan, entre
+// otros, ambiente, tipoEmision, ruc, codDoc, dirMatriz, claveAcceso,
+// totalConImpuestos, los impuestos de cada detalle y la firma; la
+// integración con el SRI tendrá que agregarlos.
+
+// Códigos de tipo de identificación del SRI
+const (
+	IdentificacionRUC             = "04"
+	IdentificacionCedula          = "05"
+	IdentificacionConsumidorFinal = "07"
+)
+
+// Códigos de forma de pago del SRI
+const (
+	FormaPagoSinSistemaFinanciero   = "01"
+	FormaPagoTarjetaCredito         = "19"
+	FormaPagoOtrosSistemaFinanciero = "20"
+)
+
+type documentoXML struct {
+	XMLName        xml.Name         `xml:"comprobante"`
+	Version        string           `xml:"version,attr"`
+	Tipo           string           `xml:"tipo,attr"`
+	InfoTributaria infoTributaria   `xml:"infoTributaria"`
+	InfoFactura    infoFactura      `xml:"infoFactura"`
+	Detalles       []detalle        `xml:"detalles>detalle"`
+	InfoAdicional  []campoAdicional `xml:"infoAdicional>campoAdicional"`
+}
+
+type infoTributaria struct {
+	RazonSocial        string `xml:"razonSocial"` // prestador del servicio
+	Identificacion     string `xml:"identificacion"`
+	TipoIdentificacion string `xml:"tipoIdentificacion"`
+	Estab              string `xml:"estab"`
+	PtoEmi             string `xml:"ptoEmi"`
+	Secuencial         string `xml:"secuencial"`
+}
+
+type infoFactura struct {
+	FechaEmision                string    `xml:"fechaEmision"` // dd/mm/aaaa
+	TipoIdentificacionComprador string    `xml:"tipoIdentificacionComprador"`
+	RazonSocialComprador        string    `xml:"razonSocialComprador"`
+	IdentificacionComprador     string    `xml:"identificacionComprador"`
+	DireccionComprador          string    `xml:"direccionComprador,omitempty"`
+	TotalSinImpuestos           string    `xml:"totalSinImpuestos"`
+	TotalDescuento              string    `xml:"totalDescuento"`
+	ImporteTotal                string    `xml:"importeTotal"`
+	Moneda                      string    `xml:"moneda"`
+	Pagos                       []pagoXML `xml:"pagos>pago"`
+}
+
+type pagoXML struct {
+	FormaPago string `xml:"formaPago"`
+	Total     string `xml:"total"`
+}
+
+type detalle struct {
+	CodigoPrincipal        string `xml:"codigoPrincipal"`
+	Descripcion            string `xml:"descripcion"`
+	Cantidad               string `xml:"cantidad"`
+	PrecioUnitario         string `xml:"precioUnitario"`
+	Descuento              string `xml:"descuento"`
+	PrecioTotalSinImpuesto string `xml:"precioTotalSinImpuesto"`
+}
+
+type campoAdicional struct {
+	Nombre string `xml:"nombre,attr"`
+	Valor  string `xml:",chardata"`
+}
+
+// tipoIdentificacion deduce el código del SRI por la longitud
+func tipoIdentificacion(id string) string {
+	switch len(id) {
+	case 13:
+		return IdentificacionRUC
+	case 10:
+		return IdentificacionCedula
+	}
+	return IdentificacionConsumidorFinal
+}
+
+// formaPago traduce el método registrado al código del SRI
+func formaPago(metodo string) string {
+	m := strings.ToLower(metodo)
+	switch {
+	case m == "" || strings.Contains(m, "efectivo"):
+		return FormaPagoSinSistemaFinanciero
+	case strings.Contains(m, "tarjeta"):
+		return FormaPagoTarjetaCredito
+	}
+	return FormaPagoOtrosSistemaFinanciero
+}
+
+func dinero(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+// XML escribe el comprobante con la estructura de la factura del SRI
+func (c *Comprobante) XML() ([]byte, error) {
+	fecha := c.FechaPago
+	if t, err := time.Parse("2006-01-02", c.FechaPago); err == nil {
+		fecha = t.Format("02/01/2006")
+	}
+	partes := strings.SplitN(c.Numero, "-", 3)
+
+	idComprador := c.IdentificacionCliente
+	tipoComprador := tipoIdentificacion(idComprador)
+	if tipoComprador == IdentificacionConsumidorFinal {
+		idComprador = "9999999999999"
+	}
+	descripcion := c.Titulo
+	if c.Categoria != "" {
+		descripcion += " (" + c.Categoria + ")"
+	}
+
+	doc := documentoXML{
+		Version: "1.0",
+		Tipo:    "recibo",
+		InfoTributaria: infoTributaria{
+			RazonSocial:        c.Prestador,
+			Identificacion:     c.IdentificacionPrestador,
+			TipoIdentificacion: tipoIdentificacion(c.IdentificacionPrestador),
+			Estab:              partes[0],
+			PtoEmi:             partes[1],
+			Secuencial:         partes[2],
+		},
+		InfoFactura: infoFactura{
+			FechaEmision:                fecha,
+			TipoIdentificacionComprador: tipoComprador,
+			RazonSocialComprador:        c.Cliente,
+			IdentificacionComprador:     idComprador,
+			DireccionComprador:          c.DireccionCliente,
+			TotalSinImpuestos:           dinero(c.Monto),
+			TotalDescuento:              dinero(0),
+			ImporteTotal:                dinero(c.Monto),
+			Moneda:                      "DOLAR",
+			Pagos:                       []pagoXML{{FormaPago: formaPago(c.Metodo), Total: dinero(c.Monto)}},
+		},
+		Detalles: []detalle{{
+			CodigoPrincipal:        fmt.Sprintf("JOB-%d", c.JobID),
+			Descripcion:            descripcion,
+			Cantidad:               "1.00",
+			PrecioUnitario:         dinero(c.Monto),
+			Descuento:              dinero(0),
+			PrecioTotalSinImpuesto: dinero(c.Monto),
+		}},
+		InfoAdicional: []campoAdicional{
+			{Nombre: "match", Valor: fmt.Sprint(c.MatchID)},
+			{Nombre: "email", Valor: c.EmailCliente},
+			{Nombre: "metodoPago", Valor: c.Metodo},
+			{Nombre: "detalleTrabajo", Valor: c.Descripcion},
+		},
+	}
+
+	// El SRI no acepta campos adicionales vacíos
+	adicionales := doc.InfoAdicional[:0]
+	for _, a := range doc.InfoAdicional {
+		if a.Valor != "" {
+			adicionales = append(adicionales, a)
+		}
+	}
+	doc.InfoAdicional = adicionales
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
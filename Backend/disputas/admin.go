@@ -0,0 +1,211 @@
+package disputas
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/cupos"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/versiones"
+	"github.com/VinkoRobi2/FlashWorkEC/completar"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/gin-gonic/gin"
+)
+
+// -------------------------------
+// POST /protected/admin/disputas/:id/resolver
+// { "resultado": "completado" | "parcial" | "no_completado", "monto": 12.5, "nota": "..." }
+// -------------------------------
+func ResolverDisputaHandler(c *gin.Context, db *sql.DB) {
+	adminID, _, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		Resultado string   `json:"resultado"`
+		Monto     *float64 `json:"monto"`
+		Nota      string   `json:"nota"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	req.Nota = strings.TrimSpace(req.Nota)
+	if req.Nota == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "la nota de resolución es requerida"})
+		return
+	}
+	if len(req.Nota) > MaxLargoDeclaracion {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "la nota supera " + strconv.Itoa(MaxLargoDeclaracion) + " caracteres"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Primero el job, como al completar: cupos.Recalcular lo necesita
+	// bloqueado y tomarlo después del match invertiría el orden
+	var jobID int
+	err = tx.QueryRow(`
+		SELECT m.job_id FROM disputas d JOIN matches_job m ON m.id = d.match_id WHERE d.id = $1
+	`, id).Scan(&jobID)
+	if err == sql.ErrNoRows {
+		responderError(c, ErrNoEncontrada)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener disputa", "err": err.Error()})
+		return
+	}
+	if _, err := tx.Exec(`SELECT 1 FROM jobs WHERE id = $1 FOR UPDATE`, jobID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al bloquear trabajo", "err": err.Error()})
+		return
+	}
+
+	// Se bloquea la disputa y su match para que dos admins no la resuelvan a la vez
+	d, err := escanear(tx.QueryRow(selectDisputa+` WHERE d.id = $1 FOR UPDATE OF d, m`, id))
+	if err == sql.ErrNoRows {
+		responderError(c, ErrNoEncontrada)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener disputa", "err": err.Error()})
+		return
+	}
+	if d.Estado != EstadoAbierta {
+		responderError(c, ErrCerrada)
+		return
+	}
+
+	var total float64
+	err = tx.QueryRow(`
+		SELECT `+compensacion.PagoFinalSQL+`
+		FROM matches_job mj
+		JOIN jobs j ON j.id = mj.job_id
+		WHERE mj.id = $1
+	`, d.MatchID).Scan(&total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el pago del match"})
+		return
+	}
+
+	// El monto a pagar sale del resultado; solo el parcial lo fija el admin
+	var monto float64
+	switch req.Resultado {
+	case ResultadoCompletado:
+		monto = total
+		_, err = tx.Exec(`
+			UPDATE matches_job
+			SET estado = 'completado', student_completed = TRUE, employer_completed = TRUE,
+			    completado_en = NOW(), pago_final = $2, resultado_disputa = $3
+			WHERE id = $1
+		`, d.MatchID, monto, req.Resultado)
+	case ResultadoParcial:
+		if req.Monto == nil || *req.Monto <= 0 || *req.Monto >= total {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("monto debe ser mayor a 0 y menor a %.2f", total)})
+			return
+		}
+		monto = math.Round(*req.Monto*100) / 100
+		_, err = tx.Exec(`
+			UPDATE matches_job
+			SET estado = 'completado', student_completed = TRUE, employer_completed = TRUE,
+			    completado_en = NOW(), pago_final = $2, resultado_disputa = $3
+			WHERE id = $1
+		`, d.MatchID, monto, req.Resultado)
+	case ResultadoNoCompletado:
+		_, err = tx.Exec(`
+			UPDATE matches_job
+			SET estado = 'cancelado', cancelado_por = 'admin', cancelado_en = NOW(),
+			    motivo_cancelacion = $2, pago_final = 0, resultado_disputa = $3
+			WHERE id = $1
+		`, d.MatchID, "Disputa resuelta: trabajo no completado", req.Resultado)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "resultado debe ser completado, parcial o no_completado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar match", "err": err.Error()})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE disputas
+		SET estado = $2, resultado = $3, monto_pagar = $4, nota_resolucion = $5,
+		    resuelta_por = $6, resuelta_en = NOW()
+		WHERE id = $1
+	`, id, EstadoResuelta, req.Resultado, monto, req.Nota, adminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al resolver disputa", "err": err.Error()})
+		return
+	}
+
+	estadoMatch := "completado"
+	if req.Resultado == ResultadoNoCompletado {
+		estadoMatch = "cancelado"
+	}
+	if err := completar.RegistrarHistorial(tx, d.MatchID, completar.EventoDisputaResuelta, EstadoMatchDisputa, estadoMatch, "admin", req.Resultado); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al registrar historial", "err": err.Error()})
+		return
+	}
+
+	if req.Resultado == ResultadoNoCompletado {
+		if err := versiones.CerrarPropuestaSinContratados(tx, d.JobID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al cerrar propuesta de pago", "err": err.Error()})
+			return
+		}
+	}
+
+	// Completado o cancelado, el cupo del match cambia de situación
+	estadoCupos, err := cupos.Recalcular(tx, d.JobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error actualizando cupos del trabajo"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al resolver disputa"})
+		return
+	}
+
+	if req.Resultado == ResultadoNoCompletado {
+		completar.AvisarCancelado(d.MatchID, d.JobID)
+	} else {
+		completar.AvisarCompletado(d.MatchID, d.JobID)
+	}
+
+	mensaje := fmt.Sprintf("La disputa sobre \"%s\" se resolvió: %s. Monto a pagar: $%.2f.", d.Titulo, textoResultado(req.Resultado), monto)
+	notificar(db, d.EstudianteID, notificaciones.RolEstudiante, "disputa_resuelta", "Disputa resuelta", mensaje, d)
+	notificar(db, d.EmpleadorID, notificaciones.RolEmpleador, "disputa_resuelta", "Disputa resuelta", mensaje, d)
+
+	c.JSON(http.StatusOK, gin.H{
+		"mensaje":     "Disputa resuelta",
+		"disputa_id":  id,
+		"resultado":   req.Resultado,
+		"monto_pagar": monto,
+		"job_estado":  estadoCupos.EstadoJob,
+	})
+}
+
+func textoResultado(r string) string {
+	switch r {
+	case ResultadoCompletado:
+		return "trabajo completado"
+	case ResultadoParcial:
+		return "trabajo completado parcialmente"
+	}
+	return "trabajo no completado"
+}
@@ -0,0 +1,238 @@
+package disputas
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+)
+
+// Disputas sobre la finalización de un match. Cualquiera de las partes
+// puede abrir una mientras el match no esté completado ni cancelado; el
+// match pasa a 'en_disputa' y ya no se puede completar ni cancelar hasta
+// que un admin la resuelva. Cada parte deja su declaración y evidencias
+// (fotos o PDF) que se guardan fuera de ./uploads, en DirPrivado, y solo
+// se descargan con sesión de una de las partes o de un admin.
+//
+// La resolución decide el desenlace del match: completado (pago total),
+// parcial (completado con el monto que fije el admin) o no_completado
+// (se cancela sin pago). Como las valoraciones exigen un match
+// completado, quedan habilitadas o no según el resultado.
+
+const (
+	EstadoAbierta  = "abierta"
+	EstadoResuelta = "resuelta"
+
+	ResultadoCompletado   = "completado"
+	ResultadoParcial      = "parcial"
+	ResultadoNoCompletado = "no_completado"
+
+	EstadoMatchDisputa = "en_disputa"
+
+	MaxLargoDeclaracion   = 2000
+	MaxBytesEvidencia     = 5 << 20 // 5 MB por archivo
+	MaxEvidenciasPorParte = 10
+	DirPrivado            = "./privado/disputas"
+)
+
+// Motivos por los que se abre una disputa
+var Motivos = []string{"trabajo_no_realizado", "trabajo_incompleto", "calidad", "pago", "otro"}
+
+// Tipos de archivo aceptados como evidencia (según su contenido)
+var tiposEvidencia = map[string]string{
+	"image/jpeg":      "jpg",
+	"image/png":       "png",
+	"application/pdf": "pdf",
+}
+
+var (
+	ErrNoEncontrada = errors.New("disputa no encontrada")
+	ErrPermiso      = errors.New("no tienes permiso sobre esta disputa")
+	ErrCerrada      = errors.New("la disputa ya fue resuelta")
+	ErrFormato      = errors.New("la evidencia debe ser JPG, PNG o PDF")
+	ErrMuyGrande    = fmt.Errorf("la evidencia supera %d MB", MaxBytesEvidencia>>20)
+	ErrLimite       = fmt.Errorf("máximo %d evidencias por parte", MaxEvidenciasPorParte)
+)
+
+type Evidencia struct {
+	ID          int    `json:"id"`
+	AutorRol    string `json:"autor_rol"`
+	Nombre      string `json:"nombre"`
+	Descripcion string `json:"descripcion"`
+	TipoMime    string `json:"tipo_mime"`
+	Bytes       int    `json:"bytes"`
+	CreadoEn    string `json:"creado_en"`
+	archivo     string
+}
+
+type Disputa struct {
+	ID                    int         `json:"id"`
+	MatchID               int         `json:"match_id"`
+	JobID                 int         `json:"job_id"`
+	Titulo                string      `json:"titulo"`
+	EstudianteID          int         `json:"estudiante_id"`
+	EmpleadorID           int         `json:"empleador_id"`
+	AbiertaPor            string      `json:"abierta_por"`
+	Motivo                string      `json:"motivo"`
+	DeclaracionEstudiante *string     `json:"declaracion_estudiante"`
+	DeclaracionEmpleador  *string     `json:"declaracion_empleador"`
+	Estado                string      `json:"estado"`
+	EstadoPrevio          string      `json:"estado_previo"`
+	Resultado             *string     `json:"resultado"`
+	MontoPagar            *float64    `json:"monto_pagar"`
+	NotaResolucion        *string     `json:"nota_resolucion"`
+	ResueltaEn            *string     `json:"resuelta_en"`
+	CreadoEn              string      `json:"creado_en"`
+	Evidencias            []Evidencia `json:"evidencias"`
+}
+
+// Ejecutor permite usar tanto *sql.DB como *sql.Tx
+type Ejecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func motivoValido(m string) bool {
+	for _, v := range Motivos {
+		if v == m {
+			return true
+		}
+	}
+	return false
+}
+
+const selectDisputa = `
+	SELECT d.id, d.match_id, m.job_id, j.titulo, m.estudiante_id, j.empleador_id,
+	       d.abierta_por, d.motivo, d.declaracion_estudiante, d.declaracion_empleador,
+	       d.estado, d.estado_previo, d.resultado, CAST(d.monto_pagar AS FLOAT),
+	       d.nota_resolucion, d.resuelta_en, d.creado_en
+	FROM disputas d
+	JOIN matches_job m ON m.id = d.match_id
+	JOIN jobs j ON j.id = m.job_id
+`
+
+type escaneable interface {
+	Scan(dest ...interface{}) error
+}
+
+func escanear(r escaneable) (*Disputa, error) {
+	var d Disputa
+	var resueltaEn sql.NullTime
+	var creadoEn time.Time
+	err := r.Scan(&d.ID, &d.MatchID, &d.JobID, &d.Titulo, &d.EstudianteID, &d.EmpleadorID,
+		&d.AbiertaPor, &d.Motivo, &d.DeclaracionEstudiante, &d.DeclaracionEmpleador,
+		&d.Estado, &d.EstadoPrevio, &d.Resultado, &d.MontoPagar,
+		&d.NotaResolucion, &resueltaEn, &creadoEn)
+	if err != nil {
+		return nil, err
+	}
+	if resueltaEn.Valid {
+		s := resueltaEn.Time.Format(time.RFC3339)
+		d.ResueltaEn = &s
+	}
+	d.CreadoEn = creadoEn.Format(time.RFC3339)
+	d.Evidencias = []Evidencia{}
+	return &d, nil
+}
+
+// Cargar lee la disputa con sus evidencias y comprueba que el usuario
+// sea una de las partes (o admin)
+func Cargar(db *sql.DB, id, userID int, rol string) (*Disputa, error) {
+	d, err := escanear(db.QueryRow(selectDisputa+` WHERE d.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNoEncontrada
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !d.EsParte(userID, rol) && rol != "admin" {
+		return nil, ErrPermiso
+	}
+
+	rows, err := db.Query(`
+		SELECT id, autor_rol, nombre_original, descripcion, tipo_mime, bytes, archivo, creado_en
+		FROM disputas_evidencias
+		WHERE disputa_id = $1
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e Evidencia
+		var creadoEn time.Time
+		if err := rows.Scan(&e.ID, &e.AutorRol, &e.Nombre, &e.Descripcion, &e.TipoMime, &e.Bytes, &e.archivo, &creadoEn); err != nil {
+			return nil, err
+		}
+		e.CreadoEn = creadoEn.Format(time.RFC3339)
+		d.Evidencias = append(d.Evidencias, e)
+	}
+	return d, rows.Err()
+}
+
+// EsParte indica si el usuario es el estudiante o el empleador del match
+func (d *Disputa) EsParte(userID int, rol string) bool {
+	switch rol {
+	case notificaciones.RolEstudiante:
+		return userID == d.EstudianteID
+	case notificaciones.RolEmpleador:
+		return userID == d.EmpleadorID
+	}
+	return false
+}
+
+// guardarArchivo valida la evidencia por su contenido y la escribe en DirPrivado
+func guardarArchivo(disputaID int, data []byte) (archivo, mime string, err error) {
+	if len(data) > MaxBytesEvidencia {
+		return "", "", ErrMuyGrande
+	}
+	mime = http.DetectContentType(data)
+	ext, ok := tiposEvidencia[mime]
+	if !ok {
+		return "", "", ErrFormato
+	}
+
+	dir := filepath.Join(DirPrivado, fmt.Sprint(disputaID))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+	archivo = filepath.Join(fmt.Sprint(disputaID), fmt.Sprintf("%d.%s", time.Now().UnixNano(), ext))
+	if err := os.WriteFile(filepath.Join(DirPrivado, archivo), data, 0600); err != nil {
+		return "", "", err
+	}
+	return archivo, mime, nil
+}
+
+// notificar avisa a un usuario de un movimiento en la disputa
+func notificar(db *sql.DB, usuarioID int, rol, tipo, titulo, mensaje string, d *Disputa) {
+	err := notificaciones.Notificar(db, notificaciones.Notificacion{
+		UsuarioID: usuarioID,
+		Rol:       rol,
+		Tipo:      tipo,
+		Titulo:    titulo,
+		Mensaje:   mensaje,
+		Datos: map[string]interface{}{
+			"disputa_id": d.ID,
+			"match_id":   d.MatchID,
+			"job_id":     d.JobID,
+		},
+	})
+	if err != nil {
+		log.Println("Error notificando disputa:", err)
+	}
+}
+
+// contraparte devuelve el ID y rol de la otra parte de la disputa
+func (d *Disputa) contraparte(rol string) (int, string) {
+	if rol == notificaciones.RolEstudiante {
+		return d.EmpleadorID, notificaciones.RolEmpleador
+	}
+	return d.EstudianteID, notificaciones.RolEstudiante
+}
@@ -0,0 +1,406 @@
+package disputas
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/VinkoRobi2/FlashWorkEC/completar"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/VinkoRobi2/FlashWorkEC/ofertas"
+	"github.com/gin-gonic/gin"
+)
+
+func usuario(c *gin.Context) (int, string, bool) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		return 0, "", false
+	}
+	return userIDInterface.(int), c.GetString("roles"), true
+}
+
+// responderError traduce los errores del paquete a respuestas HTTP
+func responderError(c *gin.Context, err error) {
+	switch err {
+	case ErrNoEncontrada, ofertas.ErrNoEncontrada:
+		c.JSON(http.StatusNotFound, gin.H{"error": "no encontrado"})
+	case ErrPermiso, ofertas.ErrPermiso:
+		c.JSON(http.StatusForbidden, gin.H{"error": ErrPermiso.Error()})
+	case ErrCerrada:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case ErrFormato, ErrMuyGrande, ErrLimite:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error en la disputa", "err": err.Error()})
+	}
+}
+
+func limpiarDeclaracion(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", "la declaración es requerida"
+	}
+	if len(s) > MaxLargoDeclaracion {
+		return "", "la declaración supera " + strconv.Itoa(MaxLargoDeclaracion) + " caracteres"
+	}
+	return s, ""
+}
+
+// columnaDeclaracion devuelve la columna donde escribe cada parte
+func columnaDeclaracion(rol string) string {
+	if rol == notificaciones.RolEstudiante {
+		return "declaracion_estudiante"
+	}
+	return "declaracion_empleador"
+}
+
+// -------------------------------
+// POST /protected/disputas  { "match_id": 1, "motivo": "trabajo_incompleto", "declaracion": "..." }
+// -------------------------------
+func AbrirDisputaHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var req struct {
+		MatchID     int    `json:"match_id"`
+		Motivo      string `json:"motivo"`
+		Declaracion string `json:"declaracion"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.MatchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id es requerido"})
+		return
+	}
+	if !motivoValido(req.Motivo) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "motivo debe ser " + strings.Join(Motivos, ", ")})
+		return
+	}
+	declaracion, msg := limpiarDeclaracion(req.Declaracion)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	m, err := ofertas.CargarMatch(tx, req.MatchID, userID, rol, true)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	switch m.Estado {
+	case "completado", "cancelado":
+		c.JSON(http.StatusConflict, gin.H{"error": "el match ya está " + m.Estado})
+		return
+	case EstadoMatchDisputa:
+		c.JSON(http.StatusConflict, gin.H{"error": "el match ya tiene una disputa abierta"})
+		return
+	}
+
+	estadoPrevio := m.Estado
+	if estadoPrevio == "" {
+		estadoPrevio = "en_progreso"
+	}
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO disputas (match_id, abierta_por, motivo, `+columnaDeclaracion(rol)+`, estado_previo)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, m.ID, rol, req.Motivo, declaracion, estadoPrevio).Scan(&id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al abrir disputa", "err": err.Error()})
+		return
+	}
+	if _, err := tx.Exec(`UPDATE matches_job SET estado = $2 WHERE id = $1`, m.ID, EstadoMatchDisputa); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar match"})
+		return
+	}
+	if err := completar.RegistrarHistorial(tx, m.ID, completar.EventoDisputaAbierta, m.Estado, EstadoMatchDisputa, rol, req.Motivo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al registrar historial", "err": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al abrir disputa"})
+		return
+	}
+
+	d := &Disputa{ID: id, MatchID: m.ID, JobID: m.JobID, EstudianteID: m.EstudianteID, EmpleadorID: m.EmpleadorID}
+	destino, rolDestino := d.contraparte(rol)
+	notificar(db, destino, rolDestino, "disputa_abierta", "Se abrió una disputa",
+		fmt.Sprintf("La otra parte abrió una disputa sobre \"%s\". Agrega tu declaración y evidencias para que un administrador la resuelva.", m.Titulo), d)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"mensaje":    "Disputa abierta; un administrador la revisará",
+		"disputa_id": id,
+		"estado":     EstadoAbierta,
+	})
+}
+
+// -------------------------------
+// GET /protected/disputas?estado=abierta
+// Las disputas de los matches del usuario
+// -------------------------------
+func ListarDisputasHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var filtroParte string
+	switch rol {
+	case notificaciones.RolEstudiante:
+		filtroParte = "m.estudiante_id = $1"
+	case notificaciones.RolEmpleador:
+		filtroParte = "j.empleador_id = $1"
+	case "admin":
+		filtroParte = "$1::int IS NOT NULL"
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "rol no permitido"})
+		return
+	}
+
+	estado := c.Query("estado")
+	if estado != "" && estado != EstadoAbierta && estado != EstadoResuelta {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "estado debe ser abierta o resuelta"})
+		return
+	}
+
+	rows, err := db.Query(selectDisputa+`
+		WHERE `+filtroParte+` AND ($2 = '' OR d.estado = $2)
+		ORDER BY d.estado = 'abierta' DESC, d.creado_en DESC
+		LIMIT 100
+	`, userID, estado)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener disputas", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	lista := []*Disputa{}
+	for rows.Next() {
+		d, err := escanear(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo disputas", "err": err.Error()})
+			return
+		}
+		d.Evidencias = nil
+		lista = append(lista, d)
+	}
+	c.JSON(http.StatusOK, gin.H{"disputas": lista, "total": len(lista)})
+}
+
+// -------------------------------
+// GET /protected/disputas/:id
+// -------------------------------
+func VerDisputaHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	d, err := Cargar(db, id, userID, rol)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"disputa": d})
+}
+
+// -------------------------------
+// POST /protected/disputas/:id/declaracion  { "declaracion": "..." }
+// Cada parte escribe (o corrige) su propia versión mientras está abierta
+// -------------------------------
+func DeclaracionHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	var req struct {
+		Declaracion string `json:"declaracion"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	declaracion, msg := limpiarDeclaracion(req.Declaracion)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	d, err := Cargar(db, id, userID, rol)
+	if err == nil && !d.EsParte(userID, rol) {
+		err = ErrPermiso
+	}
+	if err == nil && d.Estado != EstadoAbierta {
+		err = ErrCerrada
+	}
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+
+	res, err := db.Exec(`
+		UPDATE disputas SET `+columnaDeclaracion(rol)+` = $2
+		WHERE id = $1 AND estado = $3
+	`, id, declaracion, EstadoAbierta)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar declaración", "err": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		responderError(c, ErrCerrada)
+		return
+	}
+
+	destino, rolDestino := d.contraparte(rol)
+	notificar(db, destino, rolDestino, "disputa_declaracion", "Nueva declaración en la disputa",
+		fmt.Sprintf("La otra parte agregó su declaración en la disputa de \"%s\".", d.Titulo), d)
+
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Declaración guardada"})
+}
+
+// -------------------------------
+// POST /protected/disputas/:id/evidencias  (multipart: archivo, descripcion)
+// -------------------------------
+func SubirEvidenciaHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	d, err := Cargar(db, id, userID, rol)
+	if err == nil && !d.EsParte(userID, rol) {
+		err = ErrPermiso
+	}
+	if err == nil && d.Estado != EstadoAbierta {
+		err = ErrCerrada
+	}
+	if err == nil {
+		propias := 0
+		for _, e := range d.Evidencias {
+			if e.AutorRol == rol {
+				propias++
+			}
+		}
+		if propias >= MaxEvidenciasPorParte {
+			err = ErrLimite
+		}
+	}
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+
+	archivo, err := c.FormFile("archivo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archivo es requerido"})
+		return
+	}
+	if archivo.Size > MaxBytesEvidencia {
+		responderError(c, ErrMuyGrande)
+		return
+	}
+	f, err := archivo.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, MaxBytesEvidencia+1))
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+
+	ruta, mime, err := guardarArchivo(id, data)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+
+	e := Evidencia{
+		AutorRol:    rol,
+		Nombre:      filepath.Base(archivo.Filename),
+		Descripcion: strings.TrimSpace(c.PostForm("descripcion")),
+		TipoMime:    mime,
+		Bytes:       len(data),
+	}
+	err = db.QueryRow(`
+		INSERT INTO disputas_evidencias (disputa_id, autor_rol, autor_id, nombre_original, descripcion, tipo_mime, bytes, archivo)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, TO_CHAR(creado_en, 'YYYY-MM-DD"T"HH24:MI:SSOF')
+	`, id, rol, userID, e.Nombre, e.Descripcion, e.TipoMime, e.Bytes, ruta).Scan(&e.ID, &e.CreadoEn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar evidencia", "err": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"mensaje": "Evidencia agregada", "evidencia": e})
+}
+
+// -------------------------------
+// GET /protected/disputas/:id/evidencias/:evidencia_id
+// Descarga privada: solo las partes o un admin
+// -------------------------------
+func DescargarEvidenciaHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	evidenciaID, err := strconv.Atoi(c.Param("evidencia_id"))
+	if err != nil || evidenciaID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de evidencia inválido"})
+		return
+	}
+
+	d, err := Cargar(db, id, userID, rol)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	for _, e := range d.Evidencias {
+		if e.ID == evidenciaID {
+			c.Header("Content-Type", e.TipoMime)
+			c.FileAttachment(filepath.Join(DirPrivado, e.archivo), e.Nombre)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Evidencia no encontrada"})
+}
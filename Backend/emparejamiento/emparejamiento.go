@@ -0,0 +1,217 @@
+package emparejamiento
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/cupos"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/preguntas"
+	"github.com/VinkoRobi2/FlashWorkEC/eventos"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+)
+
+// Motor de match. Guardar un interés (like o dislike, de cualquiera de
+// las dos partes) y decidir si hay match pasa en una sola transacción
+// que bloquea la fila del job: dos likes simultáneos sobre el mismo job
+// se ordenan y el segundo siempre ve al primero. La unicidad de
+// matches_job (estudiante, job) evita duplicados aunque algo se salte
+// el bloqueo. El evento MatchCreado se publica solo después del commit.
+
+var (
+	ErrNoEncontrado = errors.New("trabajo no encontrado")
+	ErrBorrador     = errors.New("el trabajo todavía es un borrador")
+	ErrSinCupos     = errors.New("este trabajo ya no tiene cupos disponibles")
+	ErrPermiso      = errors.New("no puedes responder likes de este trabajo")
+)
+
+// Interes es un like o dislike de una de las partes
+type Interes struct {
+	Rol          string // notificaciones.RolEstudiante | notificaciones.RolEmpleador
+	UsuarioID    int    // quien da el like
+	EstudianteID int    // a quién, cuando el like es del empleador
+	JobID        int
+	Interesado   bool
+	// Solo para el estudiante: respuestas ya revisadas con preguntas.Revisar
+	Respuestas []preguntas.Respuesta
+	Descartado bool
+}
+
+// Resultado de registrar un interés
+type Resultado struct {
+	Match   bool
+	MatchID int
+}
+
+// Estado es lo que se sabe del par estudiante-job al momento de decidir
+type Estado struct {
+	Rol            string // quién acaba de dar like o dislike
+	LikeEstudiante bool
+	LikeEmpleador  bool
+	// Las respuestas del estudiante lo descartan
+	Descartado     bool
+	MatchExistente bool
+}
+
+// Evaluar decide si corresponde crear el match. Un candidato descartado
+// por sus respuestas no hace match con su propio like, pero sí cuando el
+// empleador lo elige a mano.
+func Evaluar(e Estado) bool {
+	if e.MatchExistente || !e.LikeEstudiante || !e.LikeEmpleador {
+		return false
+	}
+	if e.Descartado && e.Rol == notificaciones.RolEstudiante {
+		return false
+	}
+	return true
+}
+
+type Servicio struct {
+	db      *sql.DB
+	eventos eventos.Publicador
+}
+
+func Nuevo(db *sql.DB, pub eventos.Publicador) *Servicio {
+	return &Servicio{db: db, eventos: pub}
+}
+
+// BloquearJob toma el lock de la fila del job dentro de tx. Todo lo que
+// cambie intereses o matches de un job debe pasar por aquí primero.
+func BloquearJob(tx *sql.Tx, jobID int) (empleadorID int, estado, titulo string, err error) {
+	err = tx.QueryRow(`
+		SELECT empleador_id, estado, titulo FROM jobs
+		WHERE id = $1 AND eliminado_en IS NULL
+		FOR UPDATE
+	`, jobID).Scan(&empleadorID, &estado, &titulo)
+	if err == sql.ErrNoRows {
+		err = ErrNoEncontrado
+	}
+	return
+}
+
+// Registrar guarda el interés y, si ambas partes dieron like, crea el match
+func (s *Servicio) Registrar(in Interes) (*Resultado, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	empleadorID, estadoJob, titulo, err := BloquearJob(tx, in.JobID)
+	if err != nil {
+		return nil, err
+	}
+
+	estudianteID := in.EstudianteID
+	switch in.Rol {
+	case notificaciones.RolEstudiante:
+		estudianteID = in.UsuarioID
+		// Los borradores no existen para los estudiantes
+		if estadoJob == "borrador" {
+			return nil, ErrNoEncontrado
+		}
+	case notificaciones.RolEmpleador:
+		if in.UsuarioID != empleadorID {
+			return nil, ErrPermiso
+		}
+		if estadoJob == "borrador" {
+			return nil, ErrBorrador
+		}
+	default:
+		return nil, ErrPermiso
+	}
+
+	// Un job con todos sus cupos ocupados ya no acepta likes; con el
+	// lock tomado, "abierto" garantiza que queda al menos un cupo
+	if in.Interesado && estadoJob != "abierto" {
+		return nil, ErrSinCupos
+	}
+
+	if in.Rol == notificaciones.RolEstudiante {
+		_, err = tx.Exec(`
+			INSERT INTO intereses_estudiante (estudiante_id, job_id, interesado)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (estudiante_id, job_id)
+			DO UPDATE SET interesado = EXCLUDED.interesado, creado_en = NOW()
+		`, estudianteID, in.JobID, in.Interesado)
+		if err != nil {
+			return nil, err
+		}
+		// Un dislike borra las respuestas de un like anterior
+		if !in.Interesado {
+			in.Respuestas, in.Descartado = nil, false
+		}
+		if err := preguntas.GuardarRespuestas(tx, estudianteID, in.JobID, in.Respuestas, in.Descartado); err != nil {
+			return nil, err
+		}
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO intereses_empleador (empleador_id, estudiante_id, job_id, interesado)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (empleador_id, estudiante_id, job_id)
+			DO UPDATE SET interesado = EXCLUDED.interesado, creado_en = NOW()
+		`, empleadorID, estudianteID, in.JobID, in.Interesado)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := &Resultado{}
+	if !in.Interesado {
+		return res, tx.Commit()
+	}
+
+	e := Estado{Rol: in.Rol}
+	err = tx.QueryRow(`
+		SELECT
+			COALESCE((SELECT interesado FROM intereses_estudiante
+			          WHERE estudiante_id = $1 AND job_id = $2), false),
+			COALESCE((SELECT descartado FROM intereses_estudiante
+			          WHERE estudiante_id = $1 AND job_id = $2), false),
+			COALESCE((SELECT interesado FROM intereses_empleador
+			          WHERE empleador_id = $3 AND estudiante_id = $1 AND job_id = $2), false),
+			EXISTS (SELECT 1 FROM matches_job
+			        WHERE estudiante_id = $1 AND job_id = $2 AND is_match = true)
+	`, estudianteID, in.JobID, empleadorID).Scan(&e.LikeEstudiante, &e.Descartado, &e.LikeEmpleador, &e.MatchExistente)
+	if err != nil {
+		return nil, err
+	}
+
+	if !Evaluar(e) {
+		return res, tx.Commit()
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO matches_job (estudiante_id, empleador_id, job_id, is_match, emparejado_en)
+		VALUES ($1, $2, $3, true, NOW())
+		ON CONFLICT (estudiante_id, empleador_id, job_id)
+		DO UPDATE SET is_match = true,
+		              -- Un match que ya existía conserva su fecha
+		              emparejado_en = CASE WHEN matches_job.is_match
+		                                   THEN COALESCE(matches_job.emparejado_en, NOW())
+		                                   ELSE NOW() END
+		RETURNING id
+	`, estudianteID, empleadorID, in.JobID).Scan(&res.MatchID)
+	if err != nil {
+		return nil, err
+	}
+
+	// El match ocupa un cupo; si era el último, el job sale del feed
+	if _, err := cupos.Recalcular(tx, in.JobID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	res.Match = true
+
+	if s.eventos != nil {
+		s.eventos.Publicar(eventos.TipoMatchCreado, eventos.MatchCreado{
+			MatchID:      res.MatchID,
+			JobID:        in.JobID,
+			Titulo:       titulo,
+			EstudianteID: estudianteID,
+			EmpleadorID:  empleadorID,
+		})
+	}
+	return res, nil
+}
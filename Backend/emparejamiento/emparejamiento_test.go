@@ -0,0 +1,31 @@
+package emparejamiento
+
+import (
+	"testing"
+
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+)
+
+func TestEvaluar(t *testing.T) {
+	casos := []struct {
+		nombre string
+		estado Estado
+		match  bool
+	}{
+		{"ambos likes", Estado{Rol: notificaciones.RolEstudiante, LikeEstudiante: true, LikeEmpleador: true}, true},
+		{"solo like del estudiante", Estado{Rol: notificaciones.RolEstudiante, LikeEstudiante: true}, false},
+		{"solo like del empleador", Estado{Rol: notificaciones.RolEmpleador, LikeEmpleador: true}, false},
+		{"sin likes", Estado{Rol: notificaciones.RolEstudiante}, false},
+		{"match existente", Estado{Rol: notificaciones.RolEstudiante, LikeEstudiante: true, LikeEmpleador: true, MatchExistente: true}, false},
+		{"descartado con su propio like", Estado{Rol: notificaciones.RolEstudiante, LikeEstudiante: true, LikeEmpleador: true, Descartado: true}, false},
+		{"descartado elegido por el empleador", Estado{Rol: notificaciones.RolEmpleador, LikeEstudiante: true, LikeEmpleador: true, Descartado: true}, true},
+		{"descartado sin like del empleador", Estado{Rol: notificaciones.RolEmpleador, LikeEstudiante: true, Descartado: true}, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := Evaluar(c.estado); got != c.match {
+				t.Errorf("Evaluar(%+v) = %v, se esperaba %v", c.estado, got, c.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package eventos
+
+import (
+	"log"
+	"sync"
+)
+
+// Bus de eventos de dominio en memoria. Quien produce el evento lo
+// publica después de confirmar su transacción; los suscriptores
+// (notificaciones, chat, ...) reaccionan sin que el productor los conozca.
+// Los manejadores corren en orden y en la misma goroutine; un panic en
+// uno se registra y no afecta a los demás.
+
+// Tipos de evento
+const (
+	TipoMatchCreado     = "match_creado"
+	TipoMatchCompletado = "match_completado"
+	TipoMatchCancelado  = "match_cancelado"
+	TipoMatchPagado     = "match_pagado"
+)
+
+// MatchCreado se publica cuando un estudiante y un empleador hacen match en un trabajo
+type MatchCreado struct {
+	MatchID      int
+	JobID        int
+	Titulo       string
+	EstudianteID int
+	EmpleadorID  int
+}
+
+// MatchCompletado se publica cuando el match queda completado, por ambas
+// confirmaciones, por vencimiento del plazo o por resolución de disputa
+type MatchCompletado struct {
+	MatchID int
+	JobID   int
+}
+
+// MatchCancelado se publica cuando el match se cancela
+type MatchCancelado struct {
+	MatchID int
+	JobID   int
+}
+
+// MatchPagado se publica cuando los pagos confirmados cubren todo lo
+// adeudado por el match
+type MatchPagado struct {
+	MatchID int
+	JobID   int
+}
+
+// Manejador recibe los datos del evento (p. ej. MatchCreado)
+type Manejador func(datos interface{})
+
+// Publicador es lo que necesita quien emite eventos
+type Publicador interface {
+	Publicar(tipo string, datos interface{})
+}
+
+type Bus struct {
+	mu          sync.RWMutex
+	manejadores map[string][]Manejador
+}
+
+func Nuevo() *Bus {
+	return &Bus{manejadores: map[string][]Manejador{}}
+}
+
+// Suscribir registra un manejador para un tipo de evento
+func (b *Bus) Suscribir(tipo string, m Manejador) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.manejadores[tipo] = append(b.manejadores[tipo], m)
+}
+
+// Publicar entrega el evento a todos los suscriptores del tipo
+func (b *Bus) Publicar(tipo string, datos interface{}) {
+	b.mu.RLock()
+	ms := b.manejadores[tipo]
+	b.mu.RUnlock()
+
+	for _, m := range ms {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Panic manejando evento %s: %v", tipo, r)
+				}
+			}()
+			m(datos)
+		}()
+	}
+}
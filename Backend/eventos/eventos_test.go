@@ -0,0 +1,59 @@
+package eventos
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPublicarEntregaEnOrden(t *testing.T) {
+	b := Nuevo()
+	var recibidos []string
+	b.Suscribir(TipoMatchCreado, func(datos interface{}) {
+		recibidos = append(recibidos, "primero")
+	})
+	b.Suscribir(TipoMatchCreado, func(datos interface{}) {
+		m := datos.(MatchCreado)
+		if m.MatchID != 7 {
+			t.Errorf("MatchID = %d, se esperaba 7", m.MatchID)
+		}
+		recibidos = append(recibidos, "segundo")
+	})
+
+	b.Publicar(TipoMatchCreado, MatchCreado{MatchID: 7})
+
+	if want := []string{"primero", "segundo"}; !reflect.DeepEqual(recibidos, want) {
+		t.Errorf("recibidos = %v, se esperaba %v", recibidos, want)
+	}
+}
+
+func TestPublicarSoloAlTipoSuscrito(t *testing.T) {
+	b := Nuevo()
+	llamados := 0
+	b.Suscribir(TipoMatchCompletado, func(datos interface{}) { llamados++ })
+
+	b.Publicar(TipoMatchCancelado, MatchCancelado{MatchID: 1})
+	if llamados != 0 {
+		t.Fatalf("un evento de otro tipo llegó al manejador")
+	}
+	b.Publicar(TipoMatchCompletado, MatchCompletado{MatchID: 1})
+	if llamados != 1 {
+		t.Fatalf("llamados = %d, se esperaba 1", llamados)
+	}
+}
+
+func TestPublicarSinSuscriptores(t *testing.T) {
+	Nuevo().Publicar(TipoMatchCreado, MatchCreado{})
+}
+
+func TestPanicNoAfectaAOtrosManejadores(t *testing.T) {
+	b := Nuevo()
+	segundo := false
+	b.Suscribir(TipoMatchCreado, func(datos interface{}) { panic("falla") })
+	b.Suscribir(TipoMatchCreado, func(datos interface{}) { segundo = true })
+
+	b.Publicar(TipoMatchCreado, MatchCreado{})
+
+	if !segundo {
+		t.Fatal("el panic del primer manejador impidió llamar al segundo")
+	}
+}
@@ -0,0 +1,20 @@
+package exportar
+
+import "strings"
+
+// Caracteres con los que Excel, LibreOffice y Sheets interpretan una
+// celda como fórmula (también tabulador y retorno de carro, que pueden
+// precederla)
+const inicioFormula = "=+-@\t\r"
+
+// CeldaCSV neutraliza un texto que viene de los usuarios (títulos,
+// nombres, categorías) antes de escribirlo en un CSV: si empieza como una
+// fórmula se le antepone un apóstrofo, para que la hoja lo muestre como
+// texto en vez de ejecutarlo. Los números que genera el servidor no pasan
+// por aquí, así un monto negativo sigue siendo un número.
+func CeldaCSV(s string) string {
+	if s != "" && strings.IndexByte(inicioFormula, s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
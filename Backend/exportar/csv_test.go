@@ -0,0 +1,24 @@
+package exportar
+
+import "testing"
+
+func TestCeldaCSV(t *testing.T) {
+	casos := []struct {
+		entrada, esperado string
+	}{
+		{"", ""},
+		{"Mesero para evento", "Mesero para evento"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+593 99 999 9999", "'+593 99 999 9999"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"Pago = 20", "Pago = 20"},
+	}
+	for _, c := range casos {
+		if got := CeldaCSV(c.entrada); got != c.esperado {
+			t.Errorf("CeldaCSV(%q) = %q, se esperaba %q", c.entrada, got, c.esperado)
+		}
+	}
+}
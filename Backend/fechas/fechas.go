@@ -0,0 +1,43 @@
+package fechas
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ZonaEcuador es la zona en la que se interpretan y muestran las fechas.
+// Ecuador no tiene horario de verano: UTC-5 fijo.
+var ZonaEcuador = time.FixedZone("ECT", -5*60*60)
+
+// Param lee un parámetro AAAA-MM-DD como inicio del día en Ecuador. Si
+// el formato no es válido responde 400 y devuelve false.
+func Param(c *gin.Context, nombre string) (*time.Time, bool) {
+	s := c.Query(nombre)
+	if s == "" {
+		return nil, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, ZonaEcuador)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": nombre + " debe tener formato AAAA-MM-DD"})
+		return nil, false
+	}
+	return &t, true
+}
+
+// Rango lee desde y hasta (opcionales). hasta incluye el día completo,
+// así que se devuelve como el inicio del día siguiente.
+func Rango(c *gin.Context) (desde, hasta *time.Time, ok bool) {
+	if desde, ok = Param(c, "desde"); !ok {
+		return nil, nil, false
+	}
+	if hasta, ok = Param(c, "hasta"); !ok {
+		return nil, nil, false
+	}
+	if hasta != nil {
+		fin := hasta.AddDate(0, 0, 1)
+		hasta = &fin
+	}
+	return desde, hasta, true
+}
@@ -0,0 +1,227 @@
+package ganancias
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/VinkoRobi2/FlashWorkEC/pagos"
+)
+
+// Ganancias del estudiante sobre sus matches completados. Lo ganado es lo
+// adeudado según el libro de pagos (pago_final); lo recibido, los pagos
+// que el estudiante confirmó. Las horas son las registradas con la
+// asistencia o, sin registro, las estimadas del trabajo. Los periodos
+// usan la fecha de completado en hora de Ecuador; los semestres son
+// enero–junio (S1) y julio–diciembre (S2).
+
+// Trabajo es un match completado con su dinero y sus horas
+type Trabajo struct {
+	MatchID          int        `json:"match_id"`
+	JobID            int        `json:"job_id"`
+	Titulo           string     `json:"titulo"`
+	Categoria        string     `json:"categoria"`
+	EmpleadorID      int        `json:"empleador_id"`
+	Empleador        string     `json:"empleador"`
+	CompletadoEn     *time.Time `json:"completado_en"`
+	Ganado           float64    `json:"ganado"`
+	Recibido         float64    `json:"recibido"`
+	PorConfirmar     float64    `json:"por_confirmar"`
+	Pendiente        float64    `json:"pendiente"`
+	EstadoPago       string     `json:"estado_pago"`
+	Horas            float64    `json:"horas"`
+	HorasRegistradas bool       `json:"horas_registradas"`
+}
+
+// Grupo acumula los trabajos de un periodo, categoría o empleador
+type Grupo struct {
+	Clave        string  `json:"clave"`
+	Nombre       string  `json:"nombre,omitempty"`
+	Trabajos     int     `json:"trabajos"`
+	Ganado       float64 `json:"ganado"`
+	Recibido     float64 `json:"recibido"`
+	PorConfirmar float64 `json:"por_confirmar"`
+	Pendiente    float64 `json:"pendiente"`
+	Horas        float64 `json:"horas"`
+}
+
+type Resumen struct {
+	Totales      Grupo   `json:"totales"`
+	PorHora      float64 `json:"por_hora"` // ganado / horas
+	PorSemana    []Grupo `json:"por_semana"`
+	PorMes       []Grupo `json:"por_mes"`
+	PorSemestre  []Grupo `json:"por_semestre"`
+	PorCategoria []Grupo `json:"por_categoria"`
+	PorEmpleador []Grupo `json:"por_empleador"`
+}
+
+func redondear(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func (g *Grupo) sumar(t Trabajo) {
+	g.Trabajos++
+	g.Ganado = redondear(g.Ganado + t.Ganado)
+	g.Recibido = redondear(g.Recibido + t.Recibido)
+	g.PorConfirmar = redondear(g.PorConfirmar + t.PorConfirmar)
+	g.Pendiente = redondear(g.Pendiente + t.Pendiente)
+	g.Horas = redondear(g.Horas + t.Horas)
+}
+
+// Semana devuelve la semana ISO (2025-W09)
+func Semana(t time.Time) string {
+	anio, semana := t.In(fechas.ZonaEcuador).ISOWeek()
+	return fmt.Sprintf("%d-W%02d", anio, semana)
+}
+
+// Mes devuelve 2025-03
+func Mes(t time.Time) string {
+	return t.In(fechas.ZonaEcuador).Format("2006-01")
+}
+
+// Semestre devuelve 2025-S1 o 2025-S2
+func Semestre(t time.Time) string {
+	t = t.In(fechas.ZonaEcuador)
+	if t.Month() <= time.June {
+		return fmt.Sprintf("%d-S1", t.Year())
+	}
+	return fmt.Sprintf("%d-S2", t.Year())
+}
+
+// Cargar lee los matches completados del estudiante; desde y hasta
+// (opcionales) acotan la fecha de completado
+func Cargar(db *sql.DB, estudianteID int, desde, hasta *time.Time) ([]Trabajo, error) {
+	saldos, err := pagos.SaldosDe(db, estudianteID, notificaciones.RolEstudiante)
+	if err != nil {
+		return nil, err
+	}
+
+	type horas struct {
+		trabajadas, estimadas sql.NullFloat64
+	}
+	porMatch := map[int]horas{}
+	rows, err := db.Query(`
+		SELECT mj.id, CAST(mj.horas_trabajadas AS FLOAT), CAST(j.horas_estimadas AS FLOAT)
+		FROM matches_job mj
+		JOIN jobs j ON j.id = mj.job_id
+		WHERE mj.estudiante_id = $1 AND mj.is_match = true AND mj.estado = 'completado'
+	`, estudianteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var h horas
+		if err := rows.Scan(&id, &h.trabajadas, &h.estimadas); err != nil {
+			return nil, err
+		}
+		porMatch[id] = h
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	trabajos := []Trabajo{}
+	for _, s := range saldos {
+		t := Trabajo{
+			MatchID:      s.MatchID,
+			JobID:        s.JobID,
+			Titulo:       s.Titulo,
+			Categoria:    s.Categoria,
+			EmpleadorID:  s.EmpleadorID,
+			Empleador:    s.Empleador,
+			Ganado:       s.Adeudado,
+			Recibido:     s.Pagado,
+			PorConfirmar: s.PorConfirmar,
+			Pendiente:    s.Pendiente,
+			EstadoPago:   s.EstadoPago,
+		}
+		if s.CompletadoEn != nil {
+			if c, err := time.Parse(time.RFC3339, *s.CompletadoEn); err == nil {
+				c = c.In(fechas.ZonaEcuador)
+				t.CompletadoEn = &c
+			}
+		}
+		if desde != nil && (t.CompletadoEn == nil || t.CompletadoEn.Before(*desde)) {
+			continue
+		}
+		if hasta != nil && (t.CompletadoEn == nil || !t.CompletadoEn.Before(*hasta)) {
+			continue
+		}
+		h := porMatch[s.MatchID]
+		if h.trabajadas.Valid && h.trabajadas.Float64 > 0 {
+			t.Horas, t.HorasRegistradas = h.trabajadas.Float64, true
+		} else if h.estimadas.Valid {
+			t.Horas = h.estimadas.Float64
+		}
+		trabajos = append(trabajos, t)
+	}
+	return trabajos, nil
+}
+
+// agrupar suma los trabajos por la clave que devuelve clave; un trabajo
+// sin clave (sin fecha de completado) no entra en el grupo
+func agrupar(trabajos []Trabajo, clave func(Trabajo) (string, string)) []Grupo {
+	indice := map[string]int{}
+	grupos := []Grupo{}
+	for _, t := range trabajos {
+		k, nombre := clave(t)
+		if k == "" {
+			continue
+		}
+		i, ok := indice[k]
+		if !ok {
+			i = len(grupos)
+			indice[k] = i
+			grupos = append(grupos, Grupo{Clave: k, Nombre: nombre})
+		}
+		grupos[i].sumar(t)
+	}
+	return grupos
+}
+
+func porPeriodo(trabajos []Trabajo, periodo func(time.Time) string) []Grupo {
+	grupos := agrupar(trabajos, func(t Trabajo) (string, string) {
+		if t.CompletadoEn == nil {
+			return "", ""
+		}
+		return periodo(*t.CompletadoEn), ""
+	})
+	// Las claves de periodo ordenan cronológicamente como texto
+	sort.Slice(grupos, func(i, j int) bool { return grupos[i].Clave < grupos[j].Clave })
+	return grupos
+}
+
+func porMonto(grupos []Grupo) []Grupo {
+	sort.SliceStable(grupos, func(i, j int) bool { return grupos[i].Ganado > grupos[j].Ganado })
+	return grupos
+}
+
+// Resumir arma los totales y los desgloses
+func Resumir(trabajos []Trabajo) Resumen {
+	r := Resumen{Totales: Grupo{Clave: "total"}}
+	for _, t := range trabajos {
+		r.Totales.sumar(t)
+	}
+	if r.Totales.Horas > 0 {
+		r.PorHora = redondear(r.Totales.Ganado / r.Totales.Horas)
+	}
+	r.PorSemana = porPeriodo(trabajos, Semana)
+	r.PorMes = porPeriodo(trabajos, Mes)
+	r.PorSemestre = porPeriodo(trabajos, Semestre)
+	r.PorCategoria = porMonto(agrupar(trabajos, func(t Trabajo) (string, string) {
+		if t.Categoria == "" {
+			return "sin_categoria", ""
+		}
+		return t.Categoria, ""
+	}))
+	r.PorEmpleador = porMonto(agrupar(trabajos, func(t Trabajo) (string, string) {
+		return fmt.Sprint(t.EmpleadorID), t.Empleador
+	}))
+	return r
+}
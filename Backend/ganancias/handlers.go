@@ -0,0 +1,80 @@
+package ganancias
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+
+	"github.com/VinkoRobi2/FlashWorkEC/exportar"
+	"github.com/VinkoRobi2/FlashWorkEC/fechas"
+	"github.com/VinkoRobi2/FlashWorkEC/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func dinero(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+// CSV escribe un trabajo por fila. Lleva BOM para que Excel respete las tildes.
+func CSV(trabajos []Trabajo) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"match_id", "fecha", "trabajo", "categoria", "empleador", "horas", "horas_registradas",
+		"ganado", "recibido", "por_confirmar", "pendiente", "estado_pago"})
+	for _, t := range trabajos {
+		fecha := ""
+		if t.CompletadoEn != nil {
+			fecha = t.CompletadoEn.Format("2006-01-02")
+		}
+		registradas := "no"
+		if t.HorasRegistradas {
+			registradas = "si"
+		}
+		w.Write([]string{
+			fmt.Sprint(t.MatchID), fecha, exportar.CeldaCSV(t.Titulo), exportar.CeldaCSV(t.Categoria), exportar.CeldaCSV(t.Empleador),
+			dinero(t.Horas), registradas,
+			dinero(t.Ganado), dinero(t.Recibido), dinero(t.PorConfirmar), dinero(t.Pendiente), t.EstadoPago,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// -------------------------------
+// GET /protected/ganancias?desde=2025-01-01&hasta=2025-06-30&formato=json|csv
+// Totales por semana, mes, semestre, categoría y empleador
+// -------------------------------
+func GananciasHandler(c *gin.Context, db *sql.DB) {
+	userID, ok := middlewares.UsuarioConRol(c, "estudiante")
+	if !ok {
+		return
+	}
+	desde, hasta, ok := fechas.Rango(c)
+	if !ok {
+		return
+	}
+
+	trabajos, err := Cargar(db, userID, desde, hasta)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener ganancias", "err": err.Error()})
+		return
+	}
+
+	switch c.DefaultQuery("formato", "json") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"resumen": Resumir(trabajos), "trabajos": trabajos})
+	case "csv":
+		out, err := CSV(trabajos)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar CSV", "err": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="ganancias.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", out)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "formato debe ser json o csv"})
+	}
+}
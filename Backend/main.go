@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -13,9 +14,27 @@ import (
 	jobs_empleador "github.com/VinkoRobi2/FlashWorkEC/Jobs/empleadores"
 	jobsemp "github.com/VinkoRobi2/FlashWorkEC/Jobs/empleadores"
 	jobsest "github.com/VinkoRobi2/FlashWorkEC/Jobs/estudiantes"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/media"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/preguntas"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/recurrentes"
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/versiones"
+	"github.com/VinkoRobi2/FlashWorkEC/analiticas"
+	"github.com/VinkoRobi2/FlashWorkEC/asistencia"
+	"github.com/VinkoRobi2/FlashWorkEC/categorias"
+	"github.com/VinkoRobi2/FlashWorkEC/certificados"
 	"github.com/VinkoRobi2/FlashWorkEC/completar"
+	"github.com/VinkoRobi2/FlashWorkEC/comprobantes"
+	"github.com/VinkoRobi2/FlashWorkEC/disputas"
+	"github.com/VinkoRobi2/FlashWorkEC/emparejamiento"
+	"github.com/VinkoRobi2/FlashWorkEC/eventos"
+	"github.com/VinkoRobi2/FlashWorkEC/ganancias"
 	"github.com/VinkoRobi2/FlashWorkEC/mensajeria"
 	"github.com/VinkoRobi2/FlashWorkEC/middlewares"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/VinkoRobi2/FlashWorkEC/ofertas"
+	"github.com/VinkoRobi2/FlashWorkEC/pagos"
+	"github.com/VinkoRobi2/FlashWorkEC/pasarela"
+	"github.com/VinkoRobi2/FlashWorkEC/tareas"
 
 	service "github.com/VinkoRobi2/FlashWorkEC/service/login"
 	registro "github.com/VinkoRobi2/FlashWorkEC/service/register"
@@ -92,11 +111,49 @@ func main() {
 	}))
 	ms := mensajeria.New(db)
 
+	// Eventos de dominio: el motor de match publica, notificaciones y chat escuchan
+	bus := eventos.Nuevo()
+	bus.Suscribir(eventos.TipoMatchCreado, notificaciones.AlCrearMatch(db))
+	bus.Suscribir(eventos.TipoMatchCreado, ms.AlCrearMatch)
+	motor := emparejamiento.Nuevo(db, bus)
+	completar.UsarEventos(bus)
+	pagos.UsarEventos(bus)
+	bus.Suscribir(eventos.TipoMatchPagado, comprobantes.AlPagarMatch(db))
+
+	// Pagos en custodia: solo con PASARELA_PAGOS configurada ("fake" en local)
+	proveedor, err := pasarela.DesdeEntorno()
+	if err != nil {
+		log.Println("Pagos en custodia desactivados:", err)
+	}
+	custodia := pagos.NuevaCustodia(db, proveedor)
+	if custodia.Activa() {
+		bus.Suscribir(eventos.TipoMatchCreado, custodia.AlCrearMatch)
+		bus.Suscribir(eventos.TipoMatchCompletado, custodia.AlCompletarMatch)
+		bus.Suscribir(eventos.TipoMatchCancelado, custodia.AlCancelarMatch)
+	}
+
+	// Tareas programadas (seguras con varias instancias: advisory locks)
+	prog := tareas.New(db)
+	prog.Registrar("expirar-trabajos", 5*time.Minute, jobsemp.ExpirarTrabajosVencidos)
+	prog.Registrar("avisar-trabajos-por-vencer", 15*time.Minute, jobsemp.AvisarTrabajosPorVencer)
+	prog.Registrar("publicar-trabajos-programados", time.Minute, jobsemp.PublicarTrabajosProgramados)
+	prog.Registrar("generar-turnos-recurrentes", time.Hour, recurrentes.GenerarTurnosPendientes)
+	prog.Registrar("purgar-trabajos-eliminados", 6*time.Hour, jobsemp.PurgarTrabajosEliminados)
+	prog.Registrar("expirar-ofertas-pago", 15*time.Minute, ofertas.ExpirarOfertas)
+	prog.Registrar("recordar-confirmaciones", 15*time.Minute, completar.RecordarConfirmacionesPendientes)
+	prog.Registrar("confirmar-completados-automaticos", 15*time.Minute, completar.ConfirmarCompletadosAutomaticos)
+	prog.Registrar("marcar-inasistencias", 15*time.Minute, asistencia.MarcarInasistencias)
+	prog.Registrar("procesar-custodias", 5*time.Minute, custodia.Procesar)
+	prog.Registrar("emitir-comprobantes", time.Hour, comprobantes.EmitirPendientes)
+	prog.Iniciar(context.Background())
+
 	// Rutas protegidas
 	empleadores := r.Group("/protected")
 	estudiantes := r.Group("/protected")
 	both := r.Group("/protected")
+	admin := r.Group("/protected/admin")
 	both.Use(middlewares.AuthMiddleware())
+	admin.Use(middlewares.AuthMiddleware(), middlewares.AdminMiddleware())
 	estudiantes.Use(middlewares.AuthMiddleware())
 	empleadores.Use(middlewares.AuthMiddleware())
 
@@ -112,6 +169,13 @@ func main() {
 
 	both.GET("/mensajes/:receiverID", mensajeria.GetMensajesHandler(db))
 
+	both.GET("/notificaciones", func(ctx *gin.Context) {
+		notificaciones.ListarNotificacionesHandler(ctx, db)
+	})
+	both.PATCH("/notificaciones/leer", func(ctx *gin.Context) {
+		notificaciones.MarcarLeidasHandler(ctx, db)
+	})
+
 	///////
 	estudiantes.GET("/todos_trabajos", func(ctx *gin.Context) {
 		jobsest.Get_Jobs_Abiertos(ctx, db)
@@ -120,7 +184,19 @@ func main() {
 		jobsest.GetAllInteresesHandler(ctx, db)
 	})
 	estudiantes.POST("/guardar-interes", func(ctx *gin.Context) {
-		jobsest.GuardarInteresEstudianteHandler(ctx, db)
+		jobsest.GuardarInteresEstudianteHandler(ctx, db, motor)
+	})
+	estudiantes.POST("/retirar-interes", func(ctx *gin.Context) {
+		jobsest.RetirarInteresHandler(ctx, db)
+	})
+	estudiantes.POST("/deshacer-swipe", func(ctx *gin.Context) {
+		jobsest.DeshacerSwipeHandler(ctx, db)
+	})
+	estudiantes.GET("/descartados", func(ctx *gin.Context) {
+		jobsest.GetDescartadosHandler(ctx, db)
+	})
+	estudiantes.POST("/descartados/reconsiderar", func(ctx *gin.Context) {
+		jobsest.ReconsiderarDescartadoHandler(ctx, db)
 	})
 	estudiantes.POST("/editar-perfil-estudiante", func(ctx *gin.Context) {
 		estud.EditarPerfilEstudiante(db, ctx)
@@ -164,10 +240,112 @@ func main() {
 		jobsemp.DeleteJob(db, ctx)
 	})
 
+	empleadores.GET("/trabajos-eliminados", func(ctx *gin.Context) {
+		jobsemp.GetTrabajosEliminados(db, ctx)
+	})
+
+	empleadores.POST("/restaurar-trabajo", func(ctx *gin.Context) {
+		jobsemp.RestaurarTrabajoHandler(db, ctx)
+	})
+
+	empleadores.POST("/archivar-trabajo", func(ctx *gin.Context) {
+		jobsemp.ArchivarTrabajoHandler(db, ctx)
+	})
+
 	empleadores.PATCH("/editar-trabajo", func(ctx *gin.Context) {
 		jobsemp.EditJob(db, ctx)
 	})
 
+	empleadores.POST("/renovar-trabajo", func(ctx *gin.Context) {
+		jobsemp.RenovarTrabajoHandler(db, ctx)
+	})
+
+	empleadores.PATCH("/editar-borrador", func(ctx *gin.Context) {
+		jobsemp.EditarBorradorHandler(db, ctx)
+	})
+
+	empleadores.POST("/publicar-trabajo", func(ctx *gin.Context) {
+		jobsemp.PublicarTrabajoHandler(db, ctx)
+	})
+
+	empleadores.POST("/duplicar-trabajo", func(ctx *gin.Context) {
+		jobsemp.DuplicarTrabajoHandler(db, ctx)
+	})
+
+	empleadores.POST("/importar-trabajos", func(ctx *gin.Context) {
+		jobsemp.ImportarTrabajosHandler(ctx, db)
+	})
+
+	// Galería de fotos del trabajo
+	empleadores.GET("/trabajos/:id/fotos", func(ctx *gin.Context) {
+		media.ListarFotosHandler(ctx, db)
+	})
+	empleadores.POST("/trabajos/:id/fotos", func(ctx *gin.Context) {
+		media.AgregarFotoHandler(ctx, db)
+	})
+	empleadores.PUT("/trabajos/:id/fotos/orden", func(ctx *gin.Context) {
+		media.OrdenarFotosHandler(ctx, db)
+	})
+	empleadores.PATCH("/trabajos/:id/fotos/:foto_id/portada", func(ctx *gin.Context) {
+		media.PortadaHandler(ctx, db)
+	})
+	empleadores.DELETE("/trabajos/:id/fotos/:foto_id", func(ctx *gin.Context) {
+		media.BorrarFotoHandler(ctx, db)
+	})
+
+	// Preguntas de filtro del trabajo
+	empleadores.GET("/trabajos/:id/preguntas", func(ctx *gin.Context) {
+		preguntas.ListarPreguntasHandler(ctx, db)
+	})
+	empleadores.PUT("/trabajos/:id/preguntas", func(ctx *gin.Context) {
+		preguntas.GuardarPreguntasHandler(ctx, db)
+	})
+
+	// Historial de ediciones y cambios de pago
+	empleadores.GET("/trabajos/:id/historial", func(ctx *gin.Context) {
+		versiones.HistorialHandler(ctx, db)
+	})
+	estudiantes.GET("/cambios-pago", func(ctx *gin.Context) {
+		versiones.PropuestasPendientesHandler(ctx, db)
+	})
+	estudiantes.POST("/cambios-pago/responder", func(ctx *gin.Context) {
+		versiones.ResponderPropuestaHandler(ctx, db)
+	})
+
+	// Plantillas de trabajo
+	empleadores.POST("/plantillas", func(ctx *gin.Context) {
+		jobsemp.CrearPlantillaHandler(db, ctx)
+	})
+	empleadores.GET("/plantillas", func(ctx *gin.Context) {
+		jobsemp.ListarPlantillasHandler(db, ctx)
+	})
+	empleadores.PUT("/plantillas/:id", func(ctx *gin.Context) {
+		jobsemp.EditarPlantillaHandler(db, ctx)
+	})
+	empleadores.DELETE("/plantillas/:id", func(ctx *gin.Context) {
+		jobsemp.BorrarPlantillaHandler(db, ctx)
+	})
+
+	// Trabajos recurrentes y turnos
+	empleadores.POST("/trabajos-recurrentes", func(ctx *gin.Context) {
+		recurrentes.CrearRecurrenteHandler(ctx, db)
+	})
+	empleadores.GET("/trabajos-recurrentes", func(ctx *gin.Context) {
+		recurrentes.ListarRecurrentesHandler(ctx, db)
+	})
+	empleadores.PUT("/trabajos-recurrentes/:id", func(ctx *gin.Context) {
+		recurrentes.EditarRecurrenteHandler(ctx, db)
+	})
+	empleadores.PATCH("/trabajos-recurrentes/:id/estado", func(ctx *gin.Context) {
+		recurrentes.CambiarEstadoRecurrenteHandler(ctx, db)
+	})
+	empleadores.GET("/turnos/calendario", func(ctx *gin.Context) {
+		recurrentes.CalendarioTurnosHandler(ctx, db)
+	})
+	empleadores.POST("/turnos/cancelar", func(ctx *gin.Context) {
+		recurrentes.CancelarTurnoHandler(ctx, db)
+	})
+
 	empleadores.GET("/empleador/:id", func(ctx *gin.Context) {
 		emp.Get_Empleador_Publico_Info(ctx, db)
 	})
@@ -179,7 +357,7 @@ func main() {
 	})
 
 	empleadores.POST("/matches/responder", func(ctx *gin.Context) {
-		jobsemp.GuardarInteresEmpleadorHandler(ctx, db)
+		jobsemp.GuardarInteresEmpleadorHandler(ctx, db, motor)
 	})
 
 	empleadores.GET("/ver-likes-empleador", func(ctx *gin.Context) {
@@ -198,6 +376,103 @@ func main() {
 	both.GET("/completar/estado", func(c *gin.Context) {
 		completar.ObtenerEstadoMatchHandler(db, c)
 	})
+	both.GET("/completar/entregables", func(c *gin.Context) {
+		completar.ListarEntregablesHandler(db, c)
+	})
+	both.GET("/completar/entregables/:id", func(c *gin.Context) {
+		completar.DescargarEntregableHandler(db, c)
+	})
+
+	// Certificados de trabajos completados
+	estudiantes.GET("/certificados/historial", func(ctx *gin.Context) {
+		certificados.HistorialHandler(ctx, db)
+	})
+	estudiantes.GET("/certificados/:match_id", func(ctx *gin.Context) {
+		certificados.CertificadoHandler(ctx, db)
+	})
+
+	both.POST("/matches/cancelar", func(c *gin.Context) {
+		completar.CancelarMatchHandler(db, c)
+	})
+	both.GET("/matches/historial", func(c *gin.Context) {
+		completar.HistorialMatchHandler(db, c)
+	})
+
+	// Asistencia en trabajos presenciales
+	empleadores.GET("/asistencia/codigo", func(ctx *gin.Context) {
+		asistencia.CodigoHandler(ctx, db)
+	})
+	estudiantes.POST("/asistencia/entrada", func(ctx *gin.Context) {
+		asistencia.EntradaHandler(ctx, db)
+	})
+	estudiantes.POST("/asistencia/salida", func(ctx *gin.Context) {
+		asistencia.SalidaHandler(ctx, db)
+	})
+	both.GET("/asistencia", func(ctx *gin.Context) {
+		asistencia.VerAsistenciaHandler(ctx, db)
+	})
+
+	// Negociación del pago de un match
+	both.GET("/matches/ofertas", func(ctx *gin.Context) {
+		ofertas.ListarOfertasHandler(ctx, db)
+	})
+	both.POST("/matches/ofertas", func(ctx *gin.Context) {
+		ofertas.CrearOfertaHandler(ctx, db)
+	})
+	both.POST("/matches/ofertas/responder", func(ctx *gin.Context) {
+		ofertas.ResponderOfertaHandler(ctx, db)
+	})
+
+	// Disputas sobre la finalización de un match
+	both.GET("/disputas", func(ctx *gin.Context) {
+		disputas.ListarDisputasHandler(ctx, db)
+	})
+	both.POST("/disputas", func(ctx *gin.Context) {
+		disputas.AbrirDisputaHandler(ctx, db)
+	})
+	both.GET("/disputas/:id", func(ctx *gin.Context) {
+		disputas.VerDisputaHandler(ctx, db)
+	})
+	both.POST("/disputas/:id/declaracion", func(ctx *gin.Context) {
+		disputas.DeclaracionHandler(ctx, db)
+	})
+	both.POST("/disputas/:id/evidencias", func(ctx *gin.Context) {
+		disputas.SubirEvidenciaHandler(ctx, db)
+	})
+	both.GET("/disputas/:id/evidencias/:evidencia_id", func(ctx *gin.Context) {
+		disputas.DescargarEvidenciaHandler(ctx, db)
+	})
+
+	// Libro de pagos de los matches completados
+	both.GET("/pagos", func(ctx *gin.Context) {
+		pagos.VerPagosHandler(ctx, db)
+	})
+	both.GET("/pagos/pendientes", func(ctx *gin.Context) {
+		pagos.PendientesHandler(ctx, db)
+	})
+	both.GET("/pagos/balance", func(ctx *gin.Context) {
+		pagos.BalanceHandler(ctx, db)
+	})
+	empleadores.POST("/pagos", func(ctx *gin.Context) {
+		pagos.MarcarPagadoHandler(ctx, db)
+	})
+	estudiantes.POST("/pagos/:id/responder", func(ctx *gin.Context) {
+		pagos.ResponderPagoHandler(ctx, db)
+	})
+	estudiantes.GET("/ganancias", func(ctx *gin.Context) {
+		ganancias.GananciasHandler(ctx, db)
+	})
+	empleadores.GET("/comprobantes", func(ctx *gin.Context) {
+		comprobantes.ListarHandler(ctx, db)
+	})
+	empleadores.GET("/comprobantes/:id", func(ctx *gin.Context) {
+		comprobantes.DescargarHandler(ctx, db)
+	})
+	empleadores.GET("/analiticas", func(ctx *gin.Context) {
+		analiticas.AnaliticasHandler(ctx, db)
+	})
+	both.GET("/pagos/custodia", custodia.VerCustodiaHandler)
+	empleadores.POST("/pagos/custodia/fondear", custodia.FondearHandler)
 
 	empleadores.GET("/matches/aceptados", func(ctx *gin.Context) {
 		jobsemp.GetMatchesEmpleadorHandler(ctx, db)
@@ -227,11 +502,49 @@ func main() {
 		valoracion.ObtenerValoracionEstudiante(db, ctx)
 	})
 
+	// Administración de categorías
+	admin.GET("/categorias", func(ctx *gin.Context) {
+		categorias.AdminListarCategoriasHandler(ctx, db)
+	})
+	admin.POST("/categorias", func(ctx *gin.Context) {
+		categorias.CrearCategoriaHandler(ctx, db)
+	})
+	admin.PUT("/categorias/:id", func(ctx *gin.Context) {
+		categorias.EditarCategoriaHandler(ctx, db)
+	})
+	admin.DELETE("/categorias/:id", func(ctx *gin.Context) {
+		categorias.BorrarCategoriaHandler(ctx, db)
+	})
+	admin.POST("/categorias/:id/alias", func(ctx *gin.Context) {
+		categorias.AgregarAliasHandler(ctx, db)
+	})
+
+	// Resolución de disputas
+	admin.GET("/disputas", func(ctx *gin.Context) {
+		disputas.ListarDisputasHandler(ctx, db)
+	})
+	admin.GET("/disputas/:id", func(ctx *gin.Context) {
+		disputas.VerDisputaHandler(ctx, db)
+	})
+	admin.GET("/disputas/:id/evidencias/:evidencia_id", func(ctx *gin.Context) {
+		disputas.DescargarEvidenciaHandler(ctx, db)
+	})
+	admin.POST("/disputas/:id/resolver", func(ctx *gin.Context) {
+		disputas.ResolverDisputaHandler(ctx, db)
+	})
+
 	// Rutas públicas
+	r.GET("/categorias", func(ctx *gin.Context) {
+		categorias.ListarCategoriasHandler(ctx, db)
+	})
 	r.POST("/login", func(ctx *gin.Context) {
 		service.LoginHandler(ctx, db)
 	})
 	r.Static("/uploads", "./uploads")
+	r.GET("/verificar-certificado/:code", func(ctx *gin.Context) {
+		certificados.VerificarHandler(ctx, db)
+	})
+	r.POST("/webhooks/pagos", custodia.WebhookHandler)
 	r.POST("/register", func(ctx *gin.Context) {
 		registro.RegisterHandler(ctx, db)
 	})
@@ -241,6 +554,9 @@ func main() {
 	r.POST("/auth/resend-verification", func(c *gin.Context) {
 		verify.ResendVerification(c, db)
 	})
+	r.POST("/renovar-trabajo/:token", func(c *gin.Context) {
+		jobsemp.RenovarTrabajoConTokenHandler(c, db)
+	})
 
 	if err := r.Run(":8080"); err != nil {
 		log.Fatal("Error al iniciar el servidor: ", err)
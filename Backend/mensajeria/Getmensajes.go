@@ -21,7 +21,7 @@ type Mensaje struct {
 func GetMensajesHandler(db *sql.DB) gin.HandlerFunc {
     return func(c *gin.Context) {
         // 1) user logueado (sender = el que está mirando el chat)
-        senderID, err := getUserIdFromJWT(c)
+        senderID, _, err := getUsuarioFromJWT(c)
         if err != nil {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
             return
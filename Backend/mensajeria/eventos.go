@@ -0,0 +1,35 @@
+package mensajeria
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/VinkoRobi2/FlashWorkEC/eventos"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+)
+
+// AlCrearMatch avisa por el websocket a las partes conectadas que ya
+// tienen un chat abierto para el trabajo
+func (ms *MensajeriaService) AlCrearMatch(datos interface{}) {
+	m, ok := datos.(eventos.MatchCreado)
+	if !ok {
+		return
+	}
+	aviso := func(con int) []byte {
+		b, _ := json.Marshal(map[string]interface{}{
+			"tipo":     eventos.TipoMatchCreado,
+			"match_id": m.MatchID,
+			"job_id":   m.JobID,
+			"titulo":   m.Titulo,
+			"con":      con,
+		})
+		return b
+	}
+
+	if err := ms.enviar(notificaciones.RolEstudiante, m.EstudianteID, aviso(m.EmpleadorID)); err != nil {
+		log.Println("WS error avisando match:", err)
+	}
+	if err := ms.enviar(notificaciones.RolEmpleador, m.EmpleadorID, aviso(m.EstudianteID)); err != nil {
+		log.Println("WS error avisando match:", err)
+	}
+}
@@ -7,7 +7,9 @@ import (
     "net/http"
     "strconv"
     "strings"
+    "sync"
 
+    "github.com/VinkoRobi2/FlashWorkEC/notificaciones"
     auth "github.com/VinkoRobi2/FlashWorkEC/service"
     "github.com/gin-gonic/gin"
     "github.com/gorilla/websocket"
@@ -19,17 +21,60 @@ var upgrader = websocket.Upgrader{
 
 type MensajeriaService struct {
     DB      *sql.DB
-    Clients map[int]*websocket.Conn // key: userID
+    Clients map[ClaveCliente]*Cliente // key: rol + userID
+    mu      sync.RWMutex              // Clients también se lee desde los eventos
+}
+
+// ClaveCliente identifica una conexión: estudiantes y empleadores tienen
+// ids en tablas distintas, así que el mismo número puede ser dos personas
+type ClaveCliente struct {
+    Rol string
+    ID  int
+}
+
+// Cliente es una conexión abierta. gorilla/websocket no admite dos
+// escrituras a la vez y aquí escriben el chat de otros usuarios y los
+// eventos del bus, así que toda escritura pasa por Enviar.
+type Cliente struct {
+    conn *websocket.Conn
+    mu   sync.Mutex
+}
+
+func (cl *Cliente) Enviar(msg []byte) error {
+    cl.mu.Lock()
+    defer cl.mu.Unlock()
+    return cl.conn.WriteMessage(websocket.TextMessage, msg)
 }
 
 func New(db *sql.DB) *MensajeriaService {
     return &MensajeriaService{
         DB:      db,
-        Clients: make(map[int]*websocket.Conn),
+        Clients: make(map[ClaveCliente]*Cliente),
+    }
+}
+
+// enviar escribe al usuario si está conectado
+func (ms *MensajeriaService) enviar(rol string, userID int, msg []byte) error {
+    ms.mu.RLock()
+    cl, ok := ms.Clients[ClaveCliente{rol, userID}]
+    ms.mu.RUnlock()
+    if !ok {
+        return nil
     }
+    return cl.Enviar(msg)
 }
 
-func getUserIdFromJWT(c *gin.Context) (int, error) {
+// rolContrario es el rol de quien recibe: los chats son entre un
+// estudiante y un empleador
+func rolContrario(rol string) string {
+    if rol == notificaciones.RolEstudiante {
+        return notificaciones.RolEmpleador
+    }
+    return notificaciones.RolEstudiante
+}
+
+// getUsuarioFromJWT devuelve el id y el rol de quien escribe
+func getUsuarioFromJWT(c *gin.Context) (int, string, error) {
     var tokenStr string
 
     authHeader := c.GetHeader("Authorization")
@@ -42,19 +87,24 @@ func getUserIdFromJWT(c *gin.Context) (int, error) {
     }
 
     if tokenStr == "" {
-        return 0, errors.New("token faltante")
+        return 0, "", errors.New("token faltante")
     }
 
     claims, err := auth.ValidateToken(tokenStr)
     if err != nil {
-        return 0, errors.New("token inválido")
+        return 0, "", errors.New("token inválido")
     }
 
     if claims.UserID == 0 {
-        return 0, errors.New("user_id no encontrado en claims")
+        return 0, "", errors.New("user_id no encontrado en claims")
+    }
+
+    rol := strings.ToLower(claims.Role)
+    if rol != notificaciones.RolEstudiante && rol != notificaciones.RolEmpleador {
+        return 0, "", errors.New("rol inválido para mensajería")
     }
 
-    return claims.UserID, nil
+    return claims.UserID, rol, nil
 }
 
 func (ms *MensajeriaService) MessageHandler(c *gin.Context) {
@@ -72,11 +122,12 @@ func (ms *MensajeriaService) MessageHandler(c *gin.Context) {
         return
     }
 
-    senderID, err := getUserIdFromJWT(c)
+    senderID, senderRol, err := getUsuarioFromJWT(c)
     if err != nil {
         c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
         return
     }
+    receiverRol := rolContrario(senderRol)
 
     // ==============================================
     //       🔵 WEBSOCKET MODE (si se desea)
@@ -89,8 +140,19 @@ func (ms *MensajeriaService) MessageHandler(c *gin.Context) {
         }
         defer conn.Close()
 
-        ms.Clients[senderID] = conn
-        defer delete(ms.Clients, senderID)
+        cliente := &Cliente{conn: conn}
+        clave := ClaveCliente{senderRol, senderID}
+        ms.mu.Lock()
+        ms.Clients[clave] = cliente
+        ms.mu.Unlock()
+        defer func() {
+            ms.mu.Lock()
+            // Si el usuario abrió otra conexión, esa queda registrada
+            if ms.Clients[clave] == cliente {
+                delete(ms.Clients, clave)
+            }
+            ms.mu.Unlock()
+        }()
 
         for {
             _, msg, err := conn.ReadMessage()
@@ -115,9 +177,7 @@ func (ms *MensajeriaService) MessageHandler(c *gin.Context) {
             }
 
             // Enviar en vivo si el receptor está conectado
-            if receiverConn, ok := ms.Clients[receiverID]; ok {
-                receiverConn.WriteMessage(websocket.TextMessage, []byte(mensaje))
-            }
+            ms.enviar(receiverRol, receiverID, []byte(mensaje))
         }
 
         return
@@ -152,9 +212,7 @@ func (ms *MensajeriaService) MessageHandler(c *gin.Context) {
     }
 
     // Enviar en vivo si el receptor está conectado
-    if receiverConn, ok := ms.Clients[receiverID]; ok {
-        receiverConn.WriteMessage(websocket.TextMessage, []byte(mensaje))
-    }
+    ms.enviar(receiverRol, receiverID, []byte(mensaje))
 
     c.JSON(http.StatusOK, gin.H{"status": "mensaje enviado"})
 }
\ No newline at end of file
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware va después de AuthMiddleware: solo deja pasar
+// cuentas con tipo_cuenta = 'admin' (el rol viaja en el token)
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if c.GetString("roles") != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Solo administradores"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var rolesPlural = map[string]string{
+	"estudiante": "estudiantes",
+	"empleador":  "empleadores",
+}
+
+// UsuarioConRol devuelve el ID del usuario autenticado si tiene el rol
+// indicado; si no, responde 403 y devuelve false
+func UsuarioConRol(c *gin.Context, rol string) (int, bool) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok || c.GetString("roles") != rol {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo " + rolesPlural[rol]})
+		return 0, false
+	}
+	return userIDInterface.(int), true
+}
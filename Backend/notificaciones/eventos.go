@@ -0,0 +1,44 @@
+package notificaciones
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/VinkoRobi2/FlashWorkEC/eventos"
+)
+
+// AlCrearMatch avisa a las dos partes de un match nuevo
+func AlCrearMatch(db *sql.DB) eventos.Manejador {
+	return func(datos interface{}) {
+		m, ok := datos.(eventos.MatchCreado)
+		if !ok {
+			return
+		}
+		destinos := []struct {
+			id  int
+			rol string
+		}{
+			{m.EstudianteID, RolEstudiante},
+			{m.EmpleadorID, RolEmpleador},
+		}
+		for _, d := range destinos {
+			err := Notificar(db, Notificacion{
+				UsuarioID: d.id,
+				Rol:       d.rol,
+				Tipo:      "match_creado",
+				Titulo:    "¡Tienes un nuevo match!",
+				Mensaje:   fmt.Sprintf("Hiciste match en \"%s\". Ya pueden coordinar por el chat.", m.Titulo),
+				Datos: map[string]interface{}{
+					"match_id":      m.MatchID,
+					"job_id":        m.JobID,
+					"estudiante_id": m.EstudianteID,
+					"empleador_id":  m.EmpleadorID,
+				},
+			})
+			if err != nil {
+				log.Println("Error notificando match:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package notificaciones
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Ejecutor permite usar tanto *sql.DB como *sql.Tx
+type Ejecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Roles de destinatario (los IDs de estudiantes y empleadores
+// viven en tablas distintas, así que el rol es parte de la clave)
+const (
+	RolEstudiante = "estudiante"
+	RolEmpleador  = "empleador"
+)
+
+type Notificacion struct {
+	ID        int                    `json:"id"`
+	UsuarioID int                    `json:"usuario_id"`
+	Rol       string                 `json:"rol"`
+	Tipo      string                 `json:"tipo"`
+	Titulo    string                 `json:"titulo"`
+	Mensaje   string                 `json:"mensaje"`
+	Datos     map[string]interface{} `json:"datos,omitempty"`
+	Leida     bool                   `json:"leida"`
+	CreadoEn  string                 `json:"creado_en"`
+}
+
+// Crear guarda una notificación in-app para un usuario
+func Crear(q Ejecutor, n Notificacion) error {
+	datos := []byte("{}")
+	if n.Datos != nil {
+		b, err := json.Marshal(n.Datos)
+		if err != nil {
+			return err
+		}
+		datos = b
+	}
+
+	_, err := q.Exec(`
+		INSERT INTO notificaciones (usuario_id, rol, tipo, titulo, mensaje, datos)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, n.UsuarioID, n.Rol, n.Tipo, n.Titulo, n.Mensaje, datos)
+
+	return err
+}
+
+// EmailDe busca el correo del usuario según su rol
+func EmailDe(q Ejecutor, usuarioID int, rol string) (string, error) {
+	tabla := "estudiantes"
+	if rol == RolEmpleador {
+		tabla = "empleadores"
+	}
+
+	var email string
+	err := q.QueryRow("SELECT email FROM "+tabla+" WHERE id = $1", usuarioID).Scan(&email)
+	return email, err
+}
+
+// EnviarCorreo manda un correo de texto plano usando la config SMTP del .env
+func EnviarCorreo(destino, asunto, cuerpo string) error {
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	user := os.Getenv("SMTP_USER")
+	pass := os.Getenv("SMTP_PASSWORD")
+	from := os.Getenv("FROM_EMAIL")
+	if host == "" || from == "" {
+		return fmt.Errorf("SMTP no configurado")
+	}
+	if port == "" {
+		port = "587"
+	}
+
+	message := []byte("Subject: " + asunto + "\r\n" +
+		"From: " + from + "\r\n" +
+		"To: " + destino + "\r\n" +
+		"Content-Type: text/plain; charset=\"UTF-8\"\r\n" +
+		"\r\n" +
+		cuerpo)
+
+	auth := smtp.PlainAuth("", user, pass, host)
+	return smtp.SendMail(host+":"+port, auth, from, []string{destino}, message)
+}
+
+// Notificar crea la notificación in-app y además envía el correo en segundo plano
+func Notificar(db *sql.DB, n Notificacion) error {
+	if err := Crear(db, n); err != nil {
+		return err
+	}
+
+	email, err := EmailDe(db, n.UsuarioID, n.Rol)
+	if err != nil {
+		log.Println("No se pudo obtener email para notificación:", err)
+		return nil
+	}
+
+	go func() {
+		if err := EnviarCorreo(email, n.Titulo, n.Mensaje); err != nil {
+			log.Println("Error enviando correo de notificación:", err)
+		}
+	}()
+
+	return nil
+}
+
+// -------------------------------
+// GET /protected/notificaciones?solo_no_leidas=true
+// -------------------------------
+func ListarNotificacionesHandler(c *gin.Context, db *sql.DB) {
+	userID := c.GetInt("userID")
+	rol := c.GetString("roles")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	soloNoLeidas, _ := strconv.ParseBool(c.Query("solo_no_leidas"))
+
+	rows, err := db.Query(`
+		SELECT id, usuario_id, rol, tipo, titulo, mensaje, datos, leida, creado_en
+		FROM notificaciones
+		WHERE usuario_id = $1 AND rol = $2
+		  AND ($3 = false OR leida = false)
+		ORDER BY creado_en DESC
+		LIMIT 100
+	`, userID, rol, soloNoLeidas)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener notificaciones", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	lista := []Notificacion{}
+	noLeidas := 0
+
+	for rows.Next() {
+		var n Notificacion
+		var datos []byte
+		if err := rows.Scan(&n.ID, &n.UsuarioID, &n.Rol, &n.Tipo, &n.Titulo, &n.Mensaje, &datos, &n.Leida, &n.CreadoEn); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo notificaciones", "err": err.Error()})
+			return
+		}
+		_ = json.Unmarshal(datos, &n.Datos)
+		if !n.Leida {
+			noLeidas++
+		}
+		lista = append(lista, n)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":          len(lista),
+		"no_leidas":      noLeidas,
+		"notificaciones": lista,
+	})
+}
+
+// -------------------------------
+// PATCH /protected/notificaciones/leer
+// body: { "ids": [1,2] } o { "todas": true }
+// -------------------------------
+func MarcarLeidasHandler(c *gin.Context, db *sql.DB) {
+	userID := c.GetInt("userID")
+	rol := c.GetString("roles")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var body struct {
+		IDs   []int64 `json:"ids"`
+		Todas bool    `json:"todas"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+
+	var res sql.Result
+	var err error
+	if body.Todas {
+		res, err = db.Exec(`
+			UPDATE notificaciones SET leida = true
+			WHERE usuario_id = $1 AND rol = $2 AND leida = false
+		`, userID, rol)
+	} else {
+		if len(body.IDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ids o todas son requeridos"})
+			return
+		}
+		res, err = db.Exec(`
+			UPDATE notificaciones SET leida = true
+			WHERE usuario_id = $1 AND rol = $2 AND id = ANY($3)
+		`, userID, rol, pq.Array(body.IDs))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar notificaciones"})
+		return
+	}
+
+	n, _ := res.RowsAffected()
+	c.JSON(http.StatusOK, gin.H{"message": "Notificaciones marcadas como leídas", "actualizadas": n})
+}
@@ -0,0 +1,387 @@
+package ofertas
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OfertaRequest struct {
+	MatchID      int     `json:"match_id"`
+	Monto        float64 `json:"monto"`
+	Unidad       string  `json:"unidad"`
+	Mensaje      string  `json:"mensaje"`
+	HorasValidez int     `json:"horas_validez"` // opcional, por defecto 48
+}
+
+func (req *OfertaRequest) validar() string {
+	if req.Monto <= 0 || req.Monto > MaxMonto {
+		return "monto debe ser mayor a 0 y menor a " + strconv.Itoa(MaxMonto)
+	}
+	unidad, ok := ValidarUnidad(req.Unidad)
+	if !ok {
+		return "unidad debe ser " + strings.Join(Unidades, ", ")
+	}
+	req.Unidad = unidad
+	req.Mensaje = strings.TrimSpace(req.Mensaje)
+	if len(req.Mensaje) > MaxLargoMensaje {
+		return "mensaje muy largo"
+	}
+	if req.HorasValidez == 0 {
+		req.HorasValidez = HorasValidezDefault
+	}
+	if req.HorasValidez < 1 || req.HorasValidez > HorasValidezMax {
+		return "horas_validez debe estar entre 1 y " + strconv.Itoa(HorasValidezMax)
+	}
+	// Centavos exactos
+	req.Monto = float64(int(req.Monto*100+0.5)) / 100
+	return ""
+}
+
+func usuario(c *gin.Context) (int, string, bool) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		return 0, "", false
+	}
+	return userIDInterface.(int), c.GetString("roles"), true
+}
+
+// responderError traduce los errores del paquete a respuestas HTTP
+func responderError(c *gin.Context, err error) {
+	switch err {
+	case ErrNoEncontrada:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case ErrPermiso:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case ErrPendiente, ErrAcordado, ErrNoNegociable, ErrMatchCerrado:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error en la negociación", "err": err.Error()})
+	}
+}
+
+// crearOferta inserta una oferta pendiente; el match ya debe estar bloqueado
+func crearOferta(tx *sql.Tx, m *Match, rol string, req OfertaRequest, respuestaA *int) (Oferta, error) {
+	o := Oferta{
+		MatchID:    m.ID,
+		AutorRol:   rol,
+		Monto:      req.Monto,
+		Unidad:     req.Unidad,
+		Mensaje:    req.Mensaje,
+		Estado:     "pendiente",
+		RespuestaA: respuestaA,
+	}
+	expira := time.Now().Add(time.Duration(req.HorasValidez) * time.Hour)
+	err := tx.QueryRow(`
+		INSERT INTO ofertas_pago (match_id, autor_rol, monto, unidad, mensaje, respuesta_a, expira_en)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, expira_en, creado_en
+	`, m.ID, rol, req.Monto, req.Unidad, req.Mensaje, respuestaA, expira).Scan(&o.ID, &o.ExpiraEn, &o.CreadoEn)
+	return o, err
+}
+
+// -------------------------------
+// GET /protected/matches/ofertas?match_id=1
+// Historial de la negociación y el pago vigente del match
+// -------------------------------
+func ListarOfertasHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	matchID, err := strconv.Atoi(c.Query("match_id"))
+	if err != nil || matchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id inválido"})
+		return
+	}
+
+	m, err := CargarMatch(db, matchID, userID, rol, false)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+
+	var pagoPublicado float64
+	var pagoAcordado sql.NullFloat64
+	var unidad, acordadoEn sql.NullString
+	err = db.QueryRow(`
+		SELECT j.pago_estimado, m.pago_acordado, m.unidad_pago, m.pago_acordado_en
+		FROM matches_job m JOIN jobs j ON j.id = m.job_id
+		WHERE m.id = $1
+	`, matchID).Scan(&pagoPublicado, &pagoAcordado, &unidad, &acordadoEn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer match", "err": err.Error()})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT id, match_id, autor_rol, monto, unidad, mensaje, estado, respuesta_a,
+		       expira_en, creado_en, respondida_en
+		FROM ofertas_pago
+		WHERE match_id = $1
+		ORDER BY id
+	`, matchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener ofertas", "err": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	lista := []Oferta{}
+	for rows.Next() {
+		var o Oferta
+		var respuestaA sql.NullInt64
+		var respondida sql.NullString
+		if err := rows.Scan(&o.ID, &o.MatchID, &o.AutorRol, &o.Monto, &o.Unidad, &o.Mensaje, &o.Estado,
+			&respuestaA, &o.ExpiraEn, &o.CreadoEn, &respondida); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo ofertas", "err": err.Error()})
+			return
+		}
+		if respuestaA.Valid {
+			id := int(respuestaA.Int64)
+			o.RespuestaA = &id
+		}
+		if respondida.Valid {
+			o.RespondidaEn = &respondida.String
+		}
+		lista = append(lista, o)
+	}
+
+	resp := gin.H{
+		"match_id":       matchID,
+		"job_id":         m.JobID,
+		"negociable":     m.Negociable,
+		"pago_publicado": pagoPublicado,
+		"pago_acordado":  nil,
+		"unidad_pago":    nil,
+		"ofertas":        lista,
+	}
+	if pagoAcordado.Valid {
+		resp["pago_acordado"] = pagoAcordado.Float64
+		resp["unidad_pago"] = unidad.String
+		resp["pago_acordado_en"] = acordadoEn.String
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// -------------------------------
+// POST /protected/matches/ofertas
+// { "match_id": 1, "monto": 25, "unidad": "turno", "mensaje": "...", "horas_validez": 24 }
+// -------------------------------
+func CrearOfertaHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var req OfertaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.MatchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	if msg := req.validar(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	m, err := CargarMatch(tx, req.MatchID, userID, rol, true)
+	if err == nil {
+		switch {
+		case !m.Abierto():
+			err = ErrMatchCerrado
+		case m.Acordado:
+			err = ErrAcordado
+		// El estudiante solo puede abrir la negociación si el trabajo la admite
+		case !m.Negociable && rol == "estudiante":
+			err = ErrNoNegociable
+		}
+	}
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+
+	var pendiente bool
+	if err := tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM ofertas_pago WHERE match_id = $1 AND estado = 'pendiente')
+	`, m.ID).Scan(&pendiente); err != nil {
+		responderError(c, err)
+		return
+	}
+	if pendiente {
+		responderError(c, ErrPendiente)
+		return
+	}
+
+	o, err := crearOferta(tx, m, rol, req, nil)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		responderError(c, err)
+		return
+	}
+
+	notificar(db, m, rol, "oferta_pago", "Nueva oferta de pago",
+		"Te propusieron "+textoMonto(o.Monto, o.Unidad)+" para \""+m.Titulo+"\".", o.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"mensaje": "Oferta enviada",
+		"oferta":  o,
+	})
+}
+
+// -------------------------------
+// POST /protected/matches/ofertas/responder
+// { "oferta_id": 3, "accion": "aceptar" | "rechazar" | "contraofertar", "monto": 30, "unidad": "turno" }
+// monto y unidad solo hacen falta al contraofertar
+// -------------------------------
+func ResponderOfertaHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var req struct {
+		OfertaID int    `json:"oferta_id"`
+		Accion   string `json:"accion"`
+		OfertaRequest
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.OfertaID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	if req.Accion != "aceptar" && req.Accion != "rechazar" && req.Accion != "contraofertar" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "accion debe ser aceptar, rechazar o contraofertar"})
+		return
+	}
+	if req.Accion == "contraofertar" {
+		if msg := req.OfertaRequest.validar(); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	var matchID int
+	var autorRol, unidad string
+	var monto float64
+	var vigente bool
+	err = tx.QueryRow(`
+		SELECT match_id, autor_rol, monto, unidad, expira_en > NOW()
+		FROM ofertas_pago
+		WHERE id = $1 AND estado = 'pendiente'
+	`, req.OfertaID).Scan(&matchID, &autorRol, &monto, &unidad, &vigente)
+	if err == sql.ErrNoRows {
+		responderError(c, ErrNoEncontrada)
+		return
+	}
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+
+	// Bloquear el match serializa respuestas simultáneas sobre la misma oferta
+	m, err := CargarMatch(tx, matchID, userID, rol, true)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	// Solo responde la otra parte
+	if autorRol == rol {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No puedes responder tu propia oferta"})
+		return
+	}
+	if !vigente {
+		c.JSON(http.StatusConflict, gin.H{"error": "La oferta ya expiró"})
+		return
+	}
+	if !m.Abierto() {
+		responderError(c, ErrMatchCerrado)
+		return
+	}
+
+	nuevoEstado := map[string]string{
+		"aceptar":       "aceptada",
+		"rechazar":      "rechazada",
+		"contraofertar": "contraofertada",
+	}[req.Accion]
+	res, err := tx.Exec(`
+		UPDATE ofertas_pago SET estado = $1, respondida_en = NOW()
+		WHERE id = $2 AND estado = 'pendiente'
+	`, nuevoEstado, req.OfertaID)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		responderError(c, ErrNoEncontrada)
+		return
+	}
+
+	resp := gin.H{"oferta_id": req.OfertaID, "estado": nuevoEstado}
+	var contra Oferta
+	switch req.Accion {
+	case "aceptar":
+		// El acuerdo queda congelado en el match
+		if _, err := tx.Exec(`
+			UPDATE matches_job
+			SET pago_acordado = $1, unidad_pago = $2, pago_acordado_en = NOW(), oferta_aceptada_id = $3
+			WHERE id = $4
+		`, monto, unidad, req.OfertaID, m.ID); err != nil {
+			responderError(c, err)
+			return
+		}
+		resp["pago_acordado"] = monto
+		resp["unidad_pago"] = unidad
+	case "contraofertar":
+		req.OfertaRequest.MatchID = m.ID
+		contra, err = crearOferta(tx, m, rol, req.OfertaRequest, &req.OfertaID)
+		if err != nil {
+			responderError(c, err)
+			return
+		}
+		resp["contraoferta"] = contra
+	}
+
+	if err := tx.Commit(); err != nil {
+		responderError(c, err)
+		return
+	}
+
+	switch req.Accion {
+	case "aceptar":
+		notificar(db, m, rol, "oferta_pago_aceptada", "Oferta de pago aceptada",
+			"Acordaron "+textoMonto(monto, unidad)+" para \""+m.Titulo+"\".", req.OfertaID)
+	case "rechazar":
+		notificar(db, m, rol, "oferta_pago_rechazada", "Oferta de pago rechazada",
+			"Rechazaron tu oferta de "+textoMonto(monto, unidad)+" para \""+m.Titulo+"\".", req.OfertaID)
+	case "contraofertar":
+		notificar(db, m, rol, "oferta_pago", "Te hicieron una contraoferta",
+			"Te propusieron "+textoMonto(contra.Monto, contra.Unidad)+" para \""+m.Titulo+"\".", contra.ID)
+	}
+
+	resp["mensaje"] = "Respuesta registrada"
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,215 @@
+package ofertas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+)
+
+// Negociación del pago de un match. Cada parte propone un monto y una
+// unidad; la otra acepta, rechaza o contraoferta. Solo hay una oferta
+// pendiente por match y vence a las HorasValidezDefault si nadie responde.
+// Al aceptarse, el monto queda congelado en matches_job.pago_acordado y
+// es el que se usa al completar y pagar; sin acuerdo rige el pago
+// publicado del trabajo.
+
+const (
+	HorasValidezDefault = 48
+	HorasValidezMax     = 7 * 24
+	MaxMonto            = 10000
+	MaxLargoMensaje     = 500
+)
+
+// Unidades de pago aceptadas: las mismas de la compensación del trabajo
+var Unidades = compensacion.Unidades
+
+var (
+	ErrNoEncontrada = errors.New("oferta no encontrada")
+	ErrPendiente    = errors.New("ya hay una oferta pendiente en este match")
+	ErrAcordado     = errors.New("el pago de este match ya fue acordado")
+	ErrNoNegociable = errors.New("el pago de este trabajo no es negociable")
+	ErrMatchCerrado = errors.New("el match ya no admite ofertas")
+	ErrPermiso      = errors.New("no tienes permiso sobre este match")
+)
+
+type Oferta struct {
+	ID           int     `json:"id"`
+	MatchID      int     `json:"match_id"`
+	AutorRol     string  `json:"autor_rol"`
+	Monto        float64 `json:"monto"`
+	Unidad       string  `json:"unidad"`
+	Mensaje      string  `json:"mensaje"`
+	Estado       string  `json:"estado"` // pendiente | aceptada | rechazada | contraofertada | expirada
+	RespuestaA   *int    `json:"respuesta_a"`
+	ExpiraEn     string  `json:"expira_en"`
+	CreadoEn     string  `json:"creado_en"`
+	RespondidaEn *string `json:"respondida_en"`
+}
+
+// Match son los datos del match que importan para negociar
+type Match struct {
+	ID           int
+	JobID        int
+	Titulo       string
+	EstudianteID int
+	EmpleadorID  int
+	Estado       string
+	Negociable   bool
+	Acordado     bool
+}
+
+// ValidarUnidad normaliza la unidad ("día" → "dia")
+func ValidarUnidad(u string) (string, bool) {
+	return compensacion.ValidarUnidad(u)
+}
+
+// CargarMatch lee el match y comprueba que el usuario sea parte según su rol
+func CargarMatch(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, matchID, userID int, rol string, bloquear bool) (*Match, error) {
+	query := `
+		SELECT m.id, m.job_id, j.titulo, m.estudiante_id, j.empleador_id,
+		       COALESCE(m.estado, ''), j.negociable, m.pago_acordado IS NOT NULL
+		FROM matches_job m
+		JOIN jobs j ON j.id = m.job_id
+		WHERE m.id = $1 AND m.is_match = true
+	`
+	if bloquear {
+		query += " FOR UPDATE OF m"
+	}
+	var m Match
+	err := q.QueryRow(query, matchID).Scan(&m.ID, &m.JobID, &m.Titulo, &m.EstudianteID,
+		&m.EmpleadorID, &m.Estado, &m.Negociable, &m.Acordado)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoEncontrada
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Los IDs de estudiantes y empleadores viven en tablas distintas,
+	// así que el rol del token decide contra cuál se compara
+	switch rol {
+	case notificaciones.RolEstudiante:
+		if userID != m.EstudianteID {
+			return nil, ErrPermiso
+		}
+	case notificaciones.RolEmpleador:
+		if userID != m.EmpleadorID {
+			return nil, ErrPermiso
+		}
+	default:
+		return nil, ErrPermiso
+	}
+	return &m, nil
+}
+
+// Abierto indica si el match todavía se puede negociar
+func (m *Match) Abierto() bool {
+	return m.Estado != "completado" && m.Estado != "cancelado" && m.Estado != "en_disputa"
+}
+
+// Contraparte devuelve el ID y rol de la otra parte del match
+func (m *Match) Contraparte(rol string) (int, string) {
+	if rol == notificaciones.RolEstudiante {
+		return m.EmpleadorID, notificaciones.RolEmpleador
+	}
+	return m.EstudianteID, notificaciones.RolEstudiante
+}
+
+func textoMonto(monto float64, unidad string) string {
+	return fmt.Sprintf("$%.2f por %s", monto, strings.Replace(unidad, "dia", "día", 1))
+}
+
+// notificar avisa a la contraparte de un movimiento en la negociación
+func notificar(db *sql.DB, m *Match, rol, tipo, titulo, mensaje string, ofertaID int) {
+	destino, rolDestino := m.Contraparte(rol)
+	err := notificaciones.Notificar(db, notificaciones.Notificacion{
+		UsuarioID: destino,
+		Rol:       rolDestino,
+		Tipo:      tipo,
+		Titulo:    titulo,
+		Mensaje:   mensaje,
+		Datos: map[string]interface{}{
+			"match_id":  m.ID,
+			"job_id":    m.JobID,
+			"oferta_id": ofertaID,
+		},
+	})
+	if err != nil {
+		log.Println("Error notificando oferta de pago:", err)
+	}
+}
+
+// ------------------------------------------------------
+// TAREA: expirar ofertas sin respuesta
+// También cierra las pendientes de matches que se cancelaron o completaron.
+// ------------------------------------------------------
+func ExpirarOfertas(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `
+		UPDATE ofertas_pago o
+		SET estado = 'expirada', respondida_en = NOW()
+		FROM matches_job m
+		JOIN jobs j ON j.id = m.job_id
+		WHERE o.match_id = m.id
+		  AND o.estado = 'pendiente'
+		  AND (o.expira_en <= NOW() OR m.estado IN ('cancelado', 'completado'))
+		RETURNING o.id, o.autor_rol, m.estudiante_id, j.empleador_id, m.job_id, j.titulo,
+		          o.monto, o.unidad, m.estado IS DISTINCT FROM 'cancelado' AND m.estado IS DISTINCT FROM 'completado'
+	`)
+	if err != nil {
+		return err
+	}
+
+	type expirada struct {
+		id, estudianteID, empleadorID, jobID int
+		autorRol, titulo, unidad             string
+		monto                                float64
+		porTiempo                            bool
+	}
+	var expiradas []expirada
+	for rows.Next() {
+		var e expirada
+		if err := rows.Scan(&e.id, &e.autorRol, &e.estudianteID, &e.empleadorID, &e.jobID, &e.titulo,
+			&e.monto, &e.unidad, &e.porTiempo); err != nil {
+			rows.Close()
+			return err
+		}
+		expiradas = append(expiradas, e)
+	}
+	rows.Close()
+
+	// Solo se avisa al autor cuando venció el plazo; si el match se cerró ya lo sabe
+	for _, e := range expiradas {
+		if !e.porTiempo {
+			continue
+		}
+		destino := e.empleadorID
+		if e.autorRol == notificaciones.RolEstudiante {
+			destino = e.estudianteID
+		}
+		err := notificaciones.Notificar(db, notificaciones.Notificacion{
+			UsuarioID: destino,
+			Rol:       e.autorRol,
+			Tipo:      "oferta_pago_expirada",
+			Titulo:    "Tu oferta de pago expiró",
+			Mensaje: fmt.Sprintf("Tu oferta de %s para \"%s\" venció sin respuesta. Puedes enviar una nueva.",
+				textoMonto(e.monto, e.unidad), e.titulo),
+			Datos: map[string]interface{}{"job_id": e.jobID, "oferta_id": e.id},
+		})
+		if err != nil {
+			log.Println("Error notificando oferta expirada:", err)
+		}
+	}
+
+	if len(expiradas) > 0 {
+		log.Printf("Ofertas de pago expiradas: %d", len(expiradas))
+	}
+	return nil
+}
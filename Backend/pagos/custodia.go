@@ -0,0 +1,616 @@
+package pagos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/completar"
+	"github.com/VinkoRobi2/FlashWorkEC/eventos"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/VinkoRobi2/FlashWorkEC/pasarela"
+)
+
+// Custodia de pagos dentro de la app. Con una pasarela configurada, al
+// crearse el match se autoriza el pago al empleador (fondeo); al
+// completarse se captura lo que corresponde (pago_final, como máximo lo
+// fondeado) y se transfiere al estudiante, y al cancelarse se devuelve.
+// Las llamadas a la pasarela corren fuera del request; lo que falle lo
+// reintenta la tarea Procesar. Cada custodia tiene su fila en custodias.
+
+// Estados de una custodia
+const (
+	CustodiaNueva         = "nueva"       // sin cargo todavía
+	CustodiaPorFondear    = "por_fondear" // cargo pendiente de pago del empleador
+	CustodiaFondeada      = "fondeada"
+	CustodiaFallida       = "fallida" // la pasarela rechazó el cargo
+	CustodiaPorLiberar    = "por_liberar"
+	CustodiaLiberada      = "liberada"
+	CustodiaPorReembolsar = "por_reembolsar"
+	CustodiaReembolsada   = "reembolsada"
+	CustodiaAnulada       = "anulada" // cancelado sin fondos que devolver
+)
+
+// TiempoLlamada limita cada llamada a la pasarela
+const TiempoLlamada = 30 * time.Second
+
+var ErrCustodiaInactiva = errors.New("los pagos dentro de la app no están activos")
+
+type Custodia struct {
+	db        *sql.DB
+	proveedor pasarela.PaymentProvider
+}
+
+// EstadoCustodia es lo que se muestra de la custodia de un match
+type EstadoCustodia struct {
+	MatchID       int      `json:"match_id"`
+	Proveedor     string   `json:"proveedor"`
+	Estado        string   `json:"estado"`
+	Fondeado      *float64 `json:"fondeado"`
+	Capturado     *float64 `json:"capturado"`
+	Reembolsado   *float64 `json:"reembolsado"`
+	URLPago       string   `json:"url_pago,omitempty"`
+	Error         string   `json:"error,omitempty"`
+	ActualizadoEn string   `json:"actualizado_en"`
+}
+
+// NuevaCustodia arma la custodia sobre la pasarela; con proveedor nil
+// queda inactiva y todos sus métodos no hacen nada
+func NuevaCustodia(db *sql.DB, proveedor pasarela.PaymentProvider) *Custodia {
+	c := &Custodia{db: db, proveedor: proveedor}
+	if e, ok := proveedor.(pasarela.Emisor); ok {
+		e.AlEmitir(func(h http.Header, body []byte) {
+			if err := c.ProcesarWebhook(h, body); err != nil {
+				log.Println("Error procesando webhook simulado:", err)
+			}
+		})
+	}
+	return c
+}
+
+func (c *Custodia) Activa() bool {
+	return c != nil && c.proveedor != nil
+}
+
+func llamada() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), TiempoLlamada)
+}
+
+// ------------------------------------------------------
+// Suscriptores del bus de eventos
+// ------------------------------------------------------
+
+// AlCrearMatch abre la custodia del match y pide el fondeo
+func (c *Custodia) AlCrearMatch(datos interface{}) {
+	ev, ok := datos.(eventos.MatchCreado)
+	if !ok || !c.Activa() {
+		return
+	}
+	if err := c.abrir(ev.MatchID); err != nil {
+		log.Printf("Error abriendo custodia del match %d: %v", ev.MatchID, err)
+		return
+	}
+	go func() { c.registrarError(ev.MatchID, c.fondear(ev.MatchID)) }()
+}
+
+// AlCompletarMatch libera los fondos al estudiante
+func (c *Custodia) AlCompletarMatch(datos interface{}) {
+	ev, ok := datos.(eventos.MatchCompletado)
+	if !ok || !c.Activa() {
+		return
+	}
+	go func() { c.registrarError(ev.MatchID, c.liberar(ev.MatchID)) }()
+}
+
+// AlCancelarMatch devuelve los fondos al empleador
+func (c *Custodia) AlCancelarMatch(datos interface{}) {
+	ev, ok := datos.(eventos.MatchCancelado)
+	if !ok || !c.Activa() {
+		return
+	}
+	go func() { c.registrarError(ev.MatchID, c.reembolsar(ev.MatchID)) }()
+}
+
+func (c *Custodia) registrarError(matchID int, err error) {
+	if err != nil {
+		log.Printf("Error en la custodia del match %d: %v", matchID, err)
+	}
+}
+
+// abrir crea la fila de la custodia si el match no tenía una
+func (c *Custodia) abrir(matchID int) error {
+	_, err := c.db.Exec(`
+		INSERT INTO custodias (match_id, proveedor, referencia, estado)
+		VALUES ($1, $2, $3, 'nueva')
+		ON CONFLICT (match_id) DO NOTHING
+	`, matchID, c.proveedor.Nombre(), fmt.Sprintf("match-%d", matchID))
+	return err
+}
+
+// datosCustodia son los datos de la custodia y del match bajo bloqueo
+type datosCustodia struct {
+	id, matchID, jobID         int
+	estudianteID, empleadorID  int
+	estado, referencia, titulo string
+	cargoID, transferenciaID   sql.NullString
+	fondeado, capturado        sql.NullFloat64
+	estadoMatch                string
+	montoFondeo, pagoFinal     sql.NullFloat64
+}
+
+func cargarCustodia(tx *sql.Tx, filtro string, arg interface{}) (*datosCustodia, error) {
+	var d datosCustodia
+	err := tx.QueryRow(`
+		SELECT cu.id, cu.match_id, mj.job_id, mj.estudiante_id, j.empleador_id,
+		       cu.estado, cu.referencia, j.titulo, cu.cargo_id, cu.transferencia_id,
+		       CAST(cu.monto_fondeado AS FLOAT), CAST(cu.monto_capturado AS FLOAT),
+		       COALESCE(mj.estado, ''), CAST(`+compensacion.MontoFondeoSQL+` AS FLOAT), CAST(mj.pago_final AS FLOAT)
+		FROM custodias cu
+		JOIN matches_job mj ON mj.id = cu.match_id
+		JOIN jobs j ON j.id = mj.job_id
+		WHERE `+filtro+`
+		FOR UPDATE OF cu
+	`, arg).Scan(&d.id, &d.matchID, &d.jobID, &d.estudianteID, &d.empleadorID,
+		&d.estado, &d.referencia, &d.titulo, &d.cargoID, &d.transferenciaID,
+		&d.fondeado, &d.capturado, &d.estadoMatch, &d.montoFondeo, &d.pagoFinal)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (d *datosCustodia) actualizar(tx *sql.Tx, sets string, args ...interface{}) error {
+	_, err := tx.Exec(`UPDATE custodias SET `+sets+`, actualizado_en = NOW() WHERE id = $1`,
+		append([]interface{}{d.id}, args...)...)
+	return err
+}
+
+func (c *Custodia) avisar(d *datosCustodia, rol, tipo, titulo, mensaje string, extra map[string]interface{}) {
+	destino := d.estudianteID
+	if rol == notificaciones.RolEmpleador {
+		destino = d.empleadorID
+	}
+	datos := map[string]interface{}{"match_id": d.matchID, "job_id": d.jobID}
+	for k, v := range extra {
+		datos[k] = v
+	}
+	err := notificaciones.Notificar(c.db, notificaciones.Notificacion{
+		UsuarioID: destino, Rol: rol, Tipo: tipo, Titulo: titulo, Mensaje: mensaje, Datos: datos,
+	})
+	if err != nil {
+		log.Println("Error notificando custodia:", err)
+	}
+}
+
+// fondear crea el cargo de un match con custodia nueva o fallida
+func (c *Custodia) fondear(matchID int) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	d, err := cargarCustodia(tx, "cu.match_id = $1", matchID)
+	if err != nil || d == nil {
+		return err
+	}
+	if d.estado != CustodiaNueva && d.estado != CustodiaFallida {
+		return nil
+	}
+	if d.estadoMatch == "cancelado" {
+		if err := d.actualizar(tx, "estado = 'anulada'"); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	if !d.montoFondeo.Valid || d.montoFondeo.Float64 <= 0 {
+		if err := d.actualizar(tx, "error = $2", "el trabajo no tiene pago definido"); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	ctx, cancel := llamada()
+	defer cancel()
+	cargo, err := c.proveedor.CrearCargo(ctx, pasarela.SolicitudCargo{
+		Referencia:  d.referencia,
+		Monto:       d.montoFondeo.Float64,
+		Descripcion: d.titulo,
+	})
+	switch {
+	case errors.Is(err, pasarela.ErrRechazado):
+		err = d.actualizar(tx, "estado = 'fallida', cargo_id = $2, error = $3", cargo.ID, err.Error())
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err == nil {
+			c.avisar(d, notificaciones.RolEmpleador, "custodia_fallida", "No se pudo retener el pago",
+				fmt.Sprintf("La pasarela rechazó el pago de \"%s\". Reintenta desde el match.", d.titulo), nil)
+		}
+		return err
+	case err != nil:
+		// Error de red o de la pasarela: se queda como estaba y lo reintenta la tarea
+		if e := d.actualizar(tx, "error = $2", err.Error()); e != nil {
+			return e
+		}
+		if e := tx.Commit(); e != nil {
+			return e
+		}
+		return err
+	}
+
+	estado := CustodiaFondeada
+	if cargo.Estado == pasarela.CargoPendiente {
+		estado = CustodiaPorFondear
+	}
+	err = d.actualizar(tx, "estado = $2, cargo_id = $3, monto_fondeado = $4, url_pago = $5, error = ''",
+		estado, cargo.ID, cargo.Monto, cargo.URLPago)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if estado == CustodiaPorFondear {
+		c.avisar(d, notificaciones.RolEmpleador, "custodia_por_fondear", "Completa el pago",
+			fmt.Sprintf("Completa el pago de \"%s\"; queda retenido hasta que el trabajo se complete.", d.titulo),
+			map[string]interface{}{"url_pago": cargo.URLPago})
+	}
+	return nil
+}
+
+// liberar captura y transfiere al estudiante lo de un match completado
+func (c *Custodia) liberar(matchID int) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	d, err := cargarCustodia(tx, "cu.match_id = $1", matchID)
+	if err != nil || d == nil {
+		return err
+	}
+	if d.estadoMatch != "completado" || (d.estado != CustodiaFondeada && d.estado != CustodiaPorLiberar) {
+		return nil
+	}
+
+	ctx, cancel := llamada()
+	defer cancel()
+
+	// Se captura lo que corresponde pagar, como máximo lo fondeado; un
+	// pago final de cero (disputa) devuelve todo al empleador
+	if !d.capturado.Valid {
+		monto := math.Min(d.pagoFinal.Float64, d.fondeado.Float64)
+		if !d.pagoFinal.Valid {
+			monto = d.fondeado.Float64
+		}
+		if monto <= 0 {
+			if _, err := c.proveedor.Reembolsar(ctx, d.cargoID.String, d.fondeado.Float64); err != nil {
+				return c.guardarError(tx, d, err)
+			}
+			if err := d.actualizar(tx, "estado = 'reembolsada', monto_reembolsado = monto_fondeado, error = ''"); err != nil {
+				return err
+			}
+			return tx.Commit()
+		}
+		cargo, err := c.proveedor.Capturar(ctx, d.cargoID.String, monto)
+		if err != nil {
+			return c.guardarError(tx, d, err)
+		}
+		if err := d.actualizar(tx, "estado = 'por_liberar', monto_capturado = $2", cargo.Capturado); err != nil {
+			return err
+		}
+		d.capturado = sql.NullFloat64{Float64: cargo.Capturado, Valid: true}
+	}
+
+	t, err := c.proveedor.Transferir(ctx, pasarela.SolicitudTransferencia{
+		Referencia:   d.referencia + "-pago",
+		Monto:        d.capturado.Float64,
+		EstudianteID: d.estudianteID,
+		Descripcion:  d.titulo,
+	})
+	if err != nil {
+		return c.guardarError(tx, d, err)
+	}
+	if t.Estado == pasarela.TransferenciaPendiente {
+		if err := d.actualizar(tx, "transferencia_id = $2, error = ''", t.ID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	d.transferenciaID = sql.NullString{String: t.ID, Valid: true}
+	return c.cerrarLiberacion(tx, d)
+}
+
+// PorLiberar indica si el match tiene una custodia que todavía va a
+// pagar al estudiante. Mientras tanto no se aceptan pagos manuales, que
+// se sumarían a lo liberado; una vez liberada, lo que falte (p. ej. horas
+// por encima de las estimadas) sí se paga a mano.
+func PorLiberar(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, matchID int) (bool, error) {
+	var existe bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM custodias
+			WHERE match_id = $1 AND estado IN ('nueva', 'por_fondear', 'fondeada', 'por_liberar')
+		)
+	`, matchID).Scan(&existe)
+	return existe, err
+}
+
+// cerrarLiberacion marca la custodia como liberada y asienta el pago en
+// el libro como confirmado: el dinero pasó por la pasarela
+func (c *Custodia) cerrarLiberacion(tx *sql.Tx, d *datosCustodia) error {
+	err := d.actualizar(tx, "estado = 'liberada', transferencia_id = $2, liberado_en = NOW(), error = ''", d.transferenciaID.String)
+	if err != nil {
+		return err
+	}
+	monto := d.capturado.Float64
+	var pagoID int
+	err = tx.QueryRow(`
+		INSERT INTO pagos (match_id, empleador_id, monto, metodo, referencia, nota, estado, respondido_en)
+		VALUES ($1, $2, $3, $4, $5, 'Liberado desde la custodia', 'confirmado', NOW())
+		RETURNING id
+	`, d.matchID, d.empleadorID, monto, "pasarela:"+c.proveedor.Nombre(), d.transferenciaID.String).Scan(&pagoID)
+	if err != nil {
+		return err
+	}
+	detalle := fmt.Sprintf("pago #%d por %s liberado desde la custodia", pagoID, textoMonto(monto))
+	if err := completar.RegistrarHistorial(tx, d.matchID, completar.EventoPagoConfirmado, "completado", "completado", "sistema", detalle); err != nil {
+		return err
+	}
+	s, err := CargarSaldo(tx, d.matchID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	avisarSiPagado(s)
+	c.avisar(d, notificaciones.RolEstudiante, "pago_liberado", "Pago recibido",
+		fmt.Sprintf("Se liberaron %s por \"%s\".", textoMonto(monto), d.titulo), map[string]interface{}{"pago_id": pagoID})
+	return nil
+}
+
+// reembolsar devuelve al empleador los fondos de un match cancelado
+func (c *Custodia) reembolsar(matchID int) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	d, err := cargarCustodia(tx, "cu.match_id = $1", matchID)
+	if err != nil || d == nil {
+		return err
+	}
+	if d.estadoMatch != "cancelado" {
+		return nil
+	}
+	switch d.estado {
+	case CustodiaNueva, CustodiaFallida:
+		if err := d.actualizar(tx, "estado = 'anulada'"); err != nil {
+			return err
+		}
+		return tx.Commit()
+	case CustodiaPorFondear, CustodiaFondeada, CustodiaPorReembolsar:
+	default:
+		return nil
+	}
+
+	ctx, cancel := llamada()
+	defer cancel()
+	if _, err := c.proveedor.Reembolsar(ctx, d.cargoID.String, d.fondeado.Float64); err != nil {
+		if e := d.actualizar(tx, "estado = 'por_reembolsar'"); e != nil {
+			return e
+		}
+		return c.guardarError(tx, d, err)
+	}
+	if err := d.actualizar(tx, "estado = 'reembolsada', monto_reembolsado = monto_fondeado, url_pago = '', error = ''"); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if d.estado != CustodiaPorFondear {
+		c.avisar(d, notificaciones.RolEmpleador, "custodia_reembolsada", "Pago devuelto",
+			fmt.Sprintf("Se devolvieron %s retenidos por \"%s\".", textoMonto(d.fondeado.Float64), d.titulo), nil)
+	}
+	return nil
+}
+
+// guardarError deja el error en la custodia para que la tarea reintente
+func (c *Custodia) guardarError(tx *sql.Tx, d *datosCustodia, err error) error {
+	if e := d.actualizar(tx, "error = $2", err.Error()); e != nil {
+		return e
+	}
+	if e := tx.Commit(); e != nil {
+		return e
+	}
+	return err
+}
+
+// ProcesarWebhook verifica y aplica un evento de la pasarela. Un evento
+// repetido se ignora.
+func (c *Custodia) ProcesarWebhook(h http.Header, body []byte) error {
+	if !c.Activa() {
+		return ErrCustodiaInactiva
+	}
+	ev, err := c.proveedor.VerificarWebhook(h, body)
+	if err != nil {
+		return err
+	}
+	// El evento se da por aplicado en la misma transacción que lo aplica:
+	// si algo falla, la pasarela lo reenvía y se vuelve a intentar
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`
+		INSERT INTO custodias_webhooks (evento_id, proveedor, tipo, operacion_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`, ev.ID, c.proveedor.Nombre(), ev.Tipo, ev.OperacionID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	switch ev.Tipo {
+	case pasarela.EventoCargoAutorizado:
+		var matchID int
+		err := tx.QueryRow(`
+			UPDATE custodias SET estado = 'fondeada', url_pago = '', error = '', actualizado_en = NOW()
+			WHERE cargo_id = $1 AND estado = 'por_fondear'
+			RETURNING match_id
+		`, ev.OperacionID).Scan(&matchID)
+		if err == sql.ErrNoRows {
+			return tx.Commit()
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		// Si el match terminó mientras el pago estaba pendiente, se sigue;
+		// si esto falla, la tarea lo reintenta
+		if err := c.liberar(matchID); err != nil {
+			return err
+		}
+		return c.reembolsar(matchID)
+	case pasarela.EventoCargoFallido:
+		_, err := tx.Exec(`
+			UPDATE custodias SET estado = 'fallida', error = 'la pasarela rechazó el cargo', actualizado_en = NOW()
+			WHERE cargo_id = $1 AND estado = 'por_fondear'
+		`, ev.OperacionID)
+		if err != nil {
+			return err
+		}
+	case pasarela.EventoTransferenciaCompleta:
+		d, err := cargarCustodia(tx, "cu.transferencia_id = $1", ev.OperacionID)
+		if err != nil {
+			return err
+		}
+		if d != nil && d.estado == CustodiaPorLiberar {
+			return c.cerrarLiberacion(tx, d)
+		}
+	case pasarela.EventoTransferenciaFallida:
+		// Sin transferencia, la tarea vuelve a intentarla
+		_, err := tx.Exec(`
+			UPDATE custodias SET transferencia_id = NULL, error = 'la transferencia falló', actualizado_en = NOW()
+			WHERE transferencia_id = $1 AND estado = 'por_liberar'
+		`, ev.OperacionID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ------------------------------------------------------
+// TAREA: reintentar las custodias que quedaron a medias
+// ------------------------------------------------------
+func (c *Custodia) Procesar(ctx context.Context, db *sql.DB) error {
+	if !c.Activa() {
+		return nil
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT cu.match_id,
+		       CASE
+		           WHEN mj.estado = 'cancelado' THEN 'reembolsar'
+		           WHEN mj.estado = 'completado' AND cu.estado IN ('fondeada', 'por_liberar') THEN 'liberar'
+		           ELSE 'fondear'
+		       END
+		FROM custodias cu
+		JOIN matches_job mj ON mj.id = cu.match_id
+		WHERE (mj.estado = 'cancelado' AND cu.estado IN ('nueva', 'fallida', 'por_fondear', 'fondeada', 'por_reembolsar'))
+		   OR (mj.estado = 'completado' AND (cu.estado = 'fondeada'
+		       OR (cu.estado = 'por_liberar' AND cu.transferencia_id IS NULL)))
+		   OR (cu.estado = 'nueva' AND mj.estado IS DISTINCT FROM 'cancelado')
+		ORDER BY cu.actualizado_en
+		LIMIT 100
+	`)
+	if err != nil {
+		return err
+	}
+	type pendiente struct {
+		matchID int
+		accion  string
+	}
+	var lista []pendiente
+	for rows.Next() {
+		var p pendiente
+		if err := rows.Scan(&p.matchID, &p.accion); err != nil {
+			rows.Close()
+			return err
+		}
+		lista = append(lista, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range lista {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		switch p.accion {
+		case "reembolsar":
+			err = c.reembolsar(p.matchID)
+		case "liberar":
+			err = c.liberar(p.matchID)
+		default:
+			err = c.fondear(p.matchID)
+		}
+		c.registrarError(p.matchID, err)
+	}
+	return nil
+}
+
+// Estado lee la custodia de un match; nil si no tiene
+func (c *Custodia) Estado(matchID int) (*EstadoCustodia, error) {
+	var e EstadoCustodia
+	var fondeado, capturado, reembolsado sql.NullFloat64
+	var actualizadoEn time.Time
+	err := c.db.QueryRow(`
+		SELECT match_id, proveedor, estado, CAST(monto_fondeado AS FLOAT), CAST(monto_capturado AS FLOAT),
+		       CAST(monto_reembolsado AS FLOAT), url_pago, error, actualizado_en
+		FROM custodias
+		WHERE match_id = $1
+	`, matchID).Scan(&e.MatchID, &e.Proveedor, &e.Estado, &fondeado, &capturado, &reembolsado,
+		&e.URLPago, &e.Error, &actualizadoEn)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range []struct {
+		src sql.NullFloat64
+		dst **float64
+	}{{fondeado, &e.Fondeado}, {capturado, &e.Capturado}, {reembolsado, &e.Reembolsado}} {
+		if v.src.Valid {
+			f := v.src.Float64
+			*v.dst = &f
+		}
+	}
+	e.ActualizadoEn = actualizadoEn.Format(time.RFC3339)
+	return &e, nil
+}
@@ -0,0 +1,20 @@
+package pagos
+
+import "github.com/VinkoRobi2/FlashWorkEC/eventos"
+
+// publicador recibe los matches que quedan pagados; sin configurar, no
+// se publica nada
+var publicador eventos.Publicador
+
+// UsarEventos conecta el paquete al bus de eventos de dominio
+func UsarEventos(p eventos.Publicador) {
+	publicador = p
+}
+
+// avisarSiPagado publica que el match quedó pagado por completo. Se llama
+// después de confirmar la transacción.
+func avisarSiPagado(s *Saldo) {
+	if publicador != nil && s.EstadoPago == "pagado" {
+		publicador.Publicar(eventos.TipoMatchPagado, eventos.MatchPagado{MatchID: s.MatchID, JobID: s.JobID})
+	}
+}
@@ -0,0 +1,507 @@
+package pagos
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/completar"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/VinkoRobi2/FlashWorkEC/ofertas"
+	"github.com/VinkoRobi2/FlashWorkEC/pasarela"
+	"github.com/gin-gonic/gin"
+)
+
+func usuario(c *gin.Context) (int, string, bool) {
+	userIDInterface, ok := c.Get("userID")
+	if !ok {
+		return 0, "", false
+	}
+	return userIDInterface.(int), c.GetString("roles"), true
+}
+
+// responderError traduce los errores del paquete a respuestas HTTP
+func responderError(c *gin.Context, err error) {
+	switch err {
+	case ErrNoEncontrado, ofertas.ErrNoEncontrada:
+		c.JSON(http.StatusNotFound, gin.H{"error": "no encontrado"})
+	case ofertas.ErrPermiso:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case ErrNoCompletado, ErrSinSaldo, ErrExcedeSaldo, ErrYaRespondido, ErrEnCustodia:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case ErrMontoInvalido:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error en el pago", "err": err.Error()})
+	}
+}
+
+// esParte indica si el usuario puede ver la cuenta del match
+func (s *Saldo) esParte(userID int, rol string) bool {
+	switch rol {
+	case notificaciones.RolEstudiante:
+		return userID == s.EstudianteID
+	case notificaciones.RolEmpleador:
+		return userID == s.EmpleadorID
+	case "admin":
+		return true
+	}
+	return false
+}
+
+// -------------------------------
+// GET /protected/pagos?match_id=1
+// Cuenta de un match: lo adeudado, lo pagado y cada evento de pago
+// -------------------------------
+func VerPagosHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	matchID, err := strconv.Atoi(c.Query("match_id"))
+	if err != nil || matchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id inválido"})
+		return
+	}
+
+	s, err := CargarSaldo(db, matchID)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	if !s.esParte(userID, rol) {
+		responderError(c, ofertas.ErrPermiso)
+		return
+	}
+	lista, err := Pagos(db, matchID)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"saldo": s, "pagos": lista})
+}
+
+// -------------------------------
+// POST /protected/pagos
+// { "match_id": 1, "monto": 25, "metodo": "transferencia", "referencia": "...", "nota": "..." }
+// El empleador marca un pago; sin monto se toma todo el saldo disponible
+// y sin método el publicado en el trabajo.
+// -------------------------------
+func MarcarPagadoHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok || rol != notificaciones.RolEmpleador {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo empleadores"})
+		return
+	}
+
+	var req struct {
+		MatchID    int      `json:"match_id"`
+		Monto      *float64 `json:"monto"`
+		Metodo     string   `json:"metodo"`
+		Referencia string   `json:"referencia"`
+		Nota       string   `json:"nota"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.MatchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id es requerido"})
+		return
+	}
+	req.Metodo = strings.TrimSpace(req.Metodo)
+	req.Referencia = strings.TrimSpace(req.Referencia)
+	req.Nota = strings.TrimSpace(req.Nota)
+	switch {
+	case len(req.Metodo) > MaxLargoMetodo:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("metodo supera %d caracteres", MaxLargoMetodo)})
+		return
+	case len(req.Referencia) > MaxLargoReferencia:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("referencia supera %d caracteres", MaxLargoReferencia)})
+		return
+	case len(req.Nota) > MaxLargoNota:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("nota supera %d caracteres", MaxLargoNota)})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Bloquear el match serializa pagos simultáneos sobre el mismo saldo
+	m, err := ofertas.CargarMatch(tx, req.MatchID, userID, rol, true)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	if m.Estado != "completado" {
+		responderError(c, ErrNoCompletado)
+		return
+	}
+	enCustodia, err := PorLiberar(tx, m.ID)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	if enCustodia {
+		responderError(c, ErrEnCustodia)
+		return
+	}
+	s, err := CargarSaldo(tx, m.ID)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+
+	disponible := s.Disponible()
+	monto := disponible
+	if req.Monto != nil {
+		monto = redondear(*req.Monto)
+		if monto <= 0 {
+			responderError(c, ErrMontoInvalido)
+			return
+		}
+	}
+	if disponible <= 0 {
+		responderError(c, ErrSinSaldo)
+		return
+	}
+	if monto > disponible {
+		responderError(c, ErrExcedeSaldo)
+		return
+	}
+	if req.Metodo == "" {
+		req.Metodo = s.MetodoPago
+	}
+
+	p := Pago{MatchID: m.ID, Monto: monto, Metodo: req.Metodo, Referencia: req.Referencia, Nota: req.Nota, Estado: EstadoMarcado}
+	var marcadoEn time.Time
+	err = tx.QueryRow(`
+		INSERT INTO pagos (match_id, monto, metodo, referencia, nota, empleador_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, marcado_en
+	`, m.ID, monto, p.Metodo, p.Referencia, p.Nota, userID).Scan(&p.ID, &marcadoEn)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	p.MarcadoEn = marcadoEn.Format(time.RFC3339)
+	detalle := fmt.Sprintf("pago #%d por %s (%s)", p.ID, textoMonto(monto), p.Metodo)
+	if err := completar.RegistrarHistorial(tx, m.ID, completar.EventoPagoMarcado, m.Estado, m.Estado, rol, detalle); err != nil {
+		responderError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		responderError(c, err)
+		return
+	}
+
+	notificar(db, s, notificaciones.RolEstudiante, "pago_marcado", "Pago registrado",
+		fmt.Sprintf("El empleador marcó un pago de %s por \"%s\". Confirma si lo recibiste.", textoMonto(monto), s.Titulo), p.ID)
+
+	s.PorConfirmar = redondear(s.PorConfirmar + monto)
+	s.calcular()
+	c.JSON(http.StatusCreated, gin.H{"mensaje": "Pago registrado, pendiente de confirmación", "pago": p, "saldo": s})
+}
+
+// -------------------------------
+// POST /protected/pagos/:id/responder
+// { "accion": "confirmar" | "rechazar", "motivo": "..." }
+// El estudiante confirma que recibió el pago o lo rechaza
+// -------------------------------
+func ResponderPagoHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok || rol != notificaciones.RolEstudiante {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo estudiantes"})
+		return
+	}
+	pagoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || pagoID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		Accion string `json:"accion"`
+		Motivo string `json:"motivo"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+	req.Motivo = strings.TrimSpace(req.Motivo)
+	switch req.Accion {
+	case "confirmar":
+	case "rechazar":
+		if req.Motivo == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "indica por qué rechazas el pago"})
+			return
+		}
+		if len(req.Motivo) > MaxLargoNota {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("motivo supera %d caracteres", MaxLargoNota)})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "accion debe ser confirmar o rechazar"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback()
+
+	var matchID int
+	var estado string
+	var monto float64
+	err = tx.QueryRow(`
+		SELECT match_id, estado, CAST(monto AS FLOAT) FROM pagos WHERE id = $1
+	`, pagoID).Scan(&matchID, &estado, &monto)
+	if err == sql.ErrNoRows {
+		responderError(c, ErrNoEncontrado)
+		return
+	}
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	m, err := ofertas.CargarMatch(tx, matchID, userID, rol, true)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+
+	nuevoEstado, evento := EstadoConfirmado, completar.EventoPagoConfirmado
+	if req.Accion == "rechazar" {
+		nuevoEstado, evento = EstadoRechazado, completar.EventoPagoRechazado
+	}
+	res, err := tx.Exec(`
+		UPDATE pagos
+		SET estado = $2, respondido_en = NOW(), motivo_rechazo = $3
+		WHERE id = $1 AND estado = 'marcado'
+	`, pagoID, nuevoEstado, req.Motivo)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 || estado != EstadoMarcado {
+		responderError(c, ErrYaRespondido)
+		return
+	}
+	detalle := fmt.Sprintf("pago #%d por %s", pagoID, textoMonto(monto))
+	if req.Motivo != "" {
+		detalle += ": " + req.Motivo
+	}
+	if err := completar.RegistrarHistorial(tx, m.ID, evento, m.Estado, m.Estado, rol, detalle); err != nil {
+		responderError(c, err)
+		return
+	}
+	s, err := CargarSaldo(tx, m.ID)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		responderError(c, err)
+		return
+	}
+
+	if nuevoEstado == EstadoConfirmado {
+		avisarSiPagado(s)
+		notificar(db, s, notificaciones.RolEmpleador, "pago_confirmado", "Pago confirmado",
+			fmt.Sprintf("El estudiante confirmó que recibió %s por \"%s\".", textoMonto(monto), s.Titulo), pagoID)
+	} else {
+		notificar(db, s, notificaciones.RolEmpleador, "pago_rechazado", "Pago no recibido",
+			fmt.Sprintf("El estudiante indica que no recibió el pago de %s por \"%s\": %s", textoMonto(monto), s.Titulo, req.Motivo), pagoID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Pago " + nuevoEstado, "saldo": s})
+}
+
+// -------------------------------
+// GET /protected/pagos/pendientes
+// Estudiante: lo que le falta cobrar. Empleador: lo que le falta pagar.
+// -------------------------------
+func PendientesHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok || (rol != notificaciones.RolEstudiante && rol != notificaciones.RolEmpleador) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo estudiantes o empleadores"})
+		return
+	}
+	saldos, err := SaldosDe(db, userID, rol)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+
+	pendientes := []Saldo{}
+	var total float64
+	for _, s := range saldos {
+		if s.Pendiente > 0 {
+			pendientes = append(pendientes, s)
+			total += s.Pendiente
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"pendientes": pendientes, "total_pendiente": redondear(total)})
+}
+
+// -------------------------------
+// GET /protected/pagos/balance
+// Totales del usuario sobre sus matches completados
+// -------------------------------
+func BalanceHandler(c *gin.Context, db *sql.DB) {
+	userID, rol, ok := usuario(c)
+	if !ok || (rol != notificaciones.RolEstudiante && rol != notificaciones.RolEmpleador) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo estudiantes o empleadores"})
+		return
+	}
+	saldos, err := SaldosDe(db, userID, rol)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+
+	var adeudado, pagado, porConfirmar, pendiente float64
+	matchesPendientes := 0
+	for _, s := range saldos {
+		adeudado += s.Adeudado
+		pagado += s.Pagado
+		porConfirmar += s.PorConfirmar
+		if s.Pendiente > 0 {
+			pendiente += s.Pendiente
+			matchesPendientes++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"rol":                rol,
+		"matches":            len(saldos),
+		"matches_pendientes": matchesPendientes,
+		"adeudado":           redondear(adeudado),
+		"pagado":             redondear(pagado),
+		"por_confirmar":      redondear(porConfirmar),
+		"pendiente":          redondear(pendiente),
+	})
+}
+
+// -------------------------------
+// GET /protected/pagos/custodia?match_id=1
+// Estado de los fondos retenidos del match
+// -------------------------------
+func (cu *Custodia) VerCustodiaHandler(c *gin.Context) {
+	userID, rol, ok := usuario(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	if !cu.Activa() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrCustodiaInactiva.Error()})
+		return
+	}
+	matchID, err := strconv.Atoi(c.Query("match_id"))
+	if err != nil || matchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id inválido"})
+		return
+	}
+
+	s, err := CargarSaldo(cu.db, matchID)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	if !s.esParte(userID, rol) {
+		responderError(c, ofertas.ErrPermiso)
+		return
+	}
+	e, err := cu.Estado(matchID)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	if e == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "el match no tiene pago en custodia"})
+		return
+	}
+	// El enlace de pago es solo para quien paga
+	if rol != notificaciones.RolEmpleador {
+		e.URLPago = ""
+	}
+	c.JSON(http.StatusOK, gin.H{"custodia": e})
+}
+
+// -------------------------------
+// POST /protected/pagos/custodia/fondear { "match_id": 1 }
+// El empleador reintenta retener el pago (cargo rechazado o match
+// anterior a la custodia)
+// -------------------------------
+func (cu *Custodia) FondearHandler(c *gin.Context) {
+	userID, rol, ok := usuario(c)
+	if !ok || rol != notificaciones.RolEmpleador {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo empleadores"})
+		return
+	}
+	if !cu.Activa() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrCustodiaInactiva.Error()})
+		return
+	}
+	var req struct {
+		MatchID int `json:"match_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.MatchID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match_id es requerido"})
+		return
+	}
+
+	m, err := ofertas.CargarMatch(cu.db, req.MatchID, userID, rol, false)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	if m.Estado == "cancelado" || m.Estado == "completado" {
+		c.JSON(http.StatusConflict, gin.H{"error": "el match ya está " + m.Estado})
+		return
+	}
+	if err := cu.abrir(m.ID); err != nil {
+		responderError(c, err)
+		return
+	}
+	// El error de la pasarela queda guardado en la custodia
+	if err := cu.fondear(m.ID); err != nil {
+		cu.registrarError(m.ID, err)
+	}
+
+	e, err := cu.Estado(m.ID)
+	if err != nil {
+		responderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"custodia": e})
+}
+
+// -------------------------------
+// POST /webhooks/pagos (pública, firmada por la pasarela)
+// -------------------------------
+func (cu *Custodia) WebhookHandler(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cuerpo inválido"})
+		return
+	}
+	err = cu.ProcesarWebhook(c.Request.Header, body)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	case err == ErrCustodiaInactiva:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case err == pasarela.ErrFirmaInvalida:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		// Un 500 hace que la pasarela reintente
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error procesando webhook", "err": err.Error()})
+	}
+}
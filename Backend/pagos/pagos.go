@@ -0,0 +1,242 @@
+package pagos
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/Jobs/compensacion"
+	"github.com/VinkoRobi2/FlashWorkEC/notificaciones"
+	"github.com/VinkoRobi2/FlashWorkEC/ofertas"
+)
+
+// Libro de pagos de los matches. Lo que se debe sale de matches_job: el
+// pago_final fijado al completar (acordado o publicado, por las horas en
+// trabajos por hora). El pago ocurre fuera de la app; aquí se registra
+// cada evento: el empleador lo marca como pagado con método y referencia
+// y el estudiante confirma que lo recibió o lo rechaza. Solo los pagos
+// confirmados descuentan del saldo; los rechazados quedan como constancia.
+
+const (
+	EstadoMarcado    = "marcado"
+	EstadoConfirmado = "confirmado"
+	EstadoRechazado  = "rechazado"
+
+	MaxLargoMetodo     = 50
+	MaxLargoReferencia = 100
+	MaxLargoNota       = 500
+)
+
+var (
+	ErrNoEncontrado  = errors.New("pago no encontrado")
+	ErrNoCompletado  = errors.New("solo se registran pagos de matches completados")
+	ErrSinSaldo      = errors.New("el match no tiene saldo pendiente de pago")
+	ErrExcedeSaldo   = errors.New("el monto supera el saldo pendiente del match")
+	ErrYaRespondido  = errors.New("el pago ya fue confirmado o rechazado")
+	ErrMontoInvalido = errors.New("el monto debe ser mayor que cero")
+	ErrEnCustodia    = errors.New("el pago de este match se libera desde la custodia")
+)
+
+// AdeudadoSQL es lo que el empleador debe por el match (alias mj y j).
+// Solo los matches completados generan deuda.
+const AdeudadoSQL = `CASE WHEN mj.estado = 'completado'
+		THEN COALESCE(mj.pago_final, ` + compensacion.PagoFinalSQL + `, 0)
+		ELSE 0
+	END`
+
+// saldosSQL arma el saldo de cada match; se completa con el WHERE
+const saldosSQL = `
+	SELECT mj.id, mj.job_id, j.titulo, COALESCE(j.categoria, ''), COALESCE(j.metodo_pago, ''),
+	       mj.estudiante_id, e.nombre || ' ' || e.apellido,
+	       j.empleador_id, em.nombre || ' ' || em.apellido,
+	       COALESCE(mj.estado, ''), mj.completado_en,
+	       CAST(` + AdeudadoSQL + ` AS FLOAT),
+	       CAST(COALESCE((SELECT SUM(p.monto) FROM pagos p
+	                      WHERE p.match_id = mj.id AND p.estado = 'confirmado'), 0) AS FLOAT),
+	       CAST(COALESCE((SELECT SUM(p.monto) FROM pagos p
+	                      WHERE p.match_id = mj.id AND p.estado = 'marcado'), 0) AS FLOAT)
+	FROM matches_job mj
+	JOIN jobs j ON j.id = mj.job_id
+	JOIN estudiantes e ON e.id = mj.estudiante_id
+	JOIN empleadores em ON em.id = j.empleador_id
+`
+
+// Saldo es la cuenta de un match
+type Saldo struct {
+	MatchID      int     `json:"match_id"`
+	JobID        int     `json:"job_id"`
+	Titulo       string  `json:"titulo"`
+	Categoria    string  `json:"categoria"`
+	MetodoPago   string  `json:"metodo_pago"` // el publicado en el trabajo
+	EstudianteID int     `json:"estudiante_id"`
+	Estudiante   string  `json:"estudiante"`
+	EmpleadorID  int     `json:"empleador_id"`
+	Empleador    string  `json:"empleador"`
+	EstadoMatch  string  `json:"estado_match"`
+	CompletadoEn *string `json:"completado_en"`
+	Adeudado     float64 `json:"adeudado"`
+	Pagado       float64 `json:"pagado"`        // confirmado por el estudiante
+	PorConfirmar float64 `json:"por_confirmar"` // marcado por el empleador, sin confirmar
+	Pendiente    float64 `json:"pendiente"`     // adeudado - pagado
+	EstadoPago   string  `json:"estado_pago"`   // sin_cargo | pendiente | por_confirmar | parcial | pagado
+}
+
+type Pago struct {
+	ID            int     `json:"id"`
+	MatchID       int     `json:"match_id"`
+	Monto         float64 `json:"monto"`
+	Metodo        string  `json:"metodo"`
+	Referencia    string  `json:"referencia"`
+	Nota          string  `json:"nota"`
+	Estado        string  `json:"estado"`
+	MarcadoEn     string  `json:"marcado_en"`
+	RespondidoEn  *string `json:"respondido_en"`
+	MotivoRechazo string  `json:"motivo_rechazo"`
+}
+
+// redondear deja el monto al centavo
+func redondear(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func (s *Saldo) calcular() {
+	s.Pendiente = redondear(s.Adeudado - s.Pagado)
+	switch {
+	case s.Adeudado <= 0:
+		s.EstadoPago = "sin_cargo"
+	case s.Pendiente <= 0:
+		s.EstadoPago = "pagado"
+	case s.PorConfirmar > 0:
+		s.EstadoPago = "por_confirmar"
+	case s.Pagado > 0:
+		s.EstadoPago = "parcial"
+	default:
+		s.EstadoPago = "pendiente"
+	}
+}
+
+// Disponible es lo que todavía se puede marcar como pagado
+func (s *Saldo) Disponible() float64 {
+	return redondear(s.Adeudado - s.Pagado - s.PorConfirmar)
+}
+
+func escanearSaldo(scan func(dest ...interface{}) error) (Saldo, error) {
+	var s Saldo
+	var completadoEn sql.NullTime
+	err := scan(&s.MatchID, &s.JobID, &s.Titulo, &s.Categoria, &s.MetodoPago,
+		&s.EstudianteID, &s.Estudiante, &s.EmpleadorID, &s.Empleador,
+		&s.EstadoMatch, &completadoEn, &s.Adeudado, &s.Pagado, &s.PorConfirmar)
+	if err != nil {
+		return s, err
+	}
+	if completadoEn.Valid {
+		t := completadoEn.Time.Format(time.RFC3339)
+		s.CompletadoEn = &t
+	}
+	s.calcular()
+	return s, nil
+}
+
+// CargarSaldo lee la cuenta de un match
+func CargarSaldo(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, matchID int) (*Saldo, error) {
+	s, err := escanearSaldo(q.QueryRow(saldosSQL+` WHERE mj.id = $1 AND mj.is_match = true`, matchID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ofertas.ErrNoEncontrada
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaldosDe devuelve las cuentas de los matches completados del usuario,
+// del más reciente al más antiguo
+func SaldosDe(db *sql.DB, userID int, rol string) ([]Saldo, error) {
+	filtro := ` WHERE mj.estudiante_id = $1`
+	if rol == notificaciones.RolEmpleador {
+		filtro = ` WHERE j.empleador_id = $1`
+	}
+	rows, err := db.Query(saldosSQL+filtro+`
+		AND mj.is_match = true AND mj.estado = 'completado'
+		ORDER BY mj.completado_en DESC NULLS LAST, mj.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	saldos := []Saldo{}
+	for rows.Next() {
+		s, err := escanearSaldo(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		saldos = append(saldos, s)
+	}
+	return saldos, rows.Err()
+}
+
+// Pagos lista los eventos de pago de un match en orden
+func Pagos(db *sql.DB, matchID int) ([]Pago, error) {
+	rows, err := db.Query(`
+		SELECT id, match_id, CAST(monto AS FLOAT), metodo, referencia, nota, estado,
+		       marcado_en, respondido_en, motivo_rechazo
+		FROM pagos
+		WHERE match_id = $1
+		ORDER BY id
+	`, matchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lista := []Pago{}
+	for rows.Next() {
+		var p Pago
+		var marcadoEn time.Time
+		var respondidoEn sql.NullTime
+		if err := rows.Scan(&p.ID, &p.MatchID, &p.Monto, &p.Metodo, &p.Referencia, &p.Nota, &p.Estado,
+			&marcadoEn, &respondidoEn, &p.MotivoRechazo); err != nil {
+			return nil, err
+		}
+		p.MarcadoEn = marcadoEn.Format(time.RFC3339)
+		if respondidoEn.Valid {
+			t := respondidoEn.Time.Format(time.RFC3339)
+			p.RespondidoEn = &t
+		}
+		lista = append(lista, p)
+	}
+	return lista, rows.Err()
+}
+
+// notificar avisa a la otra parte de un movimiento de pago
+func notificar(db *sql.DB, s *Saldo, rolDestino, tipo, titulo, mensaje string, pagoID int) {
+	destino := s.EstudianteID
+	if rolDestino == notificaciones.RolEmpleador {
+		destino = s.EmpleadorID
+	}
+	err := notificaciones.Notificar(db, notificaciones.Notificacion{
+		UsuarioID: destino,
+		Rol:       rolDestino,
+		Tipo:      tipo,
+		Titulo:    titulo,
+		Mensaje:   mensaje,
+		Datos: map[string]interface{}{
+			"match_id": s.MatchID,
+			"job_id":   s.JobID,
+			"pago_id":  pagoID,
+		},
+	})
+	if err != nil {
+		log.Println("Error notificando pago:", err)
+	}
+}
+
+func textoMonto(monto float64) string {
+	return fmt.Sprintf("$%.2f", monto)
+}
@@ -0,0 +1,290 @@
+package pasarela
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VinkoRobi2/FlashWorkEC/secretos"
+)
+
+// Modos de la pasarela falsa
+const (
+	FakeExito     = "exito"
+	FakeFallo     = "fallo"
+	FakePendiente = "pendiente" // se confirma por webhook tras Demora
+)
+
+// CabeceraFirma lleva el HMAC-SHA256 (hex) del cuerpo del webhook
+const CabeceraFirma = "X-Pasarela-Firma"
+
+// Fake simula una pasarela en memoria. Modo fija el resultado por
+// defecto; los centavos del monto lo fuerzan por operación, para probar
+// los tres caminos sin reiniciar: .51 falla y .52 queda pendiente.
+// Latencia se espera en cada llamada. Los datos se pierden al reiniciar.
+type Fake struct {
+	Modo     string
+	Latencia time.Duration
+	Demora   time.Duration
+	Secreto  []byte
+
+	mu             sync.Mutex
+	secuencia      int
+	cargos         map[string]*Cargo
+	porReferencia  map[string]string
+	transferencias map[string]*Transferencia
+	emitir         func(h http.Header, body []byte)
+}
+
+func NuevoFake(modo string, secreto []byte) *Fake {
+	return &Fake{
+		Modo:           modo,
+		Demora:         10 * time.Second,
+		Secreto:        secreto,
+		cargos:         map[string]*Cargo{},
+		porReferencia:  map[string]string{},
+		transferencias: map[string]*Transferencia{},
+	}
+}
+
+// SecretoWebhook es la clave con la que se firman los webhooks:
+// PASARELA_WEBHOOK_SECRET o, si no existe, JWT_SECRET
+func SecretoWebhook() ([]byte, error) {
+	return secretos.Clave("PASARELA_WEBHOOK_SECRET")
+}
+
+// FakeDesdeEntorno lee PASARELA_FAKE_MODO, PASARELA_FAKE_LATENCIA y
+// PASARELA_FAKE_DEMORA (duraciones de Go: "500ms", "30s"). Sin secreto
+// no arranca: cualquiera podría firmar webhooks.
+func FakeDesdeEntorno() (*Fake, error) {
+	secreto, err := SecretoWebhook()
+	if err != nil {
+		return nil, err
+	}
+	modo := strings.ToLower(os.Getenv("PASARELA_FAKE_MODO"))
+	if modo != FakeFallo && modo != FakePendiente {
+		modo = FakeExito
+	}
+	f := NuevoFake(modo, secreto)
+	if d, err := time.ParseDuration(os.Getenv("PASARELA_FAKE_LATENCIA")); err == nil && d >= 0 {
+		f.Latencia = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("PASARELA_FAKE_DEMORA")); err == nil && d > 0 {
+		f.Demora = d
+	}
+	return f, nil
+}
+
+func (f *Fake) Nombre() string { return "fake" }
+
+// AlEmitir registra a quién se entregan los webhooks simulados
+func (f *Fake) AlEmitir(fn func(h http.Header, body []byte)) {
+	f.mu.Lock()
+	f.emitir = fn
+	f.mu.Unlock()
+}
+
+// modoPara aplica el modo forzado por los centavos del monto
+func (f *Fake) modoPara(monto float64) string {
+	switch int(math.Round(monto*100)) % 100 {
+	case 51:
+		return FakeFallo
+	case 52:
+		return FakePendiente
+	}
+	return f.Modo
+}
+
+func (f *Fake) esperar(ctx context.Context) error {
+	if f.Latencia <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-time.After(f.Latencia):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// nuevoID se llama con f.mu tomado
+func (f *Fake) nuevoID(prefijo string) string {
+	f.secuencia++
+	return fmt.Sprintf("%s_fake_%d_%d", prefijo, time.Now().Unix(), f.secuencia)
+}
+
+func (f *Fake) CrearCargo(ctx context.Context, s SolicitudCargo) (Cargo, error) {
+	if err := f.esperar(ctx); err != nil {
+		return Cargo{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Misma referencia, mismo cargo: los reintentos no cobran dos veces
+	if id, ok := f.porReferencia[s.Referencia]; ok && f.cargos[id].Estado != CargoFallido {
+		return *f.cargos[id], nil
+	}
+
+	c := &Cargo{ID: f.nuevoID("cargo"), Monto: s.Monto, Referencia: s.Referencia}
+	f.cargos[c.ID] = c
+	f.porReferencia[s.Referencia] = c.ID
+
+	switch f.modoPara(s.Monto) {
+	case FakeFallo:
+		c.Estado = CargoFallido
+		return *c, ErrRechazado
+	case FakePendiente:
+		c.Estado = CargoPendiente
+		c.URLPago = "https://pasarela.fake/pagar/" + c.ID
+		f.diferir(func() (string, bool) {
+			if c.Estado != CargoPendiente {
+				return "", false
+			}
+			c.Estado = CargoAutorizado
+			return EventoCargoAutorizado, true
+		}, c.ID, c.Referencia)
+	default:
+		c.Estado = CargoAutorizado
+	}
+	return *c, nil
+}
+
+func (f *Fake) Capturar(ctx context.Context, cargoID string, monto float64) (Cargo, error) {
+	if err := f.esperar(ctx); err != nil {
+		return Cargo{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.cargos[cargoID]
+	if !ok {
+		return Cargo{}, ErrNoEncontrado
+	}
+	if c.Estado == CargoCapturado {
+		return *c, nil
+	}
+	if c.Estado != CargoAutorizado || monto <= 0 || monto > c.Monto {
+		return *c, ErrEstadoInvalido
+	}
+	c.Estado, c.Capturado = CargoCapturado, monto
+	return *c, nil
+}
+
+func (f *Fake) Reembolsar(ctx context.Context, cargoID string, monto float64) (Cargo, error) {
+	if err := f.esperar(ctx); err != nil {
+		return Cargo{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.cargos[cargoID]
+	if !ok {
+		return Cargo{}, ErrNoEncontrado
+	}
+	switch c.Estado {
+	case CargoReembolsado, CargoFallido:
+		return *c, nil
+	case CargoPendiente, CargoAutorizado:
+		// Sin capturar: se anula la autorización
+		c.Estado = CargoReembolsado
+	case CargoCapturado:
+		if monto <= 0 || monto > c.Capturado {
+			return *c, ErrEstadoInvalido
+		}
+		c.Capturado -= monto
+		if c.Capturado == 0 {
+			c.Estado = CargoReembolsado
+		}
+	}
+	return *c, nil
+}
+
+func (f *Fake) Transferir(ctx context.Context, s SolicitudTransferencia) (Transferencia, error) {
+	if err := f.esperar(ctx); err != nil {
+		return Transferencia{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, ok := f.transferencias[s.Referencia]; ok && t.Estado != TransferenciaFallida {
+		return *t, nil
+	}
+	t := &Transferencia{ID: f.nuevoID("transf")}
+	f.transferencias[s.Referencia] = t
+
+	switch f.modoPara(s.Monto) {
+	case FakeFallo:
+		t.Estado = TransferenciaFallida
+		return *t, ErrRechazado
+	case FakePendiente:
+		t.Estado = TransferenciaPendiente
+		f.diferir(func() (string, bool) {
+			if t.Estado != TransferenciaPendiente {
+				return "", false
+			}
+			t.Estado = TransferenciaCompletada
+			return EventoTransferenciaCompleta, true
+		}, t.ID, s.Referencia)
+	default:
+		t.Estado = TransferenciaCompletada
+	}
+	return *t, nil
+}
+
+// diferir aplica el cambio tras Demora y emite el webhook correspondiente
+func (f *Fake) diferir(cambio func() (string, bool), operacionID, referencia string) {
+	time.AfterFunc(f.Demora, func() {
+		f.mu.Lock()
+		tipo, ok := cambio()
+		emitir := f.emitir
+		var id string
+		if ok {
+			id = f.nuevoID("evt")
+		}
+		f.mu.Unlock()
+		if !ok || emitir == nil {
+			return
+		}
+
+		body, err := json.Marshal(EventoWebhook{ID: id, Tipo: tipo, OperacionID: operacionID, Referencia: referencia})
+		if err != nil {
+			log.Println("Error armando webhook simulado:", err)
+			return
+		}
+		h := http.Header{}
+		h.Set("Content-Type", "application/json")
+		h.Set(CabeceraFirma, f.firmar(body))
+		emitir(h, body)
+	})
+}
+
+func (f *Fake) firmar(body []byte) string {
+	mac := hmac.New(sha256.New, f.Secreto)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *Fake) VerificarWebhook(h http.Header, body []byte) (*EventoWebhook, error) {
+	firma := h.Get(CabeceraFirma)
+	if firma == "" || len(f.Secreto) == 0 || !hmac.Equal([]byte(firma), []byte(f.firmar(body))) {
+		return nil, ErrFirmaInvalida
+	}
+	var ev EventoWebhook
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return nil, err
+	}
+	if ev.ID == "" || ev.Tipo == "" || ev.OperacionID == "" {
+		return nil, fmt.Errorf("webhook incompleto")
+	}
+	return &ev, nil
+}
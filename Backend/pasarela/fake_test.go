@@ -0,0 +1,247 @@
+package pasarela
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func nuevoFakePrueba(modo string) *Fake {
+	f := NuevoFake(modo, []byte("clave-de-prueba"))
+	f.Demora = 10 * time.Millisecond
+	return f
+}
+
+type webhook struct {
+	h    http.Header
+	body []byte
+}
+
+// esperarWebhook devuelve el webhook emitido por el Fake o falla tras un segundo
+func esperarWebhook(t *testing.T, ch <-chan webhook) webhook {
+	t.Helper()
+	select {
+	case w := <-ch:
+		return w
+	case <-time.After(time.Second):
+		t.Fatal("no se emitió el webhook")
+		return webhook{}
+	}
+}
+
+func TestCargoExitoso(t *testing.T) {
+	f := nuevoFakePrueba(FakeExito)
+	ctx := context.Background()
+
+	c, err := f.CrearCargo(ctx, SolicitudCargo{Referencia: "match-1", Monto: 20})
+	if err != nil {
+		t.Fatalf("CrearCargo: %v", err)
+	}
+	if c.Estado != CargoAutorizado {
+		t.Fatalf("estado = %q, se esperaba %q", c.Estado, CargoAutorizado)
+	}
+
+	c, err = f.Capturar(ctx, c.ID, 15)
+	if err != nil {
+		t.Fatalf("Capturar: %v", err)
+	}
+	if c.Estado != CargoCapturado || c.Capturado != 15 {
+		t.Fatalf("cargo = %+v, se esperaba capturado por 15", c)
+	}
+
+	tr, err := f.Transferir(ctx, SolicitudTransferencia{Referencia: "match-1-pago", Monto: 15})
+	if err != nil {
+		t.Fatalf("Transferir: %v", err)
+	}
+	if tr.Estado != TransferenciaCompletada {
+		t.Fatalf("transferencia = %q, se esperaba %q", tr.Estado, TransferenciaCompletada)
+	}
+}
+
+func TestCentavosFuerzanFallo(t *testing.T) {
+	f := nuevoFakePrueba(FakeExito)
+	ctx := context.Background()
+
+	c, err := f.CrearCargo(ctx, SolicitudCargo{Referencia: "match-1", Monto: 20.51})
+	if !errors.Is(err, ErrRechazado) {
+		t.Fatalf("err = %v, se esperaba ErrRechazado", err)
+	}
+	if c.Estado != CargoFallido {
+		t.Fatalf("estado = %q, se esperaba %q", c.Estado, CargoFallido)
+	}
+
+	tr, err := f.Transferir(ctx, SolicitudTransferencia{Referencia: "match-2-pago", Monto: 10.51})
+	if !errors.Is(err, ErrRechazado) {
+		t.Fatalf("err = %v, se esperaba ErrRechazado", err)
+	}
+	if tr.Estado != TransferenciaFallida {
+		t.Fatalf("estado = %q, se esperaba %q", tr.Estado, TransferenciaFallida)
+	}
+}
+
+func TestCargoPendienteSeConfirmaPorWebhook(t *testing.T) {
+	f := nuevoFakePrueba(FakeExito)
+	ch := make(chan webhook, 1)
+	f.AlEmitir(func(h http.Header, body []byte) { ch <- webhook{h, body} })
+
+	c, err := f.CrearCargo(context.Background(), SolicitudCargo{Referencia: "match-1", Monto: 20.52})
+	if err != nil {
+		t.Fatalf("CrearCargo: %v", err)
+	}
+	if c.Estado != CargoPendiente || c.URLPago == "" {
+		t.Fatalf("cargo = %+v, se esperaba pendiente con URL de pago", c)
+	}
+
+	w := esperarWebhook(t, ch)
+	ev, err := f.VerificarWebhook(w.h, w.body)
+	if err != nil {
+		t.Fatalf("VerificarWebhook: %v", err)
+	}
+	if ev.Tipo != EventoCargoAutorizado || ev.OperacionID != c.ID || ev.Referencia != "match-1" {
+		t.Fatalf("evento = %+v, se esperaba %s del cargo %s", ev, EventoCargoAutorizado, c.ID)
+	}
+
+	// Ya autorizado, se puede capturar
+	if _, err := f.Capturar(context.Background(), c.ID, 20.52); err != nil {
+		t.Fatalf("Capturar: %v", err)
+	}
+}
+
+func TestTransferenciaPendienteSeConfirmaPorWebhook(t *testing.T) {
+	f := nuevoFakePrueba(FakeExito)
+	ch := make(chan webhook, 1)
+	f.AlEmitir(func(h http.Header, body []byte) { ch <- webhook{h, body} })
+
+	tr, err := f.Transferir(context.Background(), SolicitudTransferencia{Referencia: "match-1-pago", Monto: 8.52})
+	if err != nil {
+		t.Fatalf("Transferir: %v", err)
+	}
+	if tr.Estado != TransferenciaPendiente {
+		t.Fatalf("estado = %q, se esperaba %q", tr.Estado, TransferenciaPendiente)
+	}
+
+	w := esperarWebhook(t, ch)
+	ev, err := f.VerificarWebhook(w.h, w.body)
+	if err != nil {
+		t.Fatalf("VerificarWebhook: %v", err)
+	}
+	if ev.Tipo != EventoTransferenciaCompleta || ev.OperacionID != tr.ID {
+		t.Fatalf("evento = %+v, se esperaba %s de %s", ev, EventoTransferenciaCompleta, tr.ID)
+	}
+}
+
+func TestVerificarWebhookFirma(t *testing.T) {
+	f := nuevoFakePrueba(FakeExito)
+	body := []byte(`{"id":"evt_1","tipo":"cargo.autorizado","operacion_id":"cargo_1","referencia":"match-1"}`)
+	firmado := func(firma string) http.Header {
+		h := http.Header{}
+		if firma != "" {
+			h.Set(CabeceraFirma, firma)
+		}
+		return h
+	}
+
+	if _, err := f.VerificarWebhook(firmado(f.firmar(body)), body); err != nil {
+		t.Fatalf("firma correcta rechazada: %v", err)
+	}
+
+	otro := NuevoFake(FakeExito, []byte("otra-clave"))
+	casos := []struct {
+		nombre string
+		h      http.Header
+		body   []byte
+	}{
+		{"sin firma", firmado(""), body},
+		{"firma de otra clave", firmado(otro.firmar(body)), body},
+		{"cuerpo alterado", firmado(f.firmar(body)), bytes.Replace(body, []byte("cargo_1"), []byte("cargo_2"), 1)},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if _, err := f.VerificarWebhook(c.h, c.body); !errors.Is(err, ErrFirmaInvalida) {
+				t.Errorf("err = %v, se esperaba ErrFirmaInvalida", err)
+			}
+		})
+	}
+
+	// Sin secreto ninguna firma vale, ni siquiera la calculada sin clave
+	sinClave := NuevoFake(FakeExito, nil)
+	if _, err := sinClave.VerificarWebhook(firmado(sinClave.firmar(body)), body); !errors.Is(err, ErrFirmaInvalida) {
+		t.Errorf("err = %v, se esperaba ErrFirmaInvalida sin secreto", err)
+	}
+}
+
+func TestCrearCargoIdempotentePorReferencia(t *testing.T) {
+	f := nuevoFakePrueba(FakeExito)
+	ctx := context.Background()
+
+	primero, err := f.CrearCargo(ctx, SolicitudCargo{Referencia: "match-1", Monto: 20})
+	if err != nil {
+		t.Fatalf("CrearCargo: %v", err)
+	}
+	repetido, err := f.CrearCargo(ctx, SolicitudCargo{Referencia: "match-1", Monto: 20})
+	if err != nil {
+		t.Fatalf("CrearCargo repetido: %v", err)
+	}
+	if repetido.ID != primero.ID {
+		t.Errorf("el reintento creó otro cargo: %s y %s", primero.ID, repetido.ID)
+	}
+
+	otro, err := f.CrearCargo(ctx, SolicitudCargo{Referencia: "match-2", Monto: 20})
+	if err != nil {
+		t.Fatalf("CrearCargo: %v", err)
+	}
+	if otro.ID == primero.ID {
+		t.Error("referencias distintas comparten cargo")
+	}
+
+	// Un cargo rechazado sí se puede reintentar con la misma referencia
+	fallido, _ := f.CrearCargo(ctx, SolicitudCargo{Referencia: "match-3", Monto: 20.51})
+	reintento, err := f.CrearCargo(ctx, SolicitudCargo{Referencia: "match-3", Monto: 20})
+	if err != nil {
+		t.Fatalf("reintento tras rechazo: %v", err)
+	}
+	if reintento.ID == fallido.ID || reintento.Estado != CargoAutorizado {
+		t.Errorf("reintento = %+v, se esperaba un cargo nuevo autorizado", reintento)
+	}
+}
+
+func TestTransferirIdempotentePorReferencia(t *testing.T) {
+	f := nuevoFakePrueba(FakeExito)
+	ctx := context.Background()
+
+	primera, err := f.Transferir(ctx, SolicitudTransferencia{Referencia: "match-1-pago", Monto: 15})
+	if err != nil {
+		t.Fatalf("Transferir: %v", err)
+	}
+	repetida, err := f.Transferir(ctx, SolicitudTransferencia{Referencia: "match-1-pago", Monto: 15})
+	if err != nil {
+		t.Fatalf("Transferir repetida: %v", err)
+	}
+	if repetida.ID != primera.ID {
+		t.Errorf("el reintento transfirió dos veces: %s y %s", primera.ID, repetida.ID)
+	}
+}
+
+func TestFakeDesdeEntornoExigeSecreto(t *testing.T) {
+	t.Setenv("PASARELA_WEBHOOK_SECRET", "")
+	t.Setenv("JWT_SECRET", "")
+	if _, err := FakeDesdeEntorno(); err == nil {
+		t.Fatal("arrancó sin secreto")
+	}
+	t.Setenv("PASARELA_PAGOS", "fake")
+	if p, err := DesdeEntorno(); err == nil || p != nil {
+		t.Fatalf("DesdeEntorno = %v, %v; se esperaba un error sin pasarela", p, err)
+	}
+
+	t.Setenv("PASARELA_WEBHOOK_SECRET", "clave")
+	f, err := FakeDesdeEntorno()
+	if err != nil {
+		t.Fatalf("FakeDesdeEntorno: %v", err)
+	}
+	if string(f.Secreto) != "clave" {
+		t.Errorf("secreto = %q, se esperaba el de PASARELA_WEBHOOK_SECRET", f.Secreto)
+	}
+}
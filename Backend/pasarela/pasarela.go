@@ -0,0 +1,123 @@
+package pasarela
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Abstracción de la pasarela de pagos. El empleador fondea el match al
+// crearse (el cargo queda autorizado, en custodia), se captura al
+// completarse y se transfiere al estudiante; si se cancela, se reembolsa.
+// La pasarela real (una ecuatoriana) se implementa detrás de esta
+// interfaz; Fake permite desarrollar el flujo completo sin red.
+
+// Moneda de los cargos: Ecuador usa dólares
+const Moneda = "USD"
+
+// Estados de un cargo
+const (
+	CargoPendiente   = "pendiente"  // esperando que el pagador complete el pago
+	CargoAutorizado  = "autorizado" // fondos retenidos, sin capturar
+	CargoCapturado   = "capturado"
+	CargoReembolsado = "reembolsado"
+	CargoFallido     = "fallido"
+)
+
+// Estados de una transferencia al estudiante
+const (
+	TransferenciaPendiente  = "pendiente"
+	TransferenciaCompletada = "completada"
+	TransferenciaFallida    = "fallida"
+)
+
+// Tipos de evento que llegan por webhook
+const (
+	EventoCargoAutorizado       = "cargo.autorizado"
+	EventoCargoFallido          = "cargo.fallido"
+	EventoTransferenciaCompleta = "transferencia.completada"
+	EventoTransferenciaFallida  = "transferencia.fallida"
+)
+
+var (
+	ErrRechazado      = errors.New("la pasarela rechazó la operación")
+	ErrNoEncontrado   = errors.New("la pasarela no conoce la operación")
+	ErrFirmaInvalida  = errors.New("firma de webhook inválida")
+	ErrEstadoInvalido = errors.New("la operación no es válida en el estado actual del cargo")
+)
+
+type SolicitudCargo struct {
+	Referencia  string // única por match, para reintentos idempotentes
+	Monto       float64
+	Descripcion string
+}
+
+type Cargo struct {
+	ID         string
+	Estado     string
+	Monto      float64
+	Capturado  float64
+	URLPago    string // a dónde enviar al pagador si el cargo queda pendiente
+	Referencia string
+}
+
+type SolicitudTransferencia struct {
+	Referencia   string
+	Monto        float64
+	EstudianteID int
+	Descripcion  string
+}
+
+type Transferencia struct {
+	ID     string
+	Estado string
+}
+
+// EventoWebhook es una notificación asíncrona de la pasarela, ya verificada
+type EventoWebhook struct {
+	ID          string `json:"id"`
+	Tipo        string `json:"tipo"`
+	OperacionID string `json:"operacion_id"` // ID del cargo o de la transferencia
+	Referencia  string `json:"referencia"`
+}
+
+// PaymentProvider es lo que la app necesita de una pasarela
+type PaymentProvider interface {
+	Nombre() string
+	// CrearCargo autoriza el monto sin capturarlo
+	CrearCargo(ctx context.Context, s SolicitudCargo) (Cargo, error)
+	// Capturar cobra monto (≤ lo autorizado) y libera el resto
+	Capturar(ctx context.Context, cargoID string, monto float64) (Cargo, error)
+	// Reembolsar anula un cargo sin capturar o devuelve monto de uno capturado
+	Reembolsar(ctx context.Context, cargoID string, monto float64) (Cargo, error)
+	// Transferir paga al estudiante lo capturado
+	Transferir(ctx context.Context, s SolicitudTransferencia) (Transferencia, error)
+	// VerificarWebhook comprueba la firma y decodifica el evento
+	VerificarWebhook(h http.Header, body []byte) (*EventoWebhook, error)
+}
+
+// Emisor lo implementan las pasarelas que emiten webhooks dentro del
+// proceso (Fake): entregan el mismo cuerpo y cabeceras que llegarían por HTTP
+type Emisor interface {
+	AlEmitir(func(h http.Header, body []byte))
+}
+
+// DesdeEntorno elige la pasarela según PASARELA_PAGOS. Vacía desactiva
+// los pagos dentro de la app y devuelve nil.
+func DesdeEntorno() (PaymentProvider, error) {
+	switch nombre := strings.ToLower(strings.TrimSpace(os.Getenv("PASARELA_PAGOS"))); nombre {
+	case "":
+		return nil, nil
+	case "fake":
+		f, err := FakeDesdeEntorno()
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("pasarela de pagos desconocida: %q", nombre)
+	}
+}
@@ -0,0 +1,25 @@
+package secretos
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrSinSecreto indica que no hay clave configurada. Firmar con una clave
+// conocida permitiría falsificar tokens, códigos y webhooks, así que
+// quien la pide debe rechazar la operación.
+var ErrSinSecreto = errors.New("no hay un secreto configurado")
+
+// Clave devuelve el valor de la variable de entorno indicada o, si no está
+// definida, JWT_SECRET
+func Clave(variable string) ([]byte, error) {
+	if variable != "" {
+		if k := os.Getenv(variable); k != "" {
+			return []byte(k), nil
+		}
+	}
+	if k := os.Getenv("JWT_SECRET"); k != "" {
+		return []byte(k), nil
+	}
+	return nil, ErrSinSecreto
+}
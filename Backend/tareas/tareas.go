@@ -0,0 +1,98 @@
+package tareas
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log"
+	"time"
+)
+
+// Funcion es el trabajo periódico que ejecuta una tarea
+type Funcion func(ctx context.Context, db *sql.DB) error
+
+type tarea struct {
+	nombre    string
+	intervalo time.Duration
+	fn        Funcion
+}
+
+// Programador ejecuta tareas periódicas dentro del servidor.
+// Cada ejecución toma un advisory lock de Postgres con el nombre
+// de la tarea, así que con varias instancias del servidor solo una
+// la corre a la vez y las demás se saltan ese tick.
+type Programador struct {
+	db     *sql.DB
+	tareas []tarea
+}
+
+func New(db *sql.DB) *Programador {
+	return &Programador{db: db}
+}
+
+// Registrar agrega una tarea que se ejecutará cada intervalo
+func (p *Programador) Registrar(nombre string, intervalo time.Duration, fn Funcion) {
+	p.tareas = append(p.tareas, tarea{nombre: nombre, intervalo: intervalo, fn: fn})
+}
+
+// Iniciar lanza una goroutine por tarea; se detienen cuando ctx se cancela
+func (p *Programador) Iniciar(ctx context.Context) {
+	for _, t := range p.tareas {
+		go p.loop(ctx, t)
+	}
+}
+
+func (p *Programador) loop(ctx context.Context, t tarea) {
+	ticker := time.NewTicker(t.intervalo)
+	defer ticker.Stop()
+
+	// Primera ejecución al arrancar
+	p.ejecutar(ctx, t)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.ejecutar(ctx, t)
+		}
+	}
+}
+
+func (p *Programador) ejecutar(ctx context.Context, t tarea) {
+	// El lock es de sesión, así que tomarlo y soltarlo en la misma conexión
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		log.Printf("tarea %s: no se pudo obtener conexión: %v", t.nombre, err)
+		return
+	}
+	defer conn.Close()
+
+	key := lockKey(t.nombre)
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+		log.Printf("tarea %s: error tomando lock: %v", t.nombre, err)
+		return
+	}
+	if !ok {
+		// Otra instancia la está corriendo
+		return
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			log.Printf("tarea %s: error liberando lock: %v", t.nombre, err)
+		}
+	}()
+
+	if err := t.fn(ctx, p.db); err != nil {
+		log.Printf("tarea %s: %v", t.nombre, err)
+	}
+}
+
+// lockKey convierte el nombre de la tarea en la clave int64 del advisory lock
+func lockKey(nombre string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("cameya:" + nombre))
+	return int64(h.Sum64())
+}
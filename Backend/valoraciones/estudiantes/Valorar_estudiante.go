@@ -86,6 +86,28 @@ func CrearValoracion(db *sql.DB, c *gin.Context) {
 		return
 	}
 
+	// La asistencia registrada manda sobre lo que marque el empleador:
+	// con entrada registrada el estudiante se presentó, y sin ella en un
+	// trabajo presencial vencido queda la marca de inasistencia del match
+	var marcadoNoSePresento, asistio bool
+	err = db.QueryRow(`
+		SELECT COALESCE(BOOL_OR(mj.no_se_presento), false),
+		       EXISTS (SELECT 1 FROM asistencias a
+		               JOIN matches_job m2 ON m2.id = a.match_id
+		               WHERE m2.job_id = $1 AND m2.estudiante_id = $2)
+		FROM matches_job mj
+		WHERE mj.job_id = $1 AND mj.estudiante_id = $2 AND mj.empleador_id = $3
+	`, v.JobID, v.EstudianteValoradoID, empleadorID).Scan(&marcadoNoSePresento, &asistio)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error verificando asistencia"})
+		return
+	}
+	if asistio {
+		v.NoSePresento = false
+	} else if marcadoNoSePresento {
+		v.NoSePresento = true
+	}
+
 	var exists bool
 	checkQuery := `
 		SELECT EXISTS(